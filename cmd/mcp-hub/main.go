@@ -6,6 +6,8 @@ import (
 
 	"github.com/deep-agent/sandbox/internal/config"
 	"github.com/deep-agent/sandbox/internal/mcp"
+	"github.com/deep-agent/sandbox/internal/services/audit"
+	"github.com/deep-agent/sandbox/internal/services/bash"
 )
 
 func main() {
@@ -13,9 +15,62 @@ func main() {
 
 	log.Printf("Starting MCP Hub on port %d", cfg.MCPHubPort)
 	server := mcp.NewServer("sandbox-mcp", "1.0.0", cfg.MCPHubPort)
+	if cfg.AuditEnabled {
+		logger, err := audit.New(cfg.AuditLog, audit.Options{
+			MaxSize:    cfg.AuditMaxSize,
+			MaxBackups: cfg.AuditMaxBackups,
+			Redact:     cfg.AuditRedact,
+		})
+		if err != nil {
+			log.Fatalf("failed to open audit log, set SANDBOX_AUDIT_ENABLED=false to disable it: %v", err)
+		}
+		defer logger.Close()
+		server.Use(mcp.AuditMiddleware(logger))
+	}
 
 	registry := mcp.NewRegistry(mcp.ToolConfig{
-		CDPURL: fmt.Sprintf("ws://localhost:%d", cfg.BrowserCDPPort),
+		CDPURL:       fmt.Sprintf("ws://localhost:%d", cfg.BrowserCDPPort),
+		BashEnvAllow: cfg.BashEnvAllow,
+		BashEnvDeny:  cfg.BashEnvDeny,
+
+		BrowserMaxContexts: cfg.BrowserMaxContexts,
+		BrowserContextIdle: cfg.BrowserContextIdle,
+
+		BashDefaultTimeout: cfg.BashDefaultTimeout,
+		BashMaxTimeout:     cfg.BashMaxTimeout,
+
+		BashMaxSessions: cfg.BashMaxSessions,
+		BashSessionIdle: cfg.BashSessionIdle,
+
+		BashLimits: bash.Limits{
+			CPUTime:   cfg.BashLimitCPU,
+			Memory:    cfg.BashLimitMemory,
+			Processes: cfg.BashLimitProcesses,
+			FileSize:  cfg.BashLimitFileSize,
+			Output:    cfg.BashLimitOutput,
+			CPUQuota:  cfg.BashLimitCPUQuota,
+		},
+		BashCgroupRoot: cfg.BashCgroupRoot,
+		BashPolicyFile: cfg.BashPolicyFile,
+
+		Workspace:     cfg.Workspace,
+		SessionUsers:  cfg.SessionUsers,
+		SessionUIDMin: uint32(cfg.SessionUIDMin),
+		SessionUIDMax: uint32(cfg.SessionUIDMax),
+
+		FileConfine:       cfg.FileConfine,
+		FileReadOnlyRoots: cfg.FileReadOnlyRoots,
+
+		SnapshotDir:      cfg.SnapshotDir,
+		SnapshotMaxSize:  cfg.SnapshotMaxSize,
+		SnapshotMaxCount: cfg.SnapshotMaxCount,
+
+		FileHistory:           cfg.FileHistory,
+		FileHistoryDir:        cfg.FileHistoryDir,
+		FileHistoryMaxEntries: cfg.FileHistoryMaxEntries,
+		FileHistoryMaxSize:    cfg.FileHistoryMaxSize,
+
+		FileRequireRead: cfg.FileRequireRead,
 	})
 	registry.RegisterAll(server.AddTool)
 
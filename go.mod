@@ -8,10 +8,12 @@ require (
 	github.com/chromedp/chromedp v0.14.2
 	github.com/cloudwego/hertz v0.10.4
 	github.com/creack/pty v1.1.24
+	github.com/fsnotify/fsnotify v1.5.4
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/hertz-contrib/cors v0.1.0
 	github.com/hertz-contrib/websocket v0.2.0
 	github.com/mark3labs/mcp-go v0.43.2
+	golang.org/x/sys v0.35.0
 )
 
 require (
@@ -26,7 +28,6 @@ require (
 	github.com/cloudwego/base64x v0.1.6 // indirect
 	github.com/cloudwego/gopkg v0.1.4 // indirect
 	github.com/cloudwego/netpoll v0.7.2 // indirect
-	github.com/fsnotify/fsnotify v1.5.4 // indirect
 	github.com/go-json-experiment/json v0.0.0-20250725192818-e39067aee2d2 // indirect
 	github.com/gobwas/httphead v0.1.0 // indirect
 	github.com/gobwas/pool v0.2.1 // indirect
@@ -46,7 +47,6 @@ require (
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
 	golang.org/x/arch v0.20.0 // indirect
 	golang.org/x/net v0.25.0 // indirect
-	golang.org/x/sys v0.35.0 // indirect
 	google.golang.org/protobuf v1.36.9 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
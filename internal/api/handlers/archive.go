@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/pkg/safe"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+var archiveContentTypes = map[string]string{
+	filesystem.ArchiveTarGz: "application/gzip",
+	filesystem.ArchiveTar:   "application/x-tar",
+	filesystem.ArchiveZip:   "application/zip",
+}
+
+// Archive streams an archive of a directory as it is packed.
+func (h *FileHandler) Archive(ctx context.Context, c *app.RequestContext) {
+	var req model.FileArchiveRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+	if req.Format == "" {
+		req.Format = filesystem.ArchiveTarGz
+	}
+	contentType, ok := archiveContentTypes[req.Format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: format must be tar.gz, tar or zip",
+		})
+		return
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	pr, pw := io.Pipe()
+	safe.Go(func() {
+		pw.CloseWithError(manager.Archive(req.Path, pw, filesystem.ArchiveOptions{
+			Format:    req.Format,
+			Include:   req.Include,
+			Exclude:   req.Exclude,
+			Gitignore: req.Gitignore,
+		}))
+	})
+
+	// Errors before the first byte, such as a missing directory, still get
+	// an error response; later ones can only cut the archive short.
+	body := bufio.NewReader(pr)
+	if _, err := body.Peek(1); err != nil {
+		pr.Close()
+		archiveFailed(c, err)
+		return
+	}
+
+	name := filepath.Base(filepath.Clean(req.Path)) + "." + req.Format
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.SetContentType(contentType)
+	// The response closes the pipe once the body is written, which stops
+	// the packing if the client went away.
+	c.SetBodyStream(struct {
+		io.Reader
+		io.Closer
+	}{body, pr}, -1)
+}
+
+// Extract unpacks the archive in the request body into a directory.
+func (h *FileHandler) Extract(ctx context.Context, c *app.RequestContext) {
+	path := c.Query("path")
+	strip, err := strconv.Atoi(c.DefaultQuery("strip_components", "0"))
+	if path == "" || err != nil || strip < 0 {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: path is required and strip_components must be a non-negative integer",
+		})
+		return
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	result, err := manager.Extract(path, requestBody(c), filesystem.ExtractOptions{
+		Format:          c.Query("format"),
+		StripComponents: strip,
+	})
+	if err != nil {
+		archiveFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.FileExtractResult{
+			Path:  result.Path,
+			Files: result.Files,
+			Dirs:  result.Dirs,
+			Size:  result.Size,
+		},
+	})
+}
+
+func archiveFailed(c *app.RequestContext, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		status = http.StatusNotFound
+	case errors.Is(err, filesystem.ErrArchiveFormat), errors.Is(err, filesystem.ErrInvalidPattern),
+		errors.Is(err, filesystem.ErrUnsafeArchive):
+		status = http.StatusBadRequest
+	case errors.Is(err, filesystem.ErrArchiveLimit):
+		status = http.StatusRequestEntityTooLarge
+	}
+	c.JSON(status, model.Response{
+		Code:    status,
+		Message: err.Error(),
+	})
+}
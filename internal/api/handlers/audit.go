@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/internal/services/audit"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditHandler struct {
+	logger *audit.Logger
+}
+
+func NewAuditHandler(logger *audit.Logger) *AuditHandler {
+	return &AuditHandler{logger: logger}
+}
+
+func (h *AuditHandler) Query(ctx context.Context, c *app.RequestContext) {
+	if h.logger == nil {
+		c.JSON(http.StatusServiceUnavailable, model.Response{
+			Code:    503,
+			Message: "audit log is disabled",
+		})
+		return
+	}
+
+	filter := audit.Filter{
+		SessionID: c.Query("session"),
+		Tool:      c.Query("tool"),
+		Limit:     defaultAuditLimit,
+	}
+	var err error
+	if filter.Since, err = parseAuditTime(c.Query("since")); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid since: " + err.Error(),
+		})
+		return
+	}
+	if filter.Until, err = parseAuditTime(c.Query("until")); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid until: " + err.Error(),
+		})
+		return
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    400,
+				Message: "invalid limit: " + limit,
+			})
+			return
+		}
+		filter.Limit = min(n, maxAuditLimit)
+	}
+
+	entries, err := h.logger.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result := model.AuditQueryResult{Entries: make([]model.AuditEntry, 0, len(entries))}
+	for _, e := range entries {
+		result.Entries = append(result.Entries, model.AuditEntry{
+			TimeUnixMs:   e.Time.UnixMilli(),
+			Source:       e.Source,
+			SessionID:    e.SessionID,
+			Subject:      e.Subject,
+			Tool:         e.Tool,
+			Args:         e.Args,
+			Status:       e.Status,
+			Result:       e.Result,
+			Error:        e.Error,
+			ExitCode:     e.ExitCode,
+			DurationMs:   e.DurationMs,
+			BytesChanged: e.BytesChanged,
+		})
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: result,
+	})
+}
+
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 or Unix seconds: %s", value)
+	}
+	return t, nil
+}
@@ -1,25 +1,48 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/deep-agent/sandbox/internal/services/audit"
 	"github.com/deep-agent/sandbox/internal/services/bash"
 	"github.com/deep-agent/sandbox/pkg/ctxutil"
+	"github.com/deep-agent/sandbox/pkg/safe"
 	"github.com/deep-agent/sandbox/types/model"
+	"github.com/hertz-contrib/websocket"
 )
 
 type BashHandler struct {
-	executor *bash.Executor
+	sessions *bash.SessionManager
+	jobs     *bash.JobManager
+	guard    *bash.CommandGuard
+	upgrader *websocket.HertzUpgrader
 }
 
-func NewBashHandler(executor *bash.Executor) *BashHandler {
-	return &BashHandler{executor: executor}
+func NewBashHandler(sessions *bash.SessionManager, jobs *bash.JobManager, guard *bash.CommandGuard) *BashHandler {
+	return &BashHandler{
+		sessions: sessions,
+		jobs:     jobs,
+		guard:    guard,
+		upgrader: &websocket.HertzUpgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(ctx *app.RequestContext) bool {
+				return true
+			},
+		},
+	}
 }
 
 func (h *BashHandler) ExecCommand(ctx context.Context, c *app.RequestContext) {
@@ -32,56 +55,105 @@ func (h *BashHandler) ExecCommand(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	if req.Cwd == "" {
-		req.Cwd = ctxutil.GetCwd(ctx)
+	stdin, err := bash.DecodeStdin(req.Stdin, req.StdinEncoding)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
 	}
 
 	if req.RunInBackground {
-		timeout := 10 * time.Minute
-		if req.TimeoutMS > 0 {
-			timeout = time.Duration(req.TimeoutMS) * time.Millisecond
+		cwd := req.Cwd
+		if cwd == "" {
+			cwd = h.sessions.Cwd(ctxutil.GetSessionIDFromCtx(ctx))
+		}
+		if cwd == "" {
+			cwd = ctxutil.GetCwd(ctx)
 		}
-		result, err := h.executor.ExecuteBackground(ctx, req.Command, req.Cwd, timeout)
+		job, err := h.jobs.Start(ctx, bash.JobStartRequest{
+			SessionID: ctxutil.GetSessionIDFromCtx(ctx),
+			Command:   req.Command,
+			WorkDir:   cwd,
+			Env:       bash.MergeEnv(h.sessions.Env(ctxutil.GetSessionIDFromCtx(ctx)), req.Env),
+			Stdin:     stdinReader(stdin),
+			Timeout:   time.Duration(req.TimeoutMS) * time.Millisecond,
+			Limits:    bash.LimitsFromModel(req.Limits),
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, model.Response{
-				Code:    500,
-				Message: "execution failed: " + err.Error(),
-			})
+			execFailed(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, model.Response{
 			Code: 0,
 			Data: model.BashExecResult{
-				Output:     result.Output,
-				ExitCode:   result.ExitCode,
-				OutputFile: result.OutputFile,
+				Output:     fmt.Sprintf("Command started in background (Job ID: %s, PID: %d)", job.ID, job.PID),
+				OutputFile: job.OutputFile,
+				JobID:      job.ID,
 			},
 		})
 		return
 	}
 
-	if req.TimeoutMS > 0 {
-		h.executor.SetTimeout(time.Duration(req.TimeoutMS) * time.Millisecond)
-	}
-
-	result, err := h.executor.Execute(ctx, req.Command, req.Cwd, nil)
+	result, err := h.sessions.Execute(ctx, h.sessionExecRequest(ctx, &req, stdinReader(stdin)))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: "execution failed: " + err.Error(),
-		})
+		execFailed(c, err)
 		return
 	}
+	audit.SetExitCode(ctx, result.ExitCode)
 
 	c.JSON(http.StatusOK, model.Response{
 		Code: 0,
 		Data: model.BashExecResult{
-			Output:   result.Output,
-			ExitCode: result.ExitCode,
+			Output:        result.Output,
+			ExitCode:      result.ExitCode,
+			LimitExceeded: result.LimitExceeded,
 		},
 	})
 }
 
+// execFailed reports a command that could not run. Policy denials are
+// returned as 403 with the decision as data, and commands refused for
+// lack of a free shell session as 429.
+func execFailed(c *app.RequestContext, err error) {
+	var denied *bash.PolicyError
+	if errors.As(err, &denied) {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:    403,
+			Message: err.Error(),
+			Data:    toBashPolicyDecision(denied.Decision),
+		})
+		return
+	}
+	if errors.Is(err, bash.ErrSessionLimit) {
+		c.JSON(http.StatusTooManyRequests, model.Response{
+			Code:    429,
+			Message: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, model.Response{
+		Code:    500,
+		Message: "execution failed: " + err.Error(),
+	})
+}
+
+// jobFailed reports an error of the job manager, as 404 for unknown jobs.
+func jobFailed(c *app.RequestContext, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, bash.ErrJobNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, bash.ErrJobNotRunning):
+		status = http.StatusConflict
+	}
+	c.JSON(status, model.Response{
+		Code:    status,
+		Message: err.Error(),
+	})
+}
+
 type StreamEvent struct {
 	Event string      `json:"event"`
 	Data  interface{} `json:"data"`
@@ -93,11 +165,13 @@ type StreamChunkData struct {
 }
 
 type StreamDoneData struct {
-	Output     string `json:"output"`
-	ExitCode   int    `json:"exit_code"`
-	DurationMs int64  `json:"duration_ms"`
-	TimedOut   bool   `json:"timed_out"`
-	Truncated  bool   `json:"truncated"`
+	Output        string `json:"output"`
+	ExitCode      int    `json:"exit_code"`
+	DurationMs    int64  `json:"duration_ms"`
+	TimedOut      bool   `json:"timed_out"`
+	Truncated     bool   `json:"truncated"`
+	TimeoutMs     int64  `json:"timeout_ms"`
+	LimitExceeded string `json:"limit_exceeded,omitempty"`
 }
 
 func (h *BashHandler) ExecCommandStream(ctx context.Context, c *app.RequestContext) {
@@ -110,12 +184,13 @@ func (h *BashHandler) ExecCommandStream(ctx context.Context, c *app.RequestConte
 		return
 	}
 
-	if req.Cwd == "" {
-		req.Cwd = ctxutil.GetCwd(ctx)
-	}
-
-	if req.TimeoutMS > 0 {
-		h.executor.SetTimeout(time.Duration(req.TimeoutMS) * time.Millisecond)
+	stdin, err := bash.DecodeStdin(req.Stdin, req.StdinEncoding)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
 	}
 
 	c.SetStatusCode(consts.StatusOK)
@@ -137,18 +212,526 @@ func (h *BashHandler) ExecCommandStream(ctx context.Context, c *app.RequestConte
 		})
 	}
 
-	result, err := h.executor.ExecuteStream(ctx, req.Command, req.Cwd, onChunk, nil)
+	result, err := h.sessions.ExecuteStream(ctx, h.sessionExecRequest(ctx, &req, stdinReader(stdin)), onChunk)
 
 	if err != nil {
 		sendEvent("error", map[string]string{"message": err.Error()})
 		return
 	}
+	audit.SetExitCode(ctx, result.ExitCode)
 
 	sendEvent("done", StreamDoneData{
-		Output:     result.Output,
-		ExitCode:   result.ExitCode,
-		DurationMs: result.DurationMs,
-		TimedOut:   result.TimedOut,
-		Truncated:  result.Truncated,
+		Output:        result.Output,
+		ExitCode:      result.ExitCode,
+		DurationMs:    result.DurationMs,
+		TimedOut:      result.TimedOut,
+		Truncated:     result.Truncated,
+		TimeoutMs:     result.TimeoutMs,
+		LimitExceeded: result.LimitExceeded,
+	})
+}
+
+// ExecCommandWS runs a command over a WebSocket so the client can keep
+// feeding stdin while output streams back. The first message must be
+// {"type":"exec","data":<BashExecRequest>}; after that the client may send
+// {"type":"stdin","data":"..."} (encoded like the request's stdin),
+// {"type":"eof"} and {"type":"cancel"}. The server replies with "chunk"
+// messages followed by a final "done" or "error".
+func (h *BashHandler) ExecCommandWS(ctx context.Context, c *app.RequestContext) {
+	err := h.upgrader.Upgrade(c, func(conn *websocket.Conn) {
+		defer conn.Close()
+
+		var writeMu sync.Mutex
+		send := func(msgType string, data interface{}) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			return conn.WriteJSON(map[string]interface{}{"type": msgType, "data": data})
+		}
+
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Time{})
+
+		var msg wsMessage
+		var req model.BashExecRequest
+		if err := json.Unmarshal(message, &msg); err != nil || msg.Type != "exec" {
+			send("error", "first message must be of type exec")
+			return
+		}
+		if err := json.Unmarshal(msg.Data, &req); err != nil || req.Command == "" {
+			send("error", "invalid exec request")
+			return
+		}
+
+		initial, err := bash.DecodeStdin(req.Stdin, req.StdinEncoding)
+		if err != nil {
+			send("error", err.Error())
+			return
+		}
+
+		execCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stdinR, stdinW := io.Pipe()
+		defer stdinR.Close()
+
+		safe.Go(func() {
+			h.readStdinMessages(conn, stdinW, initial, req.StdinEncoding, cancel, send)
+		})
+
+		start := time.Now()
+		result, err := h.sessions.ExecuteStream(execCtx, h.sessionExecRequest(ctx, &req, stdinR), func(chunk bash.StreamChunk) {
+			send("chunk", StreamChunkData{Data: chunk.Data, Source: chunk.Source})
+		})
+		// The upgrade request was audited before the command arrived, so the
+		// command gets an entry of its own.
+		entry := audit.Entry{
+			Args:       map[string]interface{}{"command": req.Command, "cwd": req.Cwd, "env": req.Env},
+			Status:     audit.StatusOK,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			entry.Status, entry.Error = audit.StatusError, err.Error()
+			audit.Record(ctx, entry)
+			send("error", err.Error())
+			return
+		}
+		entry.ExitCode = &result.ExitCode
+		audit.Record(ctx, entry)
+
+		send("done", StreamDoneData{
+			Output:        result.Output,
+			ExitCode:      result.ExitCode,
+			DurationMs:    result.DurationMs,
+			TimedOut:      result.TimedOut,
+			Truncated:     result.Truncated,
+			TimeoutMs:     result.TimeoutMs,
+			LimitExceeded: result.LimitExceeded,
+		})
+	})
+
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+}
+
+func (h *BashHandler) readStdinMessages(conn *websocket.Conn, stdin *io.PipeWriter, initial []byte, encoding string, cancel context.CancelFunc, send func(string, interface{}) error) {
+	if len(initial) > 0 {
+		if _, err := stdin.Write(initial); err != nil {
+			return
+		}
+	}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			stdin.CloseWithError(err)
+			cancel()
+			return
+		}
+
+		var msg wsMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			send("error", "invalid message format")
+			continue
+		}
+
+		switch msg.Type {
+		case "stdin":
+			var data string
+			if err := json.Unmarshal(msg.Data, &data); err != nil {
+				send("error", "stdin data must be a string")
+				continue
+			}
+			decoded, err := bash.DecodeStdin(data, encoding)
+			if err != nil {
+				send("error", err.Error())
+				continue
+			}
+			stdin.Write(decoded)
+		case "eof":
+			stdin.Close()
+		case "cancel":
+			cancel()
+		case "ping":
+			send("pong", nil)
+		default:
+			send("error", "unknown message type")
+		}
+	}
+}
+
+func (h *BashHandler) sessionExecRequest(ctx context.Context, req *model.BashExecRequest, stdin io.Reader) bash.SessionExecRequest {
+	return bash.SessionExecRequest{
+		SessionID: ctxutil.GetSessionIDFromCtx(ctx),
+		Command:   req.Command,
+		Workspace: ctxutil.GetCwd(ctx),
+		Cwd:       req.Cwd,
+		Env:       req.Env,
+		Stdin:     stdin,
+		Timeout:   time.Duration(req.TimeoutMS) * time.Millisecond,
+		Limits:    bash.LimitsFromModel(req.Limits),
+	}
+}
+
+func stdinReader(data []byte) io.Reader {
+	if len(data) == 0 {
+		return nil
+	}
+	return bytes.NewReader(data)
+}
+
+func (h *BashHandler) ListSessions(ctx context.Context, c *app.RequestContext) {
+	sessions := h.sessions.List()
+
+	infos := make([]model.BashSessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, toBashSessionInfo(s))
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.BashSessionListResult{Sessions: infos},
 	})
 }
+
+func (h *BashHandler) ResetSession(ctx context.Context, c *app.RequestContext) {
+	info, err := h.sessions.Reset(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:    404,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: toBashSessionInfo(*info),
+	})
+}
+
+func (h *BashHandler) DestroySession(ctx context.Context, c *app.RequestContext) {
+	if err := h.sessions.Destroy(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:    404,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BashHandler) GetSessionEnv(ctx context.Context, c *app.RequestContext) {
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.BashSessionEnvResult{
+			SessionID: c.Param("id"),
+			Env:       h.sessions.Env(c.Param("id")),
+		},
+	})
+}
+
+func (h *BashHandler) SetSessionEnv(ctx context.Context, c *app.RequestContext) {
+	var req model.BashSessionEnvRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.sessions.SetEnv(c.Param("id"), req.Env); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.BashSessionEnvResult{
+			SessionID: c.Param("id"),
+			Env:       h.sessions.Env(c.Param("id")),
+		},
+	})
+}
+
+func toBashSessionInfo(s bash.SessionInfo) model.BashSessionInfo {
+	return model.BashSessionInfo{
+		ID:             s.ID,
+		Cwd:            s.Cwd,
+		PID:            s.PID,
+		Busy:           s.Busy,
+		CreatedAtUnix:  s.CreatedAt.Unix(),
+		LastUsedAtUnix: s.LastUsedAt.Unix(),
+	}
+}
+
+func (h *BashHandler) ListJobs(ctx context.Context, c *app.RequestContext) {
+	jobs := h.jobs.List(ctxutil.GetSessionIDFromCtx(ctx))
+
+	infos := make([]model.BashJobInfo, 0, len(jobs))
+	for _, j := range jobs {
+		infos = append(infos, toBashJobInfo(j))
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.BashJobListResult{Jobs: infos},
+	})
+}
+
+func (h *BashHandler) GetJob(ctx context.Context, c *app.RequestContext) {
+	job, err := h.jobs.Get(ctxutil.GetSessionIDFromCtx(ctx), c.Param("id"))
+	if err != nil {
+		jobFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: toBashJobInfo(*job),
+	})
+}
+
+func (h *BashHandler) GetJobOutput(ctx context.Context, c *app.RequestContext) {
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid offset: " + err.Error(),
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid limit: " + err.Error(),
+		})
+		return
+	}
+
+	output, err := h.jobs.ReadOutput(ctxutil.GetSessionIDFromCtx(ctx), c.Param("id"), offset, limit)
+	if err != nil {
+		jobFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.BashJobOutputResult{
+			Output:     output.Output,
+			Offset:     output.Offset,
+			NextOffset: output.NextOffset,
+			Size:       output.Size,
+			State:      string(output.State),
+		},
+	})
+}
+
+func (h *BashHandler) KillJob(ctx context.Context, c *app.RequestContext) {
+	var req model.BashJobKillRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	sig, err := bash.ParseSignal(req.Signal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	job, err := h.jobs.Kill(ctxutil.GetSessionIDFromCtx(ctx), c.Param("id"), sig)
+	if err != nil {
+		jobFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: toBashJobInfo(*job),
+	})
+}
+
+func toBashJobInfo(j bash.JobInfo) model.BashJobInfo {
+	info := model.BashJobInfo{
+		ID:            j.ID,
+		SessionID:     j.SessionID,
+		Command:       j.Command,
+		Cwd:           j.WorkDir,
+		PID:           j.PID,
+		State:         string(j.State),
+		ExitCode:      j.ExitCode,
+		OutputFile:    j.OutputFile,
+		StartedAtUnix: j.StartedAt.Unix(),
+	}
+	if !j.EndedAt.IsZero() {
+		info.EndedAtUnix = j.EndedAt.Unix()
+	}
+	return info
+}
+
+func (h *BashHandler) GetPolicy(ctx context.Context, c *app.RequestContext) {
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: h.guard.Policy("").Spec(),
+	})
+}
+
+func (h *BashHandler) GetSessionPolicy(ctx context.Context, c *app.RequestContext) {
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: h.guard.Policy(c.Param("id")).Spec(),
+	})
+}
+
+func (h *BashHandler) SetSessionPolicy(ctx context.Context, c *app.RequestContext) {
+	var req model.BashPolicy
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	policy, err := bash.NewCommandPolicy(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+	h.guard.SetSessionPolicy(c.Param("id"), policy)
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: policy.Spec(),
+	})
+}
+
+func (h *BashHandler) DeleteSessionPolicy(ctx context.Context, c *app.RequestContext) {
+	h.guard.SetSessionPolicy(c.Param("id"), nil)
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BashHandler) CheckPolicy(ctx context.Context, c *app.RequestContext) {
+	var req model.BashPolicyCheckRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = ctxutil.GetSessionIDFromCtx(ctx)
+	}
+	if sessionID == "" {
+		sessionID = bash.DefaultSessionID
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: toBashPolicyDecision(h.guard.Evaluate(sessionID, req.Command)),
+	})
+}
+
+func (h *BashHandler) ListApprovals(ctx context.Context, c *app.RequestContext) {
+	approvals := h.guard.Approvals()
+
+	infos := make([]model.BashApproval, 0, len(approvals))
+	for _, a := range approvals {
+		infos = append(infos, toBashApproval(a))
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.BashApprovalListResult{Approvals: infos},
+	})
+}
+
+func (h *BashHandler) ApproveCommand(ctx context.Context, c *app.RequestContext) {
+	h.resolveApproval(c, func(id, _ string) (*bash.Approval, error) {
+		return h.guard.Approve(id)
+	})
+}
+
+func (h *BashHandler) RejectCommand(ctx context.Context, c *app.RequestContext) {
+	h.resolveApproval(c, h.guard.Reject)
+}
+
+func (h *BashHandler) resolveApproval(c *app.RequestContext, resolve func(id, reason string) (*bash.Approval, error)) {
+	var req model.BashApprovalResolveRequest
+	if len(c.Request.Body()) > 0 {
+		if err := c.BindAndValidate(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    400,
+				Message: "invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	approval, err := resolve(c.Param("id"), req.Reason)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:    404,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: toBashApproval(*approval),
+	})
+}
+
+func toBashPolicyDecision(d bash.PolicyDecision) model.BashPolicyDecision {
+	return model.BashPolicyDecision{
+		Action:  d.Action,
+		Rule:    d.Rule,
+		Command: d.Command,
+		Reason:  d.Reason,
+	}
+}
+
+func toBashApproval(a bash.Approval) model.BashApproval {
+	return model.BashApproval{
+		ID:            a.ID,
+		SessionID:     a.SessionID,
+		Command:       a.Command,
+		State:         a.State,
+		Decision:      toBashPolicyDecision(a.Decision),
+		CreatedAtUnix: a.CreatedAt.Unix(),
+		ExpiresAtUnix: a.ExpiresAt.Unix(),
+	}
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/deep-agent/sandbox/internal/services/browser"
@@ -20,10 +22,7 @@ func NewBrowserHandler(controller *browser.Controller) *BrowserHandler {
 func (h *BrowserHandler) GetInfo(ctx context.Context, c *app.RequestContext) {
 	info, err := h.controller.GetInfo()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+		browserFailed(c, err)
 		return
 	}
 
@@ -43,11 +42,8 @@ func (h *BrowserHandler) Navigate(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	if err := h.controller.Navigate(req.URL); err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+	if err := h.controller.ForContext(ctx).Navigate(req.URL); err != nil {
+		browserFailed(c, err)
 		return
 	}
 
@@ -67,12 +63,9 @@ func (h *BrowserHandler) Screenshot(ctx context.Context, c *app.RequestContext)
 		Full:    req.Full,
 	}
 
-	screenshot, err := h.controller.Screenshot(opts)
+	screenshot, err := h.controller.ForContext(ctx).Screenshot(opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+		browserFailed(c, err)
 		return
 	}
 
@@ -92,11 +85,8 @@ func (h *BrowserHandler) Click(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	if err := h.controller.Click(req.Selector); err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+	if err := h.controller.ForContext(ctx).Click(browser.Element{Selector: req.Selector, Ref: req.Ref}); err != nil {
+		browserFailed(c, err)
 		return
 	}
 
@@ -116,20 +106,203 @@ func (h *BrowserHandler) Type(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	if err := h.controller.Type(req.Selector, req.Text); err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
+	if err := h.controller.ForContext(ctx).Type(browser.Element{Selector: req.Selector, Ref: req.Ref}, req.Text); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) Hover(ctx context.Context, c *app.RequestContext) {
+	var req model.BrowserHoverRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.controller.ForContext(ctx).Hover(browser.Element{Selector: req.Selector, Ref: req.Ref}); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) Select(ctx context.Context, c *app.RequestContext) {
+	var req model.BrowserSelectRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	selected, err := h.controller.ForContext(ctx).Select(browser.Element{Selector: req.Selector, Ref: req.Ref}, req.Values)
+	if err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.BrowserSelectResult{Selected: selected},
+	})
+}
+
+func (h *BrowserHandler) MouseMove(ctx context.Context, c *app.RequestContext) {
+	var req model.BrowserMouseMoveRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.controller.ForContext(ctx).MouseMove(req.X, req.Y); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) MouseClick(ctx context.Context, c *app.RequestContext) {
+	var req model.BrowserMouseClickRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.controller.ForContext(ctx).MouseClick(req.X, req.Y, browser.MouseOptions{
+		Button:    req.Button,
+		Count:     req.ClickCount,
+		Modifiers: req.Modifiers,
+	}); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) MouseDrag(ctx context.Context, c *app.RequestContext) {
+	var req model.BrowserMouseDragRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.controller.ForContext(ctx).MouseDrag(req.FromX, req.FromY, req.ToX, req.ToY, req.Steps); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) MouseWheel(ctx context.Context, c *app.RequestContext) {
+	var req model.BrowserMouseWheelRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.controller.ForContext(ctx).MouseWheel(req.X, req.Y, req.DeltaX, req.DeltaY); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) PressKey(ctx context.Context, c *app.RequestContext) {
+	var req model.BrowserKeyPressRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.controller.ForContext(ctx).PressKey(req.Key); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) InsertText(ctx context.Context, c *app.RequestContext) {
+	var req model.BrowserInsertTextRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
 		})
 		return
 	}
 
+	if err := h.controller.ForContext(ctx).InsertText(req.Text); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, model.Response{
 		Code:    0,
 		Message: "success",
 	})
 }
 
+func (h *BrowserHandler) Snapshot(ctx context.Context, c *app.RequestContext) {
+	snapshot, err := h.controller.ForContext(ctx).Snapshot()
+	if err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: snapshot,
+	})
+}
+
 func (h *BrowserHandler) Evaluate(ctx context.Context, c *app.RequestContext) {
 	var req model.BrowserEvaluateRequest
 	if err := c.BindAndValidate(&req); err != nil {
@@ -140,12 +313,9 @@ func (h *BrowserHandler) Evaluate(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	result, err := h.controller.Evaluate(req.Expression)
+	result, err := h.controller.ForContext(ctx).Evaluate(req.Expression)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+		browserFailed(c, err)
 		return
 	}
 
@@ -156,12 +326,9 @@ func (h *BrowserHandler) Evaluate(ctx context.Context, c *app.RequestContext) {
 }
 
 func (h *BrowserHandler) GetCurrentURL(ctx context.Context, c *app.RequestContext) {
-	url, err := h.controller.GetCurrentURL()
+	url, err := h.controller.ForContext(ctx).GetCurrentURL()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+		browserFailed(c, err)
 		return
 	}
 
@@ -172,12 +339,9 @@ func (h *BrowserHandler) GetCurrentURL(ctx context.Context, c *app.RequestContex
 }
 
 func (h *BrowserHandler) GetTitle(ctx context.Context, c *app.RequestContext) {
-	title, err := h.controller.GetTitle()
+	title, err := h.controller.ForContext(ctx).GetTitle()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+		browserFailed(c, err)
 		return
 	}
 
@@ -197,11 +361,8 @@ func (h *BrowserHandler) Scroll(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	if err := h.controller.Scroll(req.X, req.Y); err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+	if err := h.controller.ForContext(ctx).Scroll(req.X, req.Y); err != nil {
+		browserFailed(c, err)
 		return
 	}
 
@@ -221,12 +382,9 @@ func (h *BrowserHandler) GetHTML(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	html, err := h.controller.GetHTML(req.Selector)
+	html, err := h.controller.ForContext(ctx).GetHTML(req.Selector)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+		browserFailed(c, err)
 		return
 	}
 
@@ -246,11 +404,8 @@ func (h *BrowserHandler) WaitVisible(ctx context.Context, c *app.RequestContext)
 		return
 	}
 
-	if err := h.controller.WaitVisible(req.Selector); err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+	if err := h.controller.ForContext(ctx).WaitVisible(req.Selector); err != nil {
+		browserFailed(c, err)
 		return
 	}
 
@@ -261,12 +416,9 @@ func (h *BrowserHandler) WaitVisible(ctx context.Context, c *app.RequestContext)
 }
 
 func (h *BrowserHandler) GetPageInfo(ctx context.Context, c *app.RequestContext) {
-	info, err := h.controller.GetPageInfo()
+	info, err := h.controller.ForContext(ctx).GetPageInfo()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+		browserFailed(c, err)
 		return
 	}
 
@@ -282,12 +434,9 @@ func (h *BrowserHandler) GetPageInfo(ctx context.Context, c *app.RequestContext)
 }
 
 func (h *BrowserHandler) PDF(ctx context.Context, c *app.RequestContext) {
-	pdf, err := h.controller.PDF()
+	pdf, err := h.controller.ForContext(ctx).PDF()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+		browserFailed(c, err)
 		return
 	}
 
@@ -296,3 +445,173 @@ func (h *BrowserHandler) PDF(ctx context.Context, c *app.RequestContext) {
 		Data: model.BrowserPDFResult{PDF: pdf},
 	})
 }
+
+func (h *BrowserHandler) ListTabs(ctx context.Context, c *app.RequestContext) {
+	tabs, err := h.controller.ForContext(ctx).Tabs()
+	if err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.BrowserTabListResult{Tabs: tabs},
+	})
+}
+
+func (h *BrowserHandler) OpenTab(ctx context.Context, c *app.RequestContext) {
+	var req model.BrowserOpenTabRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	tab, err := h.controller.ForContext(ctx).OpenTab(req.URL)
+	if err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: tab,
+	})
+}
+
+func (h *BrowserHandler) CloseTab(ctx context.Context, c *app.RequestContext) {
+	if err := h.controller.ForContext(ctx).CloseTab(c.Param("id")); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) ActivateTab(ctx context.Context, c *app.RequestContext) {
+	if err := h.controller.ForContext(ctx).ActivateTab(c.Param("id")); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) ListContexts(ctx context.Context, c *app.RequestContext) {
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.BrowserContextListResult{Contexts: h.controller.Contexts()},
+	})
+}
+
+func (h *BrowserHandler) CloseContext(ctx context.Context, c *app.RequestContext) {
+	if err := h.controller.CloseContext(c.Param("id")); err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func (h *BrowserHandler) ConsoleMessages(ctx context.Context, c *app.RequestContext) {
+	limit, ok := browserLimit(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.controller.ForContext(ctx).ConsoleMessages(browser.ConsoleFilter{
+		Level: c.Query("level"),
+		Limit: limit,
+	})
+	if err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: result,
+	})
+}
+
+func (h *BrowserHandler) NetworkRequests(ctx context.Context, c *app.RequestContext) {
+	limit, ok := browserLimit(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.controller.ForContext(ctx).NetworkRequests(browser.NetworkFilter{
+		URL:    c.Query("url"),
+		Status: c.Query("status"),
+		Limit:  limit,
+	})
+	if err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: result,
+	})
+}
+
+func (h *BrowserHandler) NetworkHAR(ctx context.Context, c *app.RequestContext) {
+	har, err := h.controller.ForContext(ctx).NetworkHAR()
+	if err != nil {
+		browserFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: har,
+	})
+}
+
+// browserLimit reads the optional limit query parameter, answering the
+// request if it is invalid.
+func browserLimit(c *app.RequestContext) (int, bool) {
+	limit := c.Query("limit")
+	if limit == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid limit: " + limit,
+		})
+		return 0, false
+	}
+	return n, true
+}
+
+func browserFailed(c *app.RequestContext, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, browser.ErrInvalidElement), errors.Is(err, browser.ErrInvalidFilter),
+		errors.Is(err, browser.ErrInvalidInput):
+		status = http.StatusBadRequest
+	case errors.Is(err, browser.ErrTabNotFound), errors.Is(err, browser.ErrContextNotFound),
+		errors.Is(err, browser.ErrRefNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, browser.ErrContextLimit):
+		status = http.StatusTooManyRequests
+	}
+	c.JSON(status, model.Response{
+		Code:    status,
+		Message: err.Error(),
+	})
+}
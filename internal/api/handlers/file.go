@@ -2,10 +2,18 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/internal/services/audit"
 	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
 	"github.com/deep-agent/sandbox/types/model"
 )
 
@@ -17,6 +25,33 @@ func NewFileHandler(manager *filesystem.Manager) *FileHandler {
 	return &FileHandler{manager: manager}
 }
 
+// sessionManager returns the manager acting for the request's session.
+func (h *FileHandler) sessionManager(ctx context.Context, c *app.RequestContext) (*filesystem.Manager, bool) {
+	return scopeFiles(ctx, c, h.manager)
+}
+
+// scopeFiles returns manager acting for the request's session. It writes the
+// error response itself when the requested workspace is outside the
+// confinement root or the session user cannot be set up.
+func scopeFiles(ctx context.Context, c *app.RequestContext, manager *filesystem.Manager) (*filesystem.Manager, bool) {
+	manager, err := manager.ForContext(ctx)
+	if errors.Is(err, filesystem.ErrOutsideWorkspace) {
+		c.JSON(http.StatusForbidden, model.Response{
+			Code:    403,
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	return manager, true
+}
+
 func (h *FileHandler) ReadFile(ctx context.Context, c *app.RequestContext) {
 	var req model.FileReadRequest
 	if err := c.BindAndValidate(&req); err != nil {
@@ -27,13 +62,31 @@ func (h *FileHandler) ReadFile(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+
+	switch req.Mode {
+	case "", "raw":
+	case "auto":
+		h.readAuto(ctx, c, manager, &req)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: mode must be raw or auto",
+		})
+		return
+	}
+
 	var content string
 	var err error
 
 	if req.Base64 {
-		content, err = h.manager.ReadFileBase64(req.File)
+		content, err = manager.ReadFileBase64(req.File)
 	} else {
-		content, err = h.manager.ReadFile(req.File)
+		content, err = manager.ReadFile(req.File)
 	}
 
 	if err != nil {
@@ -50,6 +103,92 @@ func (h *FileHandler) ReadFile(ctx context.Context, c *app.RequestContext) {
 	})
 }
 
+// readAuto reads a file according to its detected type.
+func (h *FileHandler) readAuto(ctx context.Context, c *app.RequestContext, manager *filesystem.Manager, req *model.FileReadRequest) {
+	fileType, mimeType, err := manager.DetectFileType(req.File)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result := model.FileReadResult{Type: fileType, MimeType: mimeType}
+	switch fileType {
+	case filesystem.FileTypeImage:
+		var img *filesystem.Image
+		if img, err = manager.ReadImage(req.File, req.MaxDimension); err == nil {
+			result.Content = base64.StdEncoding.EncodeToString(img.Data)
+			result.MimeType = img.MimeType
+			result.Width, result.Height = img.Width, img.Height
+			result.OriginalWidth, result.OriginalHeight = img.OriginalWidth, img.OriginalHeight
+		}
+	case filesystem.FileTypePDF:
+		var pdf *filesystem.PDF
+		if pdf, err = manager.ReadPDF(ctx, req.File, req.Pages); err == nil {
+			texts := make([]string, 0, len(pdf.Pages))
+			for _, page := range pdf.Pages {
+				result.Pages = append(result.Pages, model.PDFPage{Number: page.Number, Text: page.Text})
+				texts = append(texts, page.Text)
+			}
+			result.Content = strings.Join(texts, "\f")
+			result.TotalPages = pdf.TotalPages
+		}
+	case filesystem.FileTypeNotebook:
+		var notebook *filesystem.Notebook
+		if notebook, err = manager.ReadNotebook(req.File); err == nil {
+			result.Content = notebook.Render()
+			result.Cells = toNotebookCells(notebook.Cells)
+		}
+	case filesystem.FileTypeBinary:
+		c.JSON(http.StatusUnsupportedMediaType, model.Response{
+			Code:    415,
+			Message: filesystem.ErrBinaryFile.Error() + ": " + req.File + " is " + mimeType,
+		})
+		return
+	default:
+		result.Content, err = manager.ReadFile(req.File)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: result,
+	})
+}
+
+func toNotebookCells(cells []filesystem.NotebookCell) []model.NotebookCell {
+	result := make([]model.NotebookCell, 0, len(cells))
+	for _, cell := range cells {
+		outputs := make([]model.NotebookOutput, 0, len(cell.Outputs))
+		for _, output := range cell.Outputs {
+			outputs = append(outputs, model.NotebookOutput{
+				Type:     output.Type,
+				Text:     output.Text,
+				MimeType: output.MimeType,
+				Data:     output.Data,
+			})
+		}
+		result = append(result, model.NotebookCell{
+			Index:          cell.Index,
+			ID:             cell.ID,
+			Type:           cell.Type,
+			Source:         cell.Source,
+			ExecutionCount: cell.ExecutionCount,
+			Outputs:        outputs,
+		})
+	}
+	return result
+}
+
 func (h *FileHandler) WriteFile(ctx context.Context, c *app.RequestContext) {
 	var req model.FileWriteRequest
 	if err := c.BindAndValidate(&req); err != nil {
@@ -60,11 +199,18 @@ func (h *FileHandler) WriteFile(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+
 	var err error
+	written := len(req.Content)
 	if req.Base64 {
-		err = h.manager.WriteFileBase64(req.File, req.Content)
+		err = manager.WriteFileBase64(req.File, req.Content)
+		written = base64.RawStdEncoding.DecodedLen(len(strings.TrimRight(req.Content, "=")))
 	} else {
-		err = h.manager.WriteFile(req.File, req.Content)
+		err = manager.WriteFile(req.File, req.Content)
 	}
 
 	if err != nil {
@@ -74,6 +220,7 @@ func (h *FileHandler) WriteFile(ctx context.Context, c *app.RequestContext) {
 		})
 		return
 	}
+	audit.AddBytesChanged(ctx, int64(written))
 
 	c.JSON(http.StatusOK, model.Response{
 		Code:    0,
@@ -81,6 +228,137 @@ func (h *FileHandler) WriteFile(ctx context.Context, c *app.RequestContext) {
 	})
 }
 
+func (h *FileHandler) EditFile(ctx context.Context, c *app.RequestContext) {
+	var req model.FileEditRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+
+	result, err := manager.EditFile(req.File, req.OldString, req.NewString, filesystem.EditOptions{
+		ReplaceAll: req.ReplaceAll,
+		DryRun:     req.DryRun,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.FileEditResult{
+			Diff:         result.Diff,
+			Strategy:     result.Strategy,
+			Replacements: result.Replacements,
+			DryRun:       req.DryRun,
+		},
+	})
+}
+
+func (h *FileHandler) MultiEdit(ctx context.Context, c *app.RequestContext) {
+	var req model.FileMultiEditRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+
+	edits := make([]filesystem.Edit, 0, len(req.Edits))
+	for _, edit := range req.Edits {
+		edits = append(edits, filesystem.Edit{
+			OldString:  edit.OldString,
+			NewString:  edit.NewString,
+			ReplaceAll: edit.ReplaceAll,
+		})
+	}
+	result, err := manager.MultiEdit(req.File, edits, req.DryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.FileMultiEditResult{
+			Diff:         result.Diff,
+			Strategies:   result.Strategies,
+			Replacements: result.Replacements,
+			DryRun:       req.DryRun,
+		},
+	})
+}
+
+func (h *FileHandler) ApplyPatch(ctx context.Context, c *app.RequestContext) {
+	var req model.FilePatchRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+
+	result, err := manager.ApplyPatch(req.Patch, filesystem.PatchOptions{
+		Dir:    ctxutil.GetCwd(ctx),
+		DryRun: req.DryRun,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, filesystem.ErrInvalidPatch):
+			status = http.StatusBadRequest
+		case errors.Is(err, filesystem.ErrPatchFailed):
+			status = http.StatusConflict
+		}
+		c.JSON(status, model.Response{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	files := make([]model.PatchedFile, 0, len(result.Files))
+	for _, f := range result.Files {
+		files = append(files, model.PatchedFile{
+			Path:    f.Path,
+			OldPath: f.OldPath,
+			Op:      f.Op,
+			Added:   f.Added,
+			Removed: f.Removed,
+		})
+	}
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.FilePatchResult{Files: files, DryRun: req.DryRun},
+	})
+}
+
 func (h *FileHandler) ListDir(ctx context.Context, c *app.RequestContext) {
 	var req model.FileListRequest
 	if err := c.BindAndValidate(&req); err != nil {
@@ -91,7 +369,11 @@ func (h *FileHandler) ListDir(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	files, err := h.manager.ListDir(req.Path)
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	files, err := manager.ListDir(req.Path)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Response{
 			Code:    500,
@@ -106,6 +388,62 @@ func (h *FileHandler) ListDir(ctx context.Context, c *app.RequestContext) {
 	})
 }
 
+// Tree lists a directory recursively, one page at a time.
+func (h *FileHandler) Tree(ctx context.Context, c *app.RequestContext) {
+	var req model.FileTreeRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+	if req.Depth < 0 || req.Offset < 0 || req.Limit < 0 || (req.Format != "" && req.Format != "json" && req.Format != "text") {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: depth, offset and limit must not be negative and format must be json or text",
+		})
+		return
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	result, err := manager.Tree(req.Path, filesystem.TreeOptions{
+		Depth:     req.Depth,
+		Ignore:    req.Ignore,
+		Gitignore: req.Gitignore,
+		Sort:      req.Sort,
+		Reverse:   req.Reverse,
+		Offset:    req.Offset,
+		Limit:     req.Limit,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			status = http.StatusNotFound
+		case errors.Is(err, filesystem.ErrInvalidPattern), errors.Is(err, filesystem.ErrInvalidSort),
+			errors.Is(err, syscall.ENOTDIR):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, model.Response{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+	if req.Format == "text" {
+		result.Text = filesystem.RenderTree(result)
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: result,
+	})
+}
+
 func (h *FileHandler) DeleteFile(ctx context.Context, c *app.RequestContext) {
 	var req model.FileDeleteRequest
 	if err := c.BindAndValidate(&req); err != nil {
@@ -116,7 +454,11 @@ func (h *FileHandler) DeleteFile(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	if err := h.manager.DeleteFile(req.Path); err != nil {
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	if err := manager.DeleteFile(req.Path); err != nil {
 		c.JSON(http.StatusInternalServerError, model.Response{
 			Code:    500,
 			Message: err.Error(),
@@ -140,7 +482,11 @@ func (h *FileHandler) MoveFile(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	if err := h.manager.MoveFile(req.Source, req.Destination); err != nil {
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	if err := manager.MoveFile(req.Source, req.Destination); err != nil {
 		c.JSON(http.StatusInternalServerError, model.Response{
 			Code:    500,
 			Message: err.Error(),
@@ -164,7 +510,11 @@ func (h *FileHandler) CopyFile(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	if err := h.manager.CopyFile(req.Source, req.Destination); err != nil {
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	if err := manager.CopyFile(req.Source, req.Destination); err != nil {
 		c.JSON(http.StatusInternalServerError, model.Response{
 			Code:    500,
 			Message: err.Error(),
@@ -188,7 +538,11 @@ func (h *FileHandler) MkDir(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	if err := h.manager.MkDir(req.Path); err != nil {
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	if err := manager.MkDir(req.Path); err != nil {
 		c.JSON(http.StatusInternalServerError, model.Response{
 			Code:    500,
 			Message: err.Error(),
@@ -212,9 +566,109 @@ func (h *FileHandler) Exists(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	exists := h.manager.Exists(path)
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	exists := manager.Exists(path)
 	c.JSON(http.StatusOK, model.Response{
 		Code: 0,
 		Data: model.FileExistsResult{Exists: exists},
 	})
 }
+
+func (h *FileHandler) History(ctx context.Context, c *app.RequestContext) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: limit must be a non-negative integer",
+		})
+		return
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	changes, err := manager.History(c.Query("path"), limit)
+	if err != nil {
+		historyFailed(c, err, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.FileHistoryResult{Changes: toFileChanges(changes)},
+	})
+}
+
+func (h *FileHandler) Undo(ctx context.Context, c *app.RequestContext) {
+	var req model.FileUndoRequest
+	if len(c.Request.Body()) > 0 {
+		if err := c.BindAndValidate(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    400,
+				Message: "invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	undone, err := manager.Undo(filesystem.UndoOptions{
+		ID:    req.ID,
+		Path:  req.Path,
+		Count: req.Count,
+		Force: req.Force,
+	})
+	result := model.FileUndoResult{Undone: toFileChanges(undone)}
+	if err != nil {
+		historyFailed(c, err, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: result,
+	})
+}
+
+// historyFailed reports a history error; data carries the changes undone
+// before an undo failed.
+func historyFailed(c *app.RequestContext, err error, data interface{}) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, filesystem.ErrHistoryDisabled):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, filesystem.ErrChangeNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, filesystem.ErrUndoConflict):
+		status = http.StatusConflict
+	}
+	c.JSON(status, model.Response{
+		Code:    status,
+		Message: err.Error(),
+		Data:    data,
+	})
+}
+
+func toFileChanges(changes []filesystem.Change) []model.FileChange {
+	result := make([]model.FileChange, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, model.FileChange{
+			ID:       change.ID,
+			Op:       change.Op,
+			Path:     change.Path,
+			Dest:     change.Dest,
+			TimeUnix: change.Time.Unix(),
+			Undone:   change.Undone,
+			Undoable: !change.Undone && change.Incomplete == "",
+			Reason:   change.Incomplete,
+		})
+	}
+	return result
+}
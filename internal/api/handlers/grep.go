@@ -38,7 +38,12 @@ func (h *GrepHandler) Search(ctx context.Context, c *app.RequestContext) {
 		MaxLineLength:   req.MaxLineLength,
 	}
 
-	result, err := h.manager.Grep(ctx, opts)
+	manager, ok := scopeFiles(ctx, c, h.manager)
+	if !ok {
+		return
+	}
+
+	result, err := manager.Grep(ctx, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Response{
 			Code:    500,
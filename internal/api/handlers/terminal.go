@@ -7,18 +7,22 @@ import (
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/internal/services/identity"
 	"github.com/deep-agent/sandbox/internal/services/terminal"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
 	"github.com/hertz-contrib/websocket"
 )
 
 type TerminalHandler struct {
 	workspace string
+	users     *identity.Manager
 	upgrader  *websocket.HertzUpgrader
 }
 
-func NewTerminalHandler(workspace string) *TerminalHandler {
+func NewTerminalHandler(workspace string, users *identity.Manager) *TerminalHandler {
 	return &TerminalHandler{
 		workspace: workspace,
+		users:     users,
 		upgrader: &websocket.HertzUpgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -38,7 +42,19 @@ func (h *TerminalHandler) HandleWebSocket(ctx context.Context, c *app.RequestCon
 	err := h.upgrader.Upgrade(c, func(conn *websocket.Conn) {
 		defer conn.Close()
 
-		term, err := terminal.New("/bin/bash", h.workspace, nil)
+		user, err := h.users.Lookup(ctxutil.GetSessionIDFromCtx(ctx))
+		if err != nil {
+			log.Printf("Failed to resolve session user: %v", err)
+			conn.WriteJSON(map[string]string{"type": "error", "data": err.Error()})
+			return
+		}
+
+		workDir := h.workspace
+		if user != nil {
+			workDir = user.Home
+		}
+
+		term, err := terminal.New("/bin/bash", workDir, nil, terminal.WithUser(user))
 		if err != nil {
 			log.Printf("Failed to create terminal: %v", err)
 			conn.WriteJSON(map[string]string{"type": "error", "data": err.Error()})
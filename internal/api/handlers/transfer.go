@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+// Download streams a file. A single byte range in the Range header is
+// served as a partial response.
+func (h *FileHandler) Download(ctx context.Context, c *app.RequestContext) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "path is required",
+		})
+		return
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	f, err := manager.OpenFile(path)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, os.ErrNotExist) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, model.Response{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	size := info.Size()
+	start, end, partial, err := parseRange(string(c.GetHeader("Range")), size)
+	if err != nil {
+		f.Close()
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", size))
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, model.Response{
+			Code:    416,
+			Message: err.Error(),
+		})
+		return
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		f.Close()
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(path)}))
+	c.SetContentType("application/octet-stream")
+	if partial {
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		c.Status(http.StatusPartialContent)
+	}
+	length := end - start + 1
+	// The response closes the file once the body is written.
+	c.SetBodyStream(struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, int(length))
+}
+
+// parseRange returns the first and last byte of a "bytes=" Range header
+// within a file of size bytes. Without a header, or with several ranges, the
+// whole file is returned.
+func parseRange(header string, size int64) (start, end int64, partial bool, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, size - 1, false, nil
+	}
+
+	from, to, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, fmt.Errorf("invalid range %q", header)
+	}
+	if from == "" {
+		// A suffix range: the last n bytes.
+		n, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, fmt.Errorf("invalid range %q", header)
+		}
+		return max(size-n, 0), size - 1, true, nil
+	}
+
+	start, err = strconv.ParseInt(from, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, fmt.Errorf("invalid range %q", header)
+	}
+	if start >= size {
+		return 0, 0, false, fmt.Errorf("range %q starts beyond the file size %d", header, size)
+	}
+	end = size - 1
+	if to != "" {
+		if end, err = strconv.ParseInt(to, 10, 64); err != nil || end < start {
+			return 0, 0, false, fmt.Errorf("invalid range %q", header)
+		}
+		end = min(end, size-1)
+	}
+	return start, end, true, nil
+}
+
+// Upload streams the request body into a file. Large files can be uploaded
+// in chunks: each chunk but the last sets partial, and offset is the number
+// of bytes uploaded before it.
+func (h *FileHandler) Upload(ctx context.Context, c *app.RequestContext) {
+	path := c.Query("path")
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if path == "" || err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: path is required and offset must be a non-negative integer",
+		})
+		return
+	}
+	partial, _ := strconv.ParseBool(c.DefaultQuery("partial", "false"))
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	result, err := manager.Upload(path, requestBody(c), filesystem.UploadOptions{
+		Offset:  offset,
+		Partial: partial,
+		SHA256:  c.Query("sha256"),
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		var data interface{}
+		switch {
+		case errors.Is(err, filesystem.ErrUploadOffset):
+			status = http.StatusConflict
+			if uploaded, serr := manager.UploadStatus(path); serr == nil {
+				data = model.FileUploadStatus{Path: path, Offset: uploaded}
+			}
+		case errors.Is(err, filesystem.ErrChecksumMismatch):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, model.Response{
+			Code:    status,
+			Message: err.Error(),
+			Data:    data,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.FileUploadResult{
+			Path:     result.Path,
+			Size:     result.Size,
+			SHA256:   result.SHA256,
+			Complete: result.Complete,
+		},
+	})
+}
+
+// requestBody returns a reader for the request body, which middleware may
+// already have read into memory.
+func requestBody(c *app.RequestContext) io.Reader {
+	if c.Request.IsBodyStream() {
+		return c.RequestBodyStream()
+	}
+	return bytes.NewReader(c.Request.Body())
+}
+
+// UploadStatus reports how much of an interrupted upload was received, so it
+// can be resumed at that offset.
+func (h *FileHandler) UploadStatus(ctx context.Context, c *app.RequestContext) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "path is required",
+		})
+		return
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	offset, err := manager.UploadStatus(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: model.FileUploadStatus{Path: path, Offset: offset},
+	})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/protocol/http1/resp"
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/pkg/safe"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+// watchHeartbeat is how often an idle watch stream sends a comment, which
+// keeps proxies from closing it and notices clients that went away.
+const watchHeartbeat = 30 * time.Second
+
+// Watch streams the changes below a directory as server-sent events: "ready"
+// once the directory is watched, then "changes" with batches of
+// FileWatchEvent, and "error" when changes were lost.
+func (h *FileHandler) Watch(ctx context.Context, c *app.RequestContext) {
+	req := model.FileWatchRequest{Path: c.Query("path")}
+	debounce, err := strconv.Atoi(c.DefaultQuery("debounce_ms", "0"))
+	if req.Path == "" || err != nil || debounce < 0 {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: path is required and debounce_ms must be a non-negative integer",
+		})
+		return
+	}
+	req.DebounceMs = debounce
+	for _, v := range c.QueryArgs().PeekAll("include") {
+		req.Include = append(req.Include, string(v))
+	}
+	for _, v := range c.QueryArgs().PeekAll("exclude") {
+		req.Exclude = append(req.Exclude, string(v))
+	}
+
+	manager, ok := h.sessionManager(ctx, c)
+	if !ok {
+		return
+	}
+	watcher, err := manager.Watch(req.Path, watchOptions(&req))
+	if err != nil {
+		watchFailed(c, err)
+		return
+	}
+	defer watcher.Close()
+
+	c.SetStatusCode(consts.StatusOK)
+	c.Response.Header.Set("Content-Type", "text/event-stream")
+	c.Response.Header.Set("Cache-Control", "no-cache")
+	c.Response.Header.Set("Connection", "keep-alive")
+	c.Response.Header.Set("X-Accel-Buffering", "no")
+	// Sends every event as a chunk right away instead of once the handler
+	// returns.
+	c.Response.HijackWriter(resp.NewChunkedBodyWriter(&c.Response, c.GetWriter()))
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var writeMu sync.Mutex
+	write := func(p []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		c.Write(p)
+		if err := c.Flush(); err != nil {
+			// The client went away.
+			cancel()
+		}
+	}
+	sendEvent := func(event string, data interface{}) {
+		jsonData, _ := json.Marshal(StreamEvent{Event: event, Data: data})
+		write([]byte(fmt.Sprintf("data: %s\n\n", jsonData)))
+	}
+
+	sendEvent("ready", map[string]string{"path": watcher.Root()})
+	done := make(chan struct{})
+	safe.Go(func() {
+		defer close(done)
+		watcher.Run(watchCtx, func(events []filesystem.WatchEvent) {
+			sendEvent("changes", watchEvents(events))
+		}, func(err error) {
+			sendEvent("error", map[string]string{"message": err.Error()})
+		})
+	})
+
+	ticker := time.NewTicker(watchHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			write([]byte(": ping\n\n"))
+		}
+	}
+}
+
+func watchOptions(req *model.FileWatchRequest) filesystem.WatchOptions {
+	return filesystem.WatchOptions{
+		Include:  req.Include,
+		Exclude:  req.Exclude,
+		Debounce: time.Duration(req.DebounceMs) * time.Millisecond,
+	}
+}
+
+func watchEvents(events []filesystem.WatchEvent) []model.FileWatchEvent {
+	result := make([]model.FileWatchEvent, len(events))
+	for i, e := range events {
+		result[i] = model.FileWatchEvent{Type: e.Type, Path: e.Path, IsDir: e.IsDir}
+	}
+	return result
+}
+
+func watchFailed(c *app.RequestContext, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		status = http.StatusNotFound
+	case errors.Is(err, filesystem.ErrInvalidPattern), errors.Is(err, filesystem.ErrWatchLimit),
+		errors.Is(err, syscall.ENOTDIR):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, model.Response{
+		Code:    status,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/internal/services/snapshot"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+type WorkspaceHandler struct {
+	snapshots *snapshot.Store
+}
+
+func NewWorkspaceHandler(snapshots *snapshot.Store) *WorkspaceHandler {
+	return &WorkspaceHandler{snapshots: snapshots}
+}
+
+// enabled writes the error response itself when snapshots are disabled.
+func (h *WorkspaceHandler) enabled(c *app.RequestContext) bool {
+	if h.snapshots == nil {
+		c.JSON(http.StatusServiceUnavailable, model.Response{
+			Code:    503,
+			Message: "workspace snapshots are disabled",
+		})
+		return false
+	}
+	return true
+}
+
+func (h *WorkspaceHandler) CreateSnapshot(ctx context.Context, c *app.RequestContext) {
+	var req model.WorkspaceSnapshotRequest
+	if len(c.Request.Body()) > 0 {
+		if err := c.BindAndValidate(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    400,
+				Message: "invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+	if !h.enabled(c) {
+		return
+	}
+
+	snap, err := h.snapshots.Create(ctxutil.GetCwd(ctx), snapshot.CreateOptions{
+		SessionID: ctxutil.GetSessionIDFromCtx(ctx),
+		Label:     req.Label,
+		Exclude:   req.Exclude,
+	})
+	if err != nil {
+		snapshotFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: toWorkspaceSnapshot(snap),
+	})
+}
+
+func (h *WorkspaceHandler) ListSnapshots(ctx context.Context, c *app.RequestContext) {
+	if !h.enabled(c) {
+		return
+	}
+
+	snaps, err := h.snapshots.List(ctxutil.GetCwd(ctx))
+	if err != nil {
+		snapshotFailed(c, err)
+		return
+	}
+
+	result := model.WorkspaceSnapshotListResult{Snapshots: make([]model.WorkspaceSnapshot, 0, len(snaps))}
+	for i := range snaps {
+		result.Snapshots = append(result.Snapshots, toWorkspaceSnapshot(&snaps[i]))
+	}
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: result,
+	})
+}
+
+func (h *WorkspaceHandler) DiffSnapshots(ctx context.Context, c *app.RequestContext) {
+	var req model.WorkspaceSnapshotDiffRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+	if !h.enabled(c) {
+		return
+	}
+
+	changes, err := h.snapshots.Diff(ctxutil.GetCwd(ctx), req.From, req.To)
+	if err != nil {
+		snapshotFailed(c, err)
+		return
+	}
+
+	result := model.WorkspaceSnapshotDiffResult{Changes: make([]model.WorkspaceChange, 0, len(changes))}
+	for _, change := range changes {
+		result.Changes = append(result.Changes, model.WorkspaceChange{
+			Path:    change.Path,
+			Status:  change.Status,
+			Type:    change.Type,
+			OldSize: change.OldSize,
+			NewSize: change.NewSize,
+		})
+	}
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: result,
+	})
+}
+
+func (h *WorkspaceHandler) RestoreSnapshot(ctx context.Context, c *app.RequestContext) {
+	if !h.enabled(c) {
+		return
+	}
+
+	snap, err := h.snapshots.Restore(ctxutil.GetCwd(ctx), c.Param("id"))
+	if err != nil {
+		snapshotFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code: 0,
+		Data: toWorkspaceSnapshot(snap),
+	})
+}
+
+func (h *WorkspaceHandler) DeleteSnapshot(ctx context.Context, c *app.RequestContext) {
+	if !h.enabled(c) {
+		return
+	}
+
+	if err := h.snapshots.Delete(ctxutil.GetCwd(ctx), c.Param("id")); err != nil {
+		snapshotFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+func snapshotFailed(c *app.RequestContext, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, snapshot.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, snapshot.ErrTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	}
+	c.JSON(status, model.Response{
+		Code:    status,
+		Message: err.Error(),
+	})
+}
+
+func toWorkspaceSnapshot(snap *snapshot.Snapshot) model.WorkspaceSnapshot {
+	return model.WorkspaceSnapshot{
+		ID:            snap.ID,
+		Workspace:     snap.Workspace,
+		SessionID:     snap.SessionID,
+		Label:         snap.Label,
+		Exclude:       snap.Exclude,
+		CreatedAtUnix: snap.CreatedAt.Unix(),
+		Files:         snap.Files,
+		Size:          snap.Size,
+		StoredSize:    snap.StoredSize,
+	}
+}
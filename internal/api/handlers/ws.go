@@ -4,17 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/pkg/safe"
+	"github.com/deep-agent/sandbox/types/model"
 	"github.com/hertz-contrib/websocket"
 )
 
 type WSHandler struct {
 	upgrader *websocket.HertzUpgrader
+	files    *filesystem.Manager
 }
 
-func NewWSHandler() *WSHandler {
+func NewWSHandler(files *filesystem.Manager) *WSHandler {
 	return &WSHandler{
 		upgrader: &websocket.HertzUpgrader{
 			ReadBufferSize:  1024,
@@ -23,6 +29,7 @@ func NewWSHandler() *WSHandler {
 				return true
 			},
 		},
+		files: files,
 	}
 }
 
@@ -31,13 +38,58 @@ type WSMessage struct {
 	Data json.RawMessage `json:"data,omitempty"`
 }
 
+// wsWatch identifies a watch started by a "watch" message in the replies
+// about it.
+type wsWatch struct {
+	ID     string                 `json:"id"`
+	Path   string                 `json:"path,omitempty"`
+	Events []model.FileWatchEvent `json:"events,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// wsConn is the state of one connection. Watches write from their own
+// goroutines, so every write goes through send.
+type wsConn struct {
+	conn    *websocket.Conn
+	files   *filesystem.Manager
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	watches map[string]context.CancelFunc
+	nextID  int
+}
+
+func (s *wsConn) send(msgType string, data interface{}) error {
+	msg := WSMessage{Type: msgType}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		msg.Data = raw
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.conn.WriteJSON(msg)
+}
+
+// HandleWebSocket serves the general purpose WebSocket. Besides "ping" it
+// accepts {"type":"watch","data":<FileWatchRequest>}, answered with
+// "watching" and then "file_events" messages carrying the watch id, and
+// {"type":"unwatch","data":{"id":...}}. Watches end with the connection.
 func (h *WSHandler) HandleWebSocket(ctx context.Context, c *app.RequestContext) {
+	files, ok := scopeFiles(ctx, c, h.files)
+	if !ok {
+		return
+	}
+
 	err := h.upgrader.Upgrade(c, func(conn *websocket.Conn) {
 		defer conn.Close()
 
 		wsCtx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
+		s := &wsConn{conn: conn, files: files, watches: map[string]context.CancelFunc{}}
 		for {
 			select {
 			case <-wsCtx.Done():
@@ -55,11 +107,11 @@ func (h *WSHandler) HandleWebSocket(ctx context.Context, c *app.RequestContext)
 				var msg WSMessage
 				if err := json.Unmarshal(message, &msg); err != nil {
 					log.Printf("JSON unmarshal error: %v", err)
-					conn.WriteJSON(WSMessage{Type: "error", Data: json.RawMessage(`"invalid message format"`)})
+					s.send("error", "invalid message format")
 					continue
 				}
 
-				h.handleMessage(conn, &msg)
+				h.handleMessage(wsCtx, s, &msg)
 			}
 		}
 	})
@@ -70,11 +122,59 @@ func (h *WSHandler) HandleWebSocket(ctx context.Context, c *app.RequestContext)
 	}
 }
 
-func (h *WSHandler) handleMessage(conn *websocket.Conn, msg *WSMessage) {
+func (h *WSHandler) handleMessage(ctx context.Context, s *wsConn, msg *WSMessage) {
 	switch msg.Type {
 	case "ping":
-		conn.WriteJSON(WSMessage{Type: "pong"})
+		s.send("pong", nil)
+	case "watch":
+		h.watch(ctx, s, msg.Data)
+	case "unwatch":
+		var req wsWatch
+		if err := json.Unmarshal(msg.Data, &req); err != nil || req.ID == "" {
+			s.send("error", "invalid unwatch request")
+			return
+		}
+		s.mu.Lock()
+		stop, ok := s.watches[req.ID]
+		delete(s.watches, req.ID)
+		s.mu.Unlock()
+		if !ok {
+			s.send("error", "unknown watch id "+req.ID)
+			return
+		}
+		stop()
+		s.send("unwatched", wsWatch{ID: req.ID})
 	default:
-		conn.WriteJSON(WSMessage{Type: "error", Data: json.RawMessage(`"unknown message type"`)})
+		s.send("error", "unknown message type")
+	}
+}
+
+func (h *WSHandler) watch(ctx context.Context, s *wsConn, data json.RawMessage) {
+	var req model.FileWatchRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.Path == "" || req.DebounceMs < 0 {
+		s.send("error", "invalid watch request")
+		return
 	}
+	watcher, err := s.files.Watch(req.Path, watchOptions(&req))
+	if err != nil {
+		s.send("error", err.Error())
+		return
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.watches[id] = cancel
+	s.mu.Unlock()
+
+	s.send("watching", wsWatch{ID: id, Path: watcher.Root()})
+	safe.Go(func() {
+		defer watcher.Close()
+		watcher.Run(watchCtx, func(events []filesystem.WatchEvent) {
+			s.send("file_events", wsWatch{ID: id, Events: watchEvents(events)})
+		}, func(err error) {
+			s.send("watch_error", wsWatch{ID: id, Error: err.Error()})
+		})
+	})
 }
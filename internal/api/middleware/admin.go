@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/types/consts"
+)
+
+// Admin restricts a route to callers presenting token in the X-Admin-Token
+// header, which agents are not given. With an empty token the route is
+// disabled.
+func Admin(token string) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if token == "" {
+			c.JSON(http.StatusForbidden, map[string]interface{}{
+				"code":    403,
+				"message": "admin API is disabled, set SANDBOX_ADMIN_TOKEN to enable it",
+			})
+			c.Abort()
+			return
+		}
+		given := c.Request.Header.Peek(consts.HeaderAdminToken)
+		if subtle.ConstantTimeCompare(given, []byte(token)) != 1 {
+			c.JSON(http.StatusForbidden, map[string]interface{}{
+				"code":    403,
+				"message": "invalid admin token",
+			})
+			c.Abort()
+			return
+		}
+		c.Next(ctx)
+	}
+}
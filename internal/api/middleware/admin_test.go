@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/types/consts"
+)
+
+func TestAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{name: "disabled", token: "", header: "", status: 403},
+		{name: "missing", token: "secret", header: "", status: 403},
+		{name: "wrong", token: "secret", header: "guess", status: 403},
+		{name: "valid", token: "secret", header: "secret", status: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := app.NewContext(0)
+			if tt.header != "" {
+				c.Request.Header.Set(consts.HeaderAdminToken, tt.header)
+			}
+			Admin(tt.token)(context.Background(), c)
+			if got := c.Response.StatusCode(); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+			if aborted := c.IsAborted(); aborted != (tt.status != 200) {
+				t.Errorf("aborted = %v", aborted)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/deep-agent/sandbox/internal/services/audit"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Audit records every request that acts on the sandbox: all non-GET requests
+// and WebSocket upgrades. It must run after Auth so the JWT subject is known.
+func Audit(logger *audit.Logger) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		method := string(c.Request.Method())
+		if logger == nil || !(method != http.MethodGet || strings.HasSuffix(c.FullPath(), "/ws")) {
+			c.Next(ctx)
+			return
+		}
+
+		ctx, call := audit.Begin(ctx, logger, audit.Entry{
+			Source:    audit.SourceHTTP,
+			SessionID: ctxutil.GetSessionIDFromCtx(ctx),
+			Subject:   jwtSubject(c),
+			Tool:      method + " " + c.FullPath(),
+			Args:      requestArgs(c),
+		})
+
+		c.Next(ctx)
+
+		status, result, errMsg := responseSummary(c)
+		call.Finish(status, result, errMsg)
+	}
+}
+
+func jwtSubject(c *app.RequestContext) string {
+	value, ok := c.Get("claims")
+	if !ok {
+		return ""
+	}
+	claims, ok := value.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	subject, _ := claims.GetSubject()
+	return subject
+}
+
+// requestArgs merges the JSON body, path parameters and query arguments of a
+// request. Bodies that are not JSON objects are left out.
+func requestArgs(c *app.RequestContext) map[string]interface{} {
+	args := map[string]interface{}{}
+	if body := bufferedBody(c); len(body) > 0 {
+		json.Unmarshal(body, &args)
+	}
+	for _, param := range c.Params {
+		if _, ok := args[param.Key]; !ok {
+			args[param.Key] = param.Value
+		}
+	}
+	c.QueryArgs().VisitAll(func(key, value []byte) {
+		if _, ok := args[string(key)]; !ok {
+			args[string(key)] = string(value)
+		}
+	})
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+func responseSummary(c *app.RequestContext) (status, result, errMsg string) {
+	var resp struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	var body []byte
+	if !c.Response.IsBodyStream() {
+		body = c.Response.Body()
+	}
+	decoded := json.Unmarshal(body, &resp) == nil
+
+	if c.Response.StatusCode() >= http.StatusBadRequest || (decoded && resp.Code != 0) {
+		errMsg = resp.Message
+		if !decoded {
+			errMsg = string(body)
+		}
+		return audit.StatusError, "", errMsg
+	}
+	if decoded {
+		result = resp.Message
+		if len(resp.Data) > 0 && string(resp.Data) != "null" {
+			result = string(resp.Data)
+		}
+	}
+	return audit.StatusOK, result, ""
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// BodyLimit reads streamed request bodies into memory and rejects those
+// larger than the server buffers. The server streams every large body so
+// that uploads can be written to disk as they arrive, which would otherwise
+// leave the body of any other request unbounded. The routes in streamed read
+// their bodies themselves and are passed through.
+func BodyLimit(streamed ...string) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if !c.Request.IsBodyStream() || slices.Contains(streamed, c.FullPath()) {
+			c.Next(ctx)
+			return
+		}
+		if c.Request.Header.ContentLength() > maxBufferedBody {
+			bodyTooLarge(c)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(c.RequestBodyStream(), maxBufferedBody+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"code":    400,
+				"message": "failed to read request body: " + err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if len(body) > maxBufferedBody {
+			bodyTooLarge(c)
+			return
+		}
+		c.Request.SetBody(body)
+		c.Next(ctx)
+	}
+}
+
+func bodyTooLarge(c *app.RequestContext) {
+	c.JSON(http.StatusRequestEntityTooLarge, map[string]interface{}{
+		"code":    413,
+		"message": "request body too large",
+	})
+	c.Abort()
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func TestBodyLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		size     int
+		length   int
+		status   int
+		buffered bool
+	}{
+		{name: "small", path: "/v1/file/write", size: 10, length: -1, status: 200, buffered: true},
+		{name: "chunked too large", path: "/v1/file/write", size: maxBufferedBody + 1, length: -1, status: 413},
+		{name: "declared too large", path: "/v1/file/write", size: 10, length: maxBufferedBody + 1, status: 413},
+		{name: "streamed route", path: "/v1/file/upload", size: maxBufferedBody + 1, length: -1, status: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := app.NewContext(0)
+			c.SetFullPath(tt.path)
+			body := bytes.Repeat([]byte("x"), tt.size)
+			c.Request.SetBodyStream(bytes.NewReader(body), -1)
+			c.Request.Header.SetContentLength(tt.length)
+
+			BodyLimit("/v1/file/upload")(context.Background(), c)
+			if got := c.Response.StatusCode(); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+			if tt.buffered && (c.Request.IsBodyStream() || !bytes.Equal(c.Request.Body(), body)) {
+				t.Errorf("body was not buffered")
+			}
+		})
+	}
+}
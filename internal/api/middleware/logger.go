@@ -15,7 +15,7 @@ func Logger() app.HandlerFunc {
 		path := string(c.Request.URI().Path())
 		method := string(c.Request.Method())
 		query := string(c.Request.URI().QueryString())
-		body := c.Request.Body()
+		body := bufferedBody(c)
 		sessionID := string(c.Request.Header.Peek(consts.HeaderSessionID))
 
 		log.Printf("[REQ][SessionID:%s] %s %s query=%s body=%s", sessionID, method, path, query, truncate(string(body), 1024))
@@ -24,13 +24,29 @@ func Logger() app.HandlerFunc {
 
 		latency := time.Since(start)
 		status := c.Response.StatusCode()
-		respBody := c.Response.Body()
+		var respBody []byte
+		if !c.Response.IsBodyStream() {
+			respBody = c.Response.Body()
+		}
 
 		log.Printf("[RESP][SessionID:%s] %s %s status=%d latency=%v body=%s", sessionID, method, path, status, latency, truncate(string(respBody), 1024))
 		log.Printf("================================\n")
 	}
 }
 
+// maxBufferedBody is the size up to which the server reads request bodies
+// into memory; larger ones are streamed to the handler.
+const maxBufferedBody = 4 << 20
+
+// bufferedBody returns the request body unless it is streamed. Reading a
+// streamed body here would load all of it into memory.
+func bufferedBody(c *app.RequestContext) []byte {
+	if n := c.Request.Header.ContentLength(); n < 0 || n > maxBufferedBody {
+		return nil
+	}
+	return c.Request.Body()
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
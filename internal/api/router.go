@@ -2,16 +2,21 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/app/server"
 	"github.com/deep-agent/sandbox/internal/api/handlers"
 	"github.com/deep-agent/sandbox/internal/api/middleware"
 	"github.com/deep-agent/sandbox/internal/config"
+	"github.com/deep-agent/sandbox/internal/services/audit"
 	"github.com/deep-agent/sandbox/internal/services/bash"
 	"github.com/deep-agent/sandbox/internal/services/browser"
 	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/internal/services/identity"
+	"github.com/deep-agent/sandbox/internal/services/snapshot"
 	"github.com/deep-agent/sandbox/internal/services/web"
 	"github.com/hertz-contrib/cors"
 )
@@ -20,40 +25,157 @@ type Router struct {
 	server          *server.Hertz
 	cfg             *config.Config
 	terminalHandler *handlers.TerminalHandler
+	users           *identity.Manager
+	bashSessions    *bash.SessionManager
+	bashJobs        *bash.JobManager
+	bashGuard       *bash.CommandGuard
+	browser         *browser.Controller
+	audit           *audit.Logger
+	snapshots       *snapshot.Store
+	fileHistory     *filesystem.History
 }
 
 func NewRouter(cfg *config.Config) *Router {
+	// Request bodies larger than the default limit are streamed, which
+	// lets /v1/file/upload write them to disk as they arrive. Other routes
+	// are limited by middleware.BodyLimit.
 	h := server.Default(server.WithHostPorts(
-		fmt.Sprintf(":%d", cfg.SandboxServerPort)))
+		fmt.Sprintf(":%d", cfg.SandboxServerPort)),
+		server.WithStreamBody(true))
 
+	envPolicy := bash.WithEnvPolicy(bash.NewEnvPolicy(cfg.BashEnvAllow, cfg.BashEnvDeny))
+	timeouts := bash.WithTimeouts(cfg.BashDefaultTimeout, cfg.BashMaxTimeout)
+	limits := bash.WithLimits(bash.Limits{
+		CPUTime:   cfg.BashLimitCPU,
+		Memory:    cfg.BashLimitMemory,
+		Processes: cfg.BashLimitProcesses,
+		FileSize:  cfg.BashLimitFileSize,
+		Output:    cfg.BashLimitOutput,
+		CPUQuota:  cfg.BashLimitCPUQuota,
+	})
+	policy, err := bash.LoadCommandPolicy(cfg.BashPolicyFile)
+	if err != nil {
+		log.Fatalf("failed to load bash command policy: %v", err)
+	}
+	guard := bash.NewCommandGuard(policy, cfg.BashApprovalTimeout)
+	var users *identity.Manager
+	if cfg.SessionUsers {
+		users = identity.NewManager(cfg.Workspace, uint32(cfg.SessionUIDMin), uint32(cfg.SessionUIDMax))
+	}
+	browserController := browser.NewController(fmt.Sprintf("ws://localhost:%d", cfg.BrowserCDPPort),
+		browser.WithContextLimits(cfg.BrowserMaxContexts, cfg.BrowserContextIdle))
+	sessionOpts := []bash.Option{envPolicy, timeouts, limits, bash.WithCommandGuard(guard), bash.WithUsers(users),
+		bash.WithSessionLimits(cfg.BashMaxSessions, cfg.BashSessionIdle),
+		bash.WithSessionClosed(closeBrowserContext(browserController))}
+	jobOpts := []bash.Option{envPolicy, timeouts, limits, bash.WithCommandGuard(guard), bash.WithUsers(users)}
+	if cgroups, err := bash.NewCgroupManager(cfg.BashCgroupRoot); err != nil {
+		if cfg.BashLimitMemory > 0 || cfg.BashLimitProcesses > 0 || cfg.BashLimitCPUQuota > 0 {
+			log.Fatalf("bash memory, process and CPU quota limits need cgroup v2, see SANDBOX_CGROUP_ROOT: %v", err)
+		}
+		log.Printf("bash cgroup limits disabled: %v", err)
+	} else {
+		sessionOpts = append(sessionOpts, bash.WithCgroups(cgroups))
+		jobOpts = append(jobOpts, bash.WithCgroups(cgroups))
+	}
+	sessions := bash.NewSessionManager(sessionOpts...)
 	return &Router{
 		server:          h,
 		cfg:             cfg,
-		terminalHandler: handlers.NewTerminalHandler(cfg.Workspace),
+		terminalHandler: handlers.NewTerminalHandler(cfg.Workspace, users),
+		users:           users,
+		bashSessions:    sessions,
+		bashJobs:        bash.NewJobManager(jobOpts...),
+		bashGuard:       guard,
+		browser:         browserController,
+		audit:           newAuditLogger(cfg),
+		snapshots:       newSnapshotStore(cfg, sessions),
+		fileHistory:     newFileHistory(cfg),
 	}
 }
 
+// closeBrowserContext returns a callback closing the browser context of a bash
+// session that ended.
+func closeBrowserContext(controller *browser.Controller) func(id string) {
+	return func(id string) {
+		if err := controller.CloseContext(id); err != nil && !errors.Is(err, browser.ErrContextNotFound) {
+			log.Printf("session %s: %v", id, err)
+		}
+	}
+}
+
+func newAuditLogger(cfg *config.Config) *audit.Logger {
+	if !cfg.AuditEnabled {
+		return nil
+	}
+	logger, err := audit.New(cfg.AuditLog, audit.Options{
+		MaxSize:    cfg.AuditMaxSize,
+		MaxBackups: cfg.AuditMaxBackups,
+		Redact:     cfg.AuditRedact,
+	})
+	if err != nil {
+		log.Fatalf("failed to open audit log, set SANDBOX_AUDIT_ENABLED=false to disable it: %v", err)
+	}
+	return logger
+}
+
+func newSnapshotStore(cfg *config.Config, sessions *bash.SessionManager) *snapshot.Store {
+	store, err := snapshot.NewStore(cfg.SnapshotDir, snapshot.Options{
+		Workspace: cfg.Workspace,
+		MaxSize:   cfg.SnapshotMaxSize,
+		MaxCount:  cfg.SnapshotMaxCount,
+		Restored:  sessions.Rechdir,
+	})
+	if err != nil {
+		log.Fatalf("failed to open workspace snapshot store, check SANDBOX_SNAPSHOT_DIR: %v", err)
+	}
+	return store
+}
+
+func newFileHistory(cfg *config.Config) *filesystem.History {
+	if !cfg.FileHistory {
+		return nil
+	}
+	history, err := filesystem.NewHistory(cfg.FileHistoryDir, filesystem.HistoryOptions{
+		MaxEntries: cfg.FileHistoryMaxEntries,
+		MaxSize:    cfg.FileHistoryMaxSize,
+	})
+	if err != nil {
+		log.Fatalf("failed to open file history, set SANDBOX_FILE_HISTORY=false to disable it: %v", err)
+	}
+	return history
+}
+
 func (r *Router) Setup() {
-	bashExecutor := bash.NewExecutor()
-	fileManager := filesystem.NewManager()
-	browserController := browser.NewController(fmt.Sprintf("ws://localhost:%d", r.cfg.BrowserCDPPort))
+	fileOpts := []filesystem.Option{
+		filesystem.WithUsers(r.users),
+		filesystem.WithExtractLimits(r.cfg.FileExtractMaxSize, r.cfg.FileExtractMaxEntries),
+	}
+	if r.cfg.FileConfine {
+		fileOpts = append(fileOpts, filesystem.WithConfinement(r.cfg.Workspace, r.cfg.FileReadOnlyRoots))
+	}
+	if r.fileHistory != nil {
+		fileOpts = append(fileOpts, filesystem.WithHistory(r.fileHistory))
+	}
+	fileManager := filesystem.NewManager(fileOpts...)
 	webFetcher := web.NewFetcher()
 	webSearcher := web.NewSearcher()
 
 	sandboxHandler := handlers.NewSandboxHandler(r.cfg)
-	bashHandler := handlers.NewBashHandler(bashExecutor)
+	bashHandler := handlers.NewBashHandler(r.bashSessions, r.bashJobs, r.bashGuard)
 	fileHandler := handlers.NewFileHandler(fileManager)
 	grepHandler := handlers.NewGrepHandler(fileManager)
-	browserHandler := handlers.NewBrowserHandler(browserController)
+	browserHandler := handlers.NewBrowserHandler(r.browser)
 	webHandler := handlers.NewWebHandler(webFetcher, webSearcher)
 	swaggerHandler := handlers.NewSwaggerHandler()
-	wsHandler := handlers.NewWSHandler()
+	wsHandler := handlers.NewWSHandler(fileManager)
+	auditHandler := handlers.NewAuditHandler(r.audit)
+	workspaceHandler := handlers.NewWorkspaceHandler(r.snapshots)
 
 	r.server.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Content-Type", "Authorization", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Content-Type", "Authorization", "X-Requested-With", "Range"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Accept-Ranges", "Content-Disposition"},
 		AllowCredentials: false,
 		MaxAge:           24 * time.Hour,
 	}))
@@ -65,26 +187,69 @@ func (r *Router) Setup() {
 
 	v1 := r.server.Group("/v1")
 	v1.GET("/openapi.json", swaggerHandler.OpenAPISpec)
+	v1.Use(middleware.BodyLimit("/v1/file/upload", "/v1/file/extract"))
 	v1.Use(middleware.Auth())
+	v1.Use(middleware.Audit(r.audit))
+	admin := middleware.Admin(r.cfg.AdminToken)
 	{
 		v1.GET("/sandbox", sandboxHandler.GetContext)
+		v1.GET("/audit", auditHandler.Query)
 
 		bashGroup := v1.Group("/bash")
 		{
 			bashGroup.POST("/exec", bashHandler.ExecCommand)
 			bashGroup.POST("/exec/stream", bashHandler.ExecCommandStream)
+			bashGroup.GET("/exec/ws", bashHandler.ExecCommandWS)
+			bashGroup.GET("/sessions", bashHandler.ListSessions)
+			bashGroup.POST("/sessions/:id/reset", bashHandler.ResetSession)
+			bashGroup.DELETE("/sessions/:id", bashHandler.DestroySession)
+			bashGroup.GET("/sessions/:id/env", bashHandler.GetSessionEnv)
+			bashGroup.PUT("/sessions/:id/env", bashHandler.SetSessionEnv)
+			bashGroup.GET("/sessions/:id/policy", bashHandler.GetSessionPolicy)
+			bashGroup.PUT("/sessions/:id/policy", admin, bashHandler.SetSessionPolicy)
+			bashGroup.DELETE("/sessions/:id/policy", admin, bashHandler.DeleteSessionPolicy)
+			bashGroup.GET("/policy", bashHandler.GetPolicy)
+			bashGroup.POST("/policy/check", bashHandler.CheckPolicy)
+			bashGroup.GET("/approvals", bashHandler.ListApprovals)
+			bashGroup.POST("/approvals/:id/approve", admin, bashHandler.ApproveCommand)
+			bashGroup.POST("/approvals/:id/reject", admin, bashHandler.RejectCommand)
+			bashGroup.GET("/jobs", bashHandler.ListJobs)
+			bashGroup.GET("/jobs/:id", bashHandler.GetJob)
+			bashGroup.GET("/jobs/:id/output", bashHandler.GetJobOutput)
+			bashGroup.POST("/jobs/:id/kill", bashHandler.KillJob)
 		}
 
 		fileGroup := v1.Group("/file")
 		{
 			fileGroup.POST("/read", fileHandler.ReadFile)
 			fileGroup.POST("/write", fileHandler.WriteFile)
+			fileGroup.GET("/download", fileHandler.Download)
+			fileGroup.PUT("/upload", fileHandler.Upload)
+			fileGroup.GET("/upload", fileHandler.UploadStatus)
+			fileGroup.POST("/archive", fileHandler.Archive)
+			fileGroup.POST("/extract", fileHandler.Extract)
+			fileGroup.POST("/edit", fileHandler.EditFile)
+			fileGroup.POST("/multi_edit", fileHandler.MultiEdit)
+			fileGroup.POST("/patch", fileHandler.ApplyPatch)
 			fileGroup.POST("/list", fileHandler.ListDir)
+			fileGroup.POST("/tree", fileHandler.Tree)
 			fileGroup.POST("/delete", fileHandler.DeleteFile)
 			fileGroup.POST("/move", fileHandler.MoveFile)
 			fileGroup.POST("/copy", fileHandler.CopyFile)
 			fileGroup.POST("/mkdir", fileHandler.MkDir)
 			fileGroup.GET("/exists", fileHandler.Exists)
+			fileGroup.GET("/watch", fileHandler.Watch)
+			fileGroup.GET("/history", fileHandler.History)
+			fileGroup.POST("/undo", fileHandler.Undo)
+		}
+
+		workspaceGroup := v1.Group("/workspace")
+		{
+			workspaceGroup.POST("/snapshot", workspaceHandler.CreateSnapshot)
+			workspaceGroup.GET("/snapshots", workspaceHandler.ListSnapshots)
+			workspaceGroup.POST("/snapshots/diff", workspaceHandler.DiffSnapshots)
+			workspaceGroup.POST("/snapshots/:id/restore", workspaceHandler.RestoreSnapshot)
+			workspaceGroup.DELETE("/snapshots/:id", workspaceHandler.DeleteSnapshot)
 		}
 
 		grepGroup := v1.Group("/grep")
@@ -99,6 +264,18 @@ func (r *Router) Setup() {
 			browserGroup.POST("/screenshot", browserHandler.Screenshot)
 			browserGroup.POST("/click", browserHandler.Click)
 			browserGroup.POST("/type", browserHandler.Type)
+			browserGroup.POST("/hover", browserHandler.Hover)
+			browserGroup.POST("/select", browserHandler.Select)
+			browserGroup.POST("/snapshot", browserHandler.Snapshot)
+			browserGroup.POST("/mouse/move", browserHandler.MouseMove)
+			browserGroup.POST("/mouse/click", browserHandler.MouseClick)
+			browserGroup.POST("/mouse/drag", browserHandler.MouseDrag)
+			browserGroup.POST("/mouse/wheel", browserHandler.MouseWheel)
+			browserGroup.POST("/keyboard/press", browserHandler.PressKey)
+			browserGroup.POST("/keyboard/insert", browserHandler.InsertText)
+			browserGroup.GET("/console", browserHandler.ConsoleMessages)
+			browserGroup.GET("/network", browserHandler.NetworkRequests)
+			browserGroup.GET("/network/har", browserHandler.NetworkHAR)
 			browserGroup.POST("/evaluate", browserHandler.Evaluate)
 			browserGroup.GET("/url", browserHandler.GetCurrentURL)
 			browserGroup.GET("/title", browserHandler.GetTitle)
@@ -107,6 +284,12 @@ func (r *Router) Setup() {
 			browserGroup.POST("/wait", browserHandler.WaitVisible)
 			browserGroup.GET("/page", browserHandler.GetPageInfo)
 			browserGroup.POST("/pdf", browserHandler.PDF)
+			browserGroup.GET("/tabs", browserHandler.ListTabs)
+			browserGroup.POST("/tabs", browserHandler.OpenTab)
+			browserGroup.DELETE("/tabs/:id", browserHandler.CloseTab)
+			browserGroup.POST("/tabs/:id/activate", browserHandler.ActivateTab)
+			browserGroup.GET("/contexts", browserHandler.ListContexts)
+			browserGroup.DELETE("/contexts/:id", browserHandler.CloseContext)
 		}
 
 		webGroup := v1.Group("/web")
@@ -125,5 +308,11 @@ func (r *Router) Run() error {
 }
 
 func (r *Router) Shutdown(ctx context.Context) error {
-	return r.server.Shutdown(ctx)
+	r.bashSessions.CloseAll()
+	r.bashJobs.KillAll()
+	err := r.server.Shutdown(ctx)
+	r.audit.Close()
+	r.snapshots.Close()
+	r.fileHistory.Close()
+	return err
 }
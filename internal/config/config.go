@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -12,6 +14,95 @@ type Config struct {
 	WebSocketPort     int
 	BrowserCDPPort    int
 	Workspace         string
+
+	// BrowserMaxContexts caps the sessions with a browser context of their
+	// own, zero meaning unlimited; a context unused for BrowserContextIdle is
+	// disposed.
+	BrowserMaxContexts int
+	BrowserContextIdle time.Duration
+
+	// BashEnvAllow and BashEnvDeny are glob patterns of environment variable
+	// names that callers may or may not inject into bash commands.
+	BashEnvAllow []string
+	BashEnvDeny  []string
+
+	// BashDefaultTimeout applies to foreground bash commands that do not
+	// request a timeout; requested timeouts are capped at BashMaxTimeout.
+	BashDefaultTimeout time.Duration
+	BashMaxTimeout     time.Duration
+
+	// BashMaxSessions caps the persistent shell sessions, zero meaning
+	// unlimited; a session running no command for BashSessionIdle is closed.
+	BashMaxSessions int
+	BashSessionIdle time.Duration
+
+	// The BashLimit fields are the default resource limits of every bash session and
+	// command; zero means unlimited. Requests may only tighten them.
+	BashLimitCPU       time.Duration
+	BashLimitMemory    int64
+	BashLimitProcesses int
+	BashLimitFileSize  int64
+	BashLimitOutput    int64
+	BashLimitCPUQuota  float64
+
+	// BashCgroupRoot is the cgroup v2 directory under which per-session
+	// cgroups are created. Empty uses the server's own cgroup, moving the
+	// processes in it to a leaf cgroup, which needs write access to it.
+	BashCgroupRoot string
+
+	// BashPolicyFile is a JSON command policy replacing the built-in rules.
+	// Commands held for approval are denied after BashApprovalTimeout.
+	BashPolicyFile      string
+	BashApprovalTimeout time.Duration
+
+	// AdminToken authorizes changing session command policies and resolving
+	// approvals through the X-Admin-Token header. Empty disables those
+	// endpoints; it is never passed on to commands.
+	AdminToken string
+
+	// SessionUsers runs each session as a dedicated unprivileged user with
+	// IDs allocated from [SessionUIDMin, SessionUIDMax].
+	SessionUsers  bool
+	SessionUIDMin int
+	SessionUIDMax int
+
+	// FileConfine restricts the file API and file tools to the session
+	// workspace; FileReadOnlyRoots may additionally be read.
+	FileConfine       bool
+	FileReadOnlyRoots []string
+
+	// AuditLog is the JSONL audit trail shared by the server and the MCP hub.
+	// It is rotated at AuditMaxSize, keeping AuditMaxBackups old files;
+	// arguments matching AuditRedact are redacted in addition to the defaults.
+	AuditEnabled    bool
+	AuditLog        string
+	AuditMaxSize    int64
+	AuditMaxBackups int
+	AuditRedact     []string
+
+	// SnapshotDir stores workspace snapshots. A snapshot may hold at most
+	// SnapshotMaxSize bytes of files, and only the newest SnapshotMaxCount
+	// snapshots of a workspace are kept; zero means unlimited.
+	SnapshotDir      string
+	SnapshotMaxSize  int64
+	SnapshotMaxCount int
+
+	// FileHistoryDir journals the file changes of each session so they can
+	// be undone. The newest FileHistoryMaxEntries changes are kept, and a
+	// change replacing more than FileHistoryMaxSize bytes cannot be undone.
+	FileHistory           bool
+	FileHistoryDir        string
+	FileHistoryMaxEntries int
+	FileHistoryMaxSize    int64
+
+	// FileRequireRead makes the MCP Write and Edit tools refuse to change a
+	// file the session has not read, or that changed since it was read.
+	FileRequireRead bool
+
+	// FileExtractMaxSize and FileExtractMaxEntries bound the archives
+	// /v1/file/extract unpacks.
+	FileExtractMaxSize    int64
+	FileExtractMaxEntries int
 }
 
 func Load() *Config {
@@ -21,12 +112,50 @@ func Load() *Config {
 	}
 
 	return &Config{
-		SandboxServerPort: getEnvInt("SANDBOX_SRV_PORT", 8000),
-		MCPHubPort:        getEnvInt("MCP_HUB_PORT", 8001),
-		VNCServerPort:     getEnvInt("VNC_SERVER_PORT", 5900),
-		WebSocketPort:     getEnvInt("WEBSOCKET_PROXY_PORT", 6080),
-		BrowserCDPPort:    getEnvInt("BROWSER_REMOTE_DEBUGGING_PORT", 9222),
-		Workspace:         workspace,
+		SandboxServerPort:     getEnvInt("SANDBOX_SRV_PORT", 8000),
+		MCPHubPort:            getEnvInt("MCP_HUB_PORT", 8001),
+		VNCServerPort:         getEnvInt("VNC_SERVER_PORT", 5900),
+		WebSocketPort:         getEnvInt("WEBSOCKET_PROXY_PORT", 6080),
+		BrowserCDPPort:        getEnvInt("BROWSER_REMOTE_DEBUGGING_PORT", 9222),
+		Workspace:             workspace,
+		BrowserMaxContexts:    getEnvInt("SANDBOX_BROWSER_MAX_CONTEXTS", 10),
+		BrowserContextIdle:    time.Duration(getEnvInt("SANDBOX_BROWSER_CONTEXT_IDLE_MS", 1800000)) * time.Millisecond,
+		BashEnvAllow:          getEnvList("SANDBOX_ENV_ALLOW"),
+		BashEnvDeny:           getEnvList("SANDBOX_ENV_DENY"),
+		BashDefaultTimeout:    time.Duration(getEnvInt("SANDBOX_BASH_TIMEOUT_MS", 30000)) * time.Millisecond,
+		BashMaxTimeout:        time.Duration(getEnvInt("SANDBOX_BASH_MAX_TIMEOUT_MS", 600000)) * time.Millisecond,
+		BashMaxSessions:       getEnvInt("SANDBOX_BASH_MAX_SESSIONS", 64),
+		BashSessionIdle:       time.Duration(getEnvInt("SANDBOX_BASH_SESSION_IDLE_MS", 1800000)) * time.Millisecond,
+		BashLimitCPU:          time.Duration(getEnvInt("SANDBOX_LIMIT_CPU_SECONDS", 0)) * time.Second,
+		BashLimitMemory:       int64(getEnvInt("SANDBOX_LIMIT_MEMORY_MB", 0)) << 20,
+		BashLimitProcesses:    getEnvInt("SANDBOX_LIMIT_PROCESSES", 0),
+		BashLimitFileSize:     int64(getEnvInt("SANDBOX_LIMIT_FILE_SIZE_MB", 0)) << 20,
+		BashLimitOutput:       int64(getEnvInt("SANDBOX_LIMIT_OUTPUT_MB", 16)) << 20,
+		BashLimitCPUQuota:     getEnvFloat("SANDBOX_LIMIT_CPU_QUOTA", 0),
+		BashCgroupRoot:        getEnv("SANDBOX_CGROUP_ROOT", ""),
+		BashPolicyFile:        getEnv("SANDBOX_BASH_POLICY_FILE", ""),
+		BashApprovalTimeout:   time.Duration(getEnvInt("SANDBOX_BASH_APPROVAL_TIMEOUT_MS", 300000)) * time.Millisecond,
+		AdminToken:            getEnv("SANDBOX_ADMIN_TOKEN", ""),
+		SessionUsers:          getEnvBool("SANDBOX_SESSION_USERS", false),
+		SessionUIDMin:         getEnvInt("SANDBOX_SESSION_UID_MIN", 20000),
+		SessionUIDMax:         getEnvInt("SANDBOX_SESSION_UID_MAX", 29999),
+		FileConfine:           getEnvBool("SANDBOX_FS_CONFINE", true),
+		FileReadOnlyRoots:     getEnvList("SANDBOX_FS_READONLY_ROOTS"),
+		AuditEnabled:          getEnvBool("SANDBOX_AUDIT_ENABLED", true),
+		AuditLog:              getEnv("SANDBOX_AUDIT_LOG", "/var/log/sandbox/audit.jsonl"),
+		AuditMaxSize:          int64(getEnvInt("SANDBOX_AUDIT_MAX_SIZE_MB", 100)) << 20,
+		AuditMaxBackups:       getEnvInt("SANDBOX_AUDIT_MAX_BACKUPS", 5),
+		AuditRedact:           getEnvList("SANDBOX_AUDIT_REDACT"),
+		SnapshotDir:           getEnv("SANDBOX_SNAPSHOT_DIR", "/var/lib/sandbox/snapshots"),
+		SnapshotMaxSize:       int64(getEnvInt("SANDBOX_SNAPSHOT_MAX_SIZE_MB", 1024)) << 20,
+		SnapshotMaxCount:      getEnvInt("SANDBOX_SNAPSHOT_MAX_COUNT", 20),
+		FileHistory:           getEnvBool("SANDBOX_FILE_HISTORY", true),
+		FileHistoryDir:        getEnv("SANDBOX_FILE_HISTORY_DIR", "/var/lib/sandbox/history"),
+		FileHistoryMaxEntries: getEnvInt("SANDBOX_FILE_HISTORY_MAX_ENTRIES", 100),
+		FileHistoryMaxSize:    int64(getEnvInt("SANDBOX_FILE_HISTORY_MAX_SIZE_MB", 10)) << 20,
+		FileRequireRead:       getEnvBool("SANDBOX_FILE_REQUIRE_READ", false),
+		FileExtractMaxSize:    int64(getEnvInt("SANDBOX_FILE_EXTRACT_MAX_SIZE_MB", 1024)) << 20,
+		FileExtractMaxEntries: getEnvInt("SANDBOX_FILE_EXTRACT_MAX_ENTRIES", 100000),
 	}
 }
 
@@ -45,3 +174,31 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -1,46 +1,193 @@
 package mcp
 
 import (
+	"errors"
+	"log"
+	"time"
+
 	"github.com/deep-agent/sandbox/internal/mcp/tools"
+	"github.com/deep-agent/sandbox/internal/services/bash"
+	"github.com/deep-agent/sandbox/internal/services/browser"
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/internal/services/identity"
+	"github.com/deep-agent/sandbox/internal/services/snapshot"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
 type ToolConfig struct {
-	CDPURL string
+	CDPURL       string
+	BashEnvAllow []string
+	BashEnvDeny  []string
+
+	BrowserMaxContexts int
+	BrowserContextIdle time.Duration
+
+	BashDefaultTimeout time.Duration
+	BashMaxTimeout     time.Duration
+
+	BashMaxSessions int
+	BashSessionIdle time.Duration
+
+	BashLimits     bash.Limits
+	BashCgroupRoot string
+
+	// BashPolicyFile replaces the built-in command policy. The MCP hub has no
+	// approval endpoint, so commands matching ask rules are denied.
+	BashPolicyFile string
+
+	// Workspace and the UID range are used when SessionUsers is set.
+	Workspace     string
+	SessionUsers  bool
+	SessionUIDMin uint32
+	SessionUIDMax uint32
+
+	FileConfine       bool
+	FileReadOnlyRoots []string
+
+	SnapshotDir      string
+	SnapshotMaxSize  int64
+	SnapshotMaxCount int
+
+	FileHistory           bool
+	FileHistoryDir        string
+	FileHistoryMaxEntries int
+	FileHistoryMaxSize    int64
+
+	FileRequireRead bool
 }
 
 type Registry struct {
-	config ToolConfig
+	config       ToolConfig
+	files        *filesystem.Manager
+	bashSessions *bash.SessionManager
+	bashJobs     *bash.JobManager
+	snapshots    *snapshot.Store
+	history      *filesystem.History
+	browser      *browser.Controller
 }
 
 func NewRegistry(cfg ToolConfig) *Registry {
+	envPolicy := bash.WithEnvPolicy(bash.NewEnvPolicy(cfg.BashEnvAllow, cfg.BashEnvDeny))
+	timeouts := bash.WithTimeouts(cfg.BashDefaultTimeout, cfg.BashMaxTimeout)
+	limits := bash.WithLimits(cfg.BashLimits)
+	policy, err := bash.LoadCommandPolicy(cfg.BashPolicyFile)
+	if err != nil {
+		log.Fatalf("failed to load bash command policy: %v", err)
+	}
+	guard := bash.WithCommandGuard(bash.NewCommandGuard(policy, 0))
+	var users *identity.Manager
+	if cfg.SessionUsers {
+		users = identity.NewManager(cfg.Workspace, cfg.SessionUIDMin, cfg.SessionUIDMax)
+	}
+	browserController := browser.NewController(cfg.CDPURL, browser.WithContextLimits(cfg.BrowserMaxContexts, cfg.BrowserContextIdle))
+	sessionOpts := []bash.Option{envPolicy, timeouts, limits, guard, bash.WithUsers(users),
+		bash.WithSessionLimits(cfg.BashMaxSessions, cfg.BashSessionIdle),
+		bash.WithSessionClosed(func(id string) {
+			if err := browserController.CloseContext(id); err != nil && !errors.Is(err, browser.ErrContextNotFound) {
+				log.Printf("session %s: %v", id, err)
+			}
+		})}
+	jobOpts := []bash.Option{envPolicy, timeouts, limits, guard, bash.WithUsers(users)}
+	if cgroups, err := bash.NewCgroupManager(cfg.BashCgroupRoot); err != nil {
+		if cfg.BashLimits.Memory > 0 || cfg.BashLimits.Processes > 0 || cfg.BashLimits.CPUQuota > 0 {
+			log.Fatalf("bash memory, process and CPU quota limits need cgroup v2, see SANDBOX_CGROUP_ROOT: %v", err)
+		}
+		log.Printf("bash cgroup limits disabled: %v", err)
+	} else {
+		sessionOpts = append(sessionOpts, bash.WithCgroups(cgroups))
+		jobOpts = append(jobOpts, bash.WithCgroups(cgroups))
+	}
+	fileOpts := []filesystem.Option{filesystem.WithUsers(users)}
+	if cfg.FileConfine {
+		fileOpts = append(fileOpts, filesystem.WithConfinement(cfg.Workspace, cfg.FileReadOnlyRoots))
+	}
+	if cfg.FileRequireRead {
+		fileOpts = append(fileOpts, filesystem.WithReadTracker(filesystem.NewReadTracker()))
+	}
+	var history *filesystem.History
+	if cfg.FileHistory {
+		if history, err = filesystem.NewHistory(cfg.FileHistoryDir, filesystem.HistoryOptions{
+			MaxEntries: cfg.FileHistoryMaxEntries,
+			MaxSize:    cfg.FileHistoryMaxSize,
+		}); err != nil {
+			log.Fatalf("failed to open file history, set SANDBOX_FILE_HISTORY=false to disable it: %v", err)
+		}
+		fileOpts = append(fileOpts, filesystem.WithHistory(history))
+	}
+	sessions := bash.NewSessionManager(sessionOpts...)
+	snapshots, err := snapshot.NewStore(cfg.SnapshotDir, snapshot.Options{
+		Workspace: cfg.Workspace,
+		MaxSize:   cfg.SnapshotMaxSize,
+		MaxCount:  cfg.SnapshotMaxCount,
+		Restored:  sessions.Rechdir,
+	})
+	if err != nil {
+		log.Fatalf("failed to open workspace snapshot store, check SANDBOX_SNAPSHOT_DIR: %v", err)
+	}
 	return &Registry{
-		config: cfg,
+		config:       cfg,
+		files:        filesystem.NewManager(fileOpts...),
+		bashSessions: sessions,
+		bashJobs:     bash.NewJobManager(jobOpts...),
+		snapshots:    snapshots,
+		history:      history,
+		browser:      browserController,
 	}
 }
 
 func (r *Registry) RegisterAll(addTool func(tool mcp.Tool, handler server.ToolHandlerFunc)) {
-	addTool(tools.BashToolDef(), tools.BashHandler())
-
-	addTool(tools.GlobToolDef(), tools.GlobHandler())
-	addTool(tools.GrepToolDef(), tools.GrepHandler())
-	addTool(tools.ReadToolDef(), tools.ReadHandler())
-	addTool(tools.WriteToolDef(), tools.WriteHandler())
-	addTool(tools.EditToolDef(), tools.EditHandler())
-
-	addTool(tools.BrowserNavigateToolDef(), tools.BrowserNavigateHandler(r.config.CDPURL))
-	addTool(tools.BrowserScreenshotToolDef(), tools.BrowserScreenshotHandler(r.config.CDPURL))
-	addTool(tools.BrowserClickToolDef(), tools.BrowserClickHandler(r.config.CDPURL))
-	addTool(tools.BrowserTypeToolDef(), tools.BrowserTypeHandler(r.config.CDPURL))
-	addTool(tools.BrowserGetURLToolDef(), tools.BrowserGetURLHandler(r.config.CDPURL))
-	addTool(tools.BrowserGetTitleToolDef(), tools.BrowserGetTitleHandler(r.config.CDPURL))
-	addTool(tools.BrowserGetHTMLToolDef(), tools.BrowserGetHTMLHandler(r.config.CDPURL))
-	addTool(tools.BrowserEvaluateToolDef(), tools.BrowserEvaluateHandler(r.config.CDPURL))
-	addTool(tools.BrowserScrollToolDef(), tools.BrowserScrollHandler(r.config.CDPURL))
-	addTool(tools.BrowserWaitVisibleToolDef(), tools.BrowserWaitVisibleHandler(r.config.CDPURL))
-	addTool(tools.BrowserGetPageInfoToolDef(), tools.BrowserGetPageInfoHandler(r.config.CDPURL))
-	addTool(tools.BrowserPDFToolDef(), tools.BrowserPDFHandler(r.config.CDPURL))
+	addTool(tools.BashToolDef(), tools.BashHandler(r.bashSessions, r.bashJobs))
+	addTool(tools.BashOutputToolDef(), tools.BashOutputHandler(r.bashJobs))
+	addTool(tools.KillShellToolDef(), tools.KillShellHandler(r.bashJobs))
+
+	addTool(tools.GlobToolDef(), tools.GlobHandler(r.files))
+	addTool(tools.LSToolDef(), tools.LSHandler(r.files))
+	addTool(tools.GrepToolDef(), tools.GrepHandler(r.files))
+	addTool(tools.ReadToolDef(), tools.ReadHandler(r.files))
+	addTool(tools.WriteToolDef(), tools.WriteHandler(r.files))
+	addTool(tools.EditToolDef(), tools.EditHandler(r.files))
+	addTool(tools.MultiEditToolDef(), tools.MultiEditHandler(r.files))
+	addTool(tools.ApplyPatchToolDef(), tools.ApplyPatchHandler(r.files))
+	if r.history != nil {
+		addTool(tools.UndoToolDef(), tools.UndoHandler(r.files))
+	}
+
+	if r.snapshots != nil {
+		addTool(tools.WorkspaceSnapshotToolDef(), tools.WorkspaceSnapshotHandler(r.snapshots))
+		addTool(tools.WorkspaceSnapshotListToolDef(), tools.WorkspaceSnapshotListHandler(r.snapshots))
+		addTool(tools.WorkspaceSnapshotDiffToolDef(), tools.WorkspaceSnapshotDiffHandler(r.snapshots))
+		addTool(tools.WorkspaceSnapshotRestoreToolDef(), tools.WorkspaceSnapshotRestoreHandler(r.snapshots))
+	}
+
+	addTool(tools.BrowserNavigateToolDef(), tools.BrowserNavigateHandler(r.browser))
+	addTool(tools.BrowserScreenshotToolDef(), tools.BrowserScreenshotHandler(r.browser))
+	addTool(tools.BrowserClickToolDef(), tools.BrowserClickHandler(r.browser))
+	addTool(tools.BrowserTypeToolDef(), tools.BrowserTypeHandler(r.browser))
+	addTool(tools.BrowserSnapshotToolDef(), tools.BrowserSnapshotHandler(r.browser))
+	addTool(tools.BrowserHoverToolDef(), tools.BrowserHoverHandler(r.browser))
+	addTool(tools.BrowserSelectOptionToolDef(), tools.BrowserSelectOptionHandler(r.browser))
+	addTool(tools.BrowserConsoleMessagesToolDef(), tools.BrowserConsoleMessagesHandler(r.browser))
+	addTool(tools.BrowserNetworkRequestsToolDef(), tools.BrowserNetworkRequestsHandler(r.browser))
+	addTool(tools.BrowserNetworkHARToolDef(), tools.BrowserNetworkHARHandler(r.browser, r.files))
+	addTool(tools.BrowserGetURLToolDef(), tools.BrowserGetURLHandler(r.browser))
+	addTool(tools.BrowserGetTitleToolDef(), tools.BrowserGetTitleHandler(r.browser))
+	addTool(tools.BrowserGetHTMLToolDef(), tools.BrowserGetHTMLHandler(r.browser))
+	addTool(tools.BrowserEvaluateToolDef(), tools.BrowserEvaluateHandler(r.browser))
+	addTool(tools.BrowserScrollToolDef(), tools.BrowserScrollHandler(r.browser))
+	addTool(tools.BrowserMouseMoveToolDef(), tools.BrowserMouseMoveHandler(r.browser))
+	addTool(tools.BrowserMouseClickToolDef(), tools.BrowserMouseClickHandler(r.browser))
+	addTool(tools.BrowserMouseDragToolDef(), tools.BrowserMouseDragHandler(r.browser))
+	addTool(tools.BrowserMouseWheelToolDef(), tools.BrowserMouseWheelHandler(r.browser))
+	addTool(tools.BrowserPressKeyToolDef(), tools.BrowserPressKeyHandler(r.browser))
+	addTool(tools.BrowserInsertTextToolDef(), tools.BrowserInsertTextHandler(r.browser))
+	addTool(tools.BrowserWaitVisibleToolDef(), tools.BrowserWaitVisibleHandler(r.browser))
+	addTool(tools.BrowserGetPageInfoToolDef(), tools.BrowserGetPageInfoHandler(r.browser))
+	addTool(tools.BrowserPDFToolDef(), tools.BrowserPDFHandler(r.browser))
+	addTool(tools.BrowserTabListToolDef(), tools.BrowserTabListHandler(r.browser))
+	addTool(tools.BrowserTabOpenToolDef(), tools.BrowserTabOpenHandler(r.browser))
+	addTool(tools.BrowserTabCloseToolDef(), tools.BrowserTabCloseHandler(r.browser))
+	addTool(tools.BrowserTabActivateToolDef(), tools.BrowserTabActivateHandler(r.browser))
 
 	addTool(tools.WebFetchToolDef(), tools.WebFetchHandler())
 	addTool(tools.WebSearchToolDef(), tools.WebSearchHandler())
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/deep-agent/sandbox/internal/services/audit"
 	"github.com/deep-agent/sandbox/pkg/ctxutil"
 	"github.com/deep-agent/sandbox/types/consts"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -100,6 +101,37 @@ func loggingMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
 	}
 }
 
+// AuditMiddleware records every tool call in the audit log. It has to be
+// added after the context middleware so the session ID is known.
+func AuditMiddleware(logger *audit.Logger) Middleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if logger == nil {
+				return next(ctx, request)
+			}
+
+			ctx, call := audit.Begin(ctx, logger, audit.Entry{
+				Source:    audit.SourceMCP,
+				SessionID: ctxutil.GetSessionIDFromCtx(ctx),
+				Tool:      request.Params.Name,
+				Args:      request.GetArguments(),
+			})
+
+			result, err := next(ctx, request)
+
+			switch {
+			case err != nil:
+				call.Finish(audit.StatusError, "", err.Error())
+			case result != nil && result.IsError:
+				call.Finish(audit.StatusError, "", getResultText(result))
+			default:
+				call.Finish(audit.StatusOK, getResultText(result), "")
+			}
+			return result, err
+		}
+	}
+}
+
 func getResultText(result *mcp.CallToolResult) string {
 	if result == nil || len(result.Content) == 0 {
 		return ""
@@ -2,8 +2,13 @@ package tools
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/deep-agent/sandbox/internal/services/audit"
 	"github.com/deep-agent/sandbox/internal/services/bash"
 	"github.com/deep-agent/sandbox/pkg/ctxutil"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -11,7 +16,7 @@ import (
 
 func BashToolDef() mcp.Tool {
 	return mcp.NewTool("Bash",
-		mcp.WithDescription("Executes a given bash command with optional timeout. Working directory persists between commands; shell state (everything else) does not. The shell environment is initialized from the user's profile (bash or zsh).\n\nIMPORTANT: This tool is for terminal operations like git, npm, docker, etc. DO NOT use it for file operations (reading, writing, editing, searching, finding files) - use the specialized tools for this instead.\n\nBefore executing the command, please follow these steps:\n\n1. Directory Verification:\n   - If the command will create new directories or files, first use `ls` to verify the parent directory exists and is the correct location\n   - For example, before running \"mkdir foo/bar\", first use `ls foo` to check that \"foo\" exists and is the intended parent directory\n\n2. Command Execution:\n   - Always quote file paths that contain spaces with double quotes (e.g., cd \"path with spaces/file.txt\")\n   - Examples of proper quoting:\n     - cd \"/Users/name/My Documents\" (correct)\n     - cd /Users/name/My Documents (incorrect - will fail)\n     - python \"/path/with spaces/script.py\" (correct)\n     - python /path/with spaces/script.py (incorrect - will fail)\n   - After ensuring proper quoting, execute the command.\n   - Capture the output of the command.\n\nUsage notes:\n  - The command argument is required.\n  - You can specify an optional timeout in milliseconds (up to 600000ms / 10 minutes). If not specified, commands will timeout after 120000ms (2 minutes).\n  - It is very helpful if you write a clear, concise description of what this command does. For simple commands, keep it brief (5-10 words). For complex commands (piped commands, obscure flags, or anything hard to understand at a glance), add enough context to clarify what it does.\n  - If the output exceeds 30000 characters, output will be truncated before being returned to you.\n  - Set run_in_background to true to run the command in background. Output will be written to a file and you can use Read tool to view it later."),
+		mcp.WithDescription("Executes a given bash command with optional timeout. Commands run in a persistent shell session: the working directory, exported environment variables, activated virtualenvs and shell functions persist between commands. If a command times out or exits the shell, the session is restarted with a fresh state.\n\nIMPORTANT: This tool is for terminal operations like git, npm, docker, etc. DO NOT use it for file operations (reading, writing, editing, searching, finding files) - use the specialized tools for this instead.\n\nBefore executing the command, please follow these steps:\n\n1. Directory Verification:\n   - If the command will create new directories or files, first use `ls` to verify the parent directory exists and is the correct location\n   - For example, before running \"mkdir foo/bar\", first use `ls foo` to check that \"foo\" exists and is the intended parent directory\n\n2. Command Execution:\n   - Always quote file paths that contain spaces with double quotes (e.g., cd \"path with spaces/file.txt\")\n   - Examples of proper quoting:\n     - cd \"/Users/name/My Documents\" (correct)\n     - cd /Users/name/My Documents (incorrect - will fail)\n     - python \"/path/with spaces/script.py\" (correct)\n     - python /path/with spaces/script.py (incorrect - will fail)\n   - After ensuring proper quoting, execute the command.\n   - Capture the output of the command.\n\nUsage notes:\n  - The command argument is required.\n  - You can specify an optional timeout in milliseconds (up to 600000ms / 10 minutes unless the server sets a different maximum). If not specified, commands will timeout after the server default (30000ms unless configured otherwise).\n  - It is very helpful if you write a clear, concise description of what this command does. For simple commands, keep it brief (5-10 words). For complex commands (piped commands, obscure flags, or anything hard to understand at a glance), add enough context to clarify what it does.\n  - If the output exceeds 30000 characters, output will be truncated before being returned to you.\n  - Set run_in_background to true to run the command in background. It returns a job ID; use the BashOutput tool to read its output and the KillShell tool to stop it."),
 		mcp.WithString("command",
 			mcp.Required(),
 			mcp.Description("The command to execute"),
@@ -19,6 +24,13 @@ func BashToolDef() mcp.Tool {
 		mcp.WithNumber("timeout_ms",
 			mcp.Description("Optional timeout in milliseconds (max 600000)"),
 		),
+		mcp.WithString("stdin",
+			mcp.Description("Optional text written to the command's standard input, followed by EOF. Use this for commands that read from stdin or prompt for input (e.g. `python -`, `psql`, confirmation prompts). Without it, stdin is empty."),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Optional environment variables for this command only, as a map of name to value. They do not persist in the shell session. Variables such as LD_PRELOAD or BASH_ENV are rejected by the server policy."),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
 		mcp.WithBoolean("run_in_background",
 			mcp.Description(
 				"When [run_in_background] is set to `true`, the command will run until it completes, and during this period, the user won't be able to interact with the Agent. You MUST ensure this value is set according to the following rules:\n\nAssign [run_in_background] to `false` only if:\n1. Launching a web server or dev server.\n2. Starting a long-running process that runs continuously (e.g., system services, monitoring processes, database servers, or message queues).\n\nOtherwise, set [run_in_background] to `true`. For example, if the command will finish in a relatively short amount of time, or it's important to review the command's output before responding to the user, make the command blocking.\n. Output will be written to a file."),
@@ -29,44 +41,59 @@ func BashToolDef() mcp.Tool {
 	)
 }
 
-func BashHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BashHandler(sessions *bash.SessionManager, jobs *bash.JobManager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		command, err := request.RequireString("command")
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		cwd := ctxutil.GetCwd(ctx)
+		env, err := envArgument(request)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		var stdin io.Reader
+		if text := request.GetString("stdin", ""); text != "" {
+			stdin = strings.NewReader(text)
+		}
 
+		sessionID := ctxutil.GetSessionIDFromCtx(ctx)
 		runInBackground := request.GetBool("run_in_background", false)
-		executor := bash.NewExecutor()
 
 		if runInBackground {
-			timeout := 10 * time.Minute
-			if to := request.GetFloat("timeout_ms", 0); to > 0 {
-				timeout = time.Duration(to) * time.Millisecond
+			cwd := sessions.Cwd(sessionID)
+			if cwd == "" {
+				cwd = ctxutil.GetCwd(ctx)
 			}
-			result, err := executor.ExecuteBackground(ctx, command, cwd, timeout)
+			job, err := jobs.Start(ctx, bash.JobStartRequest{
+				SessionID: sessionID,
+				Command:   command,
+				WorkDir:   cwd,
+				Env:       bash.MergeEnv(sessions.Env(sessionID), env),
+				Stdin:     stdin,
+				Timeout:   time.Duration(request.GetFloat("timeout_ms", 0)) * time.Millisecond,
+			})
 			if err != nil {
 				return mcp.NewToolResultError("Error: " + err.Error()), nil
 			}
-			output := result.Output + "\n\nOutput file: " + result.OutputFile + "\nUse Read tool to view the output."
+			output := fmt.Sprintf("Command started in background (Job ID: %s, PID: %d)\n\nOutput file: %s\nUse BashOutput with job_id %q to read new output, or KillShell to stop it.", job.ID, job.PID, job.OutputFile, job.ID)
 			return mcp.NewToolResultText(output), nil
 		}
 
-		timeoutMS := 30000
-		if to := request.GetFloat("timeout_ms", 0); to > 0 {
-			timeoutMS = int(to)
-			if timeoutMS > 60000 {
-				timeoutMS = 60000
-			}
-		}
-		executor.SetTimeout(time.Duration(timeoutMS) * time.Millisecond)
-
-		result, err := executor.Execute(ctx, command, cwd, &bash.TruncateOptions{MaxLines: 2000, MaxBytes: 50 * 1024})
+		result, err := sessions.Execute(ctx, bash.SessionExecRequest{
+			SessionID: sessionID,
+			Command:   command,
+			Workspace: ctxutil.GetCwd(ctx),
+			Env:       env,
+			Stdin:     stdin,
+			Timeout:   time.Duration(request.GetFloat("timeout_ms", 0)) * time.Millisecond,
+			Truncate:  &bash.TruncateOptions{MaxLines: 2000, MaxBytes: 50 * 1024},
+		})
 		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
 		}
+		audit.SetExitCode(ctx, result.ExitCode)
 
 		output := result.Output
 		if len(output) > 30000 {
@@ -84,3 +111,129 @@ func BashHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 		return mcp.NewToolResultText(output), nil
 	}
 }
+
+func envArgument(request mcp.CallToolRequest) (map[string]string, error) {
+	raw, ok := request.GetArguments()["env"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	values, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("env must be an object of string values")
+	}
+
+	env := make(map[string]string, len(values))
+	for name, value := range values {
+		switch v := value.(type) {
+		case string:
+			env[name] = v
+		case float64, bool:
+			env[name] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("env value for %s must be a string", name)
+		}
+	}
+	return env, nil
+}
+
+func BashOutputToolDef() mcp.Tool {
+	return mcp.NewTool("BashOutput",
+		mcp.WithDescription("Retrieves output from a running or completed background job started with the Bash tool (run_in_background).\n\nUsage:\n- Takes the job_id returned by the Bash tool\n- Always returns only new output since the last check, unless an explicit offset is given\n- Returns the job state (running, exited, killed, timed_out) and exit code\n- Supports optional regex filtering to show only lines matching a pattern"),
+		mcp.WithString("job_id",
+			mcp.Required(),
+			mcp.Description("The ID of the background job to retrieve output from"),
+		),
+		mcp.WithString("filter",
+			mcp.Description("Optional regular expression to filter the output lines. Only lines matching this regex will be included in the result. Any lines that do not match will no longer be available to read."),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Optional byte offset to read from. Omit to continue from where the previous read stopped."),
+		),
+	)
+}
+
+func BashOutputHandler(jobs *bash.JobManager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jobID, err := request.RequireString("job_id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		var filter *regexp.Regexp
+		if pattern := request.GetString("filter", ""); pattern != "" {
+			filter, err = regexp.Compile(pattern)
+			if err != nil {
+				return mcp.NewToolResultError("Error: invalid filter: " + err.Error()), nil
+			}
+		}
+
+		sessionID := ctxutil.GetSessionIDFromCtx(ctx)
+		output, err := jobs.ReadOutput(sessionID, jobID, int64(request.GetFloat("offset", -1)), 0)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		job, err := jobs.Get(sessionID, jobID)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		text := output.Output
+		if filter != nil {
+			var kept []string
+			for _, line := range strings.Split(text, "\n") {
+				if filter.MatchString(line) {
+					kept = append(kept, line)
+				}
+			}
+			text = strings.Join(kept, "\n")
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "<status>%s</status>\n", job.State)
+		if job.State != bash.JobRunning {
+			fmt.Fprintf(&b, "<exit_code>%d</exit_code>\n", job.ExitCode)
+		}
+		fmt.Fprintf(&b, "<output>\n%s</output>", text)
+		if output.NextOffset < output.Size {
+			fmt.Fprintf(&b, "\n(%d more bytes available; call BashOutput again to continue)", output.Size-output.NextOffset)
+		}
+
+		return mcp.NewToolResultText(b.String()), nil
+	}
+}
+
+func KillShellToolDef() mcp.Tool {
+	return mcp.NewTool("KillShell",
+		mcp.WithDescription("Kills a running background job by its ID.\n\nUsage:\n- Takes the job_id returned by the Bash tool\n- Sends SIGTERM by default; pass signal (e.g. SIGKILL, SIGINT) to choose another signal\n- Returns the job state after the signal was delivered"),
+		mcp.WithString("job_id",
+			mcp.Required(),
+			mcp.Description("The ID of the background job to kill"),
+		),
+		mcp.WithString("signal",
+			mcp.Description("Signal to send: SIGTERM (default), SIGKILL, SIGINT, SIGHUP, SIGQUIT, SIGUSR1 or SIGUSR2"),
+		),
+	)
+}
+
+func KillShellHandler(jobs *bash.JobManager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jobID, err := request.RequireString("job_id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		sig, err := bash.ParseSignal(request.GetString("signal", ""))
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		job, err := jobs.Kill(ctxutil.GetSessionIDFromCtx(ctx), jobID, sig)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Sent %s to job %s (state: %s)", sig, job.ID, job.State)), nil
+	}
+}
@@ -3,9 +3,11 @@ package tools
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/deep-agent/sandbox/internal/services/bash"
 	"github.com/deep-agent/sandbox/pkg/ctxutil"
 )
 
@@ -31,7 +33,7 @@ func TestBashTool_Tool(t *testing.T) {
 		}
 	}
 
-	optionalParams := []string{"timeout_ms", "description"}
+	optionalParams := []string{"timeout_ms", "description", "env", "stdin"}
 	for _, param := range optionalParams {
 		if _, ok := tool.InputSchema.Properties[param]; !ok {
 			t.Errorf("expected optional parameter '%s' in schema", param)
@@ -40,7 +42,7 @@ func TestBashTool_Tool(t *testing.T) {
 }
 
 func TestBashTool_Handler_SimpleCommand(t *testing.T) {
-	handler := BashHandler()
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -63,7 +65,7 @@ func TestBashTool_Handler_SimpleCommand(t *testing.T) {
 }
 
 func TestBashTool_Handler_CommandWithExitCode(t *testing.T) {
-	handler := BashHandler()
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -81,7 +83,7 @@ func TestBashTool_Handler_CommandWithExitCode(t *testing.T) {
 }
 
 func TestBashTool_Handler_MissingCommand(t *testing.T) {
-	handler := BashHandler()
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{})
@@ -97,7 +99,7 @@ func TestBashTool_Handler_MissingCommand(t *testing.T) {
 }
 
 func TestBashTool_Handler_Timeout(t *testing.T) {
-	handler := BashHandler()
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -123,7 +125,7 @@ func TestBashTool_Handler_Timeout(t *testing.T) {
 }
 
 func TestBashTool_Handler_TimeoutMax(t *testing.T) {
-	handler := BashHandler()
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -131,14 +133,18 @@ func TestBashTool_Handler_TimeoutMax(t *testing.T) {
 		"timeout_ms": float64(999999999),
 	})
 
-	_, err := handler(ctx, request)
+	result, err := handler(ctx, request)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+
+	if !strings.Contains(getTextContent(result), "exceeds the maximum") {
+		t.Errorf("expected capped timeout notice, got %q", getTextContent(result))
+	}
 }
 
 func TestBashTool_Handler_OutputTruncation(t *testing.T) {
-	handler := BashHandler()
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -158,7 +164,7 @@ func TestBashTool_Handler_OutputTruncation(t *testing.T) {
 
 func TestBashTool_Handler_WorkingDirectory(t *testing.T) {
 	tmpDir := os.TempDir()
-	handler := BashHandler()
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -177,7 +183,7 @@ func TestBashTool_Handler_WorkingDirectory(t *testing.T) {
 }
 
 func TestBashTool_Handler_EnvironmentVariables(t *testing.T) {
-	handler := BashHandler()
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -194,8 +200,57 @@ func TestBashTool_Handler_EnvironmentVariables(t *testing.T) {
 	}
 }
 
+func TestBashTool_Handler_EnvArgument(t *testing.T) {
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
+	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
+
+	result, err := handler(ctx, mockCallToolRequest(map[string]interface{}{
+		"command": "echo $TOOL_VAR",
+		"env":     map[string]interface{}{"TOOL_VAR": "from-tool"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", getTextContent(result))
+	}
+	if !strings.Contains(getTextContent(result), "from-tool") {
+		t.Errorf("expected output to contain 'from-tool', got %q", getTextContent(result))
+	}
+
+	result, err = handler(ctx, mockCallToolRequest(map[string]interface{}{
+		"command": "true",
+		"env":     map[string]interface{}{"LD_PRELOAD": "/tmp/x.so"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.IsError {
+		t.Error("expected denied variable to be rejected")
+	}
+}
+
+func TestBashTool_Handler_Stdin(t *testing.T) {
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
+	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
+
+	result, err := handler(ctx, mockCallToolRequest(map[string]interface{}{
+		"command": "read -r answer; echo \"answer=$answer\"",
+		"stdin":   "yes\n",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", getTextContent(result))
+	}
+	if !strings.Contains(getTextContent(result), "answer=yes") {
+		t.Errorf("expected output to contain 'answer=yes', got %q", getTextContent(result))
+	}
+}
+
 func TestBashTool_Handler_PipedCommand(t *testing.T) {
-	handler := BashHandler()
+	handler := BashHandler(bash.NewSessionManager(), bash.NewJobManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -211,3 +266,79 @@ func TestBashTool_Handler_PipedCommand(t *testing.T) {
 		t.Errorf("expected success, got error: %v", getTextContent(result))
 	}
 }
+
+func TestBashTool_Handler_BackgroundJob(t *testing.T) {
+	jobs := bash.NewJobManager()
+	handler := BashHandler(bash.NewSessionManager(), jobs)
+	outputHandler := BashOutputHandler(jobs)
+	ctx := ctxutil.WithCwd(context.Background(), t.TempDir())
+
+	result, err := handler(ctx, mockCallToolRequest(map[string]interface{}{
+		"command":           "echo started; echo skipped",
+		"run_in_background": true,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", getTextContent(result))
+	}
+	if !strings.Contains(getTextContent(result), "job_1") {
+		t.Fatalf("expected job ID in output, got %q", getTextContent(result))
+	}
+
+	var output string
+	for i := 0; i < 100; i++ {
+		result, err = outputHandler(ctx, mockCallToolRequest(map[string]interface{}{
+			"job_id": "job_1",
+			"filter": "^started",
+			"offset": float64(0),
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		output = getTextContent(result)
+		if strings.Contains(output, "<status>exited</status>") {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if !strings.Contains(output, "<exit_code>0</exit_code>") {
+		t.Errorf("expected exit code in output, got %q", output)
+	}
+	if !strings.Contains(output, "started") || strings.Contains(output, "skipped") {
+		t.Errorf("expected filtered output, got %q", output)
+	}
+}
+
+func TestKillShellTool_Handler(t *testing.T) {
+	jobs := bash.NewJobManager()
+	ctx := ctxutil.WithCwd(context.Background(), t.TempDir())
+
+	job, err := jobs.Start(ctx, bash.JobStartRequest{Command: "sleep 30", WorkDir: ctxutil.GetCwd(ctx)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, err := KillShellHandler(jobs)(ctx, mockCallToolRequest(map[string]interface{}{
+		"job_id": job.ID,
+		"signal": "SIGKILL",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %v", getTextContent(result))
+	}
+	if !strings.Contains(getTextContent(result), "killed") {
+		t.Errorf("expected killed state, got %q", getTextContent(result))
+	}
+
+	result, _ = KillShellHandler(jobs)(ctx, mockCallToolRequest(map[string]interface{}{
+		"job_id": "job_missing",
+	}))
+	if !result.IsError {
+		t.Error("expected error for unknown job")
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
+	"github.com/deep-agent/sandbox/internal/services/audit"
 	"github.com/deep-agent/sandbox/internal/services/browser"
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
@@ -19,14 +22,14 @@ func BrowserNavigateToolDef() mcp.Tool {
 	)
 }
 
-func BrowserNavigateHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserNavigateHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		url, err := request.RequireString("url")
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		if err := controller.Navigate(url); err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
 		}
@@ -44,11 +47,11 @@ func BrowserScreenshotToolDef() mcp.Tool {
 	)
 }
 
-func BrowserScreenshotHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserScreenshotHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		fullPage := request.GetBool("full_page", false)
 
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		screenshot, err := controller.Screenshot(&browser.ScreenshotOptions{
 			Full: fullPage,
 		})
@@ -62,37 +65,38 @@ func BrowserScreenshotHandler(cdpURL string) func(ctx context.Context, request m
 
 func BrowserClickToolDef() mcp.Tool {
 	return mcp.NewTool("browser_click",
-		mcp.WithDescription("Click on an element in the browser page using a CSS selector. The element must be visible."),
+		mcp.WithDescription("Click on an element in the browser page, picked by a CSS selector or by a ref from browser_snapshot. The element must be visible."),
 		mcp.WithString("selector",
-			mcp.Required(),
 			mcp.Description("CSS selector for the element to click (e.g., '#submit-button', '.nav-link', 'button[type=submit]')"),
 		),
+		mcp.WithString("ref",
+			mcp.Description("Ref of the element to click from the latest browser_snapshot (e.g., 'e12'). Use instead of selector"),
+		),
 	)
 }
 
-func BrowserClickHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserClickHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		selector, err := request.RequireString("selector")
-		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
-		}
+		el := requestElement(request)
 
-		controller := browser.NewController(cdpURL)
-		if err := controller.Click(selector); err != nil {
+		controller := browsers.ForContext(ctx)
+		if err := controller.Click(el); err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
 		}
 
-		return mcp.NewToolResultText(fmt.Sprintf("Successfully clicked element: %s", selector)), nil
+		return mcp.NewToolResultText(fmt.Sprintf("Successfully clicked element: %s", elementName(el))), nil
 	}
 }
 
 func BrowserTypeToolDef() mcp.Tool {
 	return mcp.NewTool("browser_type",
-		mcp.WithDescription("Type text into an input element in the browser. First clicks the element, then types the text."),
+		mcp.WithDescription("Type text into an input element in the browser, picked by a CSS selector or by a ref from browser_snapshot. First clicks the element, then types the text."),
 		mcp.WithString("selector",
-			mcp.Required(),
 			mcp.Description("CSS selector for the input element (e.g., '#search-input', 'input[name=email]')"),
 		),
+		mcp.WithString("ref",
+			mcp.Description("Ref of the input element from the latest browser_snapshot (e.g., 'e12'). Use instead of selector"),
+		),
 		mcp.WithString("text",
 			mcp.Required(),
 			mcp.Description("The text to type into the element"),
@@ -100,36 +104,125 @@ func BrowserTypeToolDef() mcp.Tool {
 	)
 }
 
-func BrowserTypeHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserTypeHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		selector, err := request.RequireString("selector")
+		el := requestElement(request)
+
+		text, err := request.RequireString("text")
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		text, err := request.RequireString("text")
+		controller := browsers.ForContext(ctx)
+		if err := controller.Type(el, text); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Successfully typed text into: %s", elementName(el))), nil
+	}
+}
+
+func BrowserSnapshotToolDef() mcp.Tool {
+	return mcp.NewTool("browser_snapshot",
+		mcp.WithDescription("Capture the accessibility tree of the current browser page: its headings, links, buttons, inputs and text, one element per line with its role, name and state. Elements carry a ref (e.g., [ref=e12]) that browser_click, browser_type, browser_hover and browser_select_option accept until the next snapshot. Prefer this over screenshots and HTML to understand and act on a page."),
+	)
+}
+
+func BrowserSnapshotHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		snapshot, err := browsers.ForContext(ctx).Snapshot()
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(snapshot.Snapshot), nil
+	}
+}
+
+func BrowserHoverToolDef() mcp.Tool {
+	return mcp.NewTool("browser_hover",
+		mcp.WithDescription("Move the mouse over an element in the browser page, picked by a CSS selector or by a ref from browser_snapshot. Use this to open menus and tooltips shown on hover."),
+		mcp.WithString("selector",
+			mcp.Description("CSS selector for the element to hover over"),
+		),
+		mcp.WithString("ref",
+			mcp.Description("Ref of the element to hover over from the latest browser_snapshot (e.g., 'e12'). Use instead of selector"),
+		),
+	)
+}
+
+func BrowserHoverHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		el := requestElement(request)
+
+		if err := browsers.ForContext(ctx).Hover(el); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Hovering over: %s", elementName(el))), nil
+	}
+}
+
+func BrowserSelectOptionToolDef() mcp.Tool {
+	return mcp.NewTool("browser_select_option",
+		mcp.WithDescription("Select options in a <select> element of the browser page, picked by a CSS selector or by a ref from browser_snapshot. Options are matched by value or by their visible label."),
+		mcp.WithString("selector",
+			mcp.Description("CSS selector for the <select> element"),
+		),
+		mcp.WithString("ref",
+			mcp.Description("Ref of the <select> element from the latest browser_snapshot (e.g., 'e12'). Use instead of selector"),
+		),
+		mcp.WithArray("values",
+			mcp.Required(),
+			mcp.Description("Values or labels of the options to select. Only the first is used unless the element allows multiple selection"),
+			mcp.WithStringItems(),
+		),
+	)
+}
+
+func BrowserSelectOptionHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		el := requestElement(request)
+
+		values, err := request.RequireStringSlice("values")
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		controller := browser.NewController(cdpURL)
-		if err := controller.Type(selector, text); err != nil {
+		selected, err := browsers.ForContext(ctx).Select(el, values)
+		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
 		}
 
-		return mcp.NewToolResultText(fmt.Sprintf("Successfully typed text into: %s", selector)), nil
+		return mcp.NewToolResultText(fmt.Sprintf("Selected %q in: %s", selected, elementName(el))), nil
 	}
 }
 
+// requestElement reads the element a browser tool acts on from its selector
+// and ref arguments.
+func requestElement(request mcp.CallToolRequest) browser.Element {
+	return browser.Element{
+		Selector: request.GetString("selector", ""),
+		Ref:      request.GetString("ref", ""),
+	}
+}
+
+func elementName(el browser.Element) string {
+	if el.Ref != "" {
+		return el.Ref
+	}
+	return el.Selector
+}
+
 func BrowserGetURLToolDef() mcp.Tool {
 	return mcp.NewTool("browser_get_url",
 		mcp.WithDescription("Get the current URL of the browser page."),
 	)
 }
 
-func BrowserGetURLHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserGetURLHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		url, err := controller.GetCurrentURL()
 		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
@@ -145,9 +238,9 @@ func BrowserGetTitleToolDef() mcp.Tool {
 	)
 }
 
-func BrowserGetTitleHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserGetTitleHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		title, err := controller.GetTitle()
 		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
@@ -167,14 +260,14 @@ func BrowserGetHTMLToolDef() mcp.Tool {
 	)
 }
 
-func BrowserGetHTMLHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserGetHTMLHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		selector, err := request.RequireString("selector")
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		html, err := controller.GetHTML(selector)
 		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
@@ -198,14 +291,14 @@ func BrowserEvaluateToolDef() mcp.Tool {
 	)
 }
 
-func BrowserEvaluateHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserEvaluateHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		expression, err := request.RequireString("expression")
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		result, err := controller.Evaluate(expression)
 		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
@@ -229,12 +322,12 @@ func BrowserScrollToolDef() mcp.Tool {
 	)
 }
 
-func BrowserScrollHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserScrollHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		x := int64(request.GetFloat("x", 0))
 		y := int64(request.GetFloat("y", 0))
 
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		if err := controller.Scroll(x, y); err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
 		}
@@ -243,6 +336,226 @@ func BrowserScrollHandler(cdpURL string) func(ctx context.Context, request mcp.C
 	}
 }
 
+func BrowserMouseMoveToolDef() mcp.Tool {
+	return mcp.NewTool("browser_mouse_move",
+		mcp.WithDescription("Move the mouse to a point of the browser page, given in CSS pixels from the top left of the viewport as in browser_screenshot. Use this to hover over canvases and widgets that have no element to pick."),
+		mcp.WithNumber("x",
+			mcp.Required(),
+			mcp.Description("Horizontal position in pixels"),
+		),
+		mcp.WithNumber("y",
+			mcp.Required(),
+			mcp.Description("Vertical position in pixels"),
+		),
+	)
+}
+
+func BrowserMouseMoveHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		x, y, err := requestPoint(request, "x", "y")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := browsers.ForContext(ctx).MouseMove(x, y); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Moved mouse to: (%g, %g)", x, y)), nil
+	}
+}
+
+func BrowserMouseClickToolDef() mcp.Tool {
+	return mcp.NewTool("browser_mouse_click",
+		mcp.WithDescription("Click at a point of the browser page, given in CSS pixels from the top left of the viewport as in browser_screenshot. Use this for canvases and custom widgets; prefer browser_click when the target is an element of browser_snapshot."),
+		mcp.WithNumber("x",
+			mcp.Required(),
+			mcp.Description("Horizontal position in pixels"),
+		),
+		mcp.WithNumber("y",
+			mcp.Required(),
+			mcp.Description("Vertical position in pixels"),
+		),
+		mcp.WithString("button",
+			mcp.Description("Mouse button. Default: left"),
+			mcp.Enum("left", "right", "middle"),
+		),
+		mcp.WithNumber("click_count",
+			mcp.Description("Number of clicks, 2 for a double-click. Default: 1"),
+		),
+		mcp.WithArray("modifiers",
+			mcp.Description("Keys held during the click: Alt, Control, Meta or Shift"),
+			mcp.WithStringItems(),
+		),
+	)
+}
+
+func BrowserMouseClickHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		x, y, err := requestPoint(request, "x", "y")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		opts := browser.MouseOptions{
+			Button:    request.GetString("button", ""),
+			Count:     request.GetInt("click_count", 1),
+			Modifiers: request.GetStringSlice("modifiers", nil),
+		}
+		if err := browsers.ForContext(ctx).MouseClick(x, y, opts); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Clicked at: (%g, %g)", x, y)), nil
+	}
+}
+
+func BrowserMouseDragToolDef() mcp.Tool {
+	return mcp.NewTool("browser_mouse_drag",
+		mcp.WithDescription("Drag with the left mouse button from one point of the browser page to another, in CSS pixels from the top left of the viewport. Use this to draw on canvases and move sliders; HTML drag and drop between elements is not supported."),
+		mcp.WithNumber("from_x",
+			mcp.Required(),
+			mcp.Description("Horizontal position to start from in pixels"),
+		),
+		mcp.WithNumber("from_y",
+			mcp.Required(),
+			mcp.Description("Vertical position to start from in pixels"),
+		),
+		mcp.WithNumber("to_x",
+			mcp.Required(),
+			mcp.Description("Horizontal position to drop at in pixels"),
+		),
+		mcp.WithNumber("to_y",
+			mcp.Required(),
+			mcp.Description("Vertical position to drop at in pixels"),
+		),
+		mcp.WithNumber("steps",
+			mcp.Description("Number of mouse moves between the two points. Default: 10"),
+		),
+	)
+}
+
+func BrowserMouseDragHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		fromX, fromY, err := requestPoint(request, "from_x", "from_y")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		toX, toY, err := requestPoint(request, "to_x", "to_y")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		steps := request.GetInt("steps", 0)
+		if err := browsers.ForContext(ctx).MouseDrag(fromX, fromY, toX, toY, steps); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Dragged from (%g, %g) to (%g, %g)", fromX, fromY, toX, toY)), nil
+	}
+}
+
+func BrowserMouseWheelToolDef() mcp.Tool {
+	return mcp.NewTool("browser_mouse_wheel",
+		mcp.WithDescription("Turn the mouse wheel over a point of the browser page, scrolling whatever is under it, such as a scrollable panel or a map."),
+		mcp.WithNumber("x",
+			mcp.Required(),
+			mcp.Description("Horizontal position in pixels"),
+		),
+		mcp.WithNumber("y",
+			mcp.Required(),
+			mcp.Description("Vertical position in pixels"),
+		),
+		mcp.WithNumber("delta_x",
+			mcp.Description("Pixels to scroll right, negative to scroll left. Default: 0"),
+		),
+		mcp.WithNumber("delta_y",
+			mcp.Description("Pixels to scroll down, negative to scroll up. Default: 0"),
+		),
+	)
+}
+
+func BrowserMouseWheelHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		x, y, err := requestPoint(request, "x", "y")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		deltaX := request.GetFloat("delta_x", 0)
+		deltaY := request.GetFloat("delta_y", 0)
+
+		if err := browsers.ForContext(ctx).MouseWheel(x, y, deltaX, deltaY); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Scrolled by (%g, %g) at (%g, %g)", deltaX, deltaY, x, y)), nil
+	}
+}
+
+func BrowserPressKeyToolDef() mcp.Tool {
+	return mcp.NewTool("browser_press_key",
+		mcp.WithDescription("Press a key or a keyboard shortcut in the browser page, sent to the focused element. Use this to submit with Enter, move focus with Tab, close dialogs with Escape, or trigger shortcuts."),
+		mcp.WithString("key",
+			mcp.Required(),
+			mcp.Description("Key to press, e.g., 'Enter', 'Tab', 'Escape', 'ArrowDown', 'Backspace' or 'a', with modifiers joined by '+' as in 'Control+a' or 'Shift+Tab'"),
+		),
+	)
+}
+
+func BrowserPressKeyHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		key, err := request.RequireString("key")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := browsers.ForContext(ctx).PressKey(key); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Pressed: %s", key)), nil
+	}
+}
+
+func BrowserInsertTextToolDef() mcp.Tool {
+	return mcp.NewTool("browser_insert_text",
+		mcp.WithDescription("Insert text at the cursor of the focused element of the browser page, as pasting would. Use this after clicking into a field with browser_mouse_click; unlike browser_type it needs no selector."),
+		mcp.WithString("text",
+			mcp.Required(),
+			mcp.Description("Text to insert"),
+		),
+	)
+}
+
+func BrowserInsertTextHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		text, err := request.RequireString("text")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := browsers.ForContext(ctx).InsertText(text); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Inserted %d characters", len([]rune(text)))), nil
+	}
+}
+
+// requestPoint reads the required coordinates of a point from a browser
+// tool's arguments.
+func requestPoint(request mcp.CallToolRequest, xKey, yKey string) (float64, float64, error) {
+	x, err := request.RequireFloat(xKey)
+	if err != nil {
+		return 0, 0, err
+	}
+	y, err := request.RequireFloat(yKey)
+	if err != nil {
+		return 0, 0, err
+	}
+	return x, y, nil
+}
+
 func BrowserWaitVisibleToolDef() mcp.Tool {
 	return mcp.NewTool("browser_wait_visible",
 		mcp.WithDescription("Wait for an element to become visible on the page. Useful after navigation or dynamic content loading."),
@@ -253,14 +566,14 @@ func BrowserWaitVisibleToolDef() mcp.Tool {
 	)
 }
 
-func BrowserWaitVisibleHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserWaitVisibleHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		selector, err := request.RequireString("selector")
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		if err := controller.WaitVisible(selector); err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
 		}
@@ -275,9 +588,9 @@ func BrowserGetPageInfoToolDef() mcp.Tool {
 	)
 }
 
-func BrowserGetPageInfoHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserGetPageInfoHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		info, err := controller.GetPageInfo()
 		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
@@ -294,9 +607,9 @@ func BrowserPDFToolDef() mcp.Tool {
 	)
 }
 
-func BrowserPDFHandler(cdpURL string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func BrowserPDFHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		controller := browser.NewController(cdpURL)
+		controller := browsers.ForContext(ctx)
 		pdf, err := controller.PDF()
 		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
@@ -305,3 +618,231 @@ func BrowserPDFHandler(cdpURL string) func(ctx context.Context, request mcp.Call
 		return mcp.NewToolResultText(pdf), nil
 	}
 }
+
+func BrowserTabListToolDef() mcp.Tool {
+	return mcp.NewTool("browser_tab_list",
+		mcp.WithDescription("List the open browser tabs with their IDs, URLs and titles. The active tab is the one the other browser tools act on."),
+	)
+}
+
+func BrowserTabListHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tabs, err := browsers.ForContext(ctx).Tabs()
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		output, _ := json.Marshal(tabs)
+		return mcp.NewToolResultText(string(output)), nil
+	}
+}
+
+func BrowserTabOpenToolDef() mcp.Tool {
+	return mcp.NewTool("browser_tab_open",
+		mcp.WithDescription("Open a new browser tab and make it the active tab, optionally loading a URL in it."),
+		mcp.WithString("url",
+			mcp.Description("The URL to load in the new tab. Opens a blank tab if not provided"),
+		),
+	)
+}
+
+func BrowserTabOpenHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tab, err := browsers.ForContext(ctx).OpenTab(request.GetString("url", ""))
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		output, _ := json.Marshal(tab)
+		return mcp.NewToolResultText(string(output)), nil
+	}
+}
+
+func BrowserTabCloseToolDef() mcp.Tool {
+	return mcp.NewTool("browser_tab_close",
+		mcp.WithDescription("Close a browser tab. If it was the active tab, the next browser action picks another one."),
+		mcp.WithString("tab_id",
+			mcp.Required(),
+			mcp.Description("The ID of the tab to close, as listed by browser_tab_list"),
+		),
+	)
+}
+
+func BrowserTabCloseHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("tab_id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := browsers.ForContext(ctx).CloseTab(id); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Closed tab: %s", id)), nil
+	}
+}
+
+func BrowserTabActivateToolDef() mcp.Tool {
+	return mcp.NewTool("browser_tab_activate",
+		mcp.WithDescription("Switch to a browser tab, making it the tab the other browser tools act on."),
+		mcp.WithString("tab_id",
+			mcp.Required(),
+			mcp.Description("The ID of the tab to switch to, as listed by browser_tab_list"),
+		),
+	)
+}
+
+func BrowserTabActivateHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("tab_id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := browsers.ForContext(ctx).ActivateTab(id); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Switched to tab: %s", id)), nil
+	}
+}
+
+func BrowserConsoleMessagesToolDef() mcp.Tool {
+	return mcp.NewTool("browser_console_messages",
+		mcp.WithDescription("List what the current browser page logged to its console, oldest first: console calls, uncaught exceptions, and browser errors such as failed resource loads. Use this to find out why a page misbehaves."),
+		mcp.WithString("level",
+			mcp.Description("The lowest level to list: debug, info, warning or error. Default: debug"),
+			mcp.Enum("debug", "info", "warning", "error"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("List only the latest messages, up to this many"),
+		),
+	)
+}
+
+func BrowserConsoleMessagesHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := browsers.ForContext(ctx).ConsoleMessages(browser.ConsoleFilter{
+			Level: request.GetString("level", ""),
+			Limit: request.GetInt("limit", 0),
+		})
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		if len(result.Messages) == 0 {
+			return mcp.NewToolResultText("No console messages"), nil
+		}
+
+		var b strings.Builder
+		if result.Dropped > 0 {
+			fmt.Fprintf(&b, "(%d older messages were dropped)\n", result.Dropped)
+		}
+		for _, msg := range result.Messages {
+			fmt.Fprintf(&b, "[%s] %s", msg.Level, msg.Text)
+			if msg.URL != "" {
+				fmt.Fprintf(&b, " (%s", msg.URL)
+				if msg.Line > 0 {
+					fmt.Fprintf(&b, ":%d", msg.Line)
+				}
+				b.WriteString(")")
+			}
+			b.WriteString("\n")
+		}
+		return mcp.NewToolResultText(b.String()), nil
+	}
+}
+
+func BrowserNetworkRequestsToolDef() mcp.Tool {
+	return mcp.NewTool("browser_network_requests",
+		mcp.WithDescription("List the network requests the current browser page made, oldest first, with their status, type, size and duration. Use this to find failed or slow requests."),
+		mcp.WithString("url",
+			mcp.Description("Regular expression the request URL must match (e.g., 'api/users', '\\.js$')"),
+		),
+		mcp.WithString("status",
+			mcp.Description("Status to list: a code such as 404, a class such as 4xx, or 'failed' for requests that got no response"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("List only the latest requests, up to this many"),
+		),
+	)
+}
+
+func BrowserNetworkRequestsHandler(browsers *browser.Controller) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := browsers.ForContext(ctx).NetworkRequests(browser.NetworkFilter{
+			URL:    request.GetString("url", ""),
+			Status: request.GetString("status", ""),
+			Limit:  request.GetInt("limit", 0),
+		})
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		if len(result.Requests) == 0 {
+			return mcp.NewToolResultText("No network requests"), nil
+		}
+
+		var b strings.Builder
+		if result.Dropped > 0 {
+			fmt.Fprintf(&b, "(%d older requests were dropped)\n", result.Dropped)
+		}
+		for _, r := range result.Requests {
+			fmt.Fprintf(&b, "%s %s => ", r.Method, r.URL)
+			switch {
+			case r.Error != "":
+				b.WriteString("failed: " + r.Error)
+			case r.Status != 0:
+				fmt.Fprintf(&b, "%d %s", r.Status, r.StatusText)
+			default:
+				b.WriteString("no response")
+			}
+			fmt.Fprintf(&b, " [%s", r.ResourceType)
+			if r.Pending {
+				b.WriteString(", pending")
+			} else {
+				fmt.Fprintf(&b, ", %d bytes, %d ms", r.Size, r.DurationMs)
+			}
+			b.WriteString("]\n")
+		}
+		return mcp.NewToolResultText(b.String()), nil
+	}
+}
+
+func BrowserNetworkHARToolDef() mcp.Tool {
+	return mcp.NewTool("browser_network_export_har",
+		mcp.WithDescription("Save the network requests of the current browser page, with their headers and timings, to a HAR (HTTP Archive) file that browser dev tools and HAR viewers can open."),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("The absolute path of the HAR file to write (e.g., '/workspace/page.har')"),
+		),
+	)
+}
+
+func BrowserNetworkHARHandler(browsers *browser.Controller, files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		filePath, err := request.RequireString("file_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		har, err := browsers.ForContext(ctx).NetworkHAR()
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		content, err := json.MarshalIndent(har, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		fileManager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		if err := fileManager.WriteFile(filePath, string(content)); err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		audit.AddBytesChanged(ctx, int64(len(content)))
+
+		return mcp.NewToolResultText(fmt.Sprintf("Saved %d requests to: %s", len(har.Log.Entries), filePath)), nil
+	}
+}
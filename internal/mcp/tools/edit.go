@@ -2,6 +2,8 @@ package tools
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/deep-agent/sandbox/internal/services/filesystem"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -9,7 +11,7 @@ import (
 
 func EditToolDef() mcp.Tool {
 	return mcp.NewTool("Edit",
-		mcp.WithDescription("Performs exact string replacements in files.\n\nUsage:\n- You must use your `Read` tool at least once in the conversation before editing. This tool will error if you attempt an edit without reading the file. \n- When editing text from Read tool output, ensure you preserve the exact indentation (tabs/spaces) as it appears AFTER the line number prefix. The line number prefix format is: spaces + line number + tab. Everything after that tab is the actual file content to match. Never include any part of the line number prefix in the old_string or new_string.\n- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.\n- Only use emojis if the user explicitly requests it. Avoid adding emojis to files unless asked.\n- The edit will FAIL if `old_string` is not unique in the file. Either provide a larger string with more surrounding context to make it unique or use `replace_all` to change every instance of `old_string`.\n- Use `replace_all` for replacing and renaming strings across the file. This parameter is useful if you want to rename a variable for instance."),
+		mcp.WithDescription("Performs exact string replacements in files.\n\nUsage:\n- You must use your `Read` tool at least once in the conversation before editing. This tool will error if you attempt an edit without reading the file, or if the file was modified since you last read it.\n- When editing text from Read tool output, ensure you preserve the exact indentation (tabs/spaces) as it appears AFTER the line number prefix. The line number prefix format is: spaces + line number + tab. Everything after that tab is the actual file content to match. Never include any part of the line number prefix in the old_string or new_string.\n- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.\n- Only use emojis if the user explicitly requests it. Avoid adding emojis to files unless asked.\n- The edit will FAIL if `old_string` is not unique in the file. Either provide a larger string with more surrounding context to make it unique or use `replace_all` to change every instance of `old_string`.\n- Use `replace_all` for replacing and renaming strings across the file. This parameter is useful if you want to rename a variable for instance."),
 		mcp.WithString("file_path",
 			mcp.Required(),
 			mcp.Description("The absolute path to the file to modify"),
@@ -25,10 +27,13 @@ func EditToolDef() mcp.Tool {
 		mcp.WithBoolean("replace_all",
 			mcp.Description("Replace all occurences of old_string (default false)"),
 		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the diff of the edit without writing the file (default false)"),
+		),
 	)
 }
 
-func EditHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func EditHandler(files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		filePath, err := request.RequireString("file_path")
 		if err != nil {
@@ -47,14 +52,33 @@ func EditHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 
 		replaceAll := request.GetBool("replace_all", false)
 
-		fileManager := filesystem.NewManager()
-		err = fileManager.EditFile(filePath, oldString, newString, filesystem.EditOptions{
+		fileManager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		dryRun := request.GetBool("dry_run", false)
+		result, err := fileManager.EditFile(filePath, oldString, newString, filesystem.EditOptions{
 			ReplaceAll: replaceAll,
+			DryRun:     dryRun,
 		})
 		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
 		}
 
-		return mcp.NewToolResultText("File edited successfully"), nil
+		var b strings.Builder
+		if dryRun {
+			b.WriteString("Dry run, file not modified")
+		} else {
+			b.WriteString("File edited successfully")
+		}
+		if result.Replacements > 1 {
+			fmt.Fprintf(&b, " (%d replacements)", result.Replacements)
+		}
+		if result.Strategy != "simple" {
+			fmt.Fprintf(&b, "\nold_string did not match exactly and was matched with the %s strategy; check that the diff changes the intended lines", result.Strategy)
+		}
+		b.WriteString("\n\n")
+		b.WriteString(result.Diff)
+		return mcp.NewToolResultText(b.String()), nil
 	}
 }
@@ -24,7 +24,7 @@ func GlobToolDef() mcp.Tool {
 	)
 }
 
-func GlobHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func GlobHandler(files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		pattern, err := request.RequireString("pattern")
 		if err != nil {
@@ -36,7 +36,10 @@ func GlobHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 			searchPath = ctxutil.GetCwd(ctx)
 		}
 
-		fileManager := filesystem.NewManager()
+		fileManager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
 		result, err := fileManager.Glob(filesystem.GlobOptions{
 			Path:    searchPath,
 			Pattern: pattern,
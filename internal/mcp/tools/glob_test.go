@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
 	"github.com/deep-agent/sandbox/pkg/ctxutil"
 )
 
@@ -47,7 +48,7 @@ func TestGlobTool_Handler_SimplePattern(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GlobHandler()
+	handler := GlobHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -86,7 +87,7 @@ func TestGlobTool_Handler_DoubleStarPattern(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GlobHandler()
+	handler := GlobHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -120,7 +121,7 @@ func TestGlobTool_Handler_CustomPath(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GlobHandler()
+	handler := GlobHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"pattern": "*.js",
@@ -149,7 +150,7 @@ func TestGlobTool_Handler_NoMatches(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	handler := GlobHandler()
+	handler := GlobHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -172,7 +173,7 @@ func TestGlobTool_Handler_NoMatches(t *testing.T) {
 }
 
 func TestGlobTool_Handler_MissingPattern(t *testing.T) {
-	handler := GlobHandler()
+	handler := GlobHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{})
@@ -208,7 +209,7 @@ func TestGlobTool_Handler_SortByModTime(t *testing.T) {
 		t.Fatalf("failed to create new file: %v", err)
 	}
 
-	handler := GlobHandler()
+	handler := GlobHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -254,7 +255,7 @@ func TestGlobTool_Handler_PrefixPattern(t *testing.T) {
 		t.Fatalf("failed to create other file: %v", err)
 	}
 
-	handler := GlobHandler()
+	handler := GlobHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -38,9 +38,7 @@ func GrepToolDef() mcp.Tool {
 	)
 }
 
-func GrepHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	manager := filesystem.NewManager()
-
+func GrepHandler(files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		pattern, err := request.RequireString("pattern")
 		if err != nil {
@@ -63,6 +61,11 @@ func GrepHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 			MaxLineLength:   DefaultMaxLineLength,
 		}
 
+		manager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
 		result, err := manager.Grep(ctx, opts)
 		if err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
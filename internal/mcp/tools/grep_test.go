@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
 	"github.com/deep-agent/sandbox/pkg/ctxutil"
 )
 
@@ -45,7 +46,7 @@ func TestGrepTool_Handler_SimpleSearch(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -79,7 +80,7 @@ func TestGrepTool_Handler_ContentMode(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -113,7 +114,7 @@ func TestGrepTool_Handler_CountMode(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -144,7 +145,7 @@ func TestGrepTool_Handler_CaseInsensitive(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -180,7 +181,7 @@ func TestGrepTool_Handler_GlobFilter(t *testing.T) {
 		t.Fatalf("failed to create txt file: %v", err)
 	}
 
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -215,7 +216,7 @@ func TestGrepTool_Handler_ContextLines(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -250,7 +251,7 @@ func TestGrepTool_Handler_NoMatches(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
@@ -269,7 +270,7 @@ func TestGrepTool_Handler_NoMatches(t *testing.T) {
 }
 
 func TestGrepTool_Handler_MissingPattern(t *testing.T) {
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), os.TempDir())
 
 	request := mockCallToolRequest(map[string]interface{}{})
@@ -296,7 +297,7 @@ func TestGrepTool_Handler_CustomPath(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"pattern": "custom",
@@ -326,7 +327,7 @@ func TestGrepTool_Handler_RegexPattern(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := GrepHandler()
+	handler := GrepHandler(filesystem.NewManager())
 	ctx := ctxutil.WithCwd(context.Background(), tmpDir)
 
 	request := mockCallToolRequest(map[string]interface{}{
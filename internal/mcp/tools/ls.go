@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func LSToolDef() mcp.Tool {
+	return mcp.NewTool("LS",
+		mcp.WithDescription("Lists files and directories in a given path as a tree. The path parameter must be an absolute path, not a relative path. Directories are listed recursively, skipping what .gitignore files ignore; use depth to list fewer levels. You can optionally provide an array of glob patterns to ignore with the ignore parameter. Large listings are cut off; use offset to see the rest. You should generally prefer the Glob and Grep tools, if you know which directories to search."),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("The absolute path to the directory to list (must be absolute, not relative)"),
+		),
+		mcp.WithArray("ignore",
+			mcp.Description("List of glob patterns to ignore"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description("How many levels of directories to list. Lists all of them if not provided"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("The number of entries to skip. Only provide if a previous listing was cut off"),
+		),
+	)
+}
+
+func LSHandler(files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		fileManager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		result, err := fileManager.Tree(path, filesystem.TreeOptions{
+			Depth:     max(int(request.GetFloat("depth", 0)), 0),
+			Ignore:    request.GetStringSlice("ignore", nil),
+			Gitignore: true,
+			Offset:    max(int(request.GetFloat("offset", 0)), 0),
+			Limit:     filesystem.DefaultTreeLimit,
+		})
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(filesystem.RenderTree(result)), nil
+	}
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+)
+
+func TestLSTool_Tool(t *testing.T) {
+	tool := LSToolDef()
+
+	if tool.Name != "LS" {
+		t.Errorf("expected tool name 'LS', got '%s'", tool.Name)
+	}
+
+	for _, param := range []string{"path", "ignore", "depth", "offset"} {
+		if _, ok := tool.InputSchema.Properties[param]; !ok {
+			t.Errorf("expected '%s' parameter in schema", param)
+		}
+	}
+}
+
+func TestLSTool_Handler(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":          "package main\n",
+		"pkg/util.go":      "package pkg\n",
+		"dist/bundle.js":   "bundle",
+		"vendor/x/x.go":    "package x\n",
+		".gitignore":       "dist/\n",
+		"pkg/deep/more.go": "package deep\n",
+	} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := LSHandler(filesystem.NewManager())
+	result, err := handler(context.Background(), mockCallToolRequest(map[string]interface{}{
+		"path":   tmpDir,
+		"ignore": []interface{}{"vendor"},
+		"depth":  float64(2),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected error result: %s", getTextContent(result))
+	}
+
+	want := "- " + tmpDir + "/\n  - .gitignore\n  - main.go\n  - pkg/\n    - deep/\n    - util.go\n"
+	if got := getTextContent(result); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLSTool_Handler_NotADirectory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := LSHandler(filesystem.NewManager())
+	result, err := handler(context.Background(), mockCallToolRequest(map[string]interface{}{"path": file}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.IsError || !strings.Contains(getTextContent(result), "not a directory") {
+		t.Errorf("expected a not a directory error, got %q", getTextContent(result))
+	}
+}
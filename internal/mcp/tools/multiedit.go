@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func MultiEditToolDef() mcp.Tool {
+	return mcp.NewTool("MultiEdit",
+		mcp.WithDescription("Makes several edits to a single file in one operation. Prefer it over the Edit tool when changing several places in the same file.\n\nUsage:\n- Each edit is an exact string replacement with the same rules as the Edit tool.\n- Edits are applied in order, each to the result of the previous one, so an edit must not rely on text an earlier edit replaced.\n- The edits are atomic: if any of them fails, none is applied and the file is left unchanged.\n- The result is a unified diff of the whole change."),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("The absolute path to the file to modify"),
+		),
+		mcp.WithArray("edits",
+			mcp.Required(),
+			mcp.Description("The edits to apply, in order"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"old_string": map[string]any{
+						"type":        "string",
+						"description": "The text to replace",
+					},
+					"new_string": map[string]any{
+						"type":        "string",
+						"description": "The text to replace it with",
+					},
+					"replace_all": map[string]any{
+						"type":        "boolean",
+						"description": "Replace all occurences of old_string (default false)",
+					},
+				},
+				"required": []string{"old_string", "new_string"},
+			}),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the diff of the edits without writing the file (default false)"),
+		),
+	)
+}
+
+func MultiEditHandler(files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		filePath, err := request.RequireString("file_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		var args struct {
+			Edits []struct {
+				OldString  string `json:"old_string"`
+				NewString  string `json:"new_string"`
+				ReplaceAll bool   `json:"replace_all"`
+			} `json:"edits"`
+		}
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError("invalid edits: " + err.Error()), nil
+		}
+		if len(args.Edits) == 0 {
+			return mcp.NewToolResultError("required argument \"edits\" not found"), nil
+		}
+		edits := make([]filesystem.Edit, 0, len(args.Edits))
+		for _, edit := range args.Edits {
+			edits = append(edits, filesystem.Edit{
+				OldString:  edit.OldString,
+				NewString:  edit.NewString,
+				ReplaceAll: edit.ReplaceAll,
+			})
+		}
+
+		fileManager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		dryRun := request.GetBool("dry_run", false)
+		result, err := fileManager.MultiEdit(filePath, edits, dryRun)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		var b strings.Builder
+		if dryRun {
+			fmt.Fprintf(&b, "Dry run, file not modified (%d edits)", len(edits))
+		} else {
+			fmt.Fprintf(&b, "Applied %d edits to %s", len(edits), filePath)
+		}
+		for i, strategy := range result.Strategies {
+			if strategy != "simple" {
+				fmt.Fprintf(&b, "\nedit %d did not match exactly and was matched with the %s strategy; check that the diff changes the intended lines", i+1, strategy)
+			}
+		}
+		b.WriteString("\n\n")
+		b.WriteString(result.Diff)
+		return mcp.NewToolResultText(b.String()), nil
+	}
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+)
+
+func TestMultiEditTool(t *testing.T) {
+	files := filesystem.NewManager()
+	file := filepath.Join(t.TempDir(), "main.go")
+	os.WriteFile(file, []byte("package main\n\nfunc foo() {}\n"), 0644)
+
+	result, err := MultiEditHandler(files)(context.Background(), mockCallToolRequest(map[string]interface{}{
+		"file_path": file,
+		"edits": []interface{}{
+			map[string]interface{}{"old_string": "package main", "new_string": "package app"},
+			map[string]interface{}{"old_string": "foo", "new_string": "bar"},
+		},
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("multi edit failed: %v %s", err, getTextContent(result))
+	}
+	if text := getTextContent(result); !strings.Contains(text, "Applied 2 edits") || !strings.Contains(text, "+func bar() {}") {
+		t.Errorf("unexpected result %q", text)
+	}
+
+	result, _ = MultiEditHandler(files)(context.Background(), mockCallToolRequest(map[string]interface{}{
+		"file_path": file,
+		"edits": []interface{}{
+			map[string]interface{}{"old_string": "bar", "new_string": "baz"},
+			map[string]interface{}{"old_string": "missing", "new_string": "x"},
+		},
+	}))
+	if !result.IsError || !strings.Contains(getTextContent(result), "edit 2") {
+		t.Errorf("expected the second edit to fail, got %q", getTextContent(result))
+	}
+	if content, _ := os.ReadFile(file); string(content) != "package app\n\nfunc bar() {}\n" {
+		t.Errorf("expected file to be unchanged after a failed edit, got %q", content)
+	}
+}
+
+func TestApplyPatchTool(t *testing.T) {
+	files := filesystem.NewManager()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644)
+
+	// Paths are resolved against the working directory of the request.
+	ctx := ctxutil.WithCwd(context.Background(), dir)
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+A\n--- /dev/null\n+++ b/b.txt\n@@ -0,0 +1 @@\n+b\n"
+	result, err := ApplyPatchHandler(files)(ctx, mockCallToolRequest(map[string]interface{}{"patch": patch}))
+	if err != nil || result.IsError {
+		t.Fatalf("apply patch failed: %v %s", err, getTextContent(result))
+	}
+	if text := getTextContent(result); !strings.Contains(text, "Patch applied to 2 files") {
+		t.Errorf("unexpected result %q", text)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "b.txt")); string(content) != "b\n" {
+		t.Errorf("expected b.txt to be created, got %q", content)
+	}
+}
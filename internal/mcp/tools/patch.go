@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func ApplyPatchToolDef() mcp.Tool {
+	return mcp.NewTool("ApplyPatch",
+		mcp.WithDescription("Applies a unified diff or git-style patch that may change many files, including creating, deleting and renaming them.\n\nUsage:\n- Relative paths are resolved against the workspace; a/ and b/ prefixes are removed as git does.\n- Every hunk is checked against the current files before anything is written. If any hunk does not apply, no file is changed.\n- A hunk is applied at the line numbers in its header, or at the nearest place its context matches exactly.\n- Binary patches are not supported."),
+		mcp.WithString("patch",
+			mcp.Required(),
+			mcp.Description("The patch to apply, as produced by diff -u or git diff"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Check that the patch applies without writing any file (default false)"),
+		),
+	)
+}
+
+func ApplyPatchHandler(files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		patch, err := request.RequireString("patch")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		fileManager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		dryRun := request.GetBool("dry_run", false)
+		result, err := fileManager.ApplyPatch(patch, filesystem.PatchOptions{
+			Dir:    ctxutil.GetCwd(ctx),
+			DryRun: dryRun,
+		})
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		var b strings.Builder
+		if dryRun {
+			fmt.Fprintf(&b, "Dry run, patch applies cleanly to %d files:\n", len(result.Files))
+		} else {
+			fmt.Fprintf(&b, "Patch applied to %d files:\n", len(result.Files))
+		}
+		for _, f := range result.Files {
+			fmt.Fprintf(&b, "%-7s %s", f.Op, f.Path)
+			if f.OldPath != "" {
+				fmt.Fprintf(&b, " (from %s)", f.OldPath)
+			}
+			fmt.Fprintf(&b, " +%d -%d\n", f.Added, f.Removed)
+		}
+		return mcp.NewToolResultText(b.String()), nil
+	}
+}
@@ -2,14 +2,21 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
 
 	"github.com/deep-agent/sandbox/internal/services/filesystem"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// readImageMaxDimension is the longest side images are downscaled to, which
+// is as much detail as vision models take in.
+const readImageMaxDimension = 1568
+
 func ReadToolDef() mcp.Tool {
 	return mcp.NewTool("Read",
-		mcp.WithDescription("Reads a file from the local filesystem. You can access any file directly by using this tool.\nAssume this tool is able to read all files on the machine. If the User provides a path to a file assume that path is valid. It is okay to read a file that does not exist; an error will be returned.\n\nUsage:\n- The file_path parameter must be an absolute path, not a relative path\n- By default, it reads up to 2000 lines starting from the beginning of the file\n- You can optionally specify a line offset and limit (especially handy for long files), but it's recommended to read the whole file by not providing these parameters\n- Any lines longer than 2000 characters will be truncated\n- Results are returned using cat -n format, with line numbers starting at 1\n- This tool allows Claude Code to read images (eg PNG, JPG, etc). When reading an image file the contents are presented visually as Claude Code is a multimodal LLM.\n- This tool can read PDF files (.pdf). PDFs are processed page by page, extracting both text and visual content for analysis.\n- This tool can read Jupyter notebooks (.ipynb files) and returns all cells with their outputs, combining code, text, and visualizations.\n- This tool can only read files, not directories. To read a directory, use an ls command via the Bash tool.\n- You can call multiple tools in a single response. It is always better to speculatively read multiple potentially useful files in parallel.\n- You will regularly be asked to read screenshots. If the user provides a path to a screenshot, ALWAYS use this tool to view the file at the path. This tool will work with all temporary file paths.\n- If you read a file that exists but has empty contents you will receive a system reminder warning in place of file contents."),
+		mcp.WithDescription("Reads a file from the local filesystem. You can access any file directly by using this tool.\nAssume this tool is able to read all files on the machine. If the User provides a path to a file assume that path is valid. It is okay to read a file that does not exist; an error will be returned.\n\nUsage:\n- The file_path parameter must be an absolute path, not a relative path\n- By default, it reads up to 2000 lines starting from the beginning of the file\n- You can optionally specify a line offset and limit (especially handy for long files), but it's recommended to read the whole file by not providing these parameters\n- Any lines longer than 2000 characters will be truncated\n- Results are returned using cat -n format, with line numbers starting at 1\n- This tool allows Claude Code to read images (eg PNG, JPG, etc). When reading an image file the contents are presented visually as Claude Code is a multimodal LLM.\n- This tool can read PDF files (.pdf). PDFs are processed page by page, extracting their text. Use the pages parameter to read a range of pages of a large PDF, at most 20 pages per request.\n- This tool can read Jupyter notebooks (.ipynb files) and returns all cells with their outputs, combining code, text, and visualizations.\n- This tool can only read files, not directories. To read a directory, use an ls command via the Bash tool.\n- You can call multiple tools in a single response. It is always better to speculatively read multiple potentially useful files in parallel.\n- You will regularly be asked to read screenshots. If the user provides a path to a screenshot, ALWAYS use this tool to view the file at the path. This tool will work with all temporary file paths.\n- If you read a file that exists but has empty contents you will receive a system reminder warning in place of file contents."),
 		mcp.WithString("file_path",
 			mcp.Required(),
 			mcp.Description("The absolute path to the file to read"),
@@ -20,10 +27,13 @@ func ReadToolDef() mcp.Tool {
 		mcp.WithNumber("limit",
 			mcp.Description("The number of lines to read. Only provide if the file is too large to read at once."),
 		),
+		mcp.WithString("pages",
+			mcp.Description("Page range for PDF files (e.g., \"1-5\", \"3\"). Only applicable to PDF files. Maximum 20 pages per request."),
+		),
 	)
 }
 
-func ReadHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func ReadHandler(files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		filePath, err := request.RequireString("file_path")
 		if err != nil {
@@ -33,7 +43,23 @@ func ReadHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 		offset := int(request.GetFloat("offset", 1))
 		limit := int(request.GetFloat("limit", 2000))
 
-		fileManager := filesystem.NewManager()
+		fileManager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		fileType, _, err := fileManager.DetectFileType(filePath)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		switch fileType {
+		case filesystem.FileTypeImage:
+			return readImage(fileManager, filePath)
+		case filesystem.FileTypePDF:
+			return readPDF(ctx, fileManager, filePath, request.GetString("pages", ""))
+		case filesystem.FileTypeNotebook:
+			return readNotebook(fileManager, filePath)
+		}
+
 		result, err := fileManager.ReadFileWithOptions(filePath, filesystem.ReadOptions{
 			Offset:         offset,
 			Limit:          limit,
@@ -51,3 +77,59 @@ func ReadHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 		return mcp.NewToolResultText(result.Content), nil
 	}
 }
+
+func readImage(files *filesystem.Manager, filePath string) (*mcp.CallToolResult, error) {
+	img, err := files.ReadImage(filePath, readImageMaxDimension)
+	if err != nil {
+		return mcp.NewToolResultError("Error: " + err.Error()), nil
+	}
+
+	text := fmt.Sprintf("Image %s (%s", filePath, img.MimeType)
+	if img.Width > 0 {
+		text += fmt.Sprintf(", %dx%d", img.Width, img.Height)
+	}
+	if img.Width != img.OriginalWidth || img.Height != img.OriginalHeight {
+		text += fmt.Sprintf(", downscaled from %dx%d", img.OriginalWidth, img.OriginalHeight)
+	}
+	text += ")"
+	return mcp.NewToolResultImage(text, base64.StdEncoding.EncodeToString(img.Data), img.MimeType), nil
+}
+
+func readPDF(ctx context.Context, files *filesystem.Manager, filePath, pages string) (*mcp.CallToolResult, error) {
+	pdf, err := files.ReadPDF(ctx, filePath, pages)
+	if err != nil {
+		return mcp.NewToolResultError("Error: " + err.Error()), nil
+	}
+	if len(pdf.Pages) == 0 {
+		return mcp.NewToolResultText("<system-reminder>Warning: PDF has no pages</system-reminder>"), nil
+	}
+
+	var b strings.Builder
+	for _, page := range pdf.Pages {
+		fmt.Fprintf(&b, "<page number=\"%d\">\n%s\n</page>\n", page.Number, page.Text)
+	}
+	if last := pdf.Pages[len(pdf.Pages)-1].Number; last < pdf.TotalPages {
+		fmt.Fprintf(&b, "\nShowing pages %d-%d of %d. Use the pages parameter to read more.", pdf.Pages[0].Number, last, pdf.TotalPages)
+	}
+	return mcp.NewToolResultText(b.String()), nil
+}
+
+func readNotebook(files *filesystem.Manager, filePath string) (*mcp.CallToolResult, error) {
+	notebook, err := files.ReadNotebook(filePath)
+	if err != nil {
+		return mcp.NewToolResultError("Error: " + err.Error()), nil
+	}
+	if len(notebook.Cells) == 0 {
+		return mcp.NewToolResultText("<system-reminder>Warning: Notebook has no cells</system-reminder>"), nil
+	}
+
+	content := []mcp.Content{mcp.NewTextContent(notebook.Render())}
+	for _, cell := range notebook.Cells {
+		for _, output := range cell.Outputs {
+			if output.MimeType != "" {
+				content = append(content, mcp.NewImageContent(output.Data, output.MimeType))
+			}
+		}
+	}
+	return &mcp.CallToolResult{Content: content}, nil
+}
@@ -1,11 +1,17 @@
 package tools
 
 import (
+	"bytes"
 	"context"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
 func TestReadTool_Tool(t *testing.T) {
@@ -51,7 +57,7 @@ func TestReadTool_Handler_SimpleRead(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": testFile,
@@ -91,7 +97,7 @@ func TestReadTool_Handler_LineNumbers(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": testFile,
@@ -127,7 +133,7 @@ func TestReadTool_Handler_Offset(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": testFile,
@@ -164,7 +170,7 @@ func TestReadTool_Handler_Limit(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": testFile,
@@ -196,7 +202,7 @@ func TestReadTool_Handler_OffsetAndLimit(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": testFile,
@@ -237,7 +243,7 @@ func TestReadTool_Handler_LineTruncation(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": testFile,
@@ -266,7 +272,7 @@ func TestReadTool_Handler_EmptyFile(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": testFile,
@@ -284,7 +290,7 @@ func TestReadTool_Handler_EmptyFile(t *testing.T) {
 }
 
 func TestReadTool_Handler_FileNotFound(t *testing.T) {
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": "/nonexistent/file.txt",
@@ -301,7 +307,7 @@ func TestReadTool_Handler_FileNotFound(t *testing.T) {
 }
 
 func TestReadTool_Handler_MissingFilePath(t *testing.T) {
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{})
 
@@ -332,7 +338,7 @@ func TestReadTool_Handler_DefaultLimit(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	handler := ReadHandler()
+	handler := ReadHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": testFile,
@@ -349,3 +355,65 @@ func TestReadTool_Handler_DefaultLimit(t *testing.T) {
 		t.Errorf("expected 2000 lines (default limit), got %d", len(outputLines))
 	}
 }
+
+func TestReadTool_Handler_Image(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "shot.png")
+	img := image.NewNRGBA(image.Rect(0, 0, 3000, 1000))
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	os.WriteFile(testFile, buf.Bytes(), 0644)
+
+	handler := ReadHandler(filesystem.NewManager())
+	result, err := handler(context.Background(), mockCallToolRequest(map[string]interface{}{
+		"file_path": testFile,
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("unexpected error: %v %s", err, getTextContent(result))
+	}
+
+	if text := getTextContent(result); !strings.Contains(text, "1568x522, downscaled from 3000x1000") {
+		t.Errorf("unexpected text %q", text)
+	}
+	if len(result.Content) != 2 {
+		t.Fatalf("expected text and image content, got %d items", len(result.Content))
+	}
+	if content, ok := result.Content[1].(mcp.ImageContent); !ok || content.MIMEType != "image/png" || content.Data == "" {
+		t.Errorf("expected PNG image content, got %+v", result.Content[1])
+	}
+}
+
+func TestReadTool_Handler_Notebook(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "nb.ipynb")
+	os.WriteFile(testFile, []byte(`{"cells": [{"cell_type": "code", "source": "x = 1", "outputs": [
+		{"output_type": "display_data", "data": {"image/png": "iVBORw0KGgo="}}]}]}`), 0644)
+
+	handler := ReadHandler(filesystem.NewManager())
+	result, _ := handler(context.Background(), mockCallToolRequest(map[string]interface{}{
+		"file_path": testFile,
+	}))
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", getTextContent(result))
+	}
+	if text := getTextContent(result); !strings.Contains(text, "<cell 1 type=\"code\">\nx = 1\n") {
+		t.Errorf("unexpected text %q", text)
+	}
+	if len(result.Content) != 2 {
+		t.Errorf("expected the image output as image content, got %d items", len(result.Content))
+	}
+}
+
+func TestReadTool_Handler_Binary(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "archive.zip")
+	os.WriteFile(testFile, []byte("PK\x03\x04\x00\x00\x00"), 0644)
+
+	handler := ReadHandler(filesystem.NewManager())
+	result, _ := handler(context.Background(), mockCallToolRequest(map[string]interface{}{
+		"file_path": testFile,
+	}))
+	if !result.IsError || !strings.Contains(getTextContent(result), "cannot read binary file") {
+		t.Errorf("expected binary file to be refused, got %q", getTextContent(result))
+	}
+}
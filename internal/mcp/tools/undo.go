@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func UndoToolDef() mcp.Tool {
+	return mcp.NewTool("Undo",
+		mcp.WithDescription("Reverts changes made by the Write and Edit tools and the file API in this session, restoring the previous content of the files. Without arguments the most recent change is undone. An undo is refused when a file was modified since the change, for example by a shell command, unless `force` is set."),
+		mcp.WithString("change_id",
+			mcp.Description("The ID of a specific change to undo"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("Only undo changes to this file"),
+		),
+		mcp.WithNumber("count",
+			mcp.Description("The number of most recent changes to undo (default 1)"),
+		),
+		mcp.WithBoolean("force",
+			mcp.Description("Undo even if the files were modified after the change (default false)"),
+		),
+	)
+}
+
+func UndoHandler(files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		fileManager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		undone, err := fileManager.Undo(filesystem.UndoOptions{
+			ID:    request.GetString("change_id", ""),
+			Path:  request.GetString("file_path", ""),
+			Count: int(request.GetFloat("count", 0)),
+			Force: request.GetBool("force", false),
+		})
+
+		var b strings.Builder
+		for _, change := range undone {
+			fmt.Fprintf(&b, "Undid %s: %s %s", change.ID, change.Op, change.Path)
+			if change.Dest != "" {
+				fmt.Fprintf(&b, " -> %s", change.Dest)
+			}
+			b.WriteString("\n")
+		}
+		if err != nil {
+			return mcp.NewToolResultError(b.String() + "Error: " + err.Error()), nil
+		}
+		return mcp.NewToolResultText(b.String()), nil
+	}
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+)
+
+func TestUndoTool(t *testing.T) {
+	base := t.TempDir()
+	history, err := filesystem.NewHistory(filepath.Join(base, "history"), filesystem.HistoryOptions{})
+	if err != nil {
+		t.Fatalf("failed to create history: %v", err)
+	}
+	defer history.Close()
+	files := filesystem.NewManager(filesystem.WithHistory(history))
+	ctx := context.Background()
+
+	file := filepath.Join(base, "main.go")
+	os.WriteFile(file, []byte("package main\n"), 0644)
+	result, _ := EditHandler(files)(ctx, mockCallToolRequest(map[string]interface{}{
+		"file_path":  file,
+		"old_string": "main",
+		"new_string": "broken",
+	}))
+	if result.IsError {
+		t.Fatalf("edit failed: %s", getTextContent(result))
+	}
+
+	result, err = UndoHandler(files)(ctx, mockCallToolRequest(map[string]interface{}{"file_path": file}))
+	if err != nil || result.IsError {
+		t.Fatalf("undo failed: %v %s", err, getTextContent(result))
+	}
+	if text := getTextContent(result); !strings.Contains(text, "Undid change_1: edit "+file) {
+		t.Errorf("unexpected result %q", text)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "package main\n" {
+		t.Errorf("expected content before the edit, got %q", content)
+	}
+
+	result, _ = UndoHandler(files)(ctx, mockCallToolRequest(nil))
+	if !result.IsError {
+		t.Error("expected error with nothing left to undo")
+	}
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/deep-agent/sandbox/internal/services/snapshot"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func WorkspaceSnapshotToolDef() mcp.Tool {
+	return mcp.NewTool("workspace_snapshot",
+		mcp.WithDescription("Takes a snapshot of the workspace that workspace_snapshot_restore can roll back to. Take one before risky steps such as large refactors, dependency upgrades or destructive commands. Unchanged files are not stored again, so snapshots are cheap."),
+		mcp.WithString("label",
+			mcp.Description("A short note describing the state being saved"),
+		),
+		mcp.WithArray("exclude",
+			mcp.Description("Paths to leave out of the snapshot, such as node_modules or build output. They are kept as they are on restore. Patterns without a slash match a name at any depth."),
+			mcp.WithStringItems(),
+		),
+	)
+}
+
+func WorkspaceSnapshotHandler(snapshots *snapshot.Store) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		snap, err := snapshots.Create(ctxutil.GetCwd(ctx), snapshot.CreateOptions{
+			SessionID: ctxutil.GetSessionIDFromCtx(ctx),
+			Label:     request.GetString("label", ""),
+			Exclude:   request.GetStringSlice("exclude", nil),
+		})
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Snapshot %s created: %d files, %d bytes (%d bytes newly stored)", snap.ID, snap.Files, snap.Size, snap.StoredSize)), nil
+	}
+}
+
+func WorkspaceSnapshotListToolDef() mcp.Tool {
+	return mcp.NewTool("workspace_snapshot_list",
+		mcp.WithDescription("Lists the snapshots of the workspace, oldest first."),
+	)
+}
+
+func WorkspaceSnapshotListHandler(snapshots *snapshot.Store) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		snaps, err := snapshots.List(ctxutil.GetCwd(ctx))
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		if len(snaps) == 0 {
+			return mcp.NewToolResultText("No snapshots"), nil
+		}
+
+		var b strings.Builder
+		for _, snap := range snaps {
+			fmt.Fprintf(&b, "%s  %s  %d files  %d bytes", snap.ID, snap.CreatedAt.Format(time.RFC3339), snap.Files, snap.Size)
+			if snap.Label != "" {
+				fmt.Fprintf(&b, "  %s", snap.Label)
+			}
+			b.WriteString("\n")
+		}
+		return mcp.NewToolResultText(b.String()), nil
+	}
+}
+
+func WorkspaceSnapshotDiffToolDef() mcp.Tool {
+	return mcp.NewTool("workspace_snapshot_diff",
+		mcp.WithDescription("Lists the files added, removed or modified between two snapshots, or between a snapshot and the current workspace."),
+		mcp.WithString("from",
+			mcp.Required(),
+			mcp.Description("The ID of the older snapshot"),
+		),
+		mcp.WithString("to",
+			mcp.Description("The ID of the newer snapshot. Omit it to compare with the current workspace."),
+		),
+	)
+}
+
+func WorkspaceSnapshotDiffHandler(snapshots *snapshot.Store) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		from, err := request.RequireString("from")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		changes, err := snapshots.Diff(ctxutil.GetCwd(ctx), from, request.GetString("to", ""))
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+		if len(changes) == 0 {
+			return mcp.NewToolResultText("No changes"), nil
+		}
+
+		var b strings.Builder
+		for _, change := range changes {
+			fmt.Fprintf(&b, "%-8s %s", change.Status, change.Path)
+			if change.Type == snapshot.TypeDir {
+				b.WriteString("/")
+			}
+			b.WriteString("\n")
+		}
+		return mcp.NewToolResultText(b.String()), nil
+	}
+}
+
+func WorkspaceSnapshotRestoreToolDef() mcp.Tool {
+	return mcp.NewTool("workspace_snapshot_restore",
+		mcp.WithDescription("Restores the workspace to a snapshot. Every change made since the snapshot is discarded, except in paths the snapshot excluded. The workspace is replaced in one step, so it is never left half restored."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("The ID of the snapshot to restore"),
+		),
+	)
+}
+
+func WorkspaceSnapshotRestoreHandler(snapshots *snapshot.Store) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		snap, err := snapshots.Restore(ctxutil.GetCwd(ctx), id)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Workspace restored to snapshot %s (%d files)", snap.ID, snap.Files)), nil
+	}
+}
@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/deep-agent/sandbox/internal/services/snapshot"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+)
+
+func TestWorkspaceSnapshotTools(t *testing.T) {
+	base := t.TempDir()
+	workspace := filepath.Join(base, "workspace")
+	if err := os.MkdirAll(workspace, 0755); err != nil {
+		t.Fatal(err)
+	}
+	store, err := snapshot.NewStore(filepath.Join(base, "snapshots"), snapshot.Options{Workspace: workspace})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := ctxutil.WithCwd(context.Background(), workspace)
+	file := filepath.Join(workspace, "main.go")
+	os.WriteFile(file, []byte("package main\n"), 0644)
+
+	result, err := WorkspaceSnapshotHandler(store)(ctx, mockCallToolRequest(map[string]interface{}{
+		"label": "before refactor",
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("snapshot failed: %v %s", err, getTextContent(result))
+	}
+	id := regexp.MustCompile(`snap_\w+`).FindString(getTextContent(result))
+	if id == "" {
+		t.Fatalf("expected snapshot id in %q", getTextContent(result))
+	}
+
+	result, _ = WorkspaceSnapshotListHandler(store)(ctx, mockCallToolRequest(nil))
+	if text := getTextContent(result); !strings.Contains(text, id) || !strings.Contains(text, "before refactor") {
+		t.Errorf("expected list to show the snapshot, got %q", text)
+	}
+
+	os.WriteFile(file, []byte("broken"), 0644)
+	os.WriteFile(filepath.Join(workspace, "extra.go"), []byte("package main\n"), 0644)
+
+	result, _ = WorkspaceSnapshotDiffHandler(store)(ctx, mockCallToolRequest(map[string]interface{}{"from": id}))
+	if text := getTextContent(result); !strings.Contains(text, "added    extra.go") || !strings.Contains(text, "modified main.go") {
+		t.Errorf("unexpected diff %q", text)
+	}
+
+	result, err = WorkspaceSnapshotRestoreHandler(store)(ctx, mockCallToolRequest(map[string]interface{}{"id": id}))
+	if err != nil || result.IsError {
+		t.Fatalf("restore failed: %v %s", err, getTextContent(result))
+	}
+	if content, _ := os.ReadFile(file); string(content) != "package main\n" {
+		t.Errorf("expected restored content, got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "extra.go")); !os.IsNotExist(err) {
+		t.Error("expected extra.go to be removed by the restore")
+	}
+
+	result, _ = WorkspaceSnapshotRestoreHandler(store)(ctx, mockCallToolRequest(map[string]interface{}{"id": "snap_missing"}))
+	if !result.IsError {
+		t.Error("expected error for unknown snapshot")
+	}
+}
@@ -3,13 +3,14 @@ package tools
 import (
 	"context"
 
+	"github.com/deep-agent/sandbox/internal/services/audit"
 	"github.com/deep-agent/sandbox/internal/services/filesystem"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 func WriteToolDef() mcp.Tool {
 	return mcp.NewTool("Write",
-		mcp.WithDescription("Writes a file to the local filesystem.\n\nUsage:\n- This tool will overwrite the existing file if there is one at the provided path.\n- If this is an existing file, you MUST use the Read tool first to read the file's contents. This tool will fail if you did not read the file first.\n- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.\n- NEVER proactively create documentation files (*.md) or README files. Only create documentation files if explicitly requested by the User.\n- Only use emojis if the user explicitly requests it. Avoid writing emojis to files unless asked."),
+		mcp.WithDescription("Writes a file to the local filesystem.\n\nUsage:\n- This tool will overwrite the existing file if there is one at the provided path.\n- If this is an existing file, you MUST use the Read tool first to read the file's contents. This tool will fail if you did not read the file first, or if the file was modified since you last read it.\n- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.\n- NEVER proactively create documentation files (*.md) or README files. Only create documentation files if explicitly requested by the User.\n- Only use emojis if the user explicitly requests it. Avoid writing emojis to files unless asked."),
 		mcp.WithString("file_path",
 			mcp.Required(),
 			mcp.Description("The absolute path to the file to write (must be absolute, not relative)"),
@@ -21,7 +22,7 @@ func WriteToolDef() mcp.Tool {
 	)
 }
 
-func WriteHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func WriteHandler(files *filesystem.Manager) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		filePath, err := request.RequireString("file_path")
 		if err != nil {
@@ -33,10 +34,14 @@ func WriteHandler() func(ctx context.Context, request mcp.CallToolRequest) (*mcp
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		fileManager := filesystem.NewManager()
+		fileManager, err := files.ForContext(ctx)
+		if err != nil {
+			return mcp.NewToolResultError("Error: " + err.Error()), nil
+		}
 		if err := fileManager.WriteFile(filePath, content); err != nil {
 			return mcp.NewToolResultError("Error: " + err.Error()), nil
 		}
+		audit.AddBytesChanged(ctx, int64(len(content)))
 
 		return mcp.NewToolResultText("File written successfully: " + filePath), nil
 	}
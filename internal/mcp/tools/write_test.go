@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
 )
 
 func TestWriteTool_Tool(t *testing.T) {
@@ -38,7 +40,7 @@ func TestWriteTool_Handler_SimpleWrite(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	handler := WriteHandler()
+	handler := WriteHandler(filesystem.NewManager())
 
 	testFile := filepath.Join(tmpDir, "test.txt")
 	request := mockCallToolRequest(map[string]interface{}{
@@ -77,7 +79,7 @@ func TestWriteTool_Handler_OverwriteExisting(t *testing.T) {
 		t.Fatalf("failed to create existing file: %v", err)
 	}
 
-	handler := WriteHandler()
+	handler := WriteHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"file_path": testFile,
@@ -103,6 +105,31 @@ func TestWriteTool_Handler_OverwriteExisting(t *testing.T) {
 	}
 }
 
+func TestWriteTool_Handler_RequireRead(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "existing.txt")
+	if err := os.WriteFile(testFile, []byte("original content"), 0644); err != nil {
+		t.Fatalf("failed to create existing file: %v", err)
+	}
+
+	files := filesystem.NewManager(filesystem.WithReadTracker(filesystem.NewReadTracker()))
+	request := mockCallToolRequest(map[string]interface{}{
+		"file_path": testFile,
+		"content":   "new content",
+	})
+
+	result, _ := WriteHandler(files)(context.Background(), request)
+	if !result.IsError || !strings.Contains(getTextContent(result), "read "+testFile+" before writing it") {
+		t.Fatalf("expected write of an unread file to fail, got %q", getTextContent(result))
+	}
+
+	ReadHandler(files)(context.Background(), mockCallToolRequest(map[string]interface{}{"file_path": testFile}))
+	result, _ = WriteHandler(files)(context.Background(), request)
+	if result.IsError {
+		t.Errorf("expected write after read to succeed, got error: %v", getTextContent(result))
+	}
+}
+
 func TestWriteTool_Handler_CreateDirectory(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "write_test")
 	if err != nil {
@@ -110,7 +137,7 @@ func TestWriteTool_Handler_CreateDirectory(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	handler := WriteHandler()
+	handler := WriteHandler(filesystem.NewManager())
 
 	testFile := filepath.Join(tmpDir, "subdir", "nested", "test.txt")
 	request := mockCallToolRequest(map[string]interface{}{
@@ -144,7 +171,7 @@ func TestWriteTool_Handler_EmptyContent(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	handler := WriteHandler()
+	handler := WriteHandler(filesystem.NewManager())
 
 	testFile := filepath.Join(tmpDir, "empty.txt")
 	request := mockCallToolRequest(map[string]interface{}{
@@ -172,7 +199,7 @@ func TestWriteTool_Handler_EmptyContent(t *testing.T) {
 }
 
 func TestWriteTool_Handler_MissingFilePath(t *testing.T) {
-	handler := WriteHandler()
+	handler := WriteHandler(filesystem.NewManager())
 
 	request := mockCallToolRequest(map[string]interface{}{
 		"content": "some content",
@@ -195,7 +222,7 @@ func TestWriteTool_Handler_MissingContent(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	handler := WriteHandler()
+	handler := WriteHandler(filesystem.NewManager())
 
 	testFile := filepath.Join(tmpDir, "test.txt")
 	request := mockCallToolRequest(map[string]interface{}{
@@ -219,7 +246,7 @@ func TestWriteTool_Handler_MultilineContent(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	handler := WriteHandler()
+	handler := WriteHandler(filesystem.NewManager())
 
 	testFile := filepath.Join(tmpDir, "multiline.txt")
 	multilineContent := "line1\nline2\nline3\n"
@@ -254,7 +281,7 @@ func TestWriteTool_Handler_SuccessMessage(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	handler := WriteHandler()
+	handler := WriteHandler(filesystem.NewManager())
 
 	testFile := filepath.Join(tmpDir, "success.txt")
 	request := mockCallToolRequest(map[string]interface{}{
@@ -283,7 +310,7 @@ func TestWriteTool_Handler_UnicodeContent(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	handler := WriteHandler()
+	handler := WriteHandler(filesystem.NewManager())
 
 	testFile := filepath.Join(tmpDir, "unicode.txt")
 	unicodeContent := "Hello 世界 🌍 مرحبا"
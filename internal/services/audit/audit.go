@@ -0,0 +1,329 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	SourceHTTP = "http"
+	SourceMCP  = "mcp"
+
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+const (
+	redactedValue = "[REDACTED]"
+	maxArgLength  = 1024
+	maxResultSize = 512
+)
+
+// DefaultRedact lists argument names whose values are never written to the
+// audit log. Patterns are case-insensitive globs and apply at any depth, so
+// they also cover keys of env maps.
+var DefaultRedact = []string{
+	"*password*",
+	"*passwd*",
+	"*secret*",
+	"*token*",
+	"*api_key*",
+	"*apikey*",
+	"*credential*",
+	"*private_key*",
+	"authorization",
+	"cookie",
+}
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time         time.Time              `json:"time"`
+	Source       string                 `json:"source"`
+	SessionID    string                 `json:"session_id,omitempty"`
+	Subject      string                 `json:"subject,omitempty"`
+	Tool         string                 `json:"tool"`
+	Args         map[string]interface{} `json:"args,omitempty"`
+	Status       string                 `json:"status"`
+	Result       string                 `json:"result,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	ExitCode     *int                   `json:"exit_code,omitempty"`
+	DurationMs   int64                  `json:"duration_ms"`
+	BytesChanged int64                  `json:"bytes_changed,omitempty"`
+}
+
+type Options struct {
+	// MaxSize rotates the log once it would grow beyond this many bytes;
+	// zero disables rotation. MaxBackups rotated files are kept.
+	MaxSize    int64
+	MaxBackups int
+	// Redact adds argument name patterns to DefaultRedact.
+	Redact []string
+}
+
+// Logger appends entries to a JSONL file. Several processes may share one
+// file: writes and rotation are serialised with an flock on a sibling lock
+// file, and a logger reopens the file when another process rotated it.
+type Logger struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	redact     []string
+	file       *os.File
+	lock       *os.File
+}
+
+func New(file string, opts Options) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	lock, err := os.OpenFile(file+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit lock file: %w", err)
+	}
+
+	l := &Logger{
+		path:       file,
+		maxSize:    opts.MaxSize,
+		maxBackups: opts.MaxBackups,
+		redact:     append(append([]string{}, DefaultRedact...), opts.Redact...),
+		lock:       lock,
+	}
+	if err := l.open(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Logger) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = f
+	return nil
+}
+
+// Record appends an entry. Arguments are redacted and long values shortened
+// before they are written. A nil Logger discards entries.
+func (l *Logger) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if args, ok := l.sanitize("", e.Args).(map[string]interface{}); ok {
+		e.Args = args
+	}
+	e.Result = truncate(e.Result, maxResultSize)
+	e.Error = truncate(e.Error, maxResultSize)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := syscall.Flock(int(l.lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	defer syscall.Flock(int(l.lock.Fd()), syscall.LOCK_UN)
+
+	if err := l.reopenIfRotated(); err != nil {
+		return err
+	}
+	if err := l.rotateIfFull(int64(len(line))); err != nil {
+		return err
+	}
+	if _, err := l.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+func (l *Logger) reopenIfRotated() error {
+	current, err := os.Stat(l.path)
+	if err == nil {
+		if open, err := l.file.Stat(); err == nil && os.SameFile(current, open) {
+			return nil
+		}
+	}
+	l.file.Close()
+	return l.open()
+}
+
+func (l *Logger) rotateIfFull(incoming int64) error {
+	if l.maxSize <= 0 {
+		return nil
+	}
+	info, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	if info.Size() == 0 || info.Size()+incoming <= l.maxSize {
+		return nil
+	}
+
+	l.file.Close()
+	if l.maxBackups > 0 {
+		for i := l.maxBackups - 1; i > 0; i-- {
+			os.Rename(l.backup(i), l.backup(i+1))
+		}
+		if err := os.Rename(l.path, l.backup(1)); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return l.open()
+}
+
+func (l *Logger) backup(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lock.Close()
+	return l.file.Close()
+}
+
+func (l *Logger) sanitize(key string, value interface{}) interface{} {
+	if key != "" && l.redacted(key) {
+		return redactedValue
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = l.sanitize(k, item)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = l.sanitize(k, item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = l.sanitize("", item)
+		}
+		return out
+	case string:
+		return truncate(v, maxArgLength)
+	default:
+		return v
+	}
+}
+
+func (l *Logger) redacted(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range l.redact {
+		if matched, _ := path.Match(strings.ToLower(pattern), key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return fmt.Sprintf("%s...(%d bytes)", s[:maxLen], len(s))
+}
+
+// Filter selects entries in Query. Zero fields match everything.
+type Filter struct {
+	SessionID string
+	Tool      string
+	Since     time.Time
+	Until     time.Time
+	// Limit keeps only the most recent matches.
+	Limit int
+}
+
+func (f Filter) match(e Entry) bool {
+	switch {
+	case f.SessionID != "" && e.SessionID != f.SessionID:
+		return false
+	case f.Tool != "" && !strings.Contains(e.Tool, f.Tool):
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Query reads the rotated and current log files and returns the matching
+// entries, oldest first. It does not block writers, so a rotation during the
+// query can make it miss entries.
+func (l *Logger) Query(f Filter) ([]Entry, error) {
+	var files []string
+	for i := l.maxBackups; i > 0; i-- {
+		files = append(files, l.backup(i))
+	}
+	files = append(files, l.path)
+
+	entries := []Entry{}
+	for _, name := range files {
+		if err := scanFile(name, func(e Entry) {
+			if !f.match(e) {
+				return
+			}
+			entries = append(entries, e)
+			if f.Limit > 0 && len(entries) > 2*f.Limit {
+				entries = append(entries[:0], entries[len(entries)-f.Limit:]...)
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if f.Limit > 0 && len(entries) > f.Limit {
+		entries = entries[len(entries)-f.Limit:]
+	}
+	return entries, nil
+}
+
+func scanFile(name string, fn func(Entry)) error {
+	file, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		// A line cut short by a crash is skipped rather than failing the query.
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			fn(e)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,217 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(t *testing.T, opts Options) *Logger {
+	t.Helper()
+	l, err := New(filepath.Join(t.TempDir(), "audit", "audit.jsonl"), opts)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestLogger_RecordRedactsAndTruncates(t *testing.T) {
+	l := newTestLogger(t, Options{Redact: []string{"session_key"}})
+
+	err := l.Record(Entry{
+		Source: SourceHTTP,
+		Tool:   "POST /v1/bash/exec",
+		Args: map[string]interface{}{
+			"command":     strings.Repeat("x", 2000),
+			"Password":    "hunter2",
+			"session_key": "abc",
+			"env":         map[string]string{"GITHUB_TOKEN": "ghp_x", "HOME": "/root"},
+		},
+		Status: StatusOK,
+		Result: strings.Repeat("y", 1000),
+	})
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	entries, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("len(entries) = %d, want 1", len(entries))
+	}
+	args := entries[0].Args
+	if args["Password"] != redactedValue || args["session_key"] != redactedValue {
+		t.Errorf("secrets not redacted: %v", args)
+	}
+	env := args["env"].(map[string]interface{})
+	if env["GITHUB_TOKEN"] != redactedValue || env["HOME"] != "/root" {
+		t.Errorf("env = %v, want token redacted and HOME kept", env)
+	}
+	if cmd := args["command"].(string); len(cmd) > maxArgLength+32 || !strings.HasSuffix(cmd, "(2000 bytes)") {
+		t.Errorf("command not truncated: %d bytes", len(cmd))
+	}
+	if !strings.HasSuffix(entries[0].Result, "(1000 bytes)") {
+		t.Errorf("result not truncated: %q", entries[0].Result)
+	}
+	if entries[0].Time.IsZero() {
+		t.Error("entry time not set")
+	}
+}
+
+func TestLogger_Query(t *testing.T) {
+	l := newTestLogger(t, Options{})
+	base := time.Now().Add(-time.Hour)
+
+	for i, e := range []Entry{
+		{SessionID: "a", Tool: "Bash"},
+		{SessionID: "b", Tool: "Write"},
+		{SessionID: "a", Tool: "POST /v1/file/write"},
+		{SessionID: "a", Tool: "Bash"},
+	} {
+		e.Time = base.Add(time.Duration(i) * time.Minute)
+		e.Status = StatusOK
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "all", filter: Filter{}, want: []string{"Bash", "Write", "POST /v1/file/write", "Bash"}},
+		{name: "session", filter: Filter{SessionID: "b"}, want: []string{"Write"}},
+		{name: "tool substring", filter: Filter{Tool: "rite"}, want: []string{"Write", "POST /v1/file/write"}},
+		{name: "since until", filter: Filter{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, want: []string{"Write", "POST /v1/file/write"}},
+		{name: "limit keeps newest", filter: Filter{SessionID: "a", Limit: 2}, want: []string{"POST /v1/file/write", "Bash"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Tool)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogger_Rotate(t *testing.T) {
+	l := newTestLogger(t, Options{MaxSize: 300, MaxBackups: 2})
+
+	for i := 0; i < 20; i++ {
+		if err := l.Record(Entry{Tool: "Bash", Status: StatusOK, Result: strings.Repeat("r", 100)}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	for _, name := range []string{l.path, l.backup(1), l.backup(2)} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat(%s) error = %v", name, err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("%s is %d bytes, want at most 300", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(l.backup(3)); !os.IsNotExist(err) {
+		t.Errorf("backup 3 exists, want at most 2 backups")
+	}
+
+	entries, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(entries) == 0 || len(entries) >= 20 {
+		t.Errorf("len(entries) = %d, want the entries of the kept files only", len(entries))
+	}
+}
+
+func TestLogger_ReopensAfterExternalRotation(t *testing.T) {
+	l := newTestLogger(t, Options{})
+	other, err := New(l.path, Options{MaxSize: 1, MaxBackups: 1})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer other.Close()
+
+	l.Record(Entry{Tool: "first", Status: StatusOK})
+	// The second logger rotates the file the first one has open.
+	other.Record(Entry{Tool: "second", Status: StatusOK})
+	l.Record(Entry{Tool: "third", Status: StatusOK})
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"tool":"third"`) {
+		t.Errorf("current log = %s, want the entry written after rotation", data)
+	}
+}
+
+func TestCall(t *testing.T) {
+	l := newTestLogger(t, Options{})
+
+	ctx, call := Begin(context.Background(), l, Entry{
+		Source:    SourceMCP,
+		SessionID: "s1",
+		Subject:   "alice",
+		Tool:      "Bash",
+	})
+	SetExitCode(ctx, 2)
+	AddBytesChanged(ctx, 10)
+	AddBytesChanged(ctx, 5)
+	if err := Record(ctx, Entry{Tool: "exec", Status: StatusOK}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := call.Finish(StatusError, "", "exit status 2"); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	// Without a call in the context the helpers do nothing.
+	SetExitCode(context.Background(), 1)
+	if err := Record(context.Background(), Entry{Tool: "ignored"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	entries, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+
+	nested, finished := entries[0], entries[1]
+	if nested.Tool != "exec" || nested.SessionID != "s1" || nested.Subject != "alice" || nested.Source != SourceMCP {
+		t.Errorf("nested entry = %+v, want call details", nested)
+	}
+	if finished.ExitCode == nil || *finished.ExitCode != 2 {
+		t.Errorf("ExitCode = %v, want 2", finished.ExitCode)
+	}
+	if finished.BytesChanged != 15 || finished.Status != StatusError || finished.Error != "exit status 2" {
+		t.Errorf("finished entry = %+v", finished)
+	}
+}
+
+func TestLogger_Nil(t *testing.T) {
+	var l *Logger
+	if err := l.Record(Entry{Tool: "Bash"}); err != nil {
+		t.Errorf("Record() on nil logger error = %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("Close() on nil logger error = %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type callKey struct{}
+
+// Call collects the outcome of one audited request while it runs. Handlers
+// add details such as the exit code through the request context.
+type Call struct {
+	mu     sync.Mutex
+	logger *Logger
+	entry  Entry
+	start  time.Time
+}
+
+// Begin starts auditing a request described by e and returns a context that
+// carries the call.
+func Begin(ctx context.Context, l *Logger, e Entry) (context.Context, *Call) {
+	call := &Call{logger: l, entry: e, start: time.Now()}
+	return context.WithValue(ctx, callKey{}, call), call
+}
+
+func fromContext(ctx context.Context) *Call {
+	call, _ := ctx.Value(callKey{}).(*Call)
+	return call
+}
+
+// Finish writes the entry of the call.
+func (c *Call) Finish(status, result, errMsg string) error {
+	c.mu.Lock()
+	e := c.entry
+	c.mu.Unlock()
+
+	e.Time = c.start
+	e.Status = status
+	e.Result = result
+	e.Error = errMsg
+	e.DurationMs = time.Since(c.start).Milliseconds()
+	return c.logger.Record(e)
+}
+
+// SetExitCode records the exit code of the command run by the current call.
+func SetExitCode(ctx context.Context, code int) {
+	if call := fromContext(ctx); call != nil {
+		call.mu.Lock()
+		call.entry.ExitCode = &code
+		call.mu.Unlock()
+	}
+}
+
+// AddBytesChanged adds to the number of bytes written by the current call.
+func AddBytesChanged(ctx context.Context, n int64) {
+	if call := fromContext(ctx); call != nil {
+		call.mu.Lock()
+		call.entry.BytesChanged += n
+		call.mu.Unlock()
+	}
+}
+
+// Record writes a separate entry for work done within the current call, such
+// as a command run over a WebSocket after the upgrade request was audited.
+// Source, session and subject are taken from the call, as is the tool name
+// unless e sets one.
+func Record(ctx context.Context, e Entry) error {
+	call := fromContext(ctx)
+	if call == nil {
+		return nil
+	}
+	call.mu.Lock()
+	e.Source = call.entry.Source
+	e.SessionID = call.entry.SessionID
+	e.Subject = call.entry.Subject
+	if e.Tool == "" {
+		e.Tool = call.entry.Tool
+	}
+	call.mu.Unlock()
+	return call.logger.Record(e)
+}
@@ -0,0 +1,231 @@
+package bash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+var ErrApprovalNotFound = errors.New("approval not found")
+
+// Approval is a command held back by an ask rule until it is approved or
+// rejected.
+type Approval struct {
+	ID        string         `json:"id"`
+	SessionID string         `json:"session_id"`
+	Command   string         `json:"command"`
+	State     string         `json:"state"`
+	Decision  PolicyDecision `json:"decision"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+type approvalResult struct {
+	approved bool
+	reason   string
+}
+
+type pendingApproval struct {
+	info Approval
+	done chan approvalResult
+}
+
+// CommandGuard checks commands against the server policy and the rules a
+// session adds to it, and holds commands that need approval until they are
+// resolved.
+type CommandGuard struct {
+	mu              sync.Mutex
+	policy          *CommandPolicy
+	sessions        map[string]*CommandPolicy
+	approvals       map[string]*pendingApproval
+	approvalTimeout time.Duration
+	seq             int
+}
+
+// NewCommandGuard creates a guard for policy. A non-positive approval timeout
+// disables approvals, so commands matching an ask rule are denied.
+func NewCommandGuard(policy *CommandPolicy, approvalTimeout time.Duration) *CommandGuard {
+	if policy == nil {
+		policy = DefaultCommandPolicy()
+	}
+	return &CommandGuard{
+		policy:          policy,
+		sessions:        make(map[string]*CommandPolicy),
+		approvals:       make(map[string]*pendingApproval),
+		approvalTimeout: approvalTimeout,
+	}
+}
+
+// Policy returns the policy a session adds to the server policy, or the
+// server policy for a session without one or an empty session ID.
+func (g *CommandGuard) Policy(sessionID string) *CommandPolicy {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.policyLocked(sessionID)
+}
+
+func (g *CommandGuard) policyLocked(sessionID string) *CommandPolicy {
+	if p, ok := g.sessions[sessionID]; ok && sessionID != "" {
+		return p
+	}
+	return g.policy
+}
+
+// SetSessionPolicy sets the policy checked for one session in addition to the
+// server policy, so it can only tighten it. A nil policy removes it.
+func (g *CommandGuard) SetSessionPolicy(sessionID string, policy *CommandPolicy) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if policy == nil {
+		delete(g.sessions, sessionID)
+		return
+	}
+	g.sessions[sessionID] = policy
+}
+
+// Check returns nil when the command may run. Commands that need approval
+// block until they are approved, rejected, time out or ctx is done. Denials
+// are reported as *PolicyError.
+func (g *CommandGuard) Check(ctx context.Context, sessionID, command string) error {
+	if g == nil {
+		return nil
+	}
+
+	decision := g.Evaluate(sessionID, command)
+	switch decision.Action {
+	case PolicyAllow:
+		return nil
+	case PolicyAsk:
+		return g.await(ctx, sessionID, command, decision)
+	default:
+		return &PolicyError{Decision: decision}
+	}
+}
+
+// Evaluate checks a command against the server policy and the session's
+// policy; deny wins over ask, and ask over allow.
+func (g *CommandGuard) Evaluate(sessionID, command string) PolicyDecision {
+	g.mu.Lock()
+	session := g.sessions[sessionID]
+	g.mu.Unlock()
+
+	decision := g.policy.Evaluate(command)
+	if session == nil || decision.Action == PolicyDeny {
+		return decision
+	}
+	if d := session.Evaluate(command); d.Action == PolicyDeny || decision.Action == PolicyAllow {
+		return d
+	}
+	return decision
+}
+
+func (g *CommandGuard) await(ctx context.Context, sessionID, command string, decision PolicyDecision) error {
+	if g.approvalTimeout <= 0 {
+		decision.Action = PolicyDeny
+		decision.Reason += " (requires approval, which is not enabled)"
+		return &PolicyError{Decision: decision}
+	}
+
+	p := g.addApproval(sessionID, command, decision)
+	defer g.removeApproval(p.info.ID)
+
+	timer := time.NewTimer(g.approvalTimeout)
+	defer timer.Stop()
+
+	decision.Action = PolicyDeny
+	select {
+	case result := <-p.done:
+		if result.approved {
+			return nil
+		}
+		decision.Reason = "rejected by reviewer"
+		if result.reason != "" {
+			decision.Reason += ": " + result.reason
+		}
+	case <-timer.C:
+		decision.Reason = fmt.Sprintf("approval timed out after %v", g.approvalTimeout)
+	case <-ctx.Done():
+		return fmt.Errorf("cancelled while waiting for approval: %w", ctx.Err())
+	}
+	return &PolicyError{Decision: decision}
+}
+
+func (g *CommandGuard) addApproval(sessionID, command string, decision PolicyDecision) *pendingApproval {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.seq++
+	now := time.Now()
+	p := &pendingApproval{
+		info: Approval{
+			ID:        fmt.Sprintf("approval_%d", g.seq),
+			SessionID: sessionID,
+			Command:   command,
+			State:     ApprovalPending,
+			Decision:  decision,
+			CreatedAt: now,
+			ExpiresAt: now.Add(g.approvalTimeout),
+		},
+		done: make(chan approvalResult, 1),
+	}
+	g.approvals[p.info.ID] = p
+	return p
+}
+
+func (g *CommandGuard) removeApproval(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.approvals, id)
+}
+
+// Approvals lists the commands waiting for approval, oldest first.
+func (g *CommandGuard) Approvals() []Approval {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	approvals := make([]Approval, 0, len(g.approvals))
+	for _, p := range g.approvals {
+		approvals = append(approvals, p.info)
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].CreatedAt.Before(approvals[j].CreatedAt)
+	})
+	return approvals
+}
+
+func (g *CommandGuard) Approve(id string) (*Approval, error) {
+	return g.resolve(id, approvalResult{approved: true})
+}
+
+func (g *CommandGuard) Reject(id, reason string) (*Approval, error) {
+	return g.resolve(id, approvalResult{reason: reason})
+}
+
+func (g *CommandGuard) resolve(id string, result approvalResult) (*Approval, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, ok := g.approvals[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrApprovalNotFound, id)
+	}
+	delete(g.approvals, id)
+	p.done <- result
+
+	info := p.info
+	info.State = ApprovalRejected
+	if result.approved {
+		info.State = ApprovalApproved
+	}
+	return &info, nil
+}
@@ -0,0 +1,268 @@
+package bash
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	cgroupMount     = "/sys/fs/cgroup"
+	cgroupCPUPeriod = 100000
+)
+
+var cgroupNameSanitizer = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+var cgroupControllers = []string{"cpu", "memory", "pids"}
+
+// CgroupManager creates one cgroup v2 child per shell session below a base
+// directory, so memory.max, pids.max and cpu.max cover the whole process tree
+// of the session.
+type CgroupManager struct {
+	base string
+}
+
+// NewCgroupManager prepares a cgroup v2 sub-tree under root. An empty root
+// uses the cgroup of the current process, whose processes are moved to a leaf
+// cgroup first. It fails when cgroup v2 is not mounted or writable, or the
+// required controllers cannot be delegated.
+func NewCgroupManager(root string) (*CgroupManager, error) {
+	own := root == ""
+	if own {
+		var err error
+		if root, err = ownCgroup(); err != nil {
+			return nil, err
+		}
+	}
+
+	available, err := os.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	if err != nil {
+		return nil, fmt.Errorf("cgroup v2 is not available at %s: %w", root, err)
+	}
+	fields := strings.Fields(string(available))
+	for _, controller := range cgroupControllers {
+		if !contains(fields, controller) {
+			return nil, fmt.Errorf("cgroup controller %s is not available at %s", controller, root)
+		}
+	}
+
+	if own {
+		if err := evacuate(root); err != nil {
+			return nil, err
+		}
+	}
+	if err := enableControllers(root); err != nil {
+		return nil, err
+	}
+
+	base := filepath.Join(root, fmt.Sprintf("sandbox-%d", os.Getpid()))
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup %s: %w", base, err)
+	}
+	if err := enableControllers(base); err != nil {
+		os.Remove(base)
+		return nil, err
+	}
+
+	return &CgroupManager{base: base}, nil
+}
+
+func ownCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("failed to read own cgroup: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return filepath.Join(cgroupMount, path), nil
+		}
+	}
+	return "", errors.New("cgroup v2 hierarchy not found for current process")
+}
+
+// evacuate moves the processes of the cgroup dir to its leaf cgroup init.
+// cgroup v2 lets only a cgroup without processes of its own enable
+// controllers for its children.
+func evacuate(dir string) error {
+	leaf := filepath.Join(dir, "init")
+	if err := os.MkdirAll(leaf, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup %s: %w", leaf, err)
+	}
+	// Processes started while moving the others need another pass.
+	for i := 0; i < 10; i++ {
+		data, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+		if err != nil {
+			return fmt.Errorf("failed to list the processes of cgroup %s: %w", dir, err)
+		}
+		pids := strings.Fields(string(data))
+		if len(pids) == 0 {
+			return nil
+		}
+		for _, pid := range pids {
+			err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(pid), 0644)
+			if err != nil && !errors.Is(err, syscall.ESRCH) {
+				return fmt.Errorf("failed to move process %s to cgroup %s: %w", pid, leaf, err)
+			}
+		}
+	}
+	return fmt.Errorf("failed to move the processes of cgroup %s to %s", dir, leaf)
+}
+
+func enableControllers(dir string) error {
+	var enable []string
+	for _, controller := range cgroupControllers {
+		enable = append(enable, "+"+controller)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0644); err != nil {
+		return fmt.Errorf("failed to enable cgroup controllers in %s: %w", dir, err)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *CgroupManager) create(name string, limits Limits) (*sessionCgroup, error) {
+	path := filepath.Join(m.base, cgroupNameSanitizer.ReplaceAllString(name, "_"))
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("failed to create session cgroup: %w", err)
+	}
+
+	cg := &sessionCgroup{path: path, limits: limits}
+	settings := map[string]string{}
+	if limits.Memory > 0 {
+		settings["memory.max"] = strconv.FormatInt(limits.Memory, 10)
+		settings["memory.swap.max"] = "0"
+	}
+	if limits.Processes > 0 {
+		settings["pids.max"] = strconv.Itoa(limits.Processes)
+	}
+	if limits.CPUQuota > 0 {
+		settings["cpu.max"] = fmt.Sprintf("%d %d", int64(limits.CPUQuota*cgroupCPUPeriod), cgroupCPUPeriod)
+	}
+	if err := cg.set(settings); err != nil {
+		cg.remove()
+		return nil, err
+	}
+	return cg, nil
+}
+
+func (c *sessionCgroup) set(settings map[string]string) error {
+	for file, value := range settings {
+		err := os.WriteFile(filepath.Join(c.path, file), []byte(value), 0644)
+		if err != nil && file != "memory.swap.max" {
+			return fmt.Errorf("failed to set %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// tighten lowers the memory and process limits of the cgroup to those set in
+// limits for one command, returning a function that restores them.
+func (c *sessionCgroup) tighten(limits Limits) (restore func(), err error) {
+	lowered, previous := map[string]string{}, map[string]string{}
+	if limits.Memory > 0 {
+		lowered["memory.max"] = strconv.FormatInt(limits.Memory, 10)
+		previous["memory.max"] = cgroupMax(c.limits.Memory)
+	}
+	if limits.Processes > 0 {
+		lowered["pids.max"] = strconv.Itoa(limits.Processes)
+		previous["pids.max"] = cgroupMax(int64(c.limits.Processes))
+	}
+	restore = func() { c.set(previous) }
+	if err := c.set(lowered); err != nil {
+		restore()
+		return nil, err
+	}
+	return restore, nil
+}
+
+// cgroupMax formats a limit for memory.max or pids.max.
+func cgroupMax(limit int64) string {
+	if limit <= 0 {
+		return "max"
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+// Close removes the base cgroup. Session cgroups must already be empty.
+func (m *CgroupManager) Close() error {
+	return os.Remove(m.base)
+}
+
+type sessionCgroup struct {
+	path   string
+	limits Limits
+}
+
+type cgroupEvents struct {
+	oomKills int64
+	pidsMax  int64
+}
+
+func (c *sessionCgroup) add(pid int) error {
+	if err := os.WriteFile(filepath.Join(c.path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("failed to move process into cgroup: %w", err)
+	}
+	return nil
+}
+
+func (c *sessionCgroup) events() cgroupEvents {
+	return cgroupEvents{
+		oomKills: readCgroupEvent(filepath.Join(c.path, "memory.events"), "oom_kill"),
+		pidsMax:  readCgroupEvent(filepath.Join(c.path, "pids.events"), "max"),
+	}
+}
+
+// exceeded compares event counters taken before and after a command run
+// with limits and reports the first cgroup limit that was hit in between.
+func (c *sessionCgroup) exceeded(limits Limits, before, after cgroupEvents) (string, string) {
+	switch {
+	case after.oomKills > before.oomKills:
+		return LimitMemory, fmt.Sprintf("memory limit of %s exceeded; a process was killed by the OOM killer", formatBytes(limits.Memory))
+	case after.pidsMax > before.pidsMax:
+		return LimitProcesses, fmt.Sprintf("process limit of %d reached; new processes could not be created", limits.Processes)
+	default:
+		return "", ""
+	}
+}
+
+func (c *sessionCgroup) remove() {
+	for i := 0; i < 10; i++ {
+		if err := os.Remove(c.path); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func readCgroupEvent(file, key string) int64 {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, ok := strings.Cut(line, " ")
+		if ok && name == key {
+			n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
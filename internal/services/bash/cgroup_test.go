@@ -0,0 +1,119 @@
+package bash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newFakeCgroupRoot(t *testing.T, controllers string) string {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte(controllers+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestNewCgroupManager_MissingController(t *testing.T) {
+	root := newFakeCgroupRoot(t, "cpu memory")
+
+	if _, err := NewCgroupManager(root); err == nil || !strings.Contains(err.Error(), "pids") {
+		t.Errorf("expected missing pids controller error, got %v", err)
+	}
+}
+
+func TestNewCgroupManager_NotCgroupV2(t *testing.T) {
+	if _, err := NewCgroupManager(t.TempDir()); err == nil {
+		t.Error("expected error for a directory without cgroup.controllers")
+	}
+}
+
+func TestCgroupManager_Create(t *testing.T) {
+	root := newFakeCgroupRoot(t, "cpuset cpu io memory pids")
+
+	m, err := NewCgroupManager(root)
+	if err != nil {
+		t.Fatalf("NewCgroupManager failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(root, "cgroup.subtree_control")); got != "+cpu +memory +pids" {
+		t.Errorf("unexpected subtree_control %q", got)
+	}
+
+	cg, err := m.create("s/1", Limits{Memory: 64 << 20, Processes: 32, CPUQuota: 0.5})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if filepath.Dir(cg.path) != m.base || filepath.Base(cg.path) != "s_1" {
+		t.Errorf("unexpected cgroup path %s", cg.path)
+	}
+
+	want := map[string]string{
+		"memory.max": "67108864",
+		"pids.max":   "32",
+		"cpu.max":    "50000 100000",
+	}
+	for file, value := range want {
+		if got := readFile(t, filepath.Join(cg.path, file)); got != value {
+			t.Errorf("%s = %q, want %q", file, got, value)
+		}
+	}
+}
+
+func TestSessionCgroup_Exceeded(t *testing.T) {
+	cg := &sessionCgroup{path: t.TempDir(), limits: Limits{Memory: 1 << 20, Processes: 8}}
+	write := func(file, content string) {
+		if err := os.WriteFile(filepath.Join(cg.path, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("memory.events", "low 0\nhigh 0\nmax 3\noom 1\noom_kill 0\n")
+	write("pids.events", "max 0\n")
+	before := cg.events()
+
+	write("pids.events", "max 2\n")
+	if name, _ := cg.exceeded(cg.limits, before, cg.events()); name != LimitProcesses {
+		t.Errorf("expected %q, got %q", LimitProcesses, name)
+	}
+
+	write("memory.events", "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n")
+	if name, msg := cg.exceeded(cg.limits, before, cg.events()); name != LimitMemory || !strings.Contains(msg, "1.0MiB") {
+		t.Errorf("expected %q, got %q %q", LimitMemory, name, msg)
+	}
+
+	if name, _ := cg.exceeded(cg.limits, before, before); name != "" {
+		t.Errorf("expected no limit, got %q", name)
+	}
+}
+
+func TestSessionCgroup_Tighten(t *testing.T) {
+	cg := &sessionCgroup{path: t.TempDir(), limits: Limits{Processes: 64}}
+
+	restore, err := cg.tighten(Limits{Memory: 32 << 20, Processes: 8})
+	if err != nil {
+		t.Fatalf("tighten failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(cg.path, "memory.max")); got != "33554432" {
+		t.Errorf("memory.max = %q, want 33554432", got)
+	}
+	if got := readFile(t, filepath.Join(cg.path, "pids.max")); got != "8" {
+		t.Errorf("pids.max = %q, want 8", got)
+	}
+
+	restore()
+	if got := readFile(t, filepath.Join(cg.path, "memory.max")); got != "max" {
+		t.Errorf("restored memory.max = %q, want max", got)
+	}
+	if got := readFile(t, filepath.Join(cg.path, "pids.max")); got != "64" {
+		t.Errorf("restored pids.max = %q, want 64", got)
+	}
+}
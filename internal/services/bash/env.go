@@ -0,0 +1,138 @@
+package bash
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// DefaultDeniedEnv lists variables that let a caller hijack the dynamic loader
+// or the shell itself, so they are rejected unless explicitly allowed.
+var DefaultDeniedEnv = []string{
+	"LD_*",
+	"BASH_ENV",
+	"ENV",
+	"BASH_FUNC_*",
+	"SHELLOPTS",
+	"BASHOPTS",
+	"PROMPT_COMMAND",
+	"PS4",
+	"IFS",
+}
+
+type EnvPolicy struct {
+	allow []string
+	deny  []string
+}
+
+// NewEnvPolicy builds a policy from name globs. When allow is non-empty only
+// matching names are accepted; deny is applied on top of DefaultDeniedEnv.
+func NewEnvPolicy(allow, deny []string) *EnvPolicy {
+	return &EnvPolicy{
+		allow: allow,
+		deny:  append(append([]string{}, DefaultDeniedEnv...), deny...),
+	}
+}
+
+func DefaultEnvPolicy() *EnvPolicy {
+	return NewEnvPolicy(nil, nil)
+}
+
+func (p *EnvPolicy) Validate(env map[string]string) error {
+	var denied []string
+	for name, value := range env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name: %q", name)
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("environment variable %s contains a NUL byte", name)
+		}
+		if !p.allowed(name) {
+			denied = append(denied, name)
+		}
+	}
+
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		return fmt.Errorf("environment variables not allowed by policy: %s", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+func (p *EnvPolicy) allowed(name string) bool {
+	if len(p.allow) > 0 && !matchEnvName(p.allow, name) {
+		return false
+	}
+	return !matchEnvName(p.deny, name)
+}
+
+func matchEnvName(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeEnv returns a new map in which later maps override earlier ones.
+func MergeEnv(envs ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, env := range envs {
+		for k, v := range env {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// serverSecrets are server variables commands must not see: they would let
+// an agent mint API tokens or act as an administrator.
+var serverSecrets = []string{"JWT_SECRET", "SANDBOX_ADMIN_TOKEN"}
+
+func processEnv(env map[string]string) []string {
+	var list []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(serverSecrets, name) {
+			list = append(list, kv)
+		}
+	}
+	return append(list, envList(env)...)
+}
+
+func envNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func envList(env map[string]string) []string {
+	names := envNames(env)
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		list = append(list, name+"="+env[name])
+	}
+	return list
+}
+
+func envAssignments(env map[string]string) string {
+	var b strings.Builder
+	for _, kv := range envList(env) {
+		name, value, _ := strings.Cut(kv, "=")
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(shellQuote(value))
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
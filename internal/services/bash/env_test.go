@@ -0,0 +1,92 @@
+package bash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *EnvPolicy
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:   "plain variables allowed by default",
+			policy: DefaultEnvPolicy(),
+			env:    map[string]string{"FOO": "bar", "_X1": ""},
+		},
+		{
+			name:    "loader variables denied by default",
+			policy:  DefaultEnvPolicy(),
+			env:     map[string]string{"LD_PRELOAD": "/tmp/x.so", "BASH_ENV": "/tmp/rc"},
+			wantErr: "BASH_ENV, LD_PRELOAD",
+		},
+		{
+			name:    "invalid name",
+			policy:  DefaultEnvPolicy(),
+			env:     map[string]string{"1BAD": "x"},
+			wantErr: "invalid environment variable name",
+		},
+		{
+			name:    "NUL byte in value",
+			policy:  DefaultEnvPolicy(),
+			env:     map[string]string{"FOO": "a\x00b"},
+			wantErr: "NUL byte",
+		},
+		{
+			name:    "allow list restricts names",
+			policy:  NewEnvPolicy([]string{"APP_*"}, nil),
+			env:     map[string]string{"APP_MODE": "dev", "OTHER": "x"},
+			wantErr: "OTHER",
+		},
+		{
+			name:    "custom deny applies on top of defaults",
+			policy:  NewEnvPolicy(nil, []string{"AWS_*"}),
+			env:     map[string]string{"AWS_SECRET_ACCESS_KEY": "x"},
+			wantErr: "AWS_SECRET_ACCESS_KEY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.env)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEnvAssignments(t *testing.T) {
+	got := envAssignments(map[string]string{"B": "it's", "A": "1"})
+	want := `A='1' B='it'\''s' `
+	if got != want {
+		t.Errorf("envAssignments() = %q, want %q", got, want)
+	}
+}
+
+func TestProcessEnv_Secrets(t *testing.T) {
+	t.Setenv("SANDBOX_ADMIN_TOKEN", "secret")
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("SANDBOX_TEST_VAR", "kept")
+
+	env := strings.Join(processEnv(map[string]string{"EXTRA": "1"}), "\n")
+	for _, name := range serverSecrets {
+		if strings.Contains(env, name+"=") {
+			t.Errorf("expected %s to be removed from the command environment", name)
+		}
+	}
+	for _, kv := range []string{"SANDBOX_TEST_VAR=kept", "EXTRA=1"} {
+		if !strings.Contains(env, kv) {
+			t.Errorf("expected %s in the command environment", kv)
+		}
+	}
+}
@@ -8,11 +8,11 @@ import (
 	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/deep-agent/sandbox/internal/services/identity"
 	"github.com/deep-agent/sandbox/pkg/safe"
 )
 
@@ -28,8 +28,21 @@ type StreamChunk struct {
 
 type StreamCallback func(chunk StreamChunk)
 
+// ExecOptions carries the per-call settings of an Executor run. A zero
+// Timeout uses the executor's default; values above its maximum are capped.
+type ExecOptions struct {
+	Timeout  time.Duration
+	Env      map[string]string
+	Stdin    io.Reader
+	Limits   Limits
+	Truncate *TruncateOptions
+	// User runs the command with the credentials of a session user instead of
+	// the server's own.
+	User *identity.User
+}
+
 type Executor struct {
-	timeout time.Duration
+	opts options
 }
 
 type ExecResult struct {
@@ -40,21 +53,23 @@ type ExecResult struct {
 	DurationMs int64  `json:"duration_ms"`
 	TimedOut   bool   `json:"timed_out"`
 	Truncated  bool   `json:"truncated"`
-	Metadata   string `json:"metadata,omitempty"`
-	OutputFile string `json:"output_file,omitempty"`
+	TimeoutMs  int64  `json:"timeout_ms,omitempty"`
+	// LimitExceeded names the resource limit that stopped the command, if any.
+	LimitExceeded string `json:"limit_exceeded,omitempty"`
+	Metadata      string `json:"metadata,omitempty"`
 }
 
-func NewExecutor() *Executor {
+func NewExecutor(opts ...Option) *Executor {
 	return &Executor{
-		timeout: 30 * time.Second,
+		opts: newOptions(opts),
 	}
 }
 
-func (e *Executor) SetTimeout(timeout time.Duration) {
-	e.timeout = timeout
+func (e *Executor) validateWorkDir(workDir string) error {
+	return validateDir(workDir)
 }
 
-func (e *Executor) validateWorkDir(workDir string) error {
+func validateDir(workDir string) error {
 	info, err := os.Stat(workDir)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -68,87 +83,47 @@ func (e *Executor) validateWorkDir(workDir string) error {
 	return nil
 }
 
-func (e *Executor) ExecuteBackground(ctx context.Context, command string, workDir string, timeout time.Duration) (*ExecResult, error) {
-	startTime := time.Now()
-
-	if err := e.validateWorkDir(workDir); err != nil {
+func (e *Executor) Execute(ctx context.Context, command string, workDir string, opts ExecOptions) (*ExecResult, error) {
+	if err := e.opts.guard.Check(ctx, "", command); err != nil {
 		return nil, err
 	}
-
-	outputDir := filepath.Join(workDir, ".logs", "background_outputs")
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create output directory: %w", err)
-	}
-
-	outputFile := filepath.Join(outputDir, fmt.Sprintf("bg_%d.log", startTime.UnixNano()))
-
-	wrappedCommand := fmt.Sprintf("script -q -f -c %q %q", command, outputFile)
-	cmd := exec.Command("bash", "-c", wrappedCommand)
-	cmd.Dir = workDir
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-	}
-
-	if err := cmd.Start(); err != nil {
-		os.Remove(outputFile)
-		return nil, fmt.Errorf("failed to start background command: %w", err)
-	}
-
-	safe.Go(func() {
-		done := make(chan struct{})
-		go func() {
-			cmd.Wait()
-			close(done)
-		}()
-
-		select {
-		case <-done:
-		case <-time.After(timeout):
-			if cmd.Process != nil {
-				syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
-			}
-			<-done
-		}
-	})
-
-	return &ExecResult{
-		Output:     fmt.Sprintf("Command started in background (PID: %d)", cmd.Process.Pid),
-		ExitCode:   0,
-		DurationMs: time.Since(startTime).Milliseconds(),
-		OutputFile: outputFile,
-	}, nil
-}
-
-func (e *Executor) Execute(ctx context.Context, command string, workDir string, truncateOpts *TruncateOptions) (*ExecResult, error) {
 	startTime := time.Now()
 
 	if err := e.validateWorkDir(workDir); err != nil {
 		return nil, err
 	}
-
-	if e.timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, e.timeout)
-		defer cancel()
+	if err := e.opts.envPolicy.Validate(opts.Env); err != nil {
+		return nil, err
 	}
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	timeout := e.opts.resolveTimeout(opts.Timeout)
+	timeout.clampTo(ctx)
+	ctx, cancel := context.WithTimeout(ctx, timeout.effective)
+	defer cancel()
+
+	limits := opts.Limits.Within(e.opts.limits)
+	budget := newOutputBudget(limits.Output, cancel)
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", limits.ulimitScript()+command)
 	cmd.Dir = workDir
+	cmd.Env = processEnv(opts.Env)
+	cmd.Stdin = opts.Stdin
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setpgid: true,
 	}
+	runAs(cmd, opts.User)
 
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = budgetWriter{w: &stdout, budget: budget}
+	cmd.Stderr = budgetWriter{w: &stderr, budget: budget}
 
 	err := cmd.Run()
 	durationMs := time.Since(startTime).Milliseconds()
 
-	return e.buildResultWithTruncate(ctx, stdout.String(), stderr.String(), durationMs, err, truncateOpts)
+	return e.buildResultWithTruncate(ctx, stdout.String(), stderr.String(), durationMs, err, timeout, limits, budget, opts.Truncate)
 }
 
-func (e *Executor) buildResultWithTruncate(ctx context.Context, stdoutStr, stderrStr string, durationMs int64, err error, truncateOpts *TruncateOptions) (*ExecResult, error) {
+func (e *Executor) buildResultWithTruncate(ctx context.Context, stdoutStr, stderrStr string, durationMs int64, err error, timeout resolvedTimeout, limits Limits, budget *outputBudget, truncateOpts *TruncateOptions) (*ExecResult, error) {
 	combinedOutput := stdoutStr + stderrStr
 
 	result := &ExecResult{
@@ -159,13 +134,17 @@ func (e *Executor) buildResultWithTruncate(ctx context.Context, stdoutStr, stder
 		DurationMs: durationMs,
 		TimedOut:   false,
 		Truncated:  false,
+		TimeoutMs:  timeout.effective.Milliseconds(),
 	}
 
-	var metadataLines []string
+	metadataLines := timeout.metadata()
 
-	if ctx.Err() == context.DeadlineExceeded {
+	if budget.wasExceeded() {
+		result.LimitExceeded = LimitOutput
+		metadataLines = append(metadataLines, fmt.Sprintf("output limit of %s exceeded; command was terminated", formatBytes(limits.Output)))
+	} else if ctx.Err() == context.DeadlineExceeded {
 		result.TimedOut = true
-		metadataLines = append(metadataLines, fmt.Sprintf("command timed out after %v", e.timeout))
+		metadataLines = append(metadataLines, fmt.Sprintf("command timed out after %v", timeout.effective))
 	}
 
 	if err != nil {
@@ -176,6 +155,17 @@ func (e *Executor) buildResultWithTruncate(ctx context.Context, stdoutStr, stder
 		}
 	}
 
+	if result.LimitExceeded == "" {
+		if limit, message := limits.exceededFromExitCode(result.ExitCode); limit != "" {
+			result.LimitExceeded = limit
+			metadataLines = append(metadataLines, message)
+		}
+	}
+
+	return finalizeResult(result, metadataLines, truncateOpts), nil
+}
+
+func finalizeResult(result *ExecResult, metadataLines []string, truncateOpts *TruncateOptions) *ExecResult {
 	if truncateOpts != nil && truncateOpts.MaxLines > 0 && truncateOpts.MaxBytes > 0 {
 		truncatedOutput, wasTruncated := truncateOutput(result.Output, truncateOpts.MaxLines, truncateOpts.MaxBytes)
 		if wasTruncated {
@@ -190,27 +180,38 @@ func (e *Executor) buildResultWithTruncate(ctx context.Context, stdoutStr, stder
 		result.Output = result.Output + "\n" + result.Metadata
 	}
 
-	return result, nil
+	return result
 }
 
-func (e *Executor) ExecuteStream(ctx context.Context, command string, workDir string, onChunk StreamCallback, truncateOpts *TruncateOptions) (*ExecResult, error) {
+func (e *Executor) ExecuteStream(ctx context.Context, command string, workDir string, onChunk StreamCallback, opts ExecOptions) (*ExecResult, error) {
+	if err := e.opts.guard.Check(ctx, "", command); err != nil {
+		return nil, err
+	}
 	startTime := time.Now()
 
 	if err := e.validateWorkDir(workDir); err != nil {
 		return nil, err
 	}
-
-	if e.timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, e.timeout)
-		defer cancel()
+	if err := e.opts.envPolicy.Validate(opts.Env); err != nil {
+		return nil, err
 	}
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	timeout := e.opts.resolveTimeout(opts.Timeout)
+	timeout.clampTo(ctx)
+	ctx, cancel := context.WithTimeout(ctx, timeout.effective)
+	defer cancel()
+
+	limits := opts.Limits.Within(e.opts.limits)
+	budget := newOutputBudget(limits.Output, cancel)
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", limits.ulimitScript()+command)
 	cmd.Dir = workDir
+	cmd.Env = processEnv(opts.Env)
+	cmd.Stdin = opts.Stdin
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setpgid: true,
 	}
+	runAs(cmd, opts.User)
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -235,7 +236,7 @@ func (e *Executor) ExecuteStream(ctx context.Context, command string, workDir st
 		reader := bufio.NewReader(pipe)
 		for {
 			line, err := reader.ReadString('\n')
-			if len(line) > 0 {
+			if len(line) > 0 && budget.take(len(line)) {
 				buf.WriteString(line)
 				if onChunk != nil {
 					onChunk(StreamChunk{
@@ -260,7 +261,7 @@ func (e *Executor) ExecuteStream(ctx context.Context, command string, workDir st
 	err = cmd.Wait()
 	durationMs := time.Since(startTime).Milliseconds()
 
-	return e.buildResultWithTruncate(ctx, stdout.String(), stderr.String(), durationMs, err, truncateOpts)
+	return e.buildResultWithTruncate(ctx, stdout.String(), stderr.String(), durationMs, err, timeout, limits, budget, opts.Truncate)
 }
 
 func truncateOutput(output string, maxLines, maxBytes int) (string, bool) {
@@ -2,9 +2,14 @@ package bash
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/deep-agent/sandbox/internal/services/identity"
 )
 
 func TestNewExecutor(t *testing.T) {
@@ -14,19 +19,29 @@ func TestNewExecutor(t *testing.T) {
 		t.Fatal("NewExecutor returned nil")
 	}
 
-	if executor.timeout != 30*time.Second {
-		t.Errorf("expected default timeout 30s, got %v", executor.timeout)
+	if executor.opts.defaultTimeout != 30*time.Second {
+		t.Errorf("expected default timeout 30s, got %v", executor.opts.defaultTimeout)
 	}
 }
 
-func TestSetTimeout(t *testing.T) {
-	executor := NewExecutor()
+func TestNewExecutor_WithTimeouts(t *testing.T) {
+	executor := NewExecutor(WithTimeouts(time.Minute, 2*time.Minute))
 
-	newTimeout := 60 * time.Second
-	executor.SetTimeout(newTimeout)
+	tests := []struct {
+		requested time.Duration
+		want      time.Duration
+		capped    bool
+	}{
+		{0, time.Minute, false},
+		{5 * time.Second, 5 * time.Second, false},
+		{time.Hour, 2 * time.Minute, true},
+	}
 
-	if executor.timeout != newTimeout {
-		t.Errorf("expected timeout %v, got %v", newTimeout, executor.timeout)
+	for _, tt := range tests {
+		got := executor.opts.resolveTimeout(tt.requested)
+		if got.effective != tt.want || got.capped != tt.capped {
+			t.Errorf("resolveTimeout(%v) = (%v, %v), want (%v, %v)", tt.requested, got.effective, got.capped, tt.want, tt.capped)
+		}
 	}
 }
 
@@ -35,7 +50,7 @@ func TestExecute_EchoHello(t *testing.T) {
 	executor := NewExecutor()
 
 	ctx := context.Background()
-	result, err := executor.Execute(ctx, "echo hello", workDir, nil)
+	result, err := executor.Execute(ctx, "echo hello", workDir, ExecOptions{})
 
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
@@ -59,7 +74,7 @@ func TestExecute_ExitCode(t *testing.T) {
 	executor := NewExecutor()
 
 	ctx := context.Background()
-	result, err := executor.Execute(ctx, "exit 42", workDir, nil)
+	result, err := executor.Execute(ctx, "exit 42", workDir, ExecOptions{})
 
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
@@ -73,11 +88,10 @@ func TestExecute_ExitCode(t *testing.T) {
 func TestExecute_Timeout(t *testing.T) {
 	workDir := t.TempDir()
 	executor := NewExecutor()
-	executor.SetTimeout(500 * time.Millisecond)
 
 	ctx := context.Background()
 	startTime := time.Now()
-	result, err := executor.Execute(ctx, "sleep 10", workDir, nil)
+	result, err := executor.Execute(ctx, "sleep 10", workDir, ExecOptions{Timeout: 500 * time.Millisecond})
 	elapsed := time.Since(startTime)
 
 	if err != nil {
@@ -104,7 +118,7 @@ func TestExecute_Stderr(t *testing.T) {
 	executor := NewExecutor()
 
 	ctx := context.Background()
-	result, err := executor.Execute(ctx, "echo error >&2", workDir, nil)
+	result, err := executor.Execute(ctx, "echo error >&2", workDir, ExecOptions{})
 
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
@@ -125,7 +139,7 @@ func TestExecute_WithTruncate(t *testing.T) {
 		MaxBytes: 100,
 	}
 
-	result, err := executor.Execute(ctx, "echo -e 'line1\nline2\nline3\nline4'", workDir, truncateOpts)
+	result, err := executor.Execute(ctx, "echo -e 'line1\nline2\nline3\nline4'", workDir, ExecOptions{Truncate: truncateOpts})
 
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
@@ -266,7 +280,7 @@ func TestExecute_DurationMs(t *testing.T) {
 	executor := NewExecutor()
 
 	ctx := context.Background()
-	result, err := executor.Execute(ctx, "sleep 0.1", workDir, nil)
+	result, err := executor.Execute(ctx, "sleep 0.1", workDir, ExecOptions{})
 
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
@@ -282,7 +296,7 @@ func TestExecute_CombinedOutput(t *testing.T) {
 	executor := NewExecutor()
 
 	ctx := context.Background()
-	result, err := executor.Execute(ctx, "echo stdout; echo stderr >&2", workDir, nil)
+	result, err := executor.Execute(ctx, "echo stdout; echo stderr >&2", workDir, ExecOptions{})
 
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
@@ -300,10 +314,9 @@ func TestExecute_CombinedOutput(t *testing.T) {
 func TestExecute_TimeoutWithOutput(t *testing.T) {
 	workDir := t.TempDir()
 	executor := NewExecutor()
-	executor.SetTimeout(1 * time.Second)
 
 	ctx := context.Background()
-	result, err := executor.Execute(ctx, "for i in 1 2 3 4 5; do echo \"loop $i\"; sleep 1; done", workDir, nil)
+	result, err := executor.Execute(ctx, "for i in 1 2 3 4 5; do echo \"loop $i\"; sleep 1; done", workDir, ExecOptions{Timeout: 1 * time.Second})
 
 	// t.Logf("==================== Timeout Test Result ====================")
 	t.Logf("Error: %v", err)
@@ -324,3 +337,143 @@ func TestExecute_TimeoutWithOutput(t *testing.T) {
 		t.Errorf("expected output to contain 'timed out', got %q", result.Output)
 	}
 }
+
+func TestExecute_TimeoutCapped(t *testing.T) {
+	workDir := t.TempDir()
+	executor := NewExecutor(WithTimeouts(100*time.Millisecond, 300*time.Millisecond))
+
+	result, err := executor.Execute(context.Background(), "sleep 5", workDir, ExecOptions{Timeout: time.Hour})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if !result.TimedOut {
+		t.Fatal("expected TimedOut to be true")
+	}
+	if result.TimeoutMs != 300 {
+		t.Errorf("expected effective timeout 300ms, got %d", result.TimeoutMs)
+	}
+	if !strings.Contains(result.Metadata, "timed out after 300ms") {
+		t.Errorf("expected capped timeout in metadata, got %q", result.Metadata)
+	}
+	if !strings.Contains(result.Metadata, "exceeds the maximum") {
+		t.Errorf("expected cap notice in metadata, got %q", result.Metadata)
+	}
+}
+
+func TestExecute_ContextDeadlineShorterThanTimeout(t *testing.T) {
+	workDir := t.TempDir()
+	executor := NewExecutor()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	result, err := executor.Execute(ctx, "sleep 5", workDir, ExecOptions{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if !result.TimedOut {
+		t.Fatal("expected TimedOut to be true")
+	}
+	if result.TimeoutMs > 200 {
+		t.Errorf("expected effective timeout <= 200ms, got %d", result.TimeoutMs)
+	}
+	if strings.Contains(result.Metadata, "timed out after 10s") {
+		t.Errorf("expected metadata to report the effective timeout, got %q", result.Metadata)
+	}
+}
+
+func TestExecute_ConcurrentTimeouts(t *testing.T) {
+	workDir := t.TempDir()
+	executor := NewExecutor()
+
+	const n = 16
+	var wg sync.WaitGroup
+	results := make([]*ExecResult, n)
+	errs := make([]error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			opts := ExecOptions{Timeout: 5 * time.Second}
+			command := "echo fast"
+			if i%2 == 0 {
+				opts.Timeout = time.Duration(100+i*10) * time.Millisecond
+				command = "sleep 5"
+			}
+			results[i], errs[i] = executor.Execute(context.Background(), command, workDir, opts)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("execution %d failed: %v", i, errs[i])
+		}
+		if i%2 == 0 {
+			want := fmt.Sprintf("timed out after %v", time.Duration(100+i*10)*time.Millisecond)
+			if !results[i].TimedOut || !strings.Contains(results[i].Metadata, want) {
+				t.Errorf("execution %d: expected %q, got timedOut=%v metadata=%q", i, want, results[i].TimedOut, results[i].Metadata)
+			}
+		} else if results[i].TimedOut || results[i].Stdout != "fast\n" {
+			t.Errorf("execution %d: expected fast success, got timedOut=%v stdout=%q", i, results[i].TimedOut, results[i].Stdout)
+		}
+	}
+}
+
+func TestExecute_Stdin(t *testing.T) {
+	workDir := t.TempDir()
+	executor := NewExecutor()
+
+	result, err := executor.Execute(context.Background(), "wc -l", workDir, ExecOptions{Stdin: strings.NewReader("a\nb\nc\n")})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if strings.TrimSpace(result.Stdout) != "3" {
+		t.Errorf("expected 3 lines from stdin, got %q", result.Stdout)
+	}
+}
+
+func TestDecodeStdin(t *testing.T) {
+	tests := []struct {
+		data     string
+		encoding string
+		want     string
+		wantErr  bool
+	}{
+		{"hello", "", "hello", false},
+		{"hello", "text", "hello", false},
+		{"aGVsbG8=", "base64", "hello", false},
+		{"!!", "base64", "", true},
+		{"hello", "hex", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := DecodeStdin(tt.data, tt.encoding)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("DecodeStdin(%q, %q) error = %v, wantErr %v", tt.data, tt.encoding, err, tt.wantErr)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("DecodeStdin(%q, %q) = %q, want %q", tt.data, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestExecute_User(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+	e := NewExecutor()
+	user := &identity.User{Name: "nobody", UID: 65534, GID: 65534, Home: "/"}
+
+	result, err := e.Execute(context.Background(), `echo "$(id -u):$(id -g):$(id -G):$HOME:$USER"`, "/", ExecOptions{User: user})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "65534:65534:65534:/:nobody\n" {
+		t.Errorf("expected command to run as the session user, got %q", result.Stdout)
+	}
+}
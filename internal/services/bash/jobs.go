@@ -0,0 +1,506 @@
+package bash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/deep-agent/sandbox/internal/services/identity"
+	"github.com/deep-agent/sandbox/pkg/safe"
+)
+
+var (
+	ErrJobNotFound   = errors.New("job not found")
+	ErrJobNotRunning = errors.New("job is not running")
+)
+
+type JobState string
+
+const (
+	JobRunning  JobState = "running"
+	JobExited   JobState = "exited"
+	JobKilled   JobState = "killed"
+	JobTimedOut JobState = "timed_out"
+)
+
+const (
+	DefaultJobTimeout     = 10 * time.Minute
+	defaultJobReadLimit   = 64 * 1024
+	maxJobReadLimit       = 1024 * 1024
+	maxFinishedJobsRetain = 200
+)
+
+type JobInfo struct {
+	ID         string    `json:"id"`
+	SessionID  string    `json:"session_id"`
+	Command    string    `json:"command"`
+	WorkDir    string    `json:"work_dir"`
+	PID        int       `json:"pid"`
+	State      JobState  `json:"state"`
+	ExitCode   int       `json:"exit_code"`
+	OutputFile string    `json:"output_file"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+}
+
+type JobStartRequest struct {
+	SessionID string
+	Command   string
+	WorkDir   string
+	Env       map[string]string
+	Stdin     io.Reader
+	Timeout   time.Duration
+	// Limits tightens the manager's default rlimits for this job.
+	Limits Limits
+}
+
+type JobOutput struct {
+	Output     string   `json:"output"`
+	Offset     int64    `json:"offset"`
+	NextOffset int64    `json:"next_offset"`
+	Size       int64    `json:"size"`
+	State      JobState `json:"state"`
+}
+
+type JobManager struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	seq  int
+	opts options
+}
+
+type job struct {
+	mu            sync.Mutex
+	info          JobInfo
+	user          *identity.User
+	cmd           *exec.Cmd
+	done          chan struct{}
+	killRequested bool
+	readCursor    int64
+}
+
+// NewJobManager returns a JobManager. Jobs that do not request a timeout run
+// for DefaultJobTimeout unless WithTimeouts sets another default.
+func NewJobManager(opts ...Option) *JobManager {
+	return &JobManager{
+		jobs: make(map[string]*job),
+		opts: newOptions(append([]Option{WithTimeouts(DefaultJobTimeout, 0)}, opts...)),
+	}
+}
+
+// Start runs a job in the background. ctx only bounds the checks before the
+// job starts, such as waiting for approval; the job itself outlives it.
+func (m *JobManager) Start(ctx context.Context, req JobStartRequest) (*JobInfo, error) {
+	if err := validateDir(req.WorkDir); err != nil {
+		return nil, err
+	}
+	if err := m.opts.envPolicy.Validate(req.Env); err != nil {
+		return nil, err
+	}
+	if err := m.opts.guard.Check(ctx, normalizeSessionID(req.SessionID), req.Command); err != nil {
+		return nil, err
+	}
+	user, err := m.opts.users.Lookup(normalizeSessionID(req.SessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := m.opts.resolveTimeout(req.Timeout).effective
+
+	startTime := time.Now()
+	outputDir := filepath.Join(req.WorkDir, ".logs", "background_outputs")
+	outputFile := filepath.Join(outputDir, fmt.Sprintf("bg_%d.log", startTime.UnixNano()))
+	logFile, err := createJobLog(user, outputFile)
+	if err != nil {
+		return nil, err
+	}
+	discardLog := func() {
+		logFile.Close()
+		identity.RunAs(user, func() error { return os.Remove(outputFile) })
+	}
+
+	limits := req.Limits.Within(m.opts.limits)
+	cg, cgroupDir, err := m.jobCgroup(limits)
+	if err != nil {
+		discardLog()
+		return nil, err
+	}
+	cmd := exec.Command("bash", "-c", limits.ulimitScript()+req.Command)
+	cmd.Dir = req.WorkDir
+	cmd.Env = processEnv(req.Env)
+
+	// With explicit stdin the terminal is attached to stdout only, so the
+	// controlling tty has to be taken from fd 1 instead of fd 0.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if req.Stdin != nil {
+		cmd.Stdin = req.Stdin
+		cmd.SysProcAttr.Ctty = 1
+	}
+	if cgroupDir != nil {
+		// The job starts inside its cgroup, so nothing it forks escapes it.
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(cgroupDir.Fd())
+	}
+	runAs(cmd, user)
+
+	ptmx, err := pty.StartWithAttrs(cmd, &pty.Winsize{Rows: 50, Cols: 200}, cmd.SysProcAttr)
+	if cgroupDir != nil {
+		cgroupDir.Close()
+	}
+	if err != nil {
+		discardLog()
+		if cg != nil {
+			cg.remove()
+		}
+		return nil, fmt.Errorf("failed to start background command: %w", err)
+	}
+
+	m.mu.Lock()
+	m.seq++
+	j := &job{
+		info: JobInfo{
+			ID:         fmt.Sprintf("job_%d", m.seq),
+			SessionID:  normalizeSessionID(req.SessionID),
+			Command:    req.Command,
+			WorkDir:    req.WorkDir,
+			PID:        cmd.Process.Pid,
+			State:      JobRunning,
+			OutputFile: outputFile,
+			StartedAt:  startTime,
+		},
+		user: user,
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+	m.jobs[j.info.ID] = j
+	m.pruneLocked()
+	m.mu.Unlock()
+
+	copied := make(chan struct{})
+	safe.Go(func() {
+		defer close(copied)
+		copyPTYOutput(logFile, ptmx)
+	})
+
+	safe.Go(func() {
+		waitErr := make(chan error, 1)
+		go func() {
+			waitErr <- cmd.Wait()
+		}()
+
+		var err error
+		timedOut := false
+		select {
+		case err = <-waitErr:
+		case <-time.After(timeout):
+			timedOut = true
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			err = <-waitErr
+		}
+
+		select {
+		case <-copied:
+		case <-time.After(time.Second):
+		}
+		ptmx.Close()
+		logFile.Close()
+		if cg != nil {
+			cg.remove()
+		}
+
+		j.finish(err, timedOut)
+	})
+
+	info := j.snapshot()
+	return &info, nil
+}
+
+// createJobLog creates the output file of a job. The logs live in the session
+// workspace, which its user controls, so they are created with that user's
+// permissions and a symlink in their place is refused.
+func createJobLog(user *identity.User, path string) (*os.File, error) {
+	var f *os.File
+	err := identity.RunAs(user, func() error {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		var err error
+		if f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		return nil
+	})
+	return f, err
+}
+
+// jobCgroup creates the cgroup of a job whose limits need one and opens its
+// directory to start the job in. Jobs without such limits get neither.
+func (m *JobManager) jobCgroup(limits Limits) (*sessionCgroup, *os.File, error) {
+	if !limits.needsCgroup() {
+		return nil, nil, nil
+	}
+	if m.opts.cgroups == nil {
+		return nil, nil, errors.New("memory, process and CPU quota limits need cgroup v2")
+	}
+	cg, err := m.opts.cgroups.create("job-"+newSessionToken(), limits)
+	if err != nil {
+		return nil, nil, err
+	}
+	dir, err := os.Open(cg.path)
+	if err != nil {
+		cg.remove()
+		return nil, nil, fmt.Errorf("failed to open job cgroup: %w", err)
+	}
+	return cg, dir, nil
+}
+
+func (m *JobManager) pruneLocked() {
+	var finished []*job
+	for _, j := range m.jobs {
+		if j.snapshot().State != JobRunning {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= maxFinishedJobsRetain {
+		return
+	}
+
+	sort.Slice(finished, func(a, b int) bool {
+		return finished[a].snapshot().EndedAt.Before(finished[b].snapshot().EndedAt)
+	})
+	for _, j := range finished[:len(finished)-maxFinishedJobsRetain] {
+		delete(m.jobs, j.info.ID)
+	}
+}
+
+func (m *JobManager) lookup(sessionID, id string) (*job, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+
+	if !ok || j.info.SessionID != normalizeSessionID(sessionID) {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return j, nil
+}
+
+func (m *JobManager) List(sessionID string) []JobInfo {
+	sessionID = normalizeSessionID(sessionID)
+
+	m.mu.Lock()
+	var infos []JobInfo
+	for _, j := range m.jobs {
+		if j.info.SessionID == sessionID {
+			infos = append(infos, j.snapshot())
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(infos, func(i, k int) bool {
+		return infos[i].StartedAt.Before(infos[k].StartedAt)
+	})
+	return infos
+}
+
+func (m *JobManager) Get(sessionID, id string) (*JobInfo, error) {
+	j, err := m.lookup(sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+	info := j.snapshot()
+	return &info, nil
+}
+
+// ReadOutput returns up to limit bytes of the job's output starting at offset.
+// A negative offset continues from where the previous ReadOutput left off.
+func (m *JobManager) ReadOutput(sessionID, id string, offset int64, limit int) (*JobOutput, error) {
+	j, err := m.lookup(sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultJobReadLimit
+	}
+	if limit > maxJobReadLimit {
+		limit = maxJobReadLimit
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if offset < 0 {
+		offset = j.readCursor
+	}
+
+	var f *os.File
+	err = identity.RunAs(j.user, func() error {
+		var err error
+		f, err = os.OpenFile(j.info.OutputFile, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job output: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat job output: %w", err)
+	}
+	size := stat.Size()
+	if offset > size {
+		offset = size
+	}
+
+	buf := make([]byte, min(int64(limit), size-offset))
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read job output: %w", err)
+	}
+
+	next := offset + int64(n)
+	if next > j.readCursor {
+		j.readCursor = next
+	}
+
+	return &JobOutput{
+		Output:     string(buf[:n]),
+		Offset:     offset,
+		NextOffset: next,
+		Size:       size,
+		State:      j.info.State,
+	}, nil
+}
+
+func (m *JobManager) Kill(sessionID, id string, sig syscall.Signal) (*JobInfo, error) {
+	j, err := m.lookup(sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	j.mu.Lock()
+	if j.info.State != JobRunning {
+		j.mu.Unlock()
+		return nil, fmt.Errorf("%w: job %s is %s", ErrJobNotRunning, id, j.info.State)
+	}
+	j.killRequested = true
+	pid := j.info.PID
+	j.mu.Unlock()
+
+	if err := syscall.Kill(-pid, sig); errors.Is(err, syscall.ESRCH) {
+		return nil, fmt.Errorf("%w: job %s has exited", ErrJobNotRunning, id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to signal job %s: %w", id, err)
+	}
+
+	select {
+	case <-j.done:
+	case <-time.After(2 * time.Second):
+	}
+
+	info := j.snapshot()
+	return &info, nil
+}
+
+func (m *JobManager) KillAll() {
+	m.mu.Lock()
+	var pids []int
+	for _, j := range m.jobs {
+		if info := j.snapshot(); info.State == JobRunning {
+			pids = append(pids, info.PID)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, pid := range pids {
+		syscall.Kill(-pid, syscall.SIGKILL)
+	}
+}
+
+func (j *job) snapshot() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
+
+func (j *job) finish(err error, timedOut bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.info.EndedAt = time.Now()
+	j.info.ExitCode = 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		j.info.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		j.info.ExitCode = -1
+	}
+
+	switch {
+	case timedOut:
+		j.info.State = JobTimedOut
+	case j.killRequested:
+		j.info.State = JobKilled
+	default:
+		j.info.State = JobExited
+	}
+	close(j.done)
+}
+
+func copyPTYOutput(dst io.Writer, src io.Reader) {
+	buf := make([]byte, 32*1024)
+	var pendingCR bool
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			data := buf[:n]
+			if pendingCR {
+				data = append([]byte{'\r'}, data...)
+				pendingCR = false
+			}
+			if data[len(data)-1] == '\r' {
+				data = data[:len(data)-1]
+				pendingCR = true
+			}
+			dst.Write(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")))
+		}
+		if err != nil {
+			if pendingCR {
+				dst.Write([]byte{'\r'})
+			}
+			return
+		}
+	}
+}
+
+func ParseSignal(name string) (syscall.Signal, error) {
+	switch strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG") {
+	case "", "TERM":
+		return syscall.SIGTERM, nil
+	case "KILL":
+		return syscall.SIGKILL, nil
+	case "INT":
+		return syscall.SIGINT, nil
+	case "HUP":
+		return syscall.SIGHUP, nil
+	case "QUIT":
+		return syscall.SIGQUIT, nil
+	case "USR1":
+		return syscall.SIGUSR1, nil
+	case "USR2":
+		return syscall.SIGUSR2, nil
+	default:
+		return 0, fmt.Errorf("unsupported signal: %s", name)
+	}
+}
@@ -0,0 +1,312 @@
+package bash
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/deep-agent/sandbox/internal/services/identity"
+)
+
+func waitForJob(t *testing.T, m *JobManager, sessionID, id string) *JobInfo {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		info, err := m.Get(sessionID, id)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if info.State != JobRunning {
+			return info
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish in time", id)
+	return nil
+}
+
+func TestJobManager_StartAndExit(t *testing.T) {
+	m := NewJobManager()
+	workDir := t.TempDir()
+
+	job, err := m.Start(context.Background(), JobStartRequest{SessionID: "s1", Command: "echo hello; exit 3", WorkDir: workDir})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if job.ID == "" || job.PID == 0 {
+		t.Fatalf("expected job ID and PID, got %+v", job)
+	}
+	if !strings.HasPrefix(job.OutputFile, workDir) {
+		t.Errorf("expected output file under workdir, got %q", job.OutputFile)
+	}
+
+	info := waitForJob(t, m, "s1", job.ID)
+	if info.State != JobExited {
+		t.Errorf("expected state exited, got %s", info.State)
+	}
+	if info.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", info.ExitCode)
+	}
+	if info.EndedAt.IsZero() {
+		t.Error("expected EndedAt to be set")
+	}
+
+	output, err := m.ReadOutput("s1", job.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("ReadOutput failed: %v", err)
+	}
+	if output.Output != "hello\n" {
+		t.Errorf("expected output 'hello\\n', got %q", output.Output)
+	}
+}
+
+func TestJobManager_ReadOutputIncremental(t *testing.T) {
+	m := NewJobManager()
+	workDir := t.TempDir()
+
+	job, err := m.Start(context.Background(), JobStartRequest{Command: "printf 'abcdef\\n'", WorkDir: workDir})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waitForJob(t, m, "", job.ID)
+
+	first, err := m.ReadOutput("", job.ID, -1, 3)
+	if err != nil {
+		t.Fatalf("ReadOutput failed: %v", err)
+	}
+	if first.Output != "abc" || first.NextOffset != 3 {
+		t.Errorf("expected 'abc' up to offset 3, got %q next %d", first.Output, first.NextOffset)
+	}
+
+	second, err := m.ReadOutput("", job.ID, -1, 0)
+	if err != nil {
+		t.Fatalf("ReadOutput failed: %v", err)
+	}
+	if second.Output != "def\n" || second.Offset != 3 {
+		t.Errorf("expected 'def\\n' from offset 3, got %q from %d", second.Output, second.Offset)
+	}
+
+	again, err := m.ReadOutput("", job.ID, 1, 2)
+	if err != nil {
+		t.Fatalf("ReadOutput failed: %v", err)
+	}
+	if again.Output != "bc" {
+		t.Errorf("expected 'bc' at explicit offset, got %q", again.Output)
+	}
+}
+
+func TestJobManager_Kill(t *testing.T) {
+	m := NewJobManager()
+	workDir := t.TempDir()
+
+	job, err := m.Start(context.Background(), JobStartRequest{Command: "sleep 30", WorkDir: workDir})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	info, err := m.Kill("", job.ID, syscall.SIGKILL)
+	if err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+	if info.State != JobKilled {
+		t.Errorf("expected state killed, got %s", info.State)
+	}
+
+	if _, err := m.Kill("", job.ID, syscall.SIGKILL); !errors.Is(err, ErrJobNotRunning) {
+		t.Errorf("expected ErrJobNotRunning killing a finished job, got %v", err)
+	}
+}
+
+func TestJobManager_Timeout(t *testing.T) {
+	m := NewJobManager()
+	workDir := t.TempDir()
+
+	job, err := m.Start(context.Background(), JobStartRequest{Command: "sleep 30", WorkDir: workDir, Timeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	info := waitForJob(t, m, "", job.ID)
+	if info.State != JobTimedOut {
+		t.Errorf("expected state timed_out, got %s", info.State)
+	}
+}
+
+func TestJobManager_TimeoutCapped(t *testing.T) {
+	m := NewJobManager(WithTimeouts(0, 200*time.Millisecond))
+	workDir := t.TempDir()
+
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		job, err := m.Start(context.Background(), JobStartRequest{Command: "sleep 30", WorkDir: workDir, Timeout: timeout})
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if info := waitForJob(t, m, "", job.ID); info.State != JobTimedOut {
+			t.Errorf("timeout %v: expected state timed_out, got %s", timeout, info.State)
+		}
+	}
+}
+
+func TestJobManager_LimitsNeedCgroup(t *testing.T) {
+	m := NewJobManager()
+	workDir := t.TempDir()
+
+	if _, err := m.Start(context.Background(), JobStartRequest{Command: "true", WorkDir: workDir, Limits: Limits{Processes: 8}}); err == nil {
+		t.Fatal("expected a job with a process limit to fail without cgroups")
+	}
+	if logs, _ := filepath.Glob(filepath.Join(workDir, ".logs", "background_outputs", "*")); len(logs) != 0 {
+		t.Errorf("expected no output file to be left, got %v", logs)
+	}
+}
+
+func TestJobManager_SessionScoping(t *testing.T) {
+	m := NewJobManager()
+	workDir := t.TempDir()
+
+	job, err := m.Start(context.Background(), JobStartRequest{SessionID: "a", Command: "true", WorkDir: workDir})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if _, err := m.Get("b", job.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected job to be hidden from other sessions, got %v", err)
+	}
+	if _, err := m.Kill("b", job.ID, syscall.SIGTERM); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound killing another session's job, got %v", err)
+	}
+	if _, err := m.ReadOutput("a", "missing", 0, 0); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound for an unknown job, got %v", err)
+	}
+	if jobs := m.List("b"); len(jobs) != 0 {
+		t.Errorf("expected no jobs for session b, got %d", len(jobs))
+	}
+	if jobs := m.List("a"); len(jobs) != 1 {
+		t.Errorf("expected one job for session a, got %d", len(jobs))
+	}
+}
+
+func TestJobManager_InvalidWorkDir(t *testing.T) {
+	m := NewJobManager()
+
+	if _, err := m.Start(context.Background(), JobStartRequest{Command: "true", WorkDir: "/nonexistent/jobs/dir"}); err == nil {
+		t.Error("expected error for invalid workdir")
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    syscall.Signal
+		wantErr bool
+	}{
+		{"", syscall.SIGTERM, false},
+		{"SIGKILL", syscall.SIGKILL, false},
+		{"int", syscall.SIGINT, false},
+		{"sighup", syscall.SIGHUP, false},
+		{"SIGSTOP", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSignal(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSignal(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSignal(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestJobManager_Env(t *testing.T) {
+	m := NewJobManager(WithEnvPolicy(NewEnvPolicy([]string{"JOB_*"}, nil)))
+	workDir := t.TempDir()
+
+	job, err := m.Start(context.Background(), JobStartRequest{Command: "echo $JOB_NAME", WorkDir: workDir, Env: map[string]string{"JOB_NAME": "build"}})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waitForJob(t, m, "", job.ID)
+
+	output, err := m.ReadOutput("", job.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("ReadOutput failed: %v", err)
+	}
+	if output.Output != "build\n" {
+		t.Errorf("expected 'build\\n', got %q", output.Output)
+	}
+
+	if _, err := m.Start(context.Background(), JobStartRequest{Command: "true", WorkDir: workDir, Env: map[string]string{"OTHER": "x"}}); err == nil {
+		t.Error("expected variable outside allow list to be rejected")
+	}
+}
+
+func TestJobManager_Stdin(t *testing.T) {
+	m := NewJobManager()
+	workDir := t.TempDir()
+
+	job, err := m.Start(context.Background(), JobStartRequest{Command: "tr a-z A-Z", WorkDir: workDir, Stdin: strings.NewReader("shout\n")})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	info := waitForJob(t, m, "", job.ID)
+	if info.ExitCode != 0 {
+		t.Errorf("expected exit code 0, got %d", info.ExitCode)
+	}
+
+	output, err := m.ReadOutput("", job.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("ReadOutput failed: %v", err)
+	}
+	if output.Output != "SHOUT\n" {
+		t.Errorf("expected 'SHOUT\\n', got %q", output.Output)
+	}
+}
+
+func TestCreateJobLog_User(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+	base := t.TempDir()
+	for _, dir := range []string{filepath.Dir(base), base} {
+		if err := os.Chmod(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outside := filepath.Join(base, "outside")
+	workspace := filepath.Join(base, "workspace")
+	for _, dir := range []string{outside, workspace} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	user := &identity.User{Name: "nobody", UID: 65534, GID: 65534, Home: workspace}
+	if err := user.Chown(workspace); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := createJobLog(user, filepath.Join(workspace, ".logs", "out", "bg_1.log"))
+	if err != nil {
+		t.Fatalf("createJobLog failed: %v", err)
+	}
+	info, _ := f.Stat()
+	f.Close()
+	if stat := info.Sys().(*syscall.Stat_t); stat.Uid != 65534 {
+		t.Errorf("expected the log to belong to the session user, got uid %d", stat.Uid)
+	}
+
+	if err := os.Symlink(outside, filepath.Join(workspace, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createJobLog(user, filepath.Join(workspace, "escape", "out", "bg_2.log")); err == nil {
+		t.Error("expected a log behind a symlink to another user's directory to be refused")
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("expected nothing to be created outside the workspace, got %v", entries)
+	}
+}
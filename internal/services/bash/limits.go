@@ -0,0 +1,205 @@
+package bash
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+// Limits bounds the resources a command may use. Zero values mean unlimited.
+// CPUTime and FileSize are enforced with rlimits on every process. Memory,
+// Processes and CPUQuota are enforced through the cgroup of the session or
+// job, so they need cgroup v2 and are not enforced without it. Output caps
+// the captured output.
+type Limits struct {
+	CPUTime   time.Duration
+	Memory    int64
+	Processes int
+	FileSize  int64
+	Output    int64
+	CPUQuota  float64
+}
+
+const (
+	LimitCPU       = "cpu"
+	LimitMemory    = "memory"
+	LimitProcesses = "processes"
+	LimitFileSize  = "file_size"
+	LimitOutput    = "output"
+)
+
+const (
+	exitSIGXCPU = 128 + 24
+	exitSIGXFSZ = 128 + 25
+)
+
+// LimitsFromModel converts the limits of an API request. A nil value means no
+// per-command limits.
+func LimitsFromModel(l *model.BashLimits) Limits {
+	if l == nil {
+		return Limits{}
+	}
+	return Limits{
+		CPUTime:   time.Duration(l.CPUSeconds) * time.Second,
+		Memory:    l.MemoryBytes,
+		Processes: l.Processes,
+		FileSize:  l.FileSizeBytes,
+		Output:    l.OutputBytes,
+	}
+}
+
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// needsCgroup reports whether l sets limits only a cgroup can enforce.
+func (l Limits) needsCgroup() bool {
+	return l.Memory > 0 || l.Processes > 0 || l.CPUQuota > 0
+}
+
+// Within returns l tightened by outer: every limit is the stricter of the two,
+// so a per-command limit can lower but never raise a session limit.
+func (l Limits) Within(outer Limits) Limits {
+	return Limits{
+		CPUTime:   minLimit(l.CPUTime, outer.CPUTime),
+		Memory:    minLimit(l.Memory, outer.Memory),
+		Processes: minLimit(l.Processes, outer.Processes),
+		FileSize:  minLimit(l.FileSize, outer.FileSize),
+		Output:    minLimit(l.Output, outer.Output),
+		CPUQuota:  minLimit(l.CPUQuota, outer.CPUQuota),
+	}
+}
+
+// tightened keeps only the limits set in l, each lowered to outer where outer
+// is stricter.
+func (l Limits) tightened(outer Limits) Limits {
+	t := l.Within(outer)
+	if l.CPUTime <= 0 {
+		t.CPUTime = 0
+	}
+	if l.Memory <= 0 {
+		t.Memory = 0
+	}
+	if l.Processes <= 0 {
+		t.Processes = 0
+	}
+	if l.FileSize <= 0 {
+		t.FileSize = 0
+	}
+	if l.Output <= 0 {
+		t.Output = 0
+	}
+	if l.CPUQuota <= 0 {
+		t.CPUQuota = 0
+	}
+	return t
+}
+
+func minLimit[T int | int64 | float64 | time.Duration](a, b T) T {
+	switch {
+	case a <= 0:
+		return b
+	case b <= 0:
+		return a
+	default:
+		return min(a, b)
+	}
+}
+
+// ulimitScript returns bash builtins that apply the rlimits to the current
+// shell and everything it starts. The CPU hard limit is one second above the
+// soft limit so processes receive SIGXCPU, which is reported, before SIGKILL.
+// Memory and processes are left to cgroups: RLIMIT_AS breaks runtimes that
+// reserve large address ranges, such as Go, the JVM and node, and
+// RLIMIT_NPROC counts every thread of the user, including the browser's.
+func (l Limits) ulimitScript() string {
+	var script strings.Builder
+	if l.FileSize > 0 {
+		fmt.Fprintf(&script, "ulimit -f %d 2>/dev/null; ", max(l.FileSize/1024, 1))
+	}
+	if l.CPUTime > 0 {
+		seconds := max(int64(l.CPUTime/time.Second), 1)
+		fmt.Fprintf(&script, "ulimit -H -t %d 2>/dev/null; ulimit -S -t %d 2>/dev/null; ", seconds+1, seconds)
+	}
+	return script.String()
+}
+
+// exceededFromExitCode recognises the signals the kernel sends when an rlimit
+// is hit and returns the matching limit name and metadata line.
+func (l Limits) exceededFromExitCode(exitCode int) (string, string) {
+	switch {
+	case exitCode == exitSIGXCPU && l.CPUTime > 0:
+		return LimitCPU, fmt.Sprintf("CPU time limit of %v exceeded; process received SIGXCPU", l.CPUTime)
+	case exitCode == exitSIGXFSZ && l.FileSize > 0:
+		return LimitFileSize, fmt.Sprintf("file size limit of %s exceeded; process received SIGXFSZ", formatBytes(l.FileSize))
+	default:
+		return "", ""
+	}
+}
+
+// outputBudget caps the output captured across stdout and stderr. Once the
+// limit is crossed further output is dropped and onExceed is called once.
+type outputBudget struct {
+	mu       sync.Mutex
+	limit    int64
+	used     int64
+	exceeded bool
+	onExceed func()
+}
+
+func newOutputBudget(limit int64, onExceed func()) *outputBudget {
+	return &outputBudget{limit: limit, onExceed: onExceed}
+}
+
+func (b *outputBudget) take(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 {
+		return true
+	}
+	if b.exceeded || b.used+int64(n) > b.limit {
+		if !b.exceeded {
+			b.exceeded = true
+			b.onExceed()
+		}
+		return false
+	}
+	b.used += int64(n)
+	return true
+}
+
+func (b *outputBudget) wasExceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
+
+type budgetWriter struct {
+	w      io.Writer
+	budget *outputBudget
+}
+
+func (bw budgetWriter) Write(p []byte) (int, error) {
+	if !bw.budget.take(len(p)) {
+		return len(p), nil
+	}
+	return bw.w.Write(p)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
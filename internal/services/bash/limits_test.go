@@ -0,0 +1,103 @@
+package bash
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimits_Within(t *testing.T) {
+	outer := Limits{CPUTime: 10 * time.Second, Memory: 1 << 30, Processes: 100}
+	got := Limits{CPUTime: 2 * time.Second, Memory: 2 << 30, FileSize: 1 << 20}.Within(outer)
+	want := Limits{CPUTime: 2 * time.Second, Memory: 1 << 30, Processes: 100, FileSize: 1 << 20}
+	if got != want {
+		t.Errorf("Within() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLimits_Tightened(t *testing.T) {
+	outer := Limits{CPUTime: 10 * time.Second, Memory: 1 << 30, Processes: 100}
+	got := Limits{Memory: 2 << 30, FileSize: 1 << 20}.tightened(outer)
+	want := Limits{Memory: 1 << 30, FileSize: 1 << 20}
+	if got != want {
+		t.Errorf("tightened() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLimits_UlimitScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		want   string
+	}{
+		{name: "none", limits: Limits{}, want: ""},
+		{
+			name:   "memory files processes",
+			limits: Limits{Memory: 64 << 20, FileSize: 1 << 20, Processes: 50},
+			want:   "ulimit -f 1024 2>/dev/null; ",
+		},
+		{
+			name:   "cpu",
+			limits: Limits{CPUTime: 3 * time.Second},
+			want:   "ulimit -H -t 4 2>/dev/null; ulimit -S -t 3 2>/dev/null; ",
+		},
+		{name: "output only", limits: Limits{Output: 1024}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.ulimitScript(); got != tt.want {
+				t.Errorf("ulimitScript() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits_ExceededFromExitCode(t *testing.T) {
+	limits := Limits{CPUTime: time.Second, FileSize: 4096}
+
+	if name, _ := limits.exceededFromExitCode(exitSIGXCPU); name != LimitCPU {
+		t.Errorf("expected %q for SIGXCPU, got %q", LimitCPU, name)
+	}
+	if name, msg := limits.exceededFromExitCode(exitSIGXFSZ); name != LimitFileSize || msg == "" {
+		t.Errorf("expected %q for SIGXFSZ, got %q %q", LimitFileSize, name, msg)
+	}
+	if name, _ := limits.exceededFromExitCode(1); name != "" {
+		t.Errorf("expected no limit for exit code 1, got %q", name)
+	}
+	if name, _ := (Limits{}).exceededFromExitCode(exitSIGXCPU); name != "" {
+		t.Errorf("expected no limit without a CPU limit, got %q", name)
+	}
+}
+
+func TestExecute_OutputLimit(t *testing.T) {
+	e := NewExecutor(WithLimits(Limits{Output: 1024}))
+
+	result, err := e.Execute(context.Background(), "yes", t.TempDir(), ExecOptions{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.LimitExceeded != LimitOutput {
+		t.Errorf("expected output limit, got %q (%s)", result.LimitExceeded, result.Metadata)
+	}
+	if result.TimedOut {
+		t.Error("expected the command to stop before the timeout")
+	}
+	if len(result.Stdout) > 1024 {
+		t.Errorf("expected at most 1024 bytes of output, got %d", len(result.Stdout))
+	}
+}
+
+func TestExecute_FileSizeLimit(t *testing.T) {
+	e := NewExecutor()
+
+	result, err := e.Execute(context.Background(), "head -c 8192 /dev/zero > out", t.TempDir(), ExecOptions{
+		Limits: Limits{FileSize: 1024},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.LimitExceeded != LimitFileSize {
+		t.Errorf("expected file size limit, got %q (exit %d, %s)", result.LimitExceeded, result.ExitCode, result.Metadata)
+	}
+}
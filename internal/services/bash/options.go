@@ -0,0 +1,152 @@
+package bash
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/deep-agent/sandbox/internal/services/identity"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+	MaxTimeout     = 10 * time.Minute
+)
+
+type Option func(*options)
+
+type options struct {
+	envPolicy      *EnvPolicy
+	defaultTimeout time.Duration
+	maxTimeout     time.Duration
+	limits         Limits
+	cgroups        *CgroupManager
+	users          *identity.Manager
+	guard          *CommandGuard
+	maxSessions    int
+	sessionIdle    time.Duration
+	sessionClosed  func(id string)
+}
+
+func WithEnvPolicy(policy *EnvPolicy) Option {
+	return func(o *options) {
+		if policy != nil {
+			o.envPolicy = policy
+		}
+	}
+}
+
+// WithTimeouts sets the timeout applied when a call does not request one and
+// the upper bound for requested timeouts. Non-positive values keep the
+// package defaults.
+func WithTimeouts(defaultTimeout, maxTimeout time.Duration) Option {
+	return func(o *options) {
+		if defaultTimeout > 0 {
+			o.defaultTimeout = defaultTimeout
+		}
+		if maxTimeout > 0 {
+			o.maxTimeout = maxTimeout
+		}
+	}
+}
+
+// WithLimits sets the resource limits applied to every session, job or
+// command. Per-call limits can only tighten them.
+func WithLimits(limits Limits) Option {
+	return func(o *options) {
+		o.limits = limits
+	}
+}
+
+// WithUsers runs the sessions and jobs of each session ID as a dedicated
+// unprivileged user.
+func WithUsers(users *identity.Manager) Option {
+	return func(o *options) {
+		o.users = users
+	}
+}
+
+// WithCommandGuard checks every command against the guard's policy before it
+// runs.
+func WithCommandGuard(guard *CommandGuard) Option {
+	return func(o *options) {
+		o.guard = guard
+	}
+}
+
+// WithCgroups places each session in its own cgroup so memory, process and CPU
+// quota limits cover the session's whole process tree.
+func WithCgroups(cgroups *CgroupManager) Option {
+	return func(o *options) {
+		o.cgroups = cgroups
+	}
+}
+
+// WithSessionLimits caps the persistent shell sessions at limit, unlimited
+// when zero, and closes those unused for idle, never when zero.
+func WithSessionLimits(limit int, idle time.Duration) Option {
+	return func(o *options) {
+		o.maxSessions = limit
+		o.sessionIdle = idle
+	}
+}
+
+// WithSessionClosed calls fn with the ID of each session that is destroyed or
+// closed for being idle, so resources kept for the session can go with it.
+func WithSessionClosed(fn func(id string)) Option {
+	return func(o *options) {
+		o.sessionClosed = fn
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		envPolicy:      DefaultEnvPolicy(),
+		defaultTimeout: DefaultTimeout,
+		maxTimeout:     MaxTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.defaultTimeout > o.maxTimeout {
+		o.defaultTimeout = o.maxTimeout
+	}
+	return o
+}
+
+type resolvedTimeout struct {
+	requested time.Duration
+	effective time.Duration
+	capped    bool
+}
+
+func (o options) resolveTimeout(requested time.Duration) resolvedTimeout {
+	t := resolvedTimeout{requested: requested, effective: requested}
+	switch {
+	case requested <= 0:
+		t.effective = o.defaultTimeout
+	case requested > o.maxTimeout:
+		t.effective = o.maxTimeout
+		t.capped = true
+	}
+	return t
+}
+
+// clampTo lowers the effective timeout when ctx carries an earlier deadline,
+// so reported timeouts match when the command is actually stopped.
+func (t *resolvedTimeout) clampTo(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	if remaining := time.Until(deadline).Round(time.Millisecond); remaining < t.effective {
+		t.effective = max(remaining, 0)
+	}
+}
+
+func (t resolvedTimeout) metadata() []string {
+	if !t.capped {
+		return nil
+	}
+	return []string{fmt.Sprintf("requested timeout %v exceeds the maximum; using %v", t.requested, t.effective)}
+}
@@ -0,0 +1,250 @@
+package bash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+	PolicyAsk   = "ask"
+)
+
+const (
+	rmRecursive = `(-[A-Za-z]*[rR][A-Za-z]*|--recursive)`
+	rmRootPath  = `(/+\*?|~/?\*?|\$\{?HOME\}?/?\*?)`
+)
+
+// DefaultPolicyRules guard against the most destructive commands. They are
+// used when no policy file is configured.
+var DefaultPolicyRules = []model.BashPolicyRule{
+	{
+		ID:     "rm-root",
+		Action: PolicyDeny,
+		Match:  `^rm\s(.*\s)?(` + rmRecursive + `\s(.*\s)?` + rmRootPath + `|` + rmRootPath + `\s(.*\s)?` + rmRecursive + `)(\s|$)`,
+		Reason: "recursive removal of the root or home directory",
+	},
+	{
+		ID:      "curl-pipe-shell",
+		Action:  PolicyDeny,
+		Match:   `^(curl|wget)(\s|$)`,
+		PipedTo: `^(sh|bash|zsh|dash|ksh|fish|python[0-9.]*|perl|ruby|node)(\s|$)`,
+		Reason:  "piping downloaded content into an interpreter",
+	},
+	{
+		ID:     "git-force-push",
+		Action: PolicyDeny,
+		Match:  `^git\s(.*\s)?push\s(.*\s)?(--force(-with-lease)?(=\S*)?|-[A-Za-z]*f[A-Za-z]*|\+\S+)(\s|$)`,
+		Reason: "force-pushing rewrites remote history",
+	},
+	{
+		ID:     "mkfs",
+		Action: PolicyDeny,
+		Match:  `^mkfs(\.\w+)?(\s|$)`,
+		Reason: "creating a filesystem destroys existing data",
+	},
+	{
+		ID:     "dd-device",
+		Action: PolicyDeny,
+		Match:  `^dd\s(.*\s)?of=/dev/`,
+		Reason: "writing directly to a device",
+	},
+}
+
+// PolicyDecision is the outcome of checking a command. Rule and Command name
+// the rule that decided and the simple command it matched.
+type PolicyDecision struct {
+	Action  string `json:"action"`
+	Rule    string `json:"rule,omitempty"`
+	Command string `json:"command,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// PolicyError is returned when a command is denied or its approval is
+// rejected.
+type PolicyError struct {
+	Decision PolicyDecision
+}
+
+func (e *PolicyError) Error() string {
+	if e.Decision.Rule != "" {
+		return fmt.Sprintf("command denied by policy rule %s: %s", e.Decision.Rule, e.Decision.Reason)
+	}
+	return "command denied by policy: " + e.Decision.Reason
+}
+
+type policyRule struct {
+	spec    model.BashPolicyRule
+	match   *regexp.Regexp
+	pipedTo *regexp.Regexp
+}
+
+// CommandPolicy evaluates command lines against an ordered list of rules.
+// Every simple command of the line is checked; deny wins over ask, and ask
+// over allow.
+type CommandPolicy struct {
+	defaultAction string
+	rules         []policyRule
+}
+
+// NewCommandPolicy compiles a policy specification.
+func NewCommandPolicy(spec model.BashPolicy) (*CommandPolicy, error) {
+	p := &CommandPolicy{defaultAction: spec.Default}
+	switch p.defaultAction {
+	case "":
+		p.defaultAction = PolicyAllow
+	case PolicyAllow, PolicyDeny:
+	default:
+		return nil, fmt.Errorf("invalid default policy action: %q", spec.Default)
+	}
+
+	for i, rule := range spec.Rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		switch rule.Action {
+		case PolicyAllow, PolicyDeny, PolicyAsk:
+		default:
+			return nil, fmt.Errorf("rule %s: invalid action %q", rule.ID, rule.Action)
+		}
+		if rule.Match == "" {
+			return nil, fmt.Errorf("rule %s: match is required", rule.ID)
+		}
+		match, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: invalid match: %w", rule.ID, err)
+		}
+		compiled := policyRule{spec: rule, match: match}
+		if rule.PipedTo != "" {
+			if compiled.pipedTo, err = regexp.Compile(rule.PipedTo); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid piped_to: %w", rule.ID, err)
+			}
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+func DefaultCommandPolicy() *CommandPolicy {
+	p, err := NewCommandPolicy(model.BashPolicy{Rules: DefaultPolicyRules})
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// LoadCommandPolicy reads a JSON policy file. An empty path returns the
+// default policy.
+func LoadCommandPolicy(path string) (*CommandPolicy, error) {
+	if path == "" {
+		return DefaultCommandPolicy(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command policy: %w", err)
+	}
+
+	var spec model.BashPolicy
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to parse command policy %s: %w", path, err)
+	}
+	p, err := NewCommandPolicy(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+func (p *CommandPolicy) Spec() model.BashPolicy {
+	spec := model.BashPolicy{Default: p.defaultAction, Rules: []model.BashPolicyRule{}}
+	for _, rule := range p.rules {
+		spec.Rules = append(spec.Rules, rule.spec)
+	}
+	return spec
+}
+
+// Evaluate checks every simple command of a command line. In allow-list mode
+// a line that cannot be parsed is denied; otherwise it is checked as a single
+// command.
+func (p *CommandPolicy) Evaluate(command string) PolicyDecision {
+	commands, err := parseCommandLine(command)
+	if err != nil {
+		if p.defaultAction == PolicyDeny {
+			return PolicyDecision{Action: PolicyDeny, Reason: "command could not be parsed: " + err.Error()}
+		}
+		if words := normalizeCommand(strings.Fields(command)); len(words) > 0 {
+			commands = []shellCommand{{words: words}}
+		}
+	}
+
+	var ask *PolicyDecision
+	var unlisted string
+	for i, cmd := range commands {
+		allowed := false
+		for _, rule := range p.rules {
+			if !rule.matches(commands, i) {
+				continue
+			}
+			switch rule.spec.Action {
+			case PolicyDeny:
+				return rule.decision(cmd)
+			case PolicyAsk:
+				if ask == nil {
+					d := rule.decision(cmd)
+					ask = &d
+				}
+			case PolicyAllow:
+				allowed = true
+			}
+		}
+		if !allowed && p.defaultAction == PolicyDeny && unlisted == "" {
+			unlisted = cmd.text()
+		}
+	}
+
+	switch {
+	case unlisted != "":
+		return PolicyDecision{Action: PolicyDeny, Command: unlisted, Reason: "command is not in the allow list"}
+	case ask != nil:
+		return *ask
+	default:
+		return PolicyDecision{Action: PolicyAllow}
+	}
+}
+
+func (r policyRule) matches(commands []shellCommand, i int) bool {
+	if !r.match.MatchString(commands[i].text()) {
+		return false
+	}
+	if r.pipedTo == nil {
+		return true
+	}
+	for _, next := range commands[i+1:] {
+		if next.pipeline == commands[i].pipeline && r.pipedTo.MatchString(next.text()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r policyRule) decision(cmd shellCommand) PolicyDecision {
+	reason := r.spec.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("matched %s rule %s", r.spec.Action, r.spec.ID)
+	}
+	return PolicyDecision{
+		Action:  r.spec.Action,
+		Rule:    r.spec.ID,
+		Command: cmd.text(),
+		Reason:  reason,
+	}
+}
@@ -0,0 +1,873 @@
+package bash
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/deep-agent/sandbox/internal/services/identity"
+	"github.com/deep-agent/sandbox/pkg/safe"
+)
+
+const DefaultSessionID = "default"
+
+var ErrSessionLimit = errors.New("too many shell sessions")
+
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Cwd        string    `json:"cwd"`
+	PID        int       `json:"pid"`
+	Busy       bool      `json:"busy"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type SessionExecRequest struct {
+	SessionID string
+	Command   string
+	Workspace string
+	Cwd       string
+	Env       map[string]string
+	// Stdin is streamed to the command until it returns EOF or the command
+	// finishes. A nil Stdin reads from /dev/null.
+	Stdin   io.Reader
+	Timeout time.Duration
+	// Limits tighten the session limits for this command only. When any rlimit
+	// is set the command runs in a subshell, so cd and export do not persist.
+	Limits   Limits
+	Truncate *TruncateOptions
+}
+
+type SessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	envs     map[string]map[string]string
+	opts     options
+
+	// watch starts watchSessions with the first session; done stops it.
+	watch sync.Once
+	done  chan struct{}
+}
+
+func NewSessionManager(opts ...Option) *SessionManager {
+	return &SessionManager{
+		sessions: make(map[string]*Session),
+		envs:     make(map[string]map[string]string),
+		opts:     newOptions(opts),
+		done:     make(chan struct{}),
+	}
+}
+
+func normalizeSessionID(id string) string {
+	if id == "" {
+		return DefaultSessionID
+	}
+	return id
+}
+
+func (m *SessionManager) getOrCreate(id, workspace string, user *identity.User) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if s, ok := m.sessions[id]; ok {
+		if s.alive() {
+			s.touch(now)
+			return s, nil
+		}
+		delete(m.sessions, id)
+	}
+
+	m.expireSessions(now)
+	if m.opts.maxSessions > 0 && len(m.sessions) >= m.opts.maxSessions {
+		return nil, fmt.Errorf("%w: %d sessions are running", ErrSessionLimit, len(m.sessions))
+	}
+	s, err := startSession(id, workspace, user, m.envs[id], m.opts.limits, m.opts.cgroups)
+	if err != nil {
+		return nil, err
+	}
+	m.sessions[id] = s
+	if m.opts.sessionIdle > 0 {
+		m.watch.Do(func() { safe.Go(m.watchSessions) })
+	}
+	return s, nil
+}
+
+// expireSessions closes the sessions that ran no command for longer than
+// the idle timeout. m.mu must be held.
+func (m *SessionManager) expireSessions(now time.Time) {
+	if m.opts.sessionIdle <= 0 {
+		return
+	}
+	for id, s := range m.sessions {
+		if s.idle(now) > m.opts.sessionIdle {
+			delete(m.sessions, id)
+			// Closing waits for the shell to exit, which must not hold up
+			// other sessions.
+			safe.Go(func() {
+				s.Close()
+				m.closed(id)
+			})
+		}
+	}
+}
+
+// watchSessions expires idle sessions until CloseAll.
+func (m *SessionManager) watchSessions() {
+	interval := min(max(m.opts.sessionIdle/2, time.Second), time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			m.expireSessions(now)
+			m.mu.Unlock()
+		}
+	}
+}
+
+func (m *SessionManager) remove(s *Session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if current, ok := m.sessions[s.id]; ok && current == s {
+		delete(m.sessions, s.id)
+	}
+}
+
+func (m *SessionManager) Execute(ctx context.Context, req SessionExecRequest) (*ExecResult, error) {
+	return m.ExecuteStream(ctx, req, nil)
+}
+
+func (m *SessionManager) ExecuteStream(ctx context.Context, req SessionExecRequest, onChunk StreamCallback) (*ExecResult, error) {
+	id := normalizeSessionID(req.SessionID)
+	if err := m.opts.envPolicy.Validate(req.Env); err != nil {
+		return nil, err
+	}
+	// Time spent waiting for approval does not count against the command.
+	if err := m.opts.guard.Check(ctx, id, req.Command); err != nil {
+		return nil, err
+	}
+	startTime := time.Now()
+
+	user, err := m.opts.users.Lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := m.getOrCreate(id, req.Workspace, user)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := m.opts.resolveTimeout(req.Timeout)
+	timeout.clampTo(ctx)
+	ctx, cancel := context.WithTimeout(ctx, timeout.effective)
+	defer cancel()
+
+	run, err := s.run(ctx, req, onChunk)
+	if err != nil {
+		m.remove(s)
+		s.Close()
+		return nil, err
+	}
+
+	result := &ExecResult{
+		Stdout:     run.stdout,
+		Stderr:     run.stderr,
+		Output:     run.stdout + run.stderr,
+		ExitCode:   run.exitCode,
+		DurationMs: time.Since(startTime).Milliseconds(),
+		TimeoutMs:  timeout.effective.Milliseconds(),
+	}
+
+	metadataLines := timeout.metadata()
+	if s.cgroup == nil && req.Limits.needsCgroup() {
+		metadataLines = append(metadataLines, "memory, process and CPU quota limits need cgroup v2 and were not applied")
+	}
+	if run.limitExceeded != "" {
+		result.LimitExceeded = run.limitExceeded
+		metadataLines = append(metadataLines, run.limitMessage)
+	}
+	if run.limitExceeded == LimitOutput {
+		metadataLines = append(metadataLines, "shell session was terminated; working directory and environment have been reset")
+	} else if run.interrupted {
+		if ctx.Err() == context.DeadlineExceeded {
+			result.TimedOut = true
+			metadataLines = append(metadataLines, fmt.Sprintf("command timed out after %v", timeout.effective))
+		} else {
+			metadataLines = append(metadataLines, "command was cancelled")
+		}
+		metadataLines = append(metadataLines, "shell session was terminated; working directory and environment have been reset")
+	} else if run.exited {
+		metadataLines = append(metadataLines, "shell session exited; a new session will be started for the next command")
+	}
+
+	if run.interrupted || run.exited {
+		m.remove(s)
+		s.Close()
+	}
+
+	return finalizeResult(result, metadataLines, req.Truncate), nil
+}
+
+func (m *SessionManager) Cwd(id string) string {
+	m.mu.Lock()
+	s, ok := m.sessions[normalizeSessionID(id)]
+	m.mu.Unlock()
+
+	if !ok {
+		return ""
+	}
+	return s.Info().Cwd
+}
+
+// SetEnv replaces the default environment of a session. The variables are
+// exported into the running shell before its next command and are applied
+// again whenever the session is reset or restarted.
+func (m *SessionManager) SetEnv(id string, env map[string]string) error {
+	if err := m.opts.envPolicy.Validate(env); err != nil {
+		return err
+	}
+	id = normalizeSessionID(id)
+
+	m.mu.Lock()
+	previous := m.envs[id]
+	if len(env) == 0 {
+		delete(m.envs, id)
+	} else {
+		m.envs[id] = MergeEnv(env)
+	}
+	s, ok := m.sessions[id]
+	m.mu.Unlock()
+
+	if ok {
+		s.queueEnvUpdate(previous, env)
+	}
+	return nil
+}
+
+func (m *SessionManager) Env(id string) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return MergeEnv(m.envs[normalizeSessionID(id)])
+}
+
+// Rechdir makes the sessions whose working directory lies inside dir change
+// into it again before their next command, falling back to the session's
+// workspace. Restoring a snapshot replaces the directories of a workspace,
+// which leaves shells in deleted ones.
+func (m *SessionManager) Rechdir(dir string) {
+	m.mu.Lock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		s.mu.Lock()
+		if rel, err := filepath.Rel(dir, s.cwd); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			s.pending += fmt.Sprintf("cd -- %s 2>/dev/null || cd -- %s 2>/dev/null\n", shellQuote(s.cwd), shellQuote(s.workDir))
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (m *SessionManager) List() []SessionInfo {
+	m.mu.Lock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, s.Info())
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+
+	return infos
+}
+
+func (m *SessionManager) Reset(id string) (*SessionInfo, error) {
+	id = normalizeSessionID(id)
+
+	m.mu.Lock()
+	old, ok := m.sessions[id]
+	if ok {
+		delete(m.sessions, id)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+	old.Close()
+
+	s, err := m.getOrCreate(id, old.workDir, old.user)
+	if err != nil {
+		return nil, err
+	}
+
+	info := s.Info()
+	return &info, nil
+}
+
+func (m *SessionManager) Destroy(id string) error {
+	id = normalizeSessionID(id)
+
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	if ok {
+		delete(m.sessions, id)
+	}
+	_, hasEnv := m.envs[id]
+	delete(m.envs, id)
+	m.mu.Unlock()
+
+	if !ok {
+		if hasEnv {
+			m.closed(id)
+			return nil
+		}
+		return fmt.Errorf("session not found: %s", id)
+	}
+	err := s.Close()
+	m.closed(id)
+	return err
+}
+
+// closed reports the end of a session to the WithSessionClosed callback.
+func (m *SessionManager) closed(id string) {
+	if m.opts.sessionClosed != nil {
+		m.opts.sessionClosed(id)
+	}
+}
+
+func (m *SessionManager) CloseAll() {
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = make(map[string]*Session)
+	select {
+	case <-m.done:
+	default:
+		close(m.done)
+	}
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		s.Close()
+	}
+}
+
+// Session is a long-lived bash process. Commands are written to its stdin and
+// their completion is detected by a per-command marker echoed to stdout and
+// stderr, so cd, exports and shell functions survive between commands.
+type Session struct {
+	id       string
+	workDir  string
+	user     *identity.User
+	token    string
+	limits   Limits
+	cgroup   *sessionCgroup
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdoutCh chan []byte
+	stderrCh chan []byte
+	exited   chan struct{}
+	waitErr  error
+
+	runMu sync.Mutex
+	seq   int
+
+	mu  sync.Mutex
+	cwd string
+	// pending is written to the shell before the next command.
+	pending   string
+	busy      bool
+	closed    bool
+	createdAt time.Time
+	lastUsed  time.Time
+}
+
+type sessionRun struct {
+	stdout        string
+	stderr        string
+	exitCode      int
+	exited        bool
+	interrupted   bool
+	limitExceeded string
+	limitMessage  string
+}
+
+func startSession(id, workDir string, user *identity.User, env map[string]string, limits Limits, cgroups *CgroupManager) (*Session, error) {
+	if err := validateDir(workDir); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("bash")
+	cmd.Dir = workDir
+	cmd.Env = processEnv(env)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	runAs(cmd, user)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start shell session: %w", err)
+	}
+
+	token := newSessionToken()
+	var cg *sessionCgroup
+	if cgroups != nil {
+		var err error
+		if cg, err = cgroups.create(id+"-"+token, limits); err != nil {
+			log.Printf("session %s: cgroup limits disabled: %v", id, err)
+		} else if err := cg.add(cmd.Process.Pid); err != nil {
+			log.Printf("session %s: cgroup limits disabled: %v", id, err)
+			cg.remove()
+			cg = nil
+		}
+	}
+
+	// The shell reads this before the first command.
+	if script := limits.ulimitScript(); script != "" {
+		io.WriteString(stdin, script+"\n")
+	}
+
+	now := time.Now()
+	s := &Session{
+		id:        id,
+		workDir:   workDir,
+		user:      user,
+		token:     token,
+		limits:    limits,
+		cgroup:    cg,
+		cmd:       cmd,
+		stdin:     stdin,
+		stdoutCh:  make(chan []byte, 64),
+		stderrCh:  make(chan []byte, 64),
+		exited:    make(chan struct{}),
+		cwd:       workDir,
+		createdAt: now,
+		lastUsed:  now,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	pump := func(r io.Reader, ch chan []byte) {
+		defer wg.Done()
+		defer close(ch)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				data := make([]byte, n)
+				copy(data, buf[:n])
+				ch <- data
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	safe.Go(func() { pump(stdout, s.stdoutCh) })
+	safe.Go(func() { pump(stderr, s.stderrCh) })
+	safe.Go(func() {
+		wg.Wait()
+		s.waitErr = cmd.Wait()
+		close(s.exited)
+		if s.cgroup != nil {
+			s.cgroup.remove()
+		}
+	})
+
+	return s, nil
+}
+
+func newSessionToken() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (s *Session) alive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	select {
+	case <-s.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *Session) Info() SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return SessionInfo{
+		ID:         s.id,
+		Cwd:        s.cwd,
+		PID:        s.cmd.Process.Pid,
+		Busy:       s.busy,
+		CreatedAt:  s.createdAt,
+		LastUsedAt: s.lastUsed,
+	}
+}
+
+// touch marks the session used, so it does not expire before the command it
+// was picked for runs.
+func (s *Session) touch(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastUsed = now
+}
+
+// idle returns how long the session has been waiting for a command.
+func (s *Session) idle(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.busy {
+		return 0
+	}
+	return now.Sub(s.lastUsed)
+}
+
+func (s *Session) setBusy(busy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.busy = busy
+	s.lastUsed = time.Now()
+}
+
+func (s *Session) queueEnvUpdate(previous, env map[string]string) {
+	var script strings.Builder
+	for _, name := range envNames(previous) {
+		if _, ok := env[name]; !ok {
+			fmt.Fprintf(&script, "unset %s\n", name)
+		}
+	}
+	if len(env) > 0 {
+		fmt.Fprintf(&script, "export %s\n", strings.TrimSpace(envAssignments(env)))
+	}
+
+	s.mu.Lock()
+	s.pending += script.String()
+	s.mu.Unlock()
+}
+
+func (s *Session) takePending() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update := s.pending
+	s.pending = ""
+	return update
+}
+
+func (s *Session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	s.stdin.Close()
+	if s.cmd.Process != nil {
+		syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	select {
+	case <-s.exited:
+	case <-time.After(2 * time.Second):
+	}
+	return nil
+}
+
+func (s *Session) run(ctx context.Context, req SessionExecRequest, onChunk StreamCallback) (*sessionRun, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	s.setBusy(true)
+	defer s.setBusy(false)
+
+	s.seq++
+	marker := fmt.Sprintf("__SANDBOX_DONE_%s_%d__", s.token, s.seq)
+
+	stdinPath := "/dev/null"
+	if req.Stdin != nil {
+		feed, err := newStdinFeed(req.Stdin, s.user)
+		if err != nil {
+			return nil, err
+		}
+		defer feed.Close()
+		stdinPath = feed.path
+	}
+
+	limits := req.Limits.Within(s.limits)
+	var cgBefore cgroupEvents
+	if s.cgroup != nil {
+		restore, err := s.cgroup.tighten(req.Limits.tightened(s.limits))
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+		cgBefore = s.cgroup.events()
+	}
+
+	script := s.takePending() + buildSessionScript(req.Command, req.Cwd, req.Env, req.Limits.tightened(s.limits), stdinPath, marker)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		return nil, fmt.Errorf("failed to write to shell session: %w", err)
+	}
+
+	stdout := newMarkerScanner(marker, "stdout", onChunk)
+	stderr := newMarkerScanner(marker, "stderr", onChunk)
+	stdoutCh, stderrCh := s.stdoutCh, s.stderrCh
+
+	run := &sessionRun{}
+	for !(stdout.done && stderr.done) {
+		if limits.Output > 0 && int64(stdout.buf.Len()+stderr.buf.Len()) > limits.Output {
+			run.interrupted = true
+			run.limitExceeded = LimitOutput
+			run.limitMessage = fmt.Sprintf("output limit of %s exceeded; command was terminated", formatBytes(limits.Output))
+			break
+		}
+
+		if stdoutCh == nil && stderrCh == nil {
+			run.exited = true
+			break
+		}
+
+		select {
+		case data, ok := <-stdoutCh:
+			if !ok {
+				stdoutCh = nil
+				continue
+			}
+			stdout.write(data)
+		case data, ok := <-stderrCh:
+			if !ok {
+				stderrCh = nil
+				continue
+			}
+			stderr.write(data)
+		case <-ctx.Done():
+			run.interrupted = true
+		}
+
+		if run.interrupted {
+			break
+		}
+	}
+
+	stdout.flush()
+	stderr.flush()
+	run.stdout = stdout.output()
+	run.stderr = stderr.output()
+
+	switch {
+	case run.interrupted:
+		run.exitCode = -1
+	case run.exited:
+		<-s.exited
+		run.exitCode = -1
+		if exitErr, ok := s.waitErr.(*exec.ExitError); ok {
+			run.exitCode = exitErr.ExitCode()
+		} else if s.waitErr == nil {
+			run.exitCode = 0
+		}
+	default:
+		exitCode, cwd := parseMarkerTrailer(stdout.trailer)
+		run.exitCode = exitCode
+		if cwd != "" {
+			s.mu.Lock()
+			s.cwd = cwd
+			s.mu.Unlock()
+		}
+	}
+
+	if run.limitExceeded == "" && !run.interrupted {
+		run.limitExceeded, run.limitMessage = limits.exceededFromExitCode(run.exitCode)
+		if run.limitExceeded == "" && s.cgroup != nil {
+			run.limitExceeded, run.limitMessage = s.cgroup.exceeded(limits, cgBefore, s.cgroup.events())
+		}
+	}
+
+	return run, nil
+}
+
+// buildSessionScript wraps a command for the session shell. Per-command env
+// is passed as assignments in front of eval, which bash exports to the command
+// without changing the session's own environment.
+func buildSessionScript(command, cwd string, env map[string]string, limits Limits, stdinPath, marker string) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(command))
+	eval := fmt.Sprintf("eval \"$(printf '%%s' '%s' | base64 -d)\" < %s", encoded, shellQuote(stdinPath))
+
+	var script strings.Builder
+	if cwd != "" {
+		fmt.Fprintf(&script, "cd -- %s && ", shellQuote(cwd))
+	}
+	if ulimits := limits.ulimitScript(); ulimits != "" {
+		fmt.Fprintf(&script, "( %s%s%s )\n", ulimits, envAssignments(env), eval)
+	} else {
+		script.WriteString(envAssignments(env) + eval + "\n")
+	}
+	script.WriteString("__sandbox_ec=$?\n")
+	fmt.Fprintf(&script, "printf '%%s %%d %%s\\n' '%s' \"$__sandbox_ec\" \"$PWD\"\n", marker)
+	fmt.Fprintf(&script, "printf '%%s\\n' '%s' >&2\n", marker)
+	script.WriteString("unset __sandbox_ec\n")
+	return script.String()
+}
+
+func parseMarkerTrailer(trailer string) (int, string) {
+	trailer = strings.TrimPrefix(trailer, " ")
+	code, cwd, _ := strings.Cut(trailer, " ")
+	exitCode, err := strconv.Atoi(code)
+	if err != nil {
+		return -1, ""
+	}
+	return exitCode, cwd
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// markerScanner accumulates one stream of a session command and stops at the
+// completion marker. Trailing bytes that could be the start of the marker are
+// held back so streamed chunks never contain a partial marker.
+type markerScanner struct {
+	marker  []byte
+	source  string
+	onChunk StreamCallback
+
+	buf     bytes.Buffer
+	emitted int
+	found   int
+	trailer string
+	done    bool
+}
+
+func newMarkerScanner(marker, source string, onChunk StreamCallback) *markerScanner {
+	return &markerScanner{
+		marker:  []byte(marker),
+		source:  source,
+		onChunk: onChunk,
+		found:   -1,
+	}
+}
+
+func (sc *markerScanner) write(p []byte) {
+	if sc.done {
+		return
+	}
+
+	searchFrom := max(sc.buf.Len()-len(sc.marker)+1, 0)
+	sc.buf.Write(p)
+	data := sc.buf.Bytes()
+
+	if sc.found < 0 {
+		if idx := bytes.Index(data[searchFrom:], sc.marker); idx >= 0 {
+			sc.found = searchFrom + idx
+		}
+	}
+
+	if sc.found >= 0 {
+		rest := data[sc.found+len(sc.marker):]
+		if nl := bytes.IndexByte(rest, '\n'); nl >= 0 {
+			sc.trailer = string(rest[:nl])
+			sc.done = true
+		}
+		sc.emit(sc.found)
+		return
+	}
+
+	sc.emit(len(data) - partialSuffix(data, sc.marker))
+}
+
+// partialSuffix returns the length of the longest suffix of data that is a
+// proper prefix of marker.
+func partialSuffix(data, marker []byte) int {
+	for n := min(len(marker)-1, len(data)); n > 0; n-- {
+		if bytes.HasSuffix(data, marker[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+func (sc *markerScanner) flush() {
+	if sc.found >= 0 {
+		sc.emit(sc.found)
+		return
+	}
+	sc.emit(sc.buf.Len())
+}
+
+func (sc *markerScanner) emit(end int) {
+	if end <= sc.emitted {
+		return
+	}
+	if sc.onChunk != nil {
+		sc.onChunk(StreamChunk{
+			Data:   string(sc.buf.Bytes()[sc.emitted:end]),
+			Source: sc.source,
+		})
+	}
+	sc.emitted = end
+}
+
+func (sc *markerScanner) output() string {
+	if sc.found >= 0 {
+		return string(sc.buf.Bytes()[:sc.found])
+	}
+	return sc.buf.String()
+}
@@ -0,0 +1,763 @@
+package bash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestSessionManager(t *testing.T) *SessionManager {
+	m := NewSessionManager()
+	t.Cleanup(m.CloseAll)
+	return m
+}
+
+func TestSessionManager_Execute_Echo(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+
+	result, err := m.Execute(context.Background(), SessionExecRequest{
+		SessionID: "s1",
+		Command:   "echo hello",
+		Workspace: workDir,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if result.ExitCode != 0 {
+		t.Errorf("expected exit code 0, got %d", result.ExitCode)
+	}
+	if result.Stdout != "hello\n" {
+		t.Errorf("expected stdout 'hello\\n', got %q", result.Stdout)
+	}
+}
+
+func TestSessionManager_Execute_PersistsState(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	commands := []string{
+		"mkdir -p sub && cd sub",
+		"export SANDBOX_TEST_VAR=persisted",
+		"greet() { echo \"hi $1\"; }",
+	}
+	for _, command := range commands {
+		result, err := m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: command, Workspace: workDir})
+		if err != nil {
+			t.Fatalf("Execute(%q) failed: %v", command, err)
+		}
+		if result.ExitCode != 0 {
+			t.Fatalf("Execute(%q) exit code %d: %s", command, result.ExitCode, result.Output)
+		}
+	}
+
+	result, err := m.Execute(ctx, SessionExecRequest{
+		SessionID: "s1",
+		Command:   "pwd; echo $SANDBOX_TEST_VAR; greet there",
+		Workspace: workDir,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", result.Stdout)
+	}
+	if filepath.Base(lines[0]) != "sub" {
+		t.Errorf("expected cwd to persist, got %q", lines[0])
+	}
+	if lines[1] != "persisted" {
+		t.Errorf("expected env var to persist, got %q", lines[1])
+	}
+	if lines[2] != "hi there" {
+		t.Errorf("expected function to persist, got %q", lines[2])
+	}
+
+	if cwd := m.Cwd("s1"); filepath.Base(cwd) != "sub" {
+		t.Errorf("expected session cwd to end with sub, got %q", cwd)
+	}
+}
+
+func TestSessionManager_Execute_IsolatedSessions(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := m.Execute(ctx, SessionExecRequest{SessionID: "a", Command: "export X=a", Workspace: workDir}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	result, err := m.Execute(ctx, SessionExecRequest{SessionID: "b", Command: "echo \"[$X]\"", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "[]\n" {
+		t.Errorf("expected variable not to leak between sessions, got %q", result.Stdout)
+	}
+}
+
+func TestSessionManager_Execute_ExitCodeAndStderr(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+
+	result, err := m.Execute(context.Background(), SessionExecRequest{
+		Command:   "echo oops >&2; false",
+		Workspace: workDir,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if result.ExitCode != 1 {
+		t.Errorf("expected exit code 1, got %d", result.ExitCode)
+	}
+	if result.Stderr != "oops\n" {
+		t.Errorf("expected stderr 'oops\\n', got %q", result.Stderr)
+	}
+}
+
+func TestSessionManager_Execute_NoTrailingNewline(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+
+	result, err := m.Execute(context.Background(), SessionExecRequest{
+		Command:   "printf abc",
+		Workspace: workDir,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "abc" {
+		t.Errorf("expected stdout 'abc', got %q", result.Stdout)
+	}
+}
+
+func TestSessionManager_Execute_Timeout(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "export KEEP=1", Workspace: workDir}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	start := time.Now()
+	result, err := m.Execute(ctx, SessionExecRequest{
+		SessionID: "s1",
+		Command:   "echo before; sleep 10",
+		Workspace: workDir,
+		Timeout:   300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("expected timeout around 300ms, took %v", time.Since(start))
+	}
+	if !result.TimedOut {
+		t.Error("expected TimedOut to be true")
+	}
+	if !strings.Contains(result.Output, "before") {
+		t.Errorf("expected partial output, got %q", result.Output)
+	}
+	if !strings.Contains(result.Metadata, "timed out after 300ms") {
+		t.Errorf("expected timeout metadata, got %q", result.Metadata)
+	}
+
+	result, err = m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "echo \"[$KEEP]\"", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute after timeout failed: %v", err)
+	}
+	if result.Stdout != "[]\n" {
+		t.Errorf("expected fresh session after timeout, got %q", result.Stdout)
+	}
+}
+
+func TestSessionManager_Execute_ShellExit(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	result, err := m.Execute(ctx, SessionExecRequest{Command: "exit 7", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.ExitCode != 7 {
+		t.Errorf("expected exit code 7, got %d", result.ExitCode)
+	}
+	if !strings.Contains(result.Metadata, "shell session exited") {
+		t.Errorf("expected exit metadata, got %q", result.Metadata)
+	}
+
+	result, err = m.Execute(ctx, SessionExecRequest{Command: "echo again", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute after exit failed: %v", err)
+	}
+	if result.Stdout != "again\n" {
+		t.Errorf("expected 'again\\n', got %q", result.Stdout)
+	}
+}
+
+func TestSessionManager_Execute_Cwd(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	other := t.TempDir()
+
+	result, err := m.Execute(context.Background(), SessionExecRequest{
+		Command:   "pwd",
+		Workspace: workDir,
+		Cwd:       other,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if strings.TrimSpace(result.Stdout) != other {
+		t.Errorf("expected pwd %q, got %q", other, result.Stdout)
+	}
+}
+
+func TestSessionManager_Rechdir(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	sub := filepath.Join(workDir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Execute(context.Background(), SessionExecRequest{Command: "cd sub", Workspace: workDir}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	// Replace the directory the shell is in, as a snapshot restore does.
+	if err := os.Rename(sub, sub+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "restored"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(sub + ".old")
+	m.Rechdir(workDir)
+
+	result, err := m.Execute(context.Background(), SessionExecRequest{Command: "ls", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if strings.TrimSpace(result.Stdout) != "restored" || result.Stderr != "" {
+		t.Errorf("expected the restored directory, got stdout %q stderr %q", result.Stdout, result.Stderr)
+	}
+}
+
+func TestSessionManager_Execute_InvalidWorkspace(t *testing.T) {
+	m := newTestSessionManager(t)
+
+	_, err := m.Execute(context.Background(), SessionExecRequest{
+		Command:   "echo hello",
+		Workspace: "/nonexistent/path/for/session",
+	})
+	if err == nil {
+		t.Fatal("expected error for invalid workspace")
+	}
+}
+
+func TestSessionManager_ExecuteStream(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+
+	var streamed strings.Builder
+	result, err := m.ExecuteStream(context.Background(), SessionExecRequest{
+		Command:   "echo one; echo two",
+		Workspace: workDir,
+	}, func(chunk StreamChunk) {
+		if chunk.Source == "stdout" {
+			streamed.WriteString(chunk.Data)
+		}
+	})
+	if err != nil {
+		t.Fatalf("ExecuteStream failed: %v", err)
+	}
+
+	if streamed.String() != "one\ntwo\n" {
+		t.Errorf("expected streamed 'one\\ntwo\\n', got %q", streamed.String())
+	}
+	if result.Stdout != streamed.String() {
+		t.Errorf("expected stdout to match streamed output, got %q", result.Stdout)
+	}
+}
+
+func TestSessionManager_ListResetDestroy(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "export X=1", Workspace: workDir}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	sessions := m.List()
+	if len(sessions) != 1 || sessions[0].ID != "s1" {
+		t.Fatalf("expected one session s1, got %+v", sessions)
+	}
+	oldPID := sessions[0].PID
+
+	info, err := m.Reset("s1")
+	if err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if info.PID == oldPID {
+		t.Error("expected a new shell process after reset")
+	}
+
+	result, err := m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "echo \"[$X]\"", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "[]\n" {
+		t.Errorf("expected state cleared after reset, got %q", result.Stdout)
+	}
+
+	if err := m.Destroy("s1"); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	if len(m.List()) != 0 {
+		t.Error("expected no sessions after destroy")
+	}
+	if err := m.Destroy("s1"); err == nil {
+		t.Error("expected error destroying unknown session")
+	}
+	if _, err := m.Reset("missing"); err == nil {
+		t.Error("expected error resetting unknown session")
+	}
+}
+
+func TestSessionManager_SessionLimits(t *testing.T) {
+	m := NewSessionManager(WithSessionLimits(2, 200*time.Millisecond))
+	t.Cleanup(m.CloseAll)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	for _, id := range []string{"s1", "s2"} {
+		if _, err := m.Execute(ctx, SessionExecRequest{SessionID: id, Command: "true", Workspace: workDir}); err != nil {
+			t.Fatalf("Execute %s failed: %v", id, err)
+		}
+	}
+	if _, err := m.Execute(ctx, SessionExecRequest{SessionID: "s3", Command: "true", Workspace: workDir}); !errors.Is(err, ErrSessionLimit) {
+		t.Fatalf("expected ErrSessionLimit, got %v", err)
+	}
+	// A session that is running a command is never idle.
+	if _, err := m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "sleep 0.4", Workspace: workDir}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if _, err := m.Execute(ctx, SessionExecRequest{SessionID: "s3", Command: "true", Workspace: workDir}); err != nil {
+		t.Fatalf("expected the idle session to make room, got %v", err)
+	}
+	var ids []string
+	for _, info := range m.List() {
+		ids = append(ids, info.ID)
+	}
+	if strings.Join(ids, ",") != "s1,s3" {
+		t.Errorf("expected s2 to expire, got sessions %v", ids)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(m.List()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if sessions := m.List(); len(sessions) != 0 {
+		t.Errorf("expected idle sessions to be closed, got %+v", sessions)
+	}
+}
+
+func TestSessionManager_SessionClosed(t *testing.T) {
+	closed := make(chan string, 4)
+	m := NewSessionManager(WithSessionLimits(0, 200*time.Millisecond), WithSessionClosed(func(id string) {
+		closed <- id
+	}))
+	t.Cleanup(m.CloseAll)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	for _, id := range []string{"destroyed", "idle"} {
+		if _, err := m.Execute(ctx, SessionExecRequest{SessionID: id, Command: "true", Workspace: workDir}); err != nil {
+			t.Fatalf("Execute %s failed: %v", id, err)
+		}
+	}
+	if err := m.Destroy("destroyed"); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	for _, want := range []string{"destroyed", "idle"} {
+		select {
+		case id := <-closed:
+			if id != want {
+				t.Errorf("expected %s to be closed, got %s", want, id)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("expected %s to be closed", want)
+		}
+	}
+}
+
+func TestMarkerScanner_SplitMarker(t *testing.T) {
+	marker := "__MARK__"
+	var chunks []string
+	sc := newMarkerScanner(marker, "stdout", func(chunk StreamChunk) {
+		chunks = append(chunks, chunk.Data)
+	})
+
+	sc.write([]byte("hello __MA"))
+	sc.write([]byte("RK__ 3 /tmp\n"))
+
+	if !sc.done {
+		t.Fatal("expected scanner to be done")
+	}
+	if sc.output() != "hello " {
+		t.Errorf("expected output 'hello ', got %q", sc.output())
+	}
+	if strings.Join(chunks, "") != "hello " {
+		t.Errorf("expected streamed 'hello ', got %q", strings.Join(chunks, ""))
+	}
+
+	exitCode, cwd := parseMarkerTrailer(sc.trailer)
+	if exitCode != 3 || cwd != "/tmp" {
+		t.Errorf("expected (3, /tmp), got (%d, %q)", exitCode, cwd)
+	}
+}
+
+func TestSessionManager_Execute_Env(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	result, err := m.Execute(ctx, SessionExecRequest{
+		SessionID: "s1",
+		Command:   "echo \"$GREETING\"; bash -c 'echo \"$GREETING\"'",
+		Workspace: workDir,
+		Env:       map[string]string{"GREETING": "hello 'world'"},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "hello 'world'\nhello 'world'\n" {
+		t.Errorf("expected env in shell and child, got %q", result.Stdout)
+	}
+
+	result, err = m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "echo \"[$GREETING]\"", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "[]\n" {
+		t.Errorf("expected per-command env not to persist, got %q", result.Stdout)
+	}
+
+	if _, err := m.Execute(ctx, SessionExecRequest{
+		SessionID: "s1",
+		Command:   "true",
+		Workspace: workDir,
+		Env:       map[string]string{"LD_PRELOAD": "/tmp/evil.so"},
+	}); err == nil {
+		t.Error("expected denied variable to be rejected")
+	}
+}
+
+func TestSessionManager_SetEnv(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "true", Workspace: workDir}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if err := m.SetEnv("s1", map[string]string{"A": "1", "B": "2"}); err != nil {
+		t.Fatalf("SetEnv failed: %v", err)
+	}
+	result, err := m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "echo \"$A$B\"", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "12\n" {
+		t.Errorf("expected defaults in running session, got %q", result.Stdout)
+	}
+
+	result, err = m.Execute(ctx, SessionExecRequest{
+		SessionID: "s1",
+		Command:   "echo \"$A$B\"",
+		Workspace: workDir,
+		Env:       map[string]string{"B": "x"},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "1x\n" {
+		t.Errorf("expected request env to override defaults, got %q", result.Stdout)
+	}
+
+	if err := m.SetEnv("s1", map[string]string{"A": "3"}); err != nil {
+		t.Fatalf("SetEnv failed: %v", err)
+	}
+	if _, err := m.Reset("s1"); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	result, err = m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "echo \"$A[$B]\"", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "3[]\n" {
+		t.Errorf("expected defaults to survive reset, got %q", result.Stdout)
+	}
+
+	if env := m.Env("s1"); len(env) != 1 || env["A"] != "3" {
+		t.Errorf("unexpected session env: %v", env)
+	}
+	if err := m.SetEnv("s1", map[string]string{"BASH_ENV": "/tmp/rc"}); err == nil {
+		t.Error("expected denied variable to be rejected")
+	}
+}
+
+func TestSessionManager_ConcurrentTimeouts(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+
+	const n = 8
+	var wg sync.WaitGroup
+	results := make([]*ExecResult, n)
+	errs := make([]error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := SessionExecRequest{
+				SessionID: fmt.Sprintf("s%d", i),
+				Command:   "echo fast",
+				Workspace: workDir,
+				Timeout:   5 * time.Second,
+			}
+			if i%2 == 0 {
+				req.Command = "sleep 5"
+				req.Timeout = time.Duration(100+i*25) * time.Millisecond
+			}
+			results[i], errs[i] = m.Execute(context.Background(), req)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("execution %d failed: %v", i, errs[i])
+		}
+		if i%2 == 0 {
+			want := fmt.Sprintf("timed out after %v", time.Duration(100+i*25)*time.Millisecond)
+			if !results[i].TimedOut || !strings.Contains(results[i].Metadata, want) {
+				t.Errorf("execution %d: expected %q, got timedOut=%v metadata=%q", i, want, results[i].TimedOut, results[i].Metadata)
+			}
+		} else if results[i].TimedOut || results[i].Stdout != "fast\n" {
+			t.Errorf("execution %d: expected fast success, got timedOut=%v stdout=%q", i, results[i].TimedOut, results[i].Stdout)
+		}
+	}
+}
+
+func TestSessionManager_DefaultAndMaxTimeout(t *testing.T) {
+	m := NewSessionManager(WithTimeouts(200*time.Millisecond, 400*time.Millisecond))
+	t.Cleanup(m.CloseAll)
+	workDir := t.TempDir()
+
+	result, err := m.Execute(context.Background(), SessionExecRequest{Command: "sleep 5", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !result.TimedOut || result.TimeoutMs != 200 {
+		t.Errorf("expected default timeout of 200ms, got timedOut=%v timeout=%dms", result.TimedOut, result.TimeoutMs)
+	}
+
+	result, err = m.Execute(context.Background(), SessionExecRequest{Command: "sleep 5", Workspace: workDir, Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result.Metadata, "timed out after 400ms") {
+		t.Errorf("expected capped timeout in metadata, got %q", result.Metadata)
+	}
+}
+
+func TestSessionManager_Execute_Stdin(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	result, err := m.Execute(ctx, SessionExecRequest{
+		SessionID: "s1",
+		Command:   "read -r name; echo \"hello $name\"; cat",
+		Workspace: workDir,
+		Stdin:     strings.NewReader("world\nrest\n"),
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "hello world\nrest\n" {
+		t.Errorf("expected stdin to be consumed, got %q", result.Stdout)
+	}
+
+	result, err = m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "cat; echo done", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "done\n" {
+		t.Errorf("expected empty stdin without input, got %q", result.Stdout)
+	}
+}
+
+func TestSessionManager_Execute_StreamingStdin(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+
+	stdinR, stdinW := io.Pipe()
+	defer stdinR.Close()
+
+	lines := make(chan string, 4)
+	done := make(chan *ExecResult, 1)
+	go func() {
+		result, err := m.ExecuteStream(context.Background(), SessionExecRequest{
+			Command:   "while read -r line; do echo \"got $line\"; done; echo eof",
+			Workspace: workDir,
+			Stdin:     stdinR,
+			Timeout:   5 * time.Second,
+		}, func(chunk StreamChunk) {
+			lines <- chunk.Data
+		})
+		if err != nil {
+			t.Errorf("ExecuteStream failed: %v", err)
+		}
+		done <- result
+	}()
+
+	io.WriteString(stdinW, "one\n")
+	select {
+	case line := <-lines:
+		if line != "got one\n" {
+			t.Errorf("expected 'got one\\n', got %q", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected output before EOF")
+	}
+
+	io.WriteString(stdinW, "two\n")
+	stdinW.Close()
+
+	result := <-done
+	if result == nil {
+		t.Fatal("expected result")
+	}
+	if result.Stdout != "got one\ngot two\neof\n" {
+		t.Errorf("unexpected stdout %q", result.Stdout)
+	}
+}
+
+func TestMarkerScanner_EmitsWithoutPartialMarker(t *testing.T) {
+	var chunks []string
+	sc := newMarkerScanner("__MARK__", "stdout", func(chunk StreamChunk) {
+		chunks = append(chunks, chunk.Data)
+	})
+
+	sc.write([]byte("prompt> "))
+	if strings.Join(chunks, "") != "prompt> " {
+		t.Errorf("expected output without marker prefix to stream immediately, got %q", chunks)
+	}
+
+	sc.write([]byte("__M"))
+	if strings.Join(chunks, "") != "prompt> " {
+		t.Errorf("expected partial marker to be held back, got %q", chunks)
+	}
+}
+
+func TestSessionManager_Execute_Limits(t *testing.T) {
+	m := newTestSessionManager(t)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "export KEEP=1", Workspace: workDir}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	result, err := m.Execute(ctx, SessionExecRequest{
+		SessionID: "s1",
+		Command:   "head -c 8192 /dev/zero > out",
+		Workspace: workDir,
+		Limits:    Limits{FileSize: 1024},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.LimitExceeded != LimitFileSize {
+		t.Errorf("expected file size limit, got %q (exit %d, %s)", result.LimitExceeded, result.ExitCode, result.Metadata)
+	}
+
+	result, err = m.Execute(ctx, SessionExecRequest{
+		SessionID: "s1",
+		Command:   "head -c 8192 /dev/zero > out2; ulimit -f; echo \"[$KEEP]\"",
+		Workspace: workDir,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.LimitExceeded != "" || result.Stdout != "unlimited\n[1]\n" {
+		t.Errorf("expected per-command limits to stay scoped, got %q (%s)", result.Stdout, result.Metadata)
+	}
+}
+
+func TestSessionManager_Execute_OutputLimit(t *testing.T) {
+	m := NewSessionManager(WithLimits(Limits{Output: 4096}))
+	t.Cleanup(m.CloseAll)
+	workDir := t.TempDir()
+	ctx := context.Background()
+
+	result, err := m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "yes", Workspace: workDir, Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.LimitExceeded != LimitOutput {
+		t.Errorf("expected output limit, got %q (%s)", result.LimitExceeded, result.Metadata)
+	}
+	if result.TimedOut {
+		t.Error("expected the command to stop before the timeout")
+	}
+
+	result, err = m.Execute(ctx, SessionExecRequest{SessionID: "s1", Command: "echo ok", Workspace: workDir})
+	if err != nil {
+		t.Fatalf("Execute after output limit failed: %v", err)
+	}
+	if result.Stdout != "ok\n" {
+		t.Errorf("expected a working session, got %q", result.Stdout)
+	}
+}
+
+func TestSessionManager_Execute_CPULimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("burns a second of CPU time")
+	}
+	m := newTestSessionManager(t)
+
+	result, err := m.Execute(context.Background(), SessionExecRequest{
+		Command:   "while :; do :; done",
+		Workspace: t.TempDir(),
+		Timeout:   10 * time.Second,
+		Limits:    Limits{CPUTime: time.Second},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.LimitExceeded != LimitCPU {
+		t.Errorf("expected CPU limit, got %q (exit %d, %s)", result.LimitExceeded, result.ExitCode, result.Metadata)
+	}
+}
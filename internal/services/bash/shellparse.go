@@ -0,0 +1,556 @@
+package bash
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+const maxShellNesting = 16
+
+// shellCommand is one simple command found in a command line. Words are
+// unquoted and stripped of leading assignments and wrappers such as sudo or
+// env, so words[0] is the program that actually runs. Commands connected by
+// pipes share the same pipeline number, in pipe order.
+type shellCommand struct {
+	words    []string
+	pipeline int
+}
+
+func (c shellCommand) text() string {
+	return strings.Join(c.words, " ")
+}
+
+// parseCommandLine splits a bash command line into its simple commands. It
+// understands quoting, pipelines, lists, subshells, brace groups, command and
+// process substitution, redirections, here-documents and nested "bash -c" or
+// eval scripts. It is not a full shell parser: expansions are left as written.
+func parseCommandLine(src string) ([]shellCommand, error) {
+	p := &shellParser{}
+	if err := p.parse(src, 0); err != nil {
+		return nil, err
+	}
+	return p.commands, nil
+}
+
+type shellParser struct {
+	commands  []shellCommand
+	pipelines int
+}
+
+func (p *shellParser) newPipeline() int {
+	p.pipelines++
+	return p.pipelines - 1
+}
+
+func (p *shellParser) parse(src string, depth int) error {
+	if depth > maxShellNesting {
+		return errors.New("command nesting is too deep")
+	}
+	l := &shellLexer{p: p, src: src, depth: depth, pipeline: p.newPipeline(), closedGroup: -1}
+	return l.run()
+}
+
+const (
+	redirectNone = iota
+	redirectTarget
+	redirectHeredoc
+	redirectHeredocStrip
+)
+
+type heredoc struct {
+	delim string
+	strip bool
+}
+
+type shellLexer struct {
+	p     *shellParser
+	src   string
+	pos   int
+	depth int
+
+	word     strings.Builder
+	inWord   bool
+	words    []string
+	pipeline int
+
+	redirect    int
+	heredocs    []heredoc
+	groups      []int
+	closedGroup int
+}
+
+func (l *shellLexer) run() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\\':
+			if l.pos+1 < len(l.src) {
+				if l.src[l.pos+1] != '\n' {
+					l.word.WriteByte(l.src[l.pos+1])
+					l.inWord = true
+				}
+				l.pos += 2
+			} else {
+				l.pos++
+			}
+		case c == '\'':
+			end := strings.IndexByte(l.src[l.pos+1:], '\'')
+			if end < 0 {
+				return errors.New("unterminated single quote")
+			}
+			l.word.WriteString(l.src[l.pos+1 : l.pos+1+end])
+			l.inWord = true
+			l.pos += end + 2
+		case c == '"':
+			if err := l.doubleQuoted(); err != nil {
+				return err
+			}
+		case c == '`':
+			if err := l.backquoted(); err != nil {
+				return err
+			}
+		case c == '$' && l.peek(1) == '(':
+			if err := l.substitution(l.pos+1, l.peek(2) != '('); err != nil {
+				return err
+			}
+		case c == '$' && l.peek(1) == '{':
+			end := strings.IndexByte(l.src[l.pos:], '}')
+			if end < 0 {
+				return errors.New("unterminated parameter expansion")
+			}
+			l.word.WriteString(l.src[l.pos : l.pos+end+1])
+			l.inWord = true
+			l.pos += end + 1
+		case (c == '<' || c == '>') && l.peek(1) == '(':
+			if err := l.substitution(l.pos+1, true); err != nil {
+				return err
+			}
+		case c == '#' && !l.inWord:
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == ' ' || c == '\t':
+			l.endWord()
+			l.pos++
+		case c == '\n':
+			l.endWord()
+			l.endCommand()
+			l.endPipeline()
+			l.pos++
+			l.skipHeredocs()
+		case c == ';':
+			l.endWord()
+			l.endCommand()
+			l.endPipeline()
+			l.pos++
+			if l.peek(0) == ';' || l.peek(0) == '&' {
+				l.pos++
+			}
+		case c == '&' && l.peek(1) == '>':
+			l.endWord()
+			l.pos += 2
+			if l.peek(0) == '>' {
+				l.pos++
+			}
+			l.redirect = redirectTarget
+		case c == '&':
+			l.endWord()
+			l.endCommand()
+			l.endPipeline()
+			l.pos++
+			if l.peek(0) == '&' {
+				l.pos++
+			}
+		case c == '|' && l.peek(1) == '|':
+			l.endWord()
+			l.endCommand()
+			l.endPipeline()
+			l.pos += 2
+		case c == '|':
+			l.endWord()
+			l.endCommand()
+			l.joinGroup()
+			l.pos++
+			if l.peek(0) == '&' {
+				l.pos++
+			}
+		case c == '(':
+			l.endWord()
+			l.endCommand()
+			l.openGroup()
+			l.pos++
+		case c == ')':
+			l.endWord()
+			l.endCommand()
+			l.closeGroup()
+			l.pos++
+		case c == '<' || c == '>':
+			l.redirection()
+		default:
+			l.word.WriteByte(c)
+			l.inWord = true
+			l.pos++
+		}
+	}
+	l.endWord()
+	l.endCommand()
+	return nil
+}
+
+func (l *shellLexer) peek(offset int) byte {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *shellLexer) doubleQuoted() error {
+	l.inWord = true
+	l.pos++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return nil
+		case c == '\\' && l.pos+1 < len(l.src):
+			next := l.src[l.pos+1]
+			switch next {
+			case '\n':
+			case '$', '`', '"', '\\':
+				l.word.WriteByte(next)
+			default:
+				l.word.WriteByte(c)
+				l.word.WriteByte(next)
+			}
+			l.pos += 2
+		case c == '`':
+			if err := l.backquoted(); err != nil {
+				return err
+			}
+		case c == '$' && l.peek(1) == '(':
+			if err := l.substitution(l.pos+1, l.peek(2) != '('); err != nil {
+				return err
+			}
+		default:
+			l.word.WriteByte(c)
+			l.pos++
+		}
+	}
+	return errors.New("unterminated double quote")
+}
+
+// substitution handles $(...), <(...) and >(...) starting at the opening
+// parenthesis. The text is kept in the current word and, unless it is an
+// arithmetic expansion, parsed as commands of its own.
+func (l *shellLexer) substitution(open int, isCommand bool) error {
+	end, err := matchParen(l.src, open)
+	if err != nil {
+		return err
+	}
+	l.word.WriteString(l.src[l.pos : end+1])
+	l.inWord = true
+	inner := l.src[open+1 : end]
+	l.pos = end + 1
+	if !isCommand {
+		return nil
+	}
+	return l.p.parse(inner, l.depth+1)
+}
+
+func (l *shellLexer) backquoted() error {
+	var inner strings.Builder
+	for i := l.pos + 1; i < len(l.src); i++ {
+		switch c := l.src[i]; {
+		case c == '\\' && i+1 < len(l.src):
+			i++
+			inner.WriteByte(l.src[i])
+		case c == '`':
+			l.word.WriteString(l.src[l.pos : i+1])
+			l.inWord = true
+			l.pos = i + 1
+			return l.p.parse(inner.String(), l.depth+1)
+		default:
+			inner.WriteByte(c)
+		}
+	}
+	return errors.New("unterminated backquote")
+}
+
+// matchParen returns the index of the parenthesis closing the one at open,
+// skipping quoted text.
+func matchParen(src string, open int) (int, error) {
+	depth := 0
+	for i := open; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := strings.IndexByte(src[i+1:], '\'')
+			if end < 0 {
+				return 0, errors.New("unterminated single quote")
+			}
+			i += end + 1
+		case '"':
+			for i++; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(src) {
+				return 0, errors.New("unterminated double quote")
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, errors.New("unterminated command substitution")
+}
+
+func (l *shellLexer) redirection() {
+	// A file descriptor number such as the 2 in 2> belongs to the operator.
+	if l.inWord && isDigits(l.word.String()) {
+		l.word.Reset()
+		l.inWord = false
+	}
+	l.endWord()
+
+	op := l.src[l.pos]
+	l.pos++
+	l.redirect = redirectTarget
+	switch next := l.peek(0); {
+	case op == '<' && next == '<' && l.peek(1) == '<':
+		l.pos += 2
+	case op == '<' && next == '<':
+		l.pos++
+		l.redirect = redirectHeredoc
+		if l.peek(0) == '-' {
+			l.pos++
+			l.redirect = redirectHeredocStrip
+		}
+	case next == '>' || next == '|' || (op == '<' && next == '>'):
+		l.pos++
+	case next == '&':
+		l.pos++
+	}
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *shellLexer) skipHeredocs() {
+	for _, h := range l.heredocs {
+		for l.pos < len(l.src) {
+			end := strings.IndexByte(l.src[l.pos:], '\n')
+			var line string
+			if end < 0 {
+				line = l.src[l.pos:]
+				l.pos = len(l.src)
+			} else {
+				line = l.src[l.pos : l.pos+end]
+				l.pos += end + 1
+			}
+			if h.strip {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == h.delim {
+				break
+			}
+		}
+	}
+	l.heredocs = nil
+}
+
+func (l *shellLexer) endWord() {
+	if !l.inWord {
+		return
+	}
+	w := l.word.String()
+	l.word.Reset()
+	l.inWord = false
+
+	switch l.redirect {
+	case redirectTarget:
+		l.redirect = redirectNone
+		return
+	case redirectHeredoc, redirectHeredocStrip:
+		l.heredocs = append(l.heredocs, heredoc{delim: w, strip: l.redirect == redirectHeredocStrip})
+		l.redirect = redirectNone
+		return
+	}
+
+	if len(l.words) == 0 {
+		switch w {
+		case "{":
+			l.openGroup()
+			return
+		case "}":
+			l.closeGroup()
+			return
+		}
+	}
+	l.closedGroup = -1
+	l.words = append(l.words, w)
+}
+
+func (l *shellLexer) endCommand() {
+	if len(l.words) == 0 {
+		return
+	}
+	words := normalizeCommand(l.words)
+	l.words = nil
+	if len(words) == 0 {
+		return
+	}
+	l.p.commands = append(l.p.commands, shellCommand{words: words, pipeline: l.pipeline})
+	l.closedGroup = -1
+	l.expandNested(words)
+}
+
+func (l *shellLexer) endPipeline() {
+	l.pipeline = l.p.newPipeline()
+	l.closedGroup = -1
+}
+
+func (l *shellLexer) openGroup() {
+	l.groups = append(l.groups, len(l.p.commands))
+}
+
+func (l *shellLexer) closeGroup() {
+	if len(l.groups) == 0 {
+		return
+	}
+	l.closedGroup = l.groups[len(l.groups)-1]
+	l.groups = l.groups[:len(l.groups)-1]
+}
+
+// joinGroup handles a pipe after a subshell or brace group: every command of
+// the group writes into the pipe, so they all join the current pipeline.
+func (l *shellLexer) joinGroup() {
+	if l.closedGroup < 0 {
+		return
+	}
+	for i := l.closedGroup; i < len(l.p.commands); i++ {
+		l.p.commands[i].pipeline = l.pipeline
+	}
+	l.closedGroup = -1
+}
+
+// expandNested parses the script of "bash -c script" and "eval args" as
+// commands of their own.
+func (l *shellLexer) expandNested(words []string) {
+	var script string
+	switch words[0] {
+	case "eval":
+		script = strings.Join(words[1:], " ")
+	case "sh", "bash", "zsh", "dash", "ksh":
+		for i := 1; i < len(words)-1; i++ {
+			w := words[i]
+			if !strings.HasPrefix(w, "-") || strings.HasPrefix(w, "--") {
+				break
+			}
+			if strings.Contains(w, "c") {
+				script = words[i+1]
+				break
+			}
+		}
+	}
+	if script != "" {
+		// A script that fails to parse is still rejected by bash itself.
+		l.p.parse(script, l.depth+1)
+	}
+}
+
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"do": true, "done": true, "while": true, "until": true, "!": true,
+	"esac": true,
+}
+
+// commandWrappers lists programs that run their arguments as a command, with
+// the options that take a separate value and the number of positional
+// arguments that precede the wrapped command.
+var commandWrappers = map[string]struct {
+	valueFlags string
+	positional int
+}{
+	"sudo":    {valueFlags: "-u -g -h -p -C -D -r -t -U -T"},
+	"doas":    {valueFlags: "-u -C"},
+	"env":     {valueFlags: "-u -C -S"},
+	"command": {},
+	"builtin": {},
+	"exec":    {valueFlags: "-a"},
+	"nohup":   {},
+	"time":    {},
+	"nice":    {valueFlags: "-n"},
+	"ionice":  {valueFlags: "-c -n -p"},
+	"timeout": {valueFlags: "-s -k", positional: 1},
+	"stdbuf":  {valueFlags: "-i -o -e"},
+	"xargs":   {valueFlags: "-a -d -E -I -L -n -P -s"},
+	"chroot":  {positional: 1},
+	"setsid":  {},
+}
+
+func normalizeCommand(words []string) []string {
+	for len(words) > 0 {
+		w := words[0]
+		if shellKeywords[w] || isAssignment(w) {
+			words = words[1:]
+			continue
+		}
+		wrapper, ok := commandWrappers[filepath.Base(w)]
+		if !ok {
+			break
+		}
+		words = words[1:]
+		for len(words) > 0 && strings.HasPrefix(words[0], "-") && words[0] != "-" {
+			flag := words[0]
+			words = words[1:]
+			if flag == "--" {
+				break
+			}
+			if len(words) > 0 && containsWord(wrapper.valueFlags, flag) {
+				words = words[1:]
+			}
+		}
+		for len(words) > 0 && isAssignment(words[0]) {
+			words = words[1:]
+		}
+		if wrapper.positional > 0 && len(words) > wrapper.positional {
+			words = words[wrapper.positional:]
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	return append([]string{filepath.Base(words[0])}, words[1:]...)
+}
+
+func isAssignment(w string) bool {
+	name, _, ok := strings.Cut(w, "=")
+	return ok && envNamePattern.MatchString(name)
+}
+
+func containsWord(list, w string) bool {
+	for _, item := range strings.Fields(list) {
+		if item == w {
+			return true
+		}
+	}
+	return false
+}
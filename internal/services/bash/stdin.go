@@ -0,0 +1,85 @@
+package bash
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/deep-agent/sandbox/internal/services/identity"
+	"github.com/deep-agent/sandbox/pkg/safe"
+)
+
+const (
+	StdinEncodingText   = "text"
+	StdinEncodingBase64 = "base64"
+)
+
+// DecodeStdin converts a stdin payload from the API into raw bytes. An empty
+// encoding means text.
+func DecodeStdin(data, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", StdinEncodingText:
+		return []byte(data), nil
+	case StdinEncodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 stdin: %w", err)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported stdin encoding: %s", encoding)
+	}
+}
+
+// stdinFeed connects a reader to a command running in a session shell through
+// a named pipe. The writer end is opened once the command opens the pipe, so
+// no input is lost, and the command sees EOF when the source is drained. With
+// a session user the pipe is handed over so the shell can open it.
+type stdinFeed struct {
+	dir  string
+	path string
+}
+
+func newStdinFeed(src io.Reader, user *identity.User) (*stdinFeed, error) {
+	dir, err := os.MkdirTemp("", "sandbox-stdin-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin directory: %w", err)
+	}
+
+	path := filepath.Join(dir, "stdin")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	if user != nil {
+		for _, p := range []string{dir, path} {
+			if err := user.Chown(p); err != nil {
+				os.RemoveAll(dir)
+				return nil, fmt.Errorf("failed to hand stdin pipe to session user: %w", err)
+			}
+		}
+	}
+
+	safe.Go(func() {
+		w, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return
+		}
+		defer w.Close()
+		io.Copy(w, src)
+	})
+
+	return &stdinFeed{dir: dir, path: path}, nil
+}
+
+// Close releases a writer that is still waiting for the command to open the
+// pipe; any further writes fail once no reader is left.
+func (f *stdinFeed) Close() {
+	if r, err := os.OpenFile(f.path, os.O_RDONLY|syscall.O_NONBLOCK, 0); err == nil {
+		r.Close()
+	}
+	os.RemoveAll(f.dir)
+}
@@ -0,0 +1,21 @@
+package bash
+
+import (
+	"os/exec"
+	"syscall"
+
+	"github.com/deep-agent/sandbox/internal/services/identity"
+)
+
+// runAs makes cmd run with the credentials and login variables of user. A nil
+// user keeps the server's own user. It must be called after cmd.Env is set.
+func runAs(cmd *exec.Cmd, user *identity.User) {
+	if user == nil {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = user.Credential()
+	cmd.Env = append(cmd.Env, user.Env()...)
+}
@@ -0,0 +1,168 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/target"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+var (
+	ErrContextLimit    = errors.New("too many browser contexts")
+	ErrContextNotFound = errors.New("browser context not found")
+)
+
+// sessionContext is the browser context, with its own cookies, storage and
+// cache, the tabs of a session are opened in.
+type sessionContext struct {
+	id       cdp.BrowserContextID
+	created  time.Time
+	lastUsed time.Time
+}
+
+// WithContextLimits caps the browser contexts of sessions at limit, unlimited
+// when zero, and disposes those unused for idle, never when zero.
+func WithContextLimits(limit int, idle time.Duration) Option {
+	return func(c *Controller) {
+		c.maxContexts = limit
+		c.contextIdle = idle
+	}
+}
+
+// browserContext returns the browser context of the session, creating it if
+// needed, or "" for the default context when the controller acts for no
+// session. s.mu must be held.
+func (c *Controller) browserContext(conn context.Context) (cdp.BrowserContextID, error) {
+	if c.session == "" {
+		return "", nil
+	}
+	s := c.state
+	now := time.Now()
+	if sc := s.contexts[c.session]; sc != nil {
+		sc.lastUsed = now
+		return sc.id, nil
+	}
+
+	c.expireContexts(now)
+	if c.maxContexts > 0 && len(s.contexts) >= c.maxContexts {
+		return "", fmt.Errorf("%w: %d sessions are using the browser", ErrContextLimit, len(s.contexts))
+	}
+	ctx, cancel := c.browser(conn)
+	defer cancel()
+	// Chrome disposes the context if the connection is lost, so it cannot
+	// outlive the controller.
+	id, err := target.CreateBrowserContext().WithDisposeOnDetach(true).Do(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create browser context: %w", err)
+	}
+	s.contexts[c.session] = &sessionContext{id: id, created: now, lastUsed: now}
+	return id, nil
+}
+
+// owns reports whether a tab belongs to the browser context id, which is ""
+// for the default context. s.mu must be held.
+func (c *Controller) owns(info *target.Info, id cdp.BrowserContextID) bool {
+	if id != "" {
+		return info.BrowserContextID == id
+	}
+	for _, sc := range c.state.contexts {
+		if sc.id == info.BrowserContextID {
+			return false
+		}
+	}
+	return true
+}
+
+// expireContexts disposes the browser contexts unused for longer than the
+// idle timeout. s.mu must be held.
+func (c *Controller) expireContexts(now time.Time) {
+	if c.contextIdle <= 0 {
+		return
+	}
+	for session, sc := range c.state.contexts {
+		if now.Sub(sc.lastUsed) > c.contextIdle {
+			c.disposeContext(session)
+		}
+	}
+}
+
+// watchContexts expires idle browser contexts until the connection is
+// closed.
+func (c *Controller) watchContexts(conn context.Context) {
+	interval := min(max(c.contextIdle/2, time.Second), time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.Done():
+			return
+		case now := <-ticker.C:
+			c.state.mu.Lock()
+			if c.state.conn == conn {
+				c.expireContexts(now)
+			}
+			c.state.mu.Unlock()
+		}
+	}
+}
+
+// disposeContext closes the browser context of session with all its tabs.
+// s.mu must be held.
+func (c *Controller) disposeContext(session string) error {
+	s := c.state
+	sc := s.contexts[session]
+	if sc == nil {
+		return fmt.Errorf("%w: %s", ErrContextNotFound, session)
+	}
+	delete(s.contexts, session)
+	delete(s.active, session)
+	if s.conn == nil || s.conn.Err() != nil {
+		return nil
+	}
+	ctx, cancel := c.browser(s.conn)
+	defer cancel()
+	if err := target.DisposeBrowserContext(sc.id).Do(ctx); err != nil {
+		return fmt.Errorf("failed to dispose browser context: %w", err)
+	}
+	return nil
+}
+
+// Contexts lists the browser contexts of sessions, oldest first.
+func (c *Controller) Contexts() []model.BrowserContextInfo {
+	s := c.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]string, 0, len(s.contexts))
+	for session := range s.contexts {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return s.contexts[sessions[i]].created.Before(s.contexts[sessions[j]].created)
+	})
+	infos := make([]model.BrowserContextInfo, 0, len(sessions))
+	for _, session := range sessions {
+		sc := s.contexts[session]
+		infos = append(infos, model.BrowserContextInfo{
+			SessionID:      session,
+			ContextID:      string(sc.id),
+			CreatedAtUnix:  sc.created.Unix(),
+			LastUsedAtUnix: sc.lastUsed.Unix(),
+		})
+	}
+	return infos
+}
+
+// CloseContext ends the browser use of session, closing its tabs and
+// discarding its cookies and storage.
+func (c *Controller) CloseContext(session string) error {
+	s := c.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return c.disposeContext(session)
+}
@@ -0,0 +1,74 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/target"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+)
+
+func forSession(c *Controller, session string) *Controller {
+	return c.ForContext(ctxutil.WithSessionID(context.Background(), session))
+}
+
+func TestController_ContextLimit(t *testing.T) {
+	c := NewController("ws://localhost:0", WithContextLimits(1, 0))
+	c.state.contexts["a"] = &sessionContext{id: "A", created: time.Now(), lastUsed: time.Now()}
+
+	if id, err := forSession(c, "a").browserContext(nil); err != nil || id != "A" {
+		t.Errorf("browserContext() = %q, %v, want the session's context", id, err)
+	}
+	if _, err := forSession(c, "b").browserContext(nil); !errors.Is(err, ErrContextLimit) {
+		t.Errorf("expected ErrContextLimit, got %v", err)
+	}
+	if id, err := c.browserContext(nil); err != nil || id != "" {
+		t.Errorf("expected no context without a session, got %q, %v", id, err)
+	}
+}
+
+func TestController_ExpireContexts(t *testing.T) {
+	c := NewController("ws://localhost:0", WithContextLimits(0, time.Minute))
+	now := time.Now()
+	c.state.contexts["old"] = &sessionContext{id: "OLD", created: now.Add(-time.Hour), lastUsed: now.Add(-2 * time.Minute)}
+	c.state.contexts["new"] = &sessionContext{id: "NEW", created: now, lastUsed: now}
+	c.state.active["old"] = "tab"
+
+	c.expireContexts(now)
+
+	infos := c.Contexts()
+	if len(infos) != 1 || infos[0].SessionID != "new" || infos[0].ContextID != "NEW" {
+		t.Errorf("expected only the recently used context to be kept, got %+v", infos)
+	}
+	if _, ok := c.state.active["old"]; ok {
+		t.Error("expected the expired session to lose its active tab")
+	}
+}
+
+func TestController_CloseContext(t *testing.T) {
+	c := NewController("ws://localhost:0")
+	c.state.contexts["a"] = &sessionContext{id: "A"}
+
+	if err := c.CloseContext("a"); err != nil {
+		t.Fatalf("CloseContext() error = %v", err)
+	}
+	if err := c.CloseContext("a"); !errors.Is(err, ErrContextNotFound) {
+		t.Errorf("expected ErrContextNotFound, got %v", err)
+	}
+}
+
+func TestController_Owns(t *testing.T) {
+	c := NewController("ws://localhost:0")
+	c.state.contexts["a"] = &sessionContext{id: "A"}
+	inA := &target.Info{BrowserContextID: "A"}
+	inDefault := &target.Info{BrowserContextID: "DEFAULT"}
+
+	if !c.owns(inA, "A") || c.owns(inDefault, "A") {
+		t.Error("expected a session to own only the tabs of its context")
+	}
+	if c.owns(inA, "") || !c.owns(inDefault, "") {
+		t.Error("expected requests without a session to own only the tabs outside session contexts")
+	}
+}
@@ -8,16 +8,31 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
 	"github.com/deep-agent/sandbox/types/model"
 )
 
+// Controller drives the browser over CDP. It keeps one connection and the
+// tabs it attached to across calls, so page state carries over from one
+// action to the next. Every session acts on its own active tab, in a browser
+// context of its own.
 type Controller struct {
 	cdpURL  string
 	timeout time.Duration
+	// session is who the controller acts for, see ForContext.
+	session string
+	state   *state
+
+	maxContexts int
+	contextIdle time.Duration
 }
 
+type Option func(*Controller)
+
 type ScreenshotOptions struct {
 	Format  string `json:"format"`
 	Quality int    `json:"quality"`
@@ -31,11 +46,25 @@ type PageInfo struct {
 	Height int    `json:"height"`
 }
 
-func NewController(cdpURL string) *Controller {
-	return &Controller{
+func NewController(cdpURL string, opts ...Option) *Controller {
+	c := &Controller{
 		cdpURL:  cdpURL,
 		timeout: 30 * time.Second,
+		state:   newState(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
+
+// ForContext returns a Controller acting on the active tab of the session in
+// ctx. Sessions are isolated from each other and from requests without a
+// session, which share the browser's default context.
+func (c *Controller) ForContext(ctx context.Context) *Controller {
+	scoped := *c
+	scoped.session = ctxutil.GetSessionIDFromCtx(ctx)
+	return &scoped
 }
 
 func (c *Controller) GetInfo() (*model.BrowserInfo, error) {
@@ -56,22 +85,21 @@ func (c *Controller) GetInfo() (*model.BrowserInfo, error) {
 	}, nil
 }
 
-func (c *Controller) createContext() (context.Context, context.CancelFunc) {
-	allocCtx, _ := chromedp.NewRemoteAllocator(context.Background(), c.cdpURL)
-	ctx, _ := chromedp.NewContext(allocCtx)
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
-	return ctx, cancel
-}
-
 func (c *Controller) Navigate(url string) error {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return err
+	}
 	defer cancel()
 
 	return chromedp.Run(ctx, chromedp.Navigate(url))
 }
 
 func (c *Controller) Screenshot(opts *ScreenshotOptions) (string, error) {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return "", err
+	}
 	defer cancel()
 
 	var buf []byte
@@ -91,7 +119,10 @@ func (c *Controller) Screenshot(opts *ScreenshotOptions) (string, error) {
 }
 
 func (c *Controller) GetCurrentURL() (string, error) {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return "", err
+	}
 	defer cancel()
 
 	var url string
@@ -103,7 +134,10 @@ func (c *Controller) GetCurrentURL() (string, error) {
 }
 
 func (c *Controller) GetTitle() (string, error) {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return "", err
+	}
 	defer cancel()
 
 	var title string
@@ -114,25 +148,58 @@ func (c *Controller) GetTitle() (string, error) {
 	return title, nil
 }
 
-func (c *Controller) Click(selector string) error {
-	ctx, cancel := c.createContext()
+// Click clicks the element el picks.
+func (c *Controller) Click(el Element) error {
+	if err := el.validate(); err != nil {
+		return err
+	}
+	if el.Ref != "" {
+		return c.onElement(el, clickNode)
+	}
+
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return err
+	}
 	defer cancel()
 
-	return chromedp.Run(ctx, chromedp.Click(selector, chromedp.NodeVisible))
+	return chromedp.Run(ctx, chromedp.Click(el.Selector, chromedp.NodeVisible))
 }
 
-func (c *Controller) Type(selector, text string) error {
-	ctx, cancel := c.createContext()
+// Type clicks the element el picks and types text into it.
+func (c *Controller) Type(el Element, text string) error {
+	if err := el.validate(); err != nil {
+		return err
+	}
+	if el.Ref != "" {
+		return c.onElement(el, func(ctx context.Context, id cdp.BackendNodeID) error {
+			if err := clickNode(ctx, id); err != nil {
+				return err
+			}
+			if err := dom.Focus().WithBackendNodeID(id).Do(ctx); err != nil {
+				return err
+			}
+			return chromedp.KeyEvent(text).Do(ctx)
+		})
+	}
+
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return err
+	}
 	defer cancel()
 
 	return chromedp.Run(ctx,
-		chromedp.Click(selector, chromedp.NodeVisible),
-		chromedp.SendKeys(selector, text),
+		chromedp.Click(el.Selector, chromedp.NodeVisible),
+		chromedp.SendKeys(el.Selector, text),
 	)
 }
 
 func (c *Controller) Evaluate(expression string) (interface{}, error) {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return nil, err
+	}
 	defer cancel()
 
 	var result interface{}
@@ -144,7 +211,10 @@ func (c *Controller) Evaluate(expression string) (interface{}, error) {
 }
 
 func (c *Controller) GetHTML(selector string) (string, error) {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return "", err
+	}
 	defer cancel()
 
 	var html string
@@ -156,21 +226,30 @@ func (c *Controller) GetHTML(selector string) (string, error) {
 }
 
 func (c *Controller) WaitVisible(selector string) error {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return err
+	}
 	defer cancel()
 
 	return chromedp.Run(ctx, chromedp.WaitVisible(selector))
 }
 
 func (c *Controller) Scroll(x, y int64) error {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return err
+	}
 	defer cancel()
 
 	return chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf("window.scrollTo(%d, %d)", x, y), nil))
 }
 
 func (c *Controller) GetPageInfo() (*PageInfo, error) {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return nil, err
+	}
 	defer cancel()
 
 	var url, title string
@@ -188,7 +267,10 @@ func (c *Controller) GetPageInfo() (*PageInfo, error) {
 }
 
 func (c *Controller) PDF() (string, error) {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return "", err
+	}
 	defer cancel()
 
 	var buf []byte
@@ -204,7 +286,10 @@ func (c *Controller) PDF() (string, error) {
 }
 
 func (c *Controller) GetCookies() (string, error) {
-	ctx, cancel := c.createContext()
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return "", err
+	}
 	defer cancel()
 
 	var cookies interface{}
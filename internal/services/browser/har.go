@@ -0,0 +1,175 @@
+package browser
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+// NetworkHAR exports the requests of the session's active tab as an HTTP
+// Archive.
+func (c *Controller) NetworkHAR() (*model.HAR, error) {
+	t, _, cancel, err := c.activeTab()
+	if err != nil {
+		return nil, err
+	}
+	cancel()
+	return t.log.har(), nil
+}
+
+// har describes the requests of the tab as an HTTP Archive, oldest first.
+func (l *tabLog) har() *model.HAR {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	doc := &model.HAR{Log: model.HARLog{
+		Version: "1.2",
+		Creator: model.HARCreator{Name: "sandbox", Version: "1.0.0"},
+		Entries: []model.HAREntry{},
+	}}
+	for _, r := range l.requests.all() {
+		doc.Log.Entries = append(doc.Log.Entries, r.harEntry())
+	}
+	return doc
+}
+
+// harEntry describes r. l.mu must be held.
+func (r *request) harEntry() model.HAREntry {
+	e := model.HAREntry{
+		StartedDateTime: r.started.UTC().Format(time.RFC3339Nano),
+		Request: model.HARRequest{
+			Method:      r.method,
+			URL:         r.url,
+			Cookies:     []model.HARNameValue{},
+			Headers:     harHeaders(r.headers),
+			QueryString: harQuery(r.url),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: model.HARResponse{
+			Cookies:     []model.HARNameValue{},
+			Headers:     []model.HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		ResourceType: r.resourceType,
+		Error:        r.err,
+	}
+
+	res := r.response
+	if res != nil {
+		e.Request.HTTPVersion = res.Protocol
+		if len(res.RequestHeaders) > 0 {
+			e.Request.Headers = harHeaders(res.RequestHeaders)
+		}
+		e.Response.Status = res.Status
+		e.Response.StatusText = res.StatusText
+		e.Response.HTTPVersion = res.Protocol
+		e.Response.Headers = harHeaders(res.Headers)
+		e.Response.Content.MimeType = res.MimeType
+		e.Response.RedirectURL = header(res.Headers, "Location")
+		e.ServerIPAddress = res.RemoteIPAddress
+	}
+	if r.done {
+		e.Response.BodySize = r.size
+		e.Response.Content.Size = r.decoded
+	}
+	e.Timings = r.harTimings()
+	for _, t := range []float64{e.Timings.Blocked, e.Timings.DNS, e.Timings.Connect, e.Timings.Send, e.Timings.Wait, e.Timings.Receive} {
+		if t > 0 {
+			e.Time += t
+		}
+	}
+	return e
+}
+
+// harTimings splits the time r took into the phases of HAR, as far as the
+// browser reported them.
+func (r *request) harTimings() model.HARTimings {
+	t := model.HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	total := 0.0
+	if r.done {
+		total = (r.endedAt - r.startedAt) * 1000
+	}
+	var rt *network.ResourceTiming
+	if r.response != nil {
+		rt = r.response.Timing
+	}
+	if rt == nil {
+		t.Wait = max(total, 0)
+		return t
+	}
+
+	// The phases of the resource timing are in milliseconds from its
+	// request time, which may be later than the request was made.
+	offset := (rt.RequestTime - r.startedAt) * 1000
+	phase := func(start, end float64) float64 {
+		if start < 0 || end < 0 {
+			return -1
+		}
+		return end - start
+	}
+	t.DNS = phase(rt.DNSStart, rt.DNSEnd)
+	t.Connect = phase(rt.ConnectStart, rt.ConnectEnd)
+	t.SSL = phase(rt.SslStart, rt.SslEnd)
+	t.Send = max(rt.SendEnd-rt.SendStart, 0)
+	t.Wait = max(rt.ReceiveHeadersEnd-rt.SendEnd, 0)
+	t.Blocked = max(offset, 0)
+	for _, start := range []float64{rt.DNSStart, rt.ConnectStart, rt.SendStart} {
+		if start >= 0 {
+			t.Blocked += start
+			break
+		}
+	}
+	if r.done {
+		t.Receive = max(total-offset-rt.ReceiveHeadersEnd, 0)
+	}
+	return t
+}
+
+// harHeaders lists headers by name. Headers sent more than once come as
+// one value per line.
+func harHeaders(headers network.Headers) []model.HARNameValue {
+	list := []model.HARNameValue{}
+	for name, value := range headers {
+		for _, v := range strings.Split(fmt.Sprint(value), "\n") {
+			list = append(list, model.HARNameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func header(headers network.Headers, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func harQuery(rawURL string) []model.HARNameValue {
+	list := []model.HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return list
+	}
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range query[name] {
+			list = append(list, model.HARNameValue{Name: name, Value: v})
+		}
+	}
+	return list
+}
@@ -0,0 +1,61 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestTabLog_HAR(t *testing.T) {
+	l := newTabLog()
+	sent(l, "1", "http://app/search?q=go&page=2", 10)
+	l.listen(&network.EventResponseReceived{RequestID: "1", Response: &network.Response{
+		Status:          200,
+		StatusText:      "OK",
+		Protocol:        "http/1.1",
+		MimeType:        "application/json",
+		Headers:         network.Headers{"Set-Cookie": "a=1\nb=2", "Content-Type": "application/json"},
+		RemoteIPAddress: "127.0.0.1",
+		// Sent 5ms after the request was made, headers back 20ms later.
+		Timing: &network.ResourceTiming{RequestTime: 10.005, DNSStart: -1, DNSEnd: -1, ConnectStart: -1, ConnectEnd: -1, SslStart: -1, SslEnd: -1, SendStart: 1, SendEnd: 2, ReceiveHeadersEnd: 22},
+	}})
+	l.listen(&network.EventDataReceived{RequestID: "1", DataLength: 300})
+	finished(l, "1", 10.05)
+	sent(l, "2", "http://app/down", 11)
+	l.listen(&network.EventLoadingFailed{RequestID: "2", Timestamp: monotonicAt(11.01), Canceled: true})
+
+	har := l.har()
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatalf("unexpected HAR %+v", har.Log)
+	}
+
+	e := har.Log.Entries[0]
+	if e.Request.Method != "GET" || e.Response.Status != 200 || e.Response.HTTPVersion != "http/1.1" || e.ServerIPAddress != "127.0.0.1" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if q := e.Request.QueryString; len(q) != 2 || q[0].Name != "page" || q[1].Value != "go" {
+		t.Errorf("unexpected query string %+v", q)
+	}
+	if h := e.Response.Headers; len(h) != 3 || h[1].Name != "Set-Cookie" || h[2].Value != "b=2" {
+		t.Errorf("expected repeated headers to be split, got %+v", h)
+	}
+	if e.Response.Content.Size != 300 || e.Response.BodySize != 10 {
+		t.Errorf("unexpected sizes %+v, body %d", e.Response.Content, e.Response.BodySize)
+	}
+	tm := e.Timings
+	if !near(tm.Blocked, 6) || !near(tm.Send, 1) || !near(tm.Wait, 20) || !near(tm.Receive, 23) || tm.DNS != -1 || tm.Connect != -1 {
+		t.Errorf("unexpected timings %+v", tm)
+	}
+	if !near(e.Time, 50) {
+		t.Errorf("time = %v, want 50", e.Time)
+	}
+
+	failed := har.Log.Entries[1]
+	if failed.Error != "canceled" || failed.Response.Status != 0 || !near(failed.Timings.Wait, 10) {
+		t.Errorf("unexpected failed entry %+v", failed)
+	}
+}
+
+func near(got, want float64) bool {
+	return got > want-0.01 && got < want+0.01
+}
@@ -0,0 +1,304 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+)
+
+var ErrInvalidInput = errors.New("invalid input")
+
+const defaultDragSteps = 10
+
+// MouseOptions tunes a click. The zero value is a single left click.
+type MouseOptions struct {
+	// Button is left, right or middle.
+	Button string
+	// Count is the number of clicks, 2 for a double-click.
+	Count int
+	// Modifiers are the keys held during the click: Alt, Control, Meta or
+	// Shift.
+	Modifiers []string
+}
+
+var mouseButtons = map[string]input.MouseButton{
+	"":       input.Left,
+	"left":   input.Left,
+	"right":  input.Right,
+	"middle": input.Middle,
+}
+
+// buttonMasks are the masks of buttons held during mouse moves.
+var buttonMasks = map[input.MouseButton]int64{
+	input.Left:   1,
+	input.Right:  2,
+	input.Middle: 4,
+}
+
+// modifierKeys are the modifiers by name, with the key that holds each.
+var modifierKeys = map[string]struct {
+	modifier input.Modifier
+	key      string
+}{
+	"alt":     {input.ModifierAlt, kb.Alt},
+	"option":  {input.ModifierAlt, kb.Alt},
+	"control": {input.ModifierCtrl, kb.Control},
+	"ctrl":    {input.ModifierCtrl, kb.Control},
+	"meta":    {input.ModifierMeta, kb.Meta},
+	"cmd":     {input.ModifierMeta, kb.Meta},
+	"command": {input.ModifierMeta, kb.Meta},
+	"shift":   {input.ModifierShift, kb.Shift},
+}
+
+// keyAliases name keys that kb knows by another name.
+var keyAliases = map[string]rune{
+	"space":  ' ',
+	"esc":    '\u001b',
+	"return": '\r',
+	"del":    '\u007f',
+	"up":     []rune(kb.ArrowUp)[0],
+	"down":   []rune(kb.ArrowDown)[0],
+	"left":   []rune(kb.ArrowLeft)[0],
+	"right":  []rune(kb.ArrowRight)[0],
+}
+
+// namedKeys are the keys of kb by their lower-cased DOM key name, such as
+// enter or arrowdown.
+var namedKeys = func() map[string]rune {
+	keys := map[string]rune{}
+	for r, k := range kb.Keys {
+		if utf8.RuneCountInString(k.Key) > 1 {
+			if prev, ok := keys[strings.ToLower(k.Key)]; !ok || r < prev {
+				keys[strings.ToLower(k.Key)] = r
+			}
+		}
+	}
+	for name, r := range keyAliases {
+		keys[name] = r
+	}
+	return keys
+}()
+
+// keyCombo is a key pressed while holding modifiers.
+type keyCombo struct {
+	modifiers []string
+	mask      input.Modifier
+	key       rune
+}
+
+// parseKeys parses a key combination such as Enter, a, Control+a or
+// Shift+Tab. Names of modifiers and special keys are case-insensitive.
+func parseKeys(keys string) (*keyCombo, error) {
+	parts := strings.Split(keys, "+")
+	// A trailing + is the key itself, as in Control++.
+	if len(parts) > 1 && parts[len(parts)-1] == "" && parts[len(parts)-2] == "" {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	combo := &keyCombo{}
+	for _, name := range parts[:len(parts)-1] {
+		m, ok := modifierKeys[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown modifier %q", ErrInvalidInput, name)
+		}
+		if combo.mask&m.modifier == 0 {
+			combo.modifiers = append(combo.modifiers, m.key)
+			combo.mask |= m.modifier
+		}
+	}
+
+	name := parts[len(parts)-1]
+	if name != " " {
+		name = strings.TrimSpace(name)
+	}
+	switch {
+	case utf8.RuneCountInString(name) == 1:
+		combo.key, _ = utf8.DecodeRuneInString(name)
+		if combo.mask&input.ModifierShift != 0 {
+			combo.key = unicode.ToUpper(combo.key)
+		}
+	case namedKeys[strings.ToLower(name)] != 0:
+		combo.key = namedKeys[strings.ToLower(name)]
+	default:
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidInput, name)
+	}
+	return combo, nil
+}
+
+// parseModifiers returns the mask of the modifiers named.
+func parseModifiers(names []string) (input.Modifier, error) {
+	var mask input.Modifier
+	for _, name := range names {
+		m, ok := modifierKeys[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("%w: unknown modifier %q", ErrInvalidInput, name)
+		}
+		mask |= m.modifier
+	}
+	return mask, nil
+}
+
+// press presses the key combination: the modifiers go down in order, the key
+// is pressed, and the modifiers come up in reverse.
+func (k *keyCombo) press(ctx context.Context) error {
+	var held input.Modifier
+	for _, mod := range k.modifiers {
+		for _, ev := range kb.Encode([]rune(mod)[0]) {
+			if ev.Type == input.KeyDown {
+				held |= modifierMask(mod)
+				ev.Modifiers = held
+				if err := ev.Do(ctx); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Shortcuts type nothing, so only Shift lets the key produce text.
+	typing := k.mask&^input.ModifierShift == 0
+	for _, ev := range kb.Encode(k.key) {
+		if ev.Type == input.KeyChar && !typing {
+			continue
+		}
+		ev.Modifiers |= k.mask
+		if err := ev.Do(ctx); err != nil {
+			return err
+		}
+	}
+
+	for i := len(k.modifiers) - 1; i >= 0; i-- {
+		mod := k.modifiers[i]
+		held &^= modifierMask(mod)
+		for _, ev := range kb.Encode([]rune(mod)[0]) {
+			if ev.Type == input.KeyUp {
+				ev.Modifiers = held
+				if err := ev.Do(ctx); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func modifierMask(key string) input.Modifier {
+	for _, m := range modifierKeys {
+		if m.key == key {
+			return m.modifier
+		}
+	}
+	return 0
+}
+
+// click presses and releases button at x, y count times, as a user
+// double-clicking does for a count of 2.
+func click(ctx context.Context, x, y float64, button input.MouseButton, count int64, modifiers input.Modifier) error {
+	if err := input.DispatchMouseEvent(input.MouseMoved, x, y).WithModifiers(modifiers).Do(ctx); err != nil {
+		return err
+	}
+	for i := int64(1); i <= count; i++ {
+		if err := input.DispatchMouseEvent(input.MousePressed, x, y).
+			WithButton(button).WithButtons(buttonMasks[button]).WithClickCount(i).WithModifiers(modifiers).Do(ctx); err != nil {
+			return err
+		}
+		if err := input.DispatchMouseEvent(input.MouseReleased, x, y).
+			WithButton(button).WithClickCount(i).WithModifiers(modifiers).Do(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onPage runs fn on the session's active tab.
+func (c *Controller) onPage(fn func(ctx context.Context) error) error {
+	ctx, cancel, err := c.page()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return chromedp.Run(ctx, chromedp.ActionFunc(fn))
+}
+
+// MouseMove moves the mouse to x, y in the viewport.
+func (c *Controller) MouseMove(x, y float64) error {
+	return c.onPage(func(ctx context.Context) error {
+		return input.DispatchMouseEvent(input.MouseMoved, x, y).Do(ctx)
+	})
+}
+
+// MouseClick clicks at x, y in the viewport.
+func (c *Controller) MouseClick(x, y float64, opts MouseOptions) error {
+	button, ok := mouseButtons[strings.ToLower(opts.Button)]
+	if !ok {
+		return fmt.Errorf("%w: button must be left, right or middle", ErrInvalidInput)
+	}
+	modifiers, err := parseModifiers(opts.Modifiers)
+	if err != nil {
+		return err
+	}
+	count := int64(max(opts.Count, 1))
+	return c.onPage(func(ctx context.Context) error {
+		return click(ctx, x, y, button, count, modifiers)
+	})
+}
+
+// MouseDrag presses the left button at fromX, fromY, moves to toX, toY in
+// steps and releases it there.
+func (c *Controller) MouseDrag(fromX, fromY, toX, toY float64, steps int) error {
+	if steps <= 0 {
+		steps = defaultDragSteps
+	}
+	return c.onPage(func(ctx context.Context) error {
+		if err := input.DispatchMouseEvent(input.MouseMoved, fromX, fromY).Do(ctx); err != nil {
+			return err
+		}
+		if err := input.DispatchMouseEvent(input.MousePressed, fromX, fromY).
+			WithButton(input.Left).WithButtons(1).WithClickCount(1).Do(ctx); err != nil {
+			return err
+		}
+		for i := 1; i <= steps; i++ {
+			x := fromX + (toX-fromX)*float64(i)/float64(steps)
+			y := fromY + (toY-fromY)*float64(i)/float64(steps)
+			if err := input.DispatchMouseEvent(input.MouseMoved, x, y).
+				WithButton(input.Left).WithButtons(1).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return input.DispatchMouseEvent(input.MouseReleased, toX, toY).
+			WithButton(input.Left).WithClickCount(1).Do(ctx)
+	})
+}
+
+// MouseWheel turns the mouse wheel over x, y, scrolling whatever is under it
+// by deltaX, deltaY pixels.
+func (c *Controller) MouseWheel(x, y, deltaX, deltaY float64) error {
+	return c.onPage(func(ctx context.Context) error {
+		return input.DispatchMouseEvent(input.MouseWheel, x, y).
+			WithDeltaX(deltaX).WithDeltaY(deltaY).Do(ctx)
+	})
+}
+
+// PressKey presses a key combination such as Enter, Tab, Escape, ArrowDown,
+// Control+a or Shift+Tab on the focused element.
+func (c *Controller) PressKey(keys string) error {
+	combo, err := parseKeys(keys)
+	if err != nil {
+		return err
+	}
+	return c.onPage(combo.press)
+}
+
+// InsertText inserts text at the cursor of the focused element, as pasting
+// would, without key events.
+func (c *Controller) InsertText(text string) error {
+	return c.onPage(func(ctx context.Context) error {
+		return input.InsertText(text).Do(ctx)
+	})
+}
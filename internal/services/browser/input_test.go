@@ -0,0 +1,70 @@
+package browser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/chromedp/kb"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		keys      string
+		key       rune
+		modifiers []string
+		mask      input.Modifier
+	}{
+		{keys: "a", key: 'a'},
+		{keys: "Enter", key: '\r'},
+		{keys: "enter", key: '\r'},
+		{keys: "Return", key: '\r'},
+		{keys: "Tab", key: '\t'},
+		{keys: "Escape", key: '\u001b'},
+		{keys: "Esc", key: '\u001b'},
+		{keys: "Space", key: ' '},
+		{keys: " ", key: ' '},
+		{keys: "ArrowDown", key: []rune(kb.ArrowDown)[0]},
+		{keys: "Backspace", key: []rune(kb.Backspace)[0]},
+		{keys: "+", key: '+'},
+		{keys: "Control+a", key: 'a', modifiers: []string{kb.Control}, mask: input.ModifierCtrl},
+		{keys: "ctrl+A", key: 'A', modifiers: []string{kb.Control}, mask: input.ModifierCtrl},
+		{keys: "Shift+a", key: 'A', modifiers: []string{kb.Shift}, mask: input.ModifierShift},
+		{keys: "Shift+Tab", key: '\t', modifiers: []string{kb.Shift}, mask: input.ModifierShift},
+		{keys: "Control+Shift+k", key: 'K', modifiers: []string{kb.Control, kb.Shift}, mask: input.ModifierCtrl | input.ModifierShift},
+		{keys: "Cmd+Meta+c", key: 'c', modifiers: []string{kb.Meta}, mask: input.ModifierMeta},
+		{keys: "Control++", key: '+', modifiers: []string{kb.Control}, mask: input.ModifierCtrl},
+	}
+	for _, tt := range tests {
+		combo, err := parseKeys(tt.keys)
+		if err != nil {
+			t.Errorf("parseKeys(%q): %v", tt.keys, err)
+			continue
+		}
+		if combo.key != tt.key || combo.mask != tt.mask || !reflect.DeepEqual(combo.modifiers, tt.modifiers) {
+			t.Errorf("parseKeys(%q) = %q %q %d, want %q %q %d",
+				tt.keys, combo.key, combo.modifiers, combo.mask, tt.key, tt.modifiers, tt.mask)
+		}
+	}
+
+	for _, keys := range []string{"", "Enterr", "Hyper+a", "Control+", "ab"} {
+		if _, err := parseKeys(keys); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("parseKeys(%q) error = %v, want ErrInvalidInput", keys, err)
+		}
+	}
+}
+
+func TestParseModifiers(t *testing.T) {
+	mask, err := parseModifiers([]string{"Shift", "ctrl", "Control"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mask != input.ModifierShift|input.ModifierCtrl {
+		t.Errorf("mask = %d, want %d", mask, input.ModifierShift|input.ModifierCtrl)
+	}
+
+	if _, err := parseModifiers([]string{"Super"}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("error = %v, want ErrInvalidInput", err)
+	}
+}
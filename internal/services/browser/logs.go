@@ -0,0 +1,399 @@
+package browser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	cdplog "github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+const (
+	maxConsoleMessages = 1000
+	maxNetworkRequests = 1000
+)
+
+// levels ranks the levels of console messages.
+var levels = map[string]int{"debug": 0, "info": 1, "warning": 2, "error": 3}
+
+// ConsoleFilter picks console messages. Level is the lowest level returned,
+// and Limit keeps only the latest messages when positive.
+type ConsoleFilter struct {
+	Level string
+	Limit int
+}
+
+// NetworkFilter picks network requests. URL is a regular expression; Status
+// is a code such as 404, a class such as 4xx, or failed for requests that got
+// no response. Limit keeps only the latest requests when positive.
+type NetworkFilter struct {
+	URL    string
+	Status string
+	Limit  int
+}
+
+// ring keeps the last items added to it.
+type ring[T any] struct {
+	items []T
+	next  int
+	added int
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{items: make([]T, 0, size)}
+}
+
+// add adds v, returning the item it pushed out if the ring was full.
+func (r *ring[T]) add(v T) (old T, full bool) {
+	r.added++
+	if len(r.items) < cap(r.items) {
+		r.items = append(r.items, v)
+		return old, false
+	}
+	old = r.items[r.next]
+	r.items[r.next] = v
+	r.next = (r.next + 1) % len(r.items)
+	return old, true
+}
+
+// all returns the items, oldest first.
+func (r *ring[T]) all() []T {
+	return append(append([]T(nil), r.items[r.next:]...), r.items[:r.next]...)
+}
+
+func (r *ring[T]) dropped() int {
+	return r.added - len(r.items)
+}
+
+// tabLog records what a tab logs to its console and the requests it makes,
+// from when the controller attaches to it.
+type tabLog struct {
+	mu       sync.Mutex
+	console  *ring[model.BrowserConsoleMessage]
+	requests *ring[*request]
+	// pending are the requests not yet finished by ID.
+	pending map[network.RequestID]*request
+}
+
+// request is a network request of a tab, with what HAR export needs.
+type request struct {
+	id           network.RequestID
+	method       string
+	url          string
+	resourceType string
+	headers      network.Headers
+	started      time.Time
+	// startedAt and endedAt are on the browser's monotonic clock.
+	startedAt float64
+	endedAt   float64
+	response  *network.Response
+	size      int64
+	decoded   int64
+	err       string
+	done      bool
+}
+
+func newTabLog() *tabLog {
+	return &tabLog{
+		console:  newRing[model.BrowserConsoleMessage](maxConsoleMessages),
+		requests: newRing[*request](maxNetworkRequests),
+		pending:  map[network.RequestID]*request{},
+	}
+}
+
+// listen records the events of the tab. It runs on the goroutine reading
+// the tab's events, so it must not wait on the browser.
+func (l *tabLog) listen(ev interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *runtime.EventConsoleAPICalled:
+		msg := model.BrowserConsoleMessage{
+			Level:      consoleLevel(ev.Type),
+			Source:     "console",
+			Text:       consoleText(ev.Args),
+			TimeUnixMs: timestampMs(ev.Timestamp),
+		}
+		if ev.StackTrace != nil && len(ev.StackTrace.CallFrames) > 0 {
+			frame := ev.StackTrace.CallFrames[0]
+			msg.URL, msg.Line = frame.URL, frame.LineNumber+1
+		}
+		l.console.add(msg)
+	case *runtime.EventExceptionThrown:
+		d := ev.ExceptionDetails
+		text := d.Text
+		if d.Exception != nil && d.Exception.Description != "" {
+			text = d.Exception.Description
+		}
+		l.console.add(model.BrowserConsoleMessage{
+			Level:      "error",
+			Source:     "exception",
+			Text:       text,
+			URL:        d.URL,
+			Line:       d.LineNumber + 1,
+			TimeUnixMs: timestampMs(ev.Timestamp),
+		})
+	case *cdplog.EventEntryAdded:
+		e := ev.Entry
+		msg := model.BrowserConsoleMessage{
+			Level:  string(e.Level),
+			Source: string(e.Source),
+			Text:   e.Text,
+			URL:    e.URL,
+		}
+		if e.Level == cdplog.LevelVerbose {
+			msg.Level = "debug"
+		}
+		if e.LineNumber != 0 {
+			msg.Line = e.LineNumber + 1
+		}
+		if e.Timestamp != nil {
+			msg.TimeUnixMs = e.Timestamp.Time().UnixMilli()
+		}
+		l.console.add(msg)
+
+	case *network.EventRequestWillBeSent:
+		// A redirect reuses the ID of the request it ends.
+		if r := l.pending[ev.RequestID]; r != nil && ev.RedirectResponse != nil {
+			r.response = ev.RedirectResponse
+			l.finish(r, ev.Timestamp)
+		}
+		r := &request{
+			id:           ev.RequestID,
+			method:       ev.Request.Method,
+			url:          ev.Request.URL + ev.Request.URLFragment,
+			resourceType: string(ev.Type),
+			headers:      ev.Request.Headers,
+			started:      time.Now(),
+			startedAt:    monotonic(ev.Timestamp),
+		}
+		if ev.WallTime != nil {
+			r.started = ev.WallTime.Time()
+		}
+		if old, full := l.requests.add(r); full && l.pending[old.id] == old {
+			delete(l.pending, old.id)
+		}
+		l.pending[ev.RequestID] = r
+	case *network.EventResponseReceived:
+		if r := l.pending[ev.RequestID]; r != nil {
+			r.response = ev.Response
+		}
+	case *network.EventDataReceived:
+		if r := l.pending[ev.RequestID]; r != nil {
+			r.decoded += ev.DataLength
+		}
+	case *network.EventLoadingFinished:
+		if r := l.pending[ev.RequestID]; r != nil {
+			r.size = int64(ev.EncodedDataLength)
+			l.finish(r, ev.Timestamp)
+		}
+	case *network.EventLoadingFailed:
+		if r := l.pending[ev.RequestID]; r != nil {
+			r.err = ev.ErrorText
+			if ev.Canceled && r.err == "" {
+				r.err = "canceled"
+			}
+			l.finish(r, ev.Timestamp)
+		}
+	}
+}
+
+func (l *tabLog) finish(r *request, at *cdp.MonotonicTime) {
+	r.endedAt = monotonic(at)
+	r.done = true
+	delete(l.pending, r.id)
+}
+
+// consoleLevel maps the type of a console call to a message level.
+func consoleLevel(t runtime.APIType) string {
+	switch t {
+	case runtime.APITypeError, runtime.APITypeAssert:
+		return "error"
+	case runtime.APITypeWarning:
+		return "warning"
+	case runtime.APITypeDebug, runtime.APITypeTrace:
+		return "debug"
+	default:
+		return "info"
+	}
+}
+
+// consoleText renders the arguments of a console call as the console would.
+func consoleText(args []*runtime.RemoteObject) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch {
+		case arg.Type == runtime.TypeString:
+			var s string
+			if err := json.Unmarshal(arg.Value, &s); err == nil {
+				parts = append(parts, s)
+				continue
+			}
+		case arg.Subtype == runtime.SubtypeNull:
+			parts = append(parts, "null")
+			continue
+		case arg.UnserializableValue != "":
+			parts = append(parts, string(arg.UnserializableValue))
+			continue
+		case len(arg.Value) > 0 && arg.Type != runtime.TypeObject:
+			parts = append(parts, string(arg.Value))
+			continue
+		}
+		if arg.Description != "" {
+			parts = append(parts, arg.Description)
+		} else {
+			parts = append(parts, string(arg.Type))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func timestampMs(t *runtime.Timestamp) int64 {
+	if t == nil {
+		return time.Now().UnixMilli()
+	}
+	return t.Time().UnixMilli()
+}
+
+// monotonic returns a time of the browser's monotonic clock in seconds, the
+// unit of resource timings.
+func monotonic(t *cdp.MonotonicTime) float64 {
+	if t == nil {
+		return 0
+	}
+	return t.Time().Sub(*cdp.MonotonicTimeEpoch).Seconds()
+}
+
+// ConsoleMessages returns what the session's active tab logged to its
+// console, including uncaught exceptions and browser warnings, oldest first.
+func (c *Controller) ConsoleMessages(filter ConsoleFilter) (*model.BrowserConsoleResult, error) {
+	t, _, cancel, err := c.activeTab()
+	if err != nil {
+		return nil, err
+	}
+	cancel()
+	return t.log.consoleMessages(filter)
+}
+
+// NetworkRequests returns the requests the session's active tab made,
+// oldest first.
+func (c *Controller) NetworkRequests(filter NetworkFilter) (*model.BrowserNetworkResult, error) {
+	t, _, cancel, err := c.activeTab()
+	if err != nil {
+		return nil, err
+	}
+	cancel()
+	return t.log.networkRequests(filter)
+}
+
+func (l *tabLog) consoleMessages(filter ConsoleFilter) (*model.BrowserConsoleResult, error) {
+	lowest := 0
+	if filter.Level != "" {
+		rank, ok := levels[filter.Level]
+		if !ok {
+			return nil, fmt.Errorf("%w: level must be debug, info, warning or error", ErrInvalidFilter)
+		}
+		lowest = rank
+	}
+
+	l.mu.Lock()
+	all, dropped := l.console.all(), l.console.dropped()
+	l.mu.Unlock()
+
+	messages := []model.BrowserConsoleMessage{}
+	for _, msg := range all {
+		if levels[msg.Level] >= lowest {
+			messages = append(messages, msg)
+		}
+	}
+	return &model.BrowserConsoleResult{Messages: latest(messages, filter.Limit), Dropped: dropped}, nil
+}
+
+func (l *tabLog) networkRequests(filter NetworkFilter) (*model.BrowserNetworkResult, error) {
+	match, err := filter.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := []model.BrowserNetworkEntry{}
+	for _, r := range l.requests.all() {
+		if e := r.entry(); match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return &model.BrowserNetworkResult{Requests: latest(entries, filter.Limit), Dropped: l.requests.dropped()}, nil
+}
+
+// matcher compiles the filter into a function reporting whether it picks a
+// request.
+func (f NetworkFilter) matcher() (func(model.BrowserNetworkEntry) bool, error) {
+	var url *regexp.Regexp
+	if f.URL != "" {
+		var err error
+		if url, err = regexp.Compile(f.URL); err != nil {
+			return nil, fmt.Errorf("%w: url: %v", ErrInvalidFilter, err)
+		}
+	}
+	status := func(model.BrowserNetworkEntry) bool { return true }
+	switch s := strings.ToLower(f.Status); {
+	case s == "":
+	case s == "failed":
+		status = func(e model.BrowserNetworkEntry) bool { return e.Error != "" }
+	case len(s) == 3 && s[0] >= '1' && s[0] <= '5' && s[1:] == "xx":
+		class := int64(s[0] - '0')
+		status = func(e model.BrowserNetworkEntry) bool { return e.Status/100 == class }
+	default:
+		code, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: status must be a code, a class such as 4xx, or failed", ErrInvalidFilter)
+		}
+		status = func(e model.BrowserNetworkEntry) bool { return e.Status == code }
+	}
+	return func(e model.BrowserNetworkEntry) bool {
+		return (url == nil || url.MatchString(e.URL)) && status(e)
+	}, nil
+}
+
+func latest[T any](items []T, limit int) []T {
+	if limit > 0 && len(items) > limit {
+		return items[len(items)-limit:]
+	}
+	return items
+}
+
+// entry describes r. l.mu must be held.
+func (r *request) entry() model.BrowserNetworkEntry {
+	e := model.BrowserNetworkEntry{
+		ID:              string(r.id),
+		Method:          r.method,
+		URL:             r.url,
+		ResourceType:    r.resourceType,
+		Size:            r.size,
+		Error:           r.err,
+		Pending:         !r.done,
+		StartedAtUnixMs: r.started.UnixMilli(),
+	}
+	if r.response != nil {
+		e.Status = r.response.Status
+		e.StatusText = r.response.StatusText
+		e.MimeType = r.response.MimeType
+	}
+	if r.done {
+		e.DurationMs = int64((r.endedAt - r.startedAt) * 1000)
+	}
+	return e
+}
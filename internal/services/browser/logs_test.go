@@ -0,0 +1,169 @@
+package browser
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	cdplog "github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+)
+
+func TestRing(t *testing.T) {
+	r := newRing[int](3)
+	for i := 1; i <= 4; i++ {
+		old, full := r.add(i)
+		if full != (i == 4) || (full && old != 1) {
+			t.Errorf("add(%d) = %d, %v", i, old, full)
+		}
+	}
+	if got := r.all(); !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Errorf("all() = %v, want [2 3 4]", got)
+	}
+	if r.dropped() != 1 {
+		t.Errorf("dropped() = %d, want 1", r.dropped())
+	}
+}
+
+func monotonicAt(seconds float64) *cdp.MonotonicTime {
+	t := cdp.MonotonicTime(cdp.MonotonicTimeEpoch.Add(time.Duration(seconds * float64(time.Second))))
+	return &t
+}
+
+func sent(l *tabLog, id, url string, at float64) {
+	l.listen(&network.EventRequestWillBeSent{
+		RequestID: network.RequestID(id),
+		Request:   &network.Request{Method: "GET", URL: url},
+		Timestamp: monotonicAt(at),
+		Type:      network.ResourceTypeFetch,
+	})
+}
+
+func received(l *tabLog, id string, status int64) {
+	l.listen(&network.EventResponseReceived{
+		RequestID: network.RequestID(id),
+		Response:  &network.Response{Status: status, Headers: network.Headers{"Location": "/next"}},
+	})
+}
+
+func finished(l *tabLog, id string, at float64) {
+	l.listen(&network.EventLoadingFinished{RequestID: network.RequestID(id), Timestamp: monotonicAt(at), EncodedDataLength: 10})
+}
+
+func TestTabLog_Console(t *testing.T) {
+	l := newTabLog()
+	l.listen(&runtime.EventConsoleAPICalled{
+		Type: runtime.APITypeLog,
+		Args: []*runtime.RemoteObject{
+			{Type: runtime.TypeString, Value: []byte(`"count"`)},
+			{Type: runtime.TypeNumber, Value: []byte(`3`)},
+			{Type: runtime.TypeObject, Subtype: runtime.SubtypeNull, Value: []byte(`null`)},
+			{Type: runtime.TypeObject, Description: "Object"},
+		},
+		StackTrace: &runtime.StackTrace{CallFrames: []*runtime.CallFrame{{URL: "http://app/main.js", LineNumber: 9}}},
+	})
+	l.listen(&runtime.EventConsoleAPICalled{Type: runtime.APITypeWarning, Args: []*runtime.RemoteObject{{Type: runtime.TypeString, Value: []byte(`"slow"`)}}})
+	l.listen(&runtime.EventExceptionThrown{ExceptionDetails: &runtime.ExceptionDetails{
+		Text:      "Uncaught",
+		Exception: &runtime.RemoteObject{Description: "Error: boom"},
+	}})
+	l.listen(&cdplog.EventEntryAdded{Entry: &cdplog.Entry{Source: cdplog.SourceNetwork, Level: cdplog.LevelVerbose, Text: "preload"}})
+
+	all, err := l.consoleMessages(ConsoleFilter{})
+	if err != nil {
+		t.Fatalf("consoleMessages() error = %v", err)
+	}
+	if len(all.Messages) != 4 {
+		t.Fatalf("expected 4 messages, got %+v", all.Messages)
+	}
+	first := all.Messages[0]
+	if first.Level != "info" || first.Text != "count 3 null Object" || first.URL != "http://app/main.js" || first.Line != 10 {
+		t.Errorf("unexpected message %+v", first)
+	}
+	if last := all.Messages[3]; last.Level != "debug" || last.Source != "network" {
+		t.Errorf("expected verbose browser entries at debug level, got %+v", last)
+	}
+
+	warnings, _ := l.consoleMessages(ConsoleFilter{Level: "warning"})
+	if len(warnings.Messages) != 2 || warnings.Messages[1].Text != "Error: boom" || warnings.Messages[1].Source != "exception" {
+		t.Errorf("expected the warning and the exception, got %+v", warnings.Messages)
+	}
+	latest, _ := l.consoleMessages(ConsoleFilter{Limit: 1})
+	if len(latest.Messages) != 1 || latest.Messages[0].Source != "network" {
+		t.Errorf("expected only the latest message, got %+v", latest.Messages)
+	}
+	if _, err := l.consoleMessages(ConsoleFilter{Level: "fatal"}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter, got %v", err)
+	}
+}
+
+func TestTabLog_Network(t *testing.T) {
+	l := newTabLog()
+	sent(l, "1", "http://app/old", 1)
+	received(l, "1", 302)
+	sent(l, "1", "http://app/new", 1.1) // redirect
+	received(l, "1", 200)
+	finished(l, "1", 1.25)
+	sent(l, "2", "http://app/api/users", 2)
+	received(l, "2", 404)
+	finished(l, "2", 2.5)
+	sent(l, "3", "http://app/api/items", 3)
+	l.listen(&network.EventLoadingFailed{RequestID: "3", Timestamp: monotonicAt(3.1), ErrorText: "net::ERR_CONNECTION_REFUSED"})
+	sent(l, "4", "http://app/stream", 4)
+
+	tests := []struct {
+		filter NetworkFilter
+		want   []string
+	}{
+		{NetworkFilter{}, []string{"http://app/old", "http://app/new", "http://app/api/users", "http://app/api/items", "http://app/stream"}},
+		{NetworkFilter{Status: "3xx"}, []string{"http://app/old"}},
+		{NetworkFilter{Status: "404"}, []string{"http://app/api/users"}},
+		{NetworkFilter{Status: "failed"}, []string{"http://app/api/items"}},
+		{NetworkFilter{URL: "/api/"}, []string{"http://app/api/users", "http://app/api/items"}},
+		{NetworkFilter{URL: "/api/", Limit: 1}, []string{"http://app/api/items"}},
+	}
+	for _, tt := range tests {
+		result, err := l.networkRequests(tt.filter)
+		if err != nil {
+			t.Fatalf("networkRequests(%+v) error = %v", tt.filter, err)
+		}
+		var urls []string
+		for _, e := range result.Requests {
+			urls = append(urls, e.URL)
+		}
+		if !reflect.DeepEqual(urls, tt.want) {
+			t.Errorf("networkRequests(%+v) = %v, want %v", tt.filter, urls, tt.want)
+		}
+	}
+
+	result, _ := l.networkRequests(NetworkFilter{})
+	if users := result.Requests[2]; users.DurationMs != 500 || users.Size != 10 || users.Pending {
+		t.Errorf("unexpected entry %+v", users)
+	}
+	if stream := result.Requests[4]; !stream.Pending {
+		t.Errorf("expected an unfinished request to be pending, got %+v", stream)
+	}
+	for _, filter := range []NetworkFilter{{URL: "("}, {Status: "teapot"}} {
+		if _, err := l.networkRequests(filter); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("networkRequests(%+v) error = %v, want ErrInvalidFilter", filter, err)
+		}
+	}
+}
+
+func TestTabLog_NetworkDropped(t *testing.T) {
+	l := newTabLog()
+	for i := 0; i <= maxNetworkRequests; i++ {
+		sent(l, strconv.Itoa(i), "http://app/", float64(i))
+	}
+	result, _ := l.networkRequests(NetworkFilter{})
+	if len(result.Requests) != maxNetworkRequests || result.Dropped != 1 {
+		t.Errorf("expected %d requests and 1 dropped, got %d and %d", maxNetworkRequests, len(result.Requests), result.Dropped)
+	}
+	if len(l.pending) != maxNetworkRequests {
+		t.Errorf("expected dropped requests to stop being tracked, %d are", len(l.pending))
+	}
+}
@@ -0,0 +1,413 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/cdproto/accessibility"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+var (
+	ErrInvalidElement = errors.New("exactly one of selector and ref is required")
+	ErrRefNotFound    = errors.New("ref not found")
+)
+
+const (
+	// maxSnapshotNodes bounds how much of the accessibility tree a snapshot
+	// returns.
+	maxSnapshotNodes = 5000
+	maxSnapshotName  = 200
+)
+
+// Element picks an element by CSS selector, or by a ref from the last
+// snapshot of the tab.
+type Element struct {
+	Selector string
+	Ref      string
+}
+
+// skippedRoles are left out of snapshots when they have no name, their
+// children taking their place.
+var skippedRoles = map[string]bool{
+	"generic":      true,
+	"none":         true,
+	"presentation": true,
+	"LineBreak":    true,
+}
+
+// Snapshot returns the accessibility tree of the session's active tab. Every
+// element in it gets a ref that Click, Type, Hover and Select accept until
+// the next snapshot of the tab.
+func (c *Controller) Snapshot() (*model.BrowserSnapshotResult, error) {
+	t, ctx, cancel, err := c.activeTab()
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var tree struct {
+		Nodes []*axNode `json:"nodes"`
+	}
+	result := &model.BrowserSnapshotResult{}
+	if err := chromedp.Run(ctx,
+		chromedp.Location(&result.URL),
+		chromedp.Title(&result.Title),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			return cdp.Execute(ctx, accessibility.CommandGetFullAXTree, accessibility.GetFullAXTree(), &tree)
+		}),
+	); err != nil {
+		return nil, fmt.Errorf("failed to take snapshot: %w", err)
+	}
+
+	w := newSnapshotWalk(tree.Nodes)
+	if root := w.root(); root != nil {
+		w.walk(root, 0, "")
+	}
+	result.Nodes = w.nodes
+	result.Truncated = w.truncated
+	result.Snapshot = RenderSnapshot(result)
+
+	c.state.mu.Lock()
+	t.refs = w.refs
+	c.state.mu.Unlock()
+	return result, nil
+}
+
+// axNode is the part of an accessibility.Node snapshots use. Nodes are not
+// decoded as accessibility.Node since Chrome adds enum values, such as reasons
+// for ignoring nodes, faster than cdproto picks them up.
+type axNode struct {
+	NodeID           string            `json:"nodeId"`
+	Ignored          bool              `json:"ignored"`
+	Role             *axValue          `json:"role,omitempty"`
+	Name             *axValue          `json:"name,omitempty"`
+	Value            *axValue          `json:"value,omitempty"`
+	Properties       []*axProperty     `json:"properties,omitempty"`
+	ParentID         string            `json:"parentId,omitempty"`
+	ChildIDs         []string          `json:"childIds,omitempty"`
+	BackendDOMNodeID cdp.BackendNodeID `json:"backendDOMNodeId,omitempty"`
+}
+
+type axValue struct {
+	Value interface{} `json:"value,omitempty"`
+}
+
+type axProperty struct {
+	Name  string   `json:"name"`
+	Value *axValue `json:"value"`
+}
+
+type snapshotWalk struct {
+	byID      map[string]*axNode
+	all       []*axNode
+	nodes     []model.BrowserSnapshotNode
+	refs      map[string]cdp.BackendNodeID
+	truncated bool
+}
+
+func newSnapshotWalk(nodes []*axNode) *snapshotWalk {
+	w := &snapshotWalk{
+		byID: make(map[string]*axNode, len(nodes)),
+		all:  nodes,
+		refs: map[string]cdp.BackendNodeID{},
+	}
+	for _, n := range nodes {
+		w.byID[n.NodeID] = n
+	}
+	return w
+}
+
+func (w *snapshotWalk) root() *axNode {
+	for _, n := range w.all {
+		if n.ParentID == "" {
+			return n
+		}
+	}
+	return nil
+}
+
+// walk adds n and its descendants at depth. parentName is the name of the
+// closest element added, whose text is not repeated by its children.
+func (w *snapshotWalk) walk(n *axNode, depth int, parentName string) {
+	if len(w.nodes) >= maxSnapshotNodes {
+		w.truncated = true
+		return
+	}
+	role := axString(n.Role)
+	name := axName(axString(n.Name))
+	switch {
+	case role == "InlineTextBox":
+		return
+	case n.Ignored || (skippedRoles[role] && name == ""):
+		w.children(n, depth, parentName)
+		return
+	case role == "StaticText":
+		if name != "" && !strings.Contains(parentName, name) {
+			w.nodes = append(w.nodes, model.BrowserSnapshotNode{Role: "text", Name: name, Depth: depth})
+		}
+		return
+	}
+
+	node := model.BrowserSnapshotNode{
+		Role:  role,
+		Name:  name,
+		Depth: depth,
+	}
+	// The states of the document, its URL among them, are in the header.
+	if role != "RootWebArea" {
+		node.States = axStates(n.Properties)
+	}
+	if value := axName(axString(n.Value)); value != name {
+		node.Value = value
+	}
+	if n.BackendDOMNodeID != 0 {
+		node.Ref = fmt.Sprintf("e%d", len(w.refs)+1)
+		w.refs[node.Ref] = n.BackendDOMNodeID
+	}
+	w.nodes = append(w.nodes, node)
+	w.children(n, depth+1, name)
+}
+
+func (w *snapshotWalk) children(n *axNode, depth int, parentName string) {
+	for _, id := range n.ChildIDs {
+		if child := w.byID[id]; child != nil {
+			w.walk(child, depth, parentName)
+		}
+	}
+}
+
+func axString(v *axValue) string {
+	if v == nil || v.Value == nil {
+		return ""
+	}
+	if s, ok := v.Value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v.Value)
+}
+
+// axName collapses whitespace and shortens long names.
+func axName(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxSnapshotName {
+		s = string(r[:maxSnapshotName]) + "…"
+	}
+	return s
+}
+
+// axStates describes the properties of a node that tell its state.
+func axStates(props []*axProperty) []string {
+	var states []string
+	for _, p := range props {
+		value := axString(p.Value)
+		switch accessibility.PropertyName(p.Name) {
+		case accessibility.PropertyNameFocused, accessibility.PropertyNameDisabled,
+			accessibility.PropertyNameRequired, accessibility.PropertyNameReadonly,
+			accessibility.PropertyNameSelected, accessibility.PropertyNameModal,
+			accessibility.PropertyNameMultiselectable:
+			if value == "true" {
+				states = append(states, string(p.Name))
+			}
+		case accessibility.PropertyNameChecked, accessibility.PropertyNamePressed:
+			switch value {
+			case "true":
+				states = append(states, string(p.Name))
+			case "mixed":
+				states = append(states, string(p.Name)+"=mixed")
+			}
+		case accessibility.PropertyNameExpanded:
+			if value == "true" {
+				states = append(states, "expanded")
+			} else {
+				states = append(states, "collapsed")
+			}
+		case accessibility.PropertyNameInvalid:
+			if value != "" && value != "false" {
+				states = append(states, "invalid")
+			}
+		case accessibility.PropertyNameLevel:
+			states = append(states, "level="+value)
+		case accessibility.PropertyNameURL:
+			states = append(states, "url="+value)
+		}
+	}
+	return states
+}
+
+// RenderSnapshot renders a snapshot compactly as an indented list with one
+// element per line, e.g. `- button "Save" [disabled] [ref=e4]`.
+func RenderSnapshot(result *model.BrowserSnapshotResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Page URL: %s\nPage Title: %s\n\n", result.URL, result.Title)
+	for _, n := range result.Nodes {
+		b.WriteString(strings.Repeat("  ", n.Depth) + "- " + n.Role)
+		if n.Name != "" {
+			fmt.Fprintf(&b, " %q", n.Name)
+		}
+		for _, state := range n.States {
+			b.WriteString(" [" + state + "]")
+		}
+		if n.Ref != "" {
+			b.WriteString(" [ref=" + n.Ref + "]")
+		}
+		if n.Value != "" {
+			b.WriteString(": " + n.Value)
+		}
+		b.WriteString("\n")
+	}
+	if result.Truncated {
+		fmt.Fprintf(&b, "\n(only the first %d elements are shown)\n", len(result.Nodes))
+	}
+	return b.String()
+}
+
+func (el Element) validate() error {
+	if (el.Selector == "") == (el.Ref == "") {
+		return ErrInvalidElement
+	}
+	return nil
+}
+
+// node finds the element el picks in tab t.
+func (c *Controller) node(ctx context.Context, t *tab, el Element) (cdp.BackendNodeID, error) {
+	if err := el.validate(); err != nil {
+		return 0, err
+	}
+	if el.Ref != "" {
+		c.state.mu.Lock()
+		id, ok := t.refs[el.Ref]
+		c.state.mu.Unlock()
+		if !ok {
+			return 0, fmt.Errorf("%w: %s; take a new snapshot", ErrRefNotFound, el.Ref)
+		}
+		return id, nil
+	}
+
+	var nodes []*cdp.Node
+	if err := chromedp.Run(ctx, chromedp.Nodes(el.Selector, &nodes, chromedp.NodeVisible)); err != nil {
+		return 0, err
+	}
+	return nodes[0].BackendNodeID, nil
+}
+
+// onElement runs fn on the element el picks in the session's active tab.
+func (c *Controller) onElement(el Element, fn func(ctx context.Context, id cdp.BackendNodeID) error) error {
+	t, ctx, cancel, err := c.activeTab()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	id, err := c.node(ctx, t, el)
+	if err != nil {
+		return err
+	}
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		return fn(ctx, id)
+	}))
+}
+
+// nodeCenter scrolls the element into view and returns its center in the
+// viewport.
+func nodeCenter(ctx context.Context, id cdp.BackendNodeID) (float64, float64, error) {
+	if err := dom.ScrollIntoViewIfNeeded().WithBackendNodeID(id).Do(ctx); err != nil {
+		return 0, 0, err
+	}
+	quads, err := dom.GetContentQuads().WithBackendNodeID(id).Do(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(quads) == 0 || len(quads[0]) < 8 {
+		return 0, 0, errors.New("element is not visible")
+	}
+	q := quads[0]
+	return (q[0] + q[2] + q[4] + q[6]) / 4, (q[1] + q[3] + q[5] + q[7]) / 4, nil
+}
+
+func clickNode(ctx context.Context, id cdp.BackendNodeID) error {
+	x, y, err := nodeCenter(ctx, id)
+	if err != nil {
+		return err
+	}
+	return click(ctx, x, y, input.Left, 1, 0)
+}
+
+// Hover moves the mouse over the element el picks.
+func (c *Controller) Hover(el Element) error {
+	return c.onElement(el, func(ctx context.Context, id cdp.BackendNodeID) error {
+		x, y, err := nodeCenter(ctx, id)
+		if err != nil {
+			return err
+		}
+		return input.DispatchMouseEvent(input.MouseMoved, x, y).Do(ctx)
+	})
+}
+
+// selectOptions selects the options of a <select> whose value or label is
+// one of values and returns the values of those selected.
+const selectOptions = `function(values) {
+	if (this.nodeName !== 'SELECT') {
+		throw new Error('element is not a <select>');
+	}
+	const wanted = new Set(values);
+	const options = Array.from(this.options).filter(o => wanted.has(o.value) || wanted.has(o.label));
+	if (options.length === 0) {
+		throw new Error('no option matches ' + JSON.stringify(values));
+	}
+	for (const o of this.options) {
+		o.selected = options.includes(o) && (this.multiple || o === options[0]);
+	}
+	this.dispatchEvent(new Event('input', {bubbles: true}));
+	this.dispatchEvent(new Event('change', {bubbles: true}));
+	return Array.from(this.selectedOptions).map(o => o.value);
+}`
+
+// Select selects the options of the <select> el picks by value or label,
+// returning the values selected.
+func (c *Controller) Select(el Element, values []string) ([]string, error) {
+	var selected []string
+	err := c.onElement(el, func(ctx context.Context, id cdp.BackendNodeID) error {
+		obj, err := dom.ResolveNode().WithBackendNodeID(id).Do(ctx)
+		if err != nil {
+			return err
+		}
+		args, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		res, exc, err := runtime.CallFunctionOn(selectOptions).
+			WithObjectID(obj.ObjectID).
+			WithArguments([]*runtime.CallArgument{{Value: args}}).
+			WithReturnByValue(true).
+			Do(ctx)
+		if err != nil {
+			return err
+		}
+		if exc != nil {
+			return scriptError(exc)
+		}
+		return json.Unmarshal(res.Value, &selected)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select: %w", err)
+	}
+	return selected, nil
+}
+
+// scriptError returns the message of a script exception without its stack.
+func scriptError(exc *runtime.ExceptionDetails) error {
+	if exc.Exception == nil || exc.Exception.Description == "" {
+		return exc
+	}
+	msg, _, _ := strings.Cut(exc.Exception.Description, "\n")
+	return errors.New(msg)
+}
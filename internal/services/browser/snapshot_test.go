@@ -0,0 +1,100 @@
+package browser
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+func newAXNode(id, parent string, backend cdp.BackendNodeID, role, name string, children ...string) *axNode {
+	return &axNode{
+		NodeID:           id,
+		ParentID:         parent,
+		BackendDOMNodeID: backend,
+		Role:             &axValue{Value: role},
+		Name:             &axValue{Value: name},
+		ChildIDs:         children,
+	}
+}
+
+func TestSnapshotWalk(t *testing.T) {
+	checkbox := newAXNode("6", "3", 16, "checkbox", "Remember me")
+	checkbox.Properties = []*axProperty{
+		{Name: "checked", Value: &axValue{Value: "true"}},
+		{Name: "focused", Value: &axValue{Value: false}},
+		{Name: "level", Value: &axValue{Value: float64(2)}},
+	}
+	input := newAXNode("7", "3", 17, "textbox", "Email")
+	input.Value = &axValue{Value: "me@example.com"}
+	ignored := newAXNode("8", "3", 18, "generic", "")
+	ignored.Ignored = true
+
+	w := newSnapshotWalk([]*axNode{
+		newAXNode("1", "", 10, "RootWebArea", "Login", "2", "3"),
+		newAXNode("2", "1", 11, "heading", "Sign in", "4"),
+		newAXNode("3", "1", 12, "generic", "", "5", "6", "7", "8"),
+		newAXNode("4", "2", 0, "StaticText", "Sign in"),
+		newAXNode("5", "3", 0, "StaticText", "Welcome  back"),
+		checkbox,
+		input,
+		ignored,
+	})
+	w.walk(w.root(), 0, "")
+
+	want := []model.BrowserSnapshotNode{
+		{Ref: "e1", Role: "RootWebArea", Name: "Login", Depth: 0},
+		{Ref: "e2", Role: "heading", Name: "Sign in", Depth: 1},
+		{Role: "text", Name: "Welcome back", Depth: 1},
+		{Ref: "e3", Role: "checkbox", Name: "Remember me", States: []string{"checked", "level=2"}, Depth: 1},
+		{Ref: "e4", Role: "textbox", Name: "Email", Value: "me@example.com", Depth: 1},
+	}
+	if got, _ := json.Marshal(w.nodes); string(got) != mustJSON(want) {
+		t.Errorf("nodes = %s, want %s", got, mustJSON(want))
+	}
+	if w.refs["e3"] != 16 || w.refs["e4"] != 17 || len(w.refs) != 4 {
+		t.Errorf("unexpected refs %v", w.refs)
+	}
+}
+
+func mustJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestRenderSnapshot(t *testing.T) {
+	got := RenderSnapshot(&model.BrowserSnapshotResult{
+		URL:   "http://localhost/",
+		Title: "Login",
+		Nodes: []model.BrowserSnapshotNode{
+			{Ref: "e1", Role: "RootWebArea", Name: "Login"},
+			{Ref: "e2", Role: "button", Name: "Save", States: []string{"disabled"}, Depth: 1},
+			{Ref: "e3", Role: "textbox", Name: "Email", Value: "me@example.com", Depth: 1},
+		},
+		Truncated: true,
+	})
+	want := `Page URL: http://localhost/
+Page Title: Login
+
+- RootWebArea "Login" [ref=e1]
+  - button "Save" [disabled] [ref=e2]
+  - textbox "Email" [ref=e3]: me@example.com
+
+(only the first 3 elements are shown)
+`
+	if got != want {
+		t.Errorf("RenderSnapshot() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestController_InvalidElement(t *testing.T) {
+	c := NewController("ws://localhost:0")
+
+	for _, el := range []Element{{}, {Selector: "#a", Ref: "e1"}} {
+		if err := c.Click(el); !errors.Is(err, ErrInvalidElement) {
+			t.Errorf("Click(%+v) error = %v, want ErrInvalidElement", el, err)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+var ErrTabNotFound = errors.New("tab not found")
+
+// state is the browser connection shared by the controllers of all
+// sessions.
+type state struct {
+	mu sync.Mutex
+	// conn is the context of the tab the connection was made through; the
+	// connection lives as long as it does.
+	conn      context.Context
+	closeConn context.CancelFunc
+	connTab   target.ID
+	tabs      map[target.ID]*tab
+	active    map[string]target.ID
+	contexts  map[string]*sessionContext
+}
+
+// tab is a tab the controller attached to. Cancelling its context closes it,
+// except for the tab of the connection, which has no cancel.
+type tab struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// refs are the elements of the last snapshot of the tab by ref.
+	refs map[string]cdp.BackendNodeID
+	// log has the console messages and requests of the tab since the
+	// controller attached to it.
+	log *tabLog
+}
+
+func newState() *state {
+	return &state{
+		tabs:     map[target.ID]*tab{},
+		active:   map[string]target.ID{},
+		contexts: map[string]*sessionContext{},
+	}
+}
+
+// page returns a context for acting on the session's active tab. A session
+// without one gets a tab of its browser context, or the tab the browser was
+// showing without a session, opening one if there is none.
+func (c *Controller) page() (context.Context, context.CancelFunc, error) {
+	_, ctx, cancel, err := c.activeTab()
+	return ctx, cancel, err
+}
+
+// activeTab is page returning the tab as well.
+func (c *Controller) activeTab() (*tab, context.Context, context.CancelFunc, error) {
+	s := c.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bc, err := c.browserContext(conn)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	id, ok := s.active[c.session]
+	if !ok {
+		if id, err = c.defaultTab(conn, bc); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	t, err := c.attach(conn, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	s.active[c.session] = id
+	ctx, cancel := context.WithTimeout(t.ctx, c.timeout)
+	return t, ctx, cancel, nil
+}
+
+// connect returns the browser connection, making it if there is none or the
+// previous one was lost. s.mu must be held.
+func (c *Controller) connect() (context.Context, error) {
+	s := c.state
+	if s.conn != nil && s.conn.Err() == nil {
+		return s.conn, nil
+	}
+	s.reset()
+
+	// Attach to a tab that is already open rather than opening one for the
+	// connection, so the browser keeps showing what it was showing.
+	var opts []chromedp.ContextOption
+	if id, ok := c.firstPage(); ok {
+		opts = append(opts, chromedp.WithTargetID(id))
+	}
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(context.Background(), c.cdpURL)
+	conn, cancel := chromedp.NewContext(allocCtx, opts...)
+	log := newTabLog()
+	chromedp.ListenTarget(conn, log.listen)
+	closeConn := func() {
+		cancel()
+		cancelAlloc()
+	}
+	// The connection is bound to the context it is made with, so it cannot
+	// have a deadline of its own.
+	if err := c.run(conn, closeConn); err != nil {
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+
+	browser := cdp.WithExecutor(conn, chromedp.FromContext(conn).Browser)
+	if err := target.SetDiscoverTargets(true).Do(browser); err != nil {
+		closeConn()
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+	chromedp.ListenBrowser(conn, func(ev interface{}) {
+		if ev, ok := ev.(*target.EventTargetDestroyed); ok {
+			go s.forget(ev.TargetID)
+		}
+	})
+
+	s.conn = conn
+	s.closeConn = closeConn
+	s.connTab = chromedp.FromContext(conn).Target.TargetID
+	s.tabs[s.connTab] = &tab{ctx: conn, log: log}
+	if c.contextIdle > 0 {
+		go c.watchContexts(conn)
+	}
+	return conn, nil
+}
+
+// attach returns the tab with id, attaching to it if the controller has not
+// yet. s.mu must be held.
+func (c *Controller) attach(conn context.Context, id target.ID) (*tab, error) {
+	if t := c.state.tabs[id]; t != nil {
+		return t, nil
+	}
+	ctx, cancel := chromedp.NewContext(conn, chromedp.WithTargetID(id))
+	// Listening before the first run catches the events of attaching.
+	log := newTabLog()
+	chromedp.ListenTarget(ctx, log.listen)
+	if err := c.run(ctx, cancel); err != nil {
+		return nil, fmt.Errorf("failed to attach to tab %s: %w", id, err)
+	}
+	t := &tab{ctx: ctx, cancel: cancel, log: log}
+	c.state.tabs[id] = t
+	return t, nil
+}
+
+// run makes the first run of ctx, which binds it to the browser, calling
+// cancel if that fails or takes longer than the timeout.
+func (c *Controller) run(ctx context.Context, cancel context.CancelFunc) error {
+	timer := time.AfterFunc(c.timeout, cancel)
+	err := chromedp.Run(ctx)
+	if !timer.Stop() && err == nil {
+		err = context.DeadlineExceeded
+	}
+	if err != nil {
+		cancel()
+	}
+	return err
+}
+
+// defaultTab picks the tab for a session that has none from the browser
+// context bc. s.mu must be held.
+func (c *Controller) defaultTab(conn context.Context, bc cdp.BrowserContextID) (target.ID, error) {
+	if bc == "" && c.state.tabs[c.state.connTab] != nil {
+		return c.state.connTab, nil
+	}
+	ctx, cancel := c.browser(conn)
+	defer cancel()
+	pages, err := pageTargets(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tabs: %w", err)
+	}
+	for _, info := range pages {
+		if c.owns(info, bc) {
+			return info.TargetID, nil
+		}
+	}
+	id, err := newTab(bc).Do(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to open tab: %w", err)
+	}
+	return id, nil
+}
+
+// browser returns a context for browser wide commands.
+func (c *Controller) browser(conn context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(conn, c.timeout)
+	return cdp.WithExecutor(ctx, chromedp.FromContext(conn).Browser), cancel
+}
+
+// newTab opens a blank tab in the browser context bc.
+func newTab(bc cdp.BrowserContextID) *target.CreateTargetParams {
+	create := target.CreateTarget("about:blank")
+	if bc != "" {
+		create = create.WithBrowserContextID(bc)
+	}
+	return create
+}
+
+func pageTargets(ctx context.Context) ([]*target.Info, error) {
+	infos, err := target.GetTargets().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pages []*target.Info
+	for _, info := range infos {
+		if info.Type == "page" {
+			pages = append(pages, info)
+		}
+	}
+	return pages, nil
+}
+
+// firstPage asks the DevTools HTTP endpoint for a tab that is already open.
+func (c *Controller) firstPage() (target.ID, bool) {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(c.devtoolsURL("/json/list"))
+	if err != nil {
+		return "", false
+	}
+	defer resp.Body.Close()
+
+	var list []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", false
+	}
+	for _, t := range list {
+		if t.Type == "page" {
+			return target.ID(t.ID), true
+		}
+	}
+	return "", false
+}
+
+// devtoolsURL returns the DevTools HTTP endpoint at p next to the WebSocket
+// URL the controller was made with.
+func (c *Controller) devtoolsURL(p string) string {
+	u, err := url.Parse(c.cdpURL)
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "wss":
+		u.Scheme = "https"
+	default:
+		u.Scheme = "http"
+	}
+	if i := strings.Index(u.Path, "/devtools/"); i >= 0 {
+		u.Path = u.Path[:i]
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + p
+	return u.String()
+}
+
+// forget drops a tab that was closed.
+func (s *state) forget(id target.ID) {
+	s.mu.Lock()
+	t := s.tabs[id]
+	delete(s.tabs, id)
+	for session, active := range s.active {
+		if active == id {
+			delete(s.active, session)
+		}
+	}
+	s.mu.Unlock()
+
+	if t != nil && t.cancel != nil {
+		t.cancel()
+	}
+}
+
+// reset drops the tabs of a lost connection. s.mu must be held.
+func (s *state) reset() {
+	for id, t := range s.tabs {
+		if t.cancel != nil {
+			t.cancel()
+		}
+		delete(s.tabs, id)
+	}
+	clear(s.active)
+	clear(s.contexts)
+	if s.closeConn != nil {
+		s.closeConn()
+	}
+	s.conn, s.closeConn, s.connTab = nil, nil, ""
+}
+
+// Tabs lists the open tabs of the session, marking its active one.
+func (c *Controller) Tabs() ([]model.BrowserTab, error) {
+	s := c.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	bc, err := c.browserContext(conn)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.browser(conn)
+	defer cancel()
+	pages, err := pageTargets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tabs: %w", err)
+	}
+
+	active, ok := s.active[c.session]
+	if !ok && bc == "" && s.tabs[s.connTab] != nil {
+		active = s.connTab
+	}
+	tabs := make([]model.BrowserTab, 0, len(pages))
+	for _, info := range pages {
+		if !c.owns(info, bc) {
+			continue
+		}
+		tabs = append(tabs, model.BrowserTab{
+			ID:     string(info.TargetID),
+			URL:    info.URL,
+			Title:  info.Title,
+			Active: info.TargetID == active,
+		})
+	}
+	return tabs, nil
+}
+
+// OpenTab opens a tab, loading url in it if given, and makes it the session's
+// active tab.
+func (c *Controller) OpenTab(url string) (*model.BrowserTab, error) {
+	t, id, err := c.openTab()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(t.ctx, c.timeout)
+	defer cancel()
+	var actions []chromedp.Action
+	if url != "" {
+		actions = append(actions, chromedp.Navigate(url))
+	}
+	result := &model.BrowserTab{ID: string(id), Active: true}
+	actions = append(actions, chromedp.Location(&result.URL), chromedp.Title(&result.Title))
+	if err := chromedp.Run(ctx, actions...); err != nil {
+		return result, fmt.Errorf("failed to load %s: %w", url, err)
+	}
+	return result, nil
+}
+
+func (c *Controller) openTab() (*tab, target.ID, error) {
+	s := c.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, "", err
+	}
+	bc, err := c.browserContext(conn)
+	if err != nil {
+		return nil, "", err
+	}
+	ctx, cancel := c.browser(conn)
+	defer cancel()
+	id, err := newTab(bc).Do(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open tab: %w", err)
+	}
+	t, err := c.attach(conn, id)
+	if err != nil {
+		return nil, "", err
+	}
+	s.active[c.session] = id
+	return t, id, nil
+}
+
+// CloseTab closes the session's tab with id. Sessions that had it active get
+// a default tab on their next action.
+func (c *Controller) CloseTab(id string) error {
+	s := c.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel, err := c.findTab(target.ID(id))
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	// Closing the tab of the connection through its context would close the
+	// connection as well.
+	if t := s.tabs[target.ID(id)]; t != nil && t.cancel != nil {
+		delete(s.tabs, target.ID(id))
+		t.cancel()
+	} else if err := target.CloseTarget(target.ID(id)).Do(ctx); err != nil {
+		return fmt.Errorf("failed to close tab: %w", err)
+	}
+	for session, active := range s.active {
+		if active == target.ID(id) {
+			delete(s.active, session)
+		}
+	}
+	return nil
+}
+
+// ActivateTab makes the session's tab with id its active tab and brings it to
+// the front.
+func (c *Controller) ActivateTab(id string) error {
+	s := c.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel, err := c.findTab(target.ID(id))
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	if _, err := c.attach(s.conn, target.ID(id)); err != nil {
+		return err
+	}
+	s.active[c.session] = target.ID(id)
+	if err := target.ActivateTarget(target.ID(id)).Do(ctx); err != nil {
+		return fmt.Errorf("failed to activate tab: %w", err)
+	}
+	return nil
+}
+
+// findTab checks that the tab with id belongs to the session, returning a
+// context for browser wide commands. s.mu must be held.
+func (c *Controller) findTab(id target.ID) (context.Context, context.CancelFunc, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	bc, err := c.browserContext(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := c.browser(conn)
+	pages, err := pageTargets(ctx)
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to list tabs: %w", err)
+	}
+	for _, info := range pages {
+		if info.TargetID == id && c.owns(info, bc) {
+			return ctx, cancel, nil
+		}
+	}
+	cancel()
+	return nil, nil, fmt.Errorf("%w: %s", ErrTabNotFound, id)
+}
@@ -0,0 +1,51 @@
+package browser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+)
+
+func TestController_DevtoolsURL(t *testing.T) {
+	tests := []struct {
+		cdpURL string
+		want   string
+	}{
+		{"ws://localhost:9222", "http://localhost:9222/json/list"},
+		{"ws://127.0.0.1:9222/devtools/browser/abc", "http://127.0.0.1:9222/json/list"},
+		{"ws://localhost:8080/cdp", "http://localhost:8080/cdp/json/list"},
+		{"wss://example.com/cdp/", "https://example.com/cdp/json/list"},
+	}
+	for _, tt := range tests {
+		if got := NewController(tt.cdpURL).devtoolsURL("/json/list"); got != tt.want {
+			t.Errorf("devtoolsURL(%q) = %q, want %q", tt.cdpURL, got, tt.want)
+		}
+	}
+}
+
+func TestController_ForContext(t *testing.T) {
+	c := NewController("ws://localhost:9222")
+	scoped := c.ForContext(ctxutil.WithSessionID(context.Background(), "s1"))
+
+	if scoped.session != "s1" {
+		t.Errorf("session = %q, want %q", scoped.session, "s1")
+	}
+	if scoped.state != c.state {
+		t.Error("expected scoped controllers to share the browser connection")
+	}
+	if c.session != "" {
+		t.Errorf("expected the controller itself to stay unscoped, got session %q", c.session)
+	}
+}
+
+func TestController_NotConnected(t *testing.T) {
+	c := NewController("ws://localhost:0")
+
+	if _, err := c.Tabs(); err == nil {
+		t.Error("expected an error listing tabs without a browser")
+	}
+	if err := c.Navigate("about:blank"); err == nil {
+		t.Error("expected an error navigating without a browser")
+	}
+}
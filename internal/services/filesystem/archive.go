@@ -0,0 +1,254 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	ArchiveTarGz = "tar.gz"
+	ArchiveTar   = "tar"
+	ArchiveZip   = "zip"
+)
+
+var (
+	ErrArchiveFormat = errors.New("unsupported archive format")
+	ErrArchiveLimit  = errors.New("archive exceeds the extraction limits")
+	ErrUnsafeArchive = errors.New("unsafe path in archive")
+)
+
+type ArchiveOptions struct {
+	// Format is ArchiveTarGz, the default, ArchiveTar or ArchiveZip.
+	Format string
+	// Include keeps only the files matching a pattern and Exclude drops
+	// the files and directories matching one. Patterns use .gitignore
+	// syntax relative to the archived directory.
+	Include []string
+	Exclude []string
+	// Gitignore also drops what the .gitignore files in the directory
+	// ignore, and the .git directory.
+	Gitignore bool
+}
+
+// Archive writes the directory at path, or the single file, to w as an
+// archive. Entry names are relative to the directory, and symlinks are
+// stored as links rather than followed.
+func (m *Manager) Archive(path string, w io.Writer, opts ArchiveOptions) error {
+	return m.run(func() error { return m.archive(path, w, opts) })
+}
+
+func (m *Manager) archive(dir string, w io.Writer, opts ArchiveOptions) error {
+	absPath, err := m.validateReadPath(dir)
+	if err != nil {
+		return err
+	}
+	include, err := compilePatterns(opts.Include)
+	if err != nil {
+		return err
+	}
+	exclude, err := compilePatterns(opts.Exclude)
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	var aw archiveWriter
+	switch opts.Format {
+	case "", ArchiveTarGz:
+		aw = newTarWriter(w, true)
+	case ArchiveTar:
+		aw = newTarWriter(w, false)
+	case ArchiveZip:
+		aw = &zipWriter{zw: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("%w: %s", ErrArchiveFormat, opts.Format)
+	}
+
+	if !info.IsDir() {
+		if err := aw.add(root, filepath.Base(absPath), info); err != nil {
+			return err
+		}
+		return aw.close()
+	}
+
+	ignores := gitignores{}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files removed while the walk runs are skipped.
+			if os.IsNotExist(err) && p != root {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." {
+			skip := matchAny(exclude, rel, d.IsDir())
+			if opts.Gitignore && !skip {
+				skip = ignores.ignored(rel, d.IsDir())
+			}
+			if skip {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if d.IsDir() {
+			if opts.Gitignore {
+				ignores.enter(p, rel)
+			}
+			// With Include, directories come with the files in them.
+			if rel == "." || len(include) > 0 {
+				return nil
+			}
+		} else if len(include) > 0 && !matchAnyParent(include, rel) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return aw.add(p, rel, info)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", absPath, err)
+	}
+	return aw.close()
+}
+
+type archiveWriter interface {
+	// add writes the file at path as the entry name. Files other than
+	// regular files, directories and symlinks are skipped.
+	add(path, name string, info fs.FileInfo) error
+	close() error
+}
+
+type tarWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarWriter(w io.Writer, compress bool) *tarWriter {
+	t := &tarWriter{}
+	if compress {
+		t.gz = gzip.NewWriter(w)
+		w = t.gz
+	}
+	t.tw = tar.NewWriter(w)
+	return t
+}
+
+func (t *tarWriter) add(p, name string, info fs.FileInfo) error {
+	var link string
+	switch {
+	case info.IsDir():
+		name += "/"
+	case info.Mode()&fs.ModeSymlink != 0:
+		var err error
+		if link, err = os.Readlink(p); err != nil {
+			return err
+		}
+	case !info.Mode().IsRegular():
+		return nil
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag == tar.TypeReg {
+		return copyFileTo(t.tw, p, hdr.Size)
+	}
+	return nil
+}
+
+func (t *tarWriter) close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	if t.gz != nil {
+		return t.gz.Close()
+	}
+	return nil
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) add(p, name string, info fs.FileInfo) error {
+	if !info.IsDir() && info.Mode()&fs.ModeSymlink == 0 && !info.Mode().IsRegular() {
+		return nil
+	}
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	switch {
+	case info.IsDir():
+		hdr.Name += "/"
+	case info.Mode().IsRegular():
+		hdr.Method = zip.Deflate
+	}
+
+	w, err := z.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		// Zip stores the target of a symlink as its content.
+		link, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, link)
+		return err
+	case info.Mode().IsRegular():
+		return copyFileTo(w, p, info.Size())
+	}
+	return nil
+}
+
+func (z *zipWriter) close() error {
+	return z.zw.Close()
+}
+
+// copyFileTo writes the first size bytes of the file at p to w, the size the
+// entry header announced.
+func copyFileTo(w io.Writer, p string, size int64) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.CopyN(w, f, size); err != nil {
+		return fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listTree(t *testing.T, root string) []string {
+	t.Helper()
+	var files []string
+	filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(root, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	for _, format := range []string{ArchiveTarGz, ArchiveTar, ArchiveZip} {
+		t.Run(format, func(t *testing.T) {
+			m := NewManager()
+			src := t.TempDir()
+			writeTree(t, src, map[string]string{
+				"main.go":         "package main\n",
+				"pkg/util.go":     "package pkg\n",
+				"pkg/util.log":    "log\n",
+				"build/out.bin":   "bin\n",
+				"keep/debug.log":  "kept\n",
+				".gitignore":      "*.log\nbuild/\n!keep/*.log\n",
+				".git/HEAD":       "ref: refs/heads/main\n",
+				"docs/README.md":  "docs\n",
+				"docs/.gitignore": "*.md\n",
+			})
+			os.Symlink("main.go", filepath.Join(src, "link.go"))
+
+			var buf bytes.Buffer
+			err := m.Archive(src, &buf, ArchiveOptions{Format: format, Exclude: []string{"docs"}, Gitignore: true})
+			if err != nil {
+				t.Fatalf("Archive() error = %v", err)
+			}
+
+			dest := filepath.Join(t.TempDir(), "dest")
+			result, err := m.Extract(dest, &buf, ExtractOptions{})
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			want := []string{".gitignore", "keep/debug.log", "link.go", "main.go", "pkg/util.go"}
+			if got := listTree(t, dest); !slices.Equal(got, want) {
+				t.Errorf("extracted %v, want %v", got, want)
+			}
+			if result.Files != len(want) || result.Size == 0 {
+				t.Errorf("unexpected result %+v", result)
+			}
+			if target, err := os.Readlink(filepath.Join(dest, "link.go")); err != nil || target != "main.go" {
+				t.Errorf("expected link.go to stay a symlink, got %q, %v", target, err)
+			}
+		})
+	}
+}
+
+func TestArchive_Include(t *testing.T) {
+	m := NewManager()
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"a.go":        "a",
+		"a.txt":       "a",
+		"src/b.go":    "b",
+		"src/c/d.txt": "d",
+	})
+
+	var buf bytes.Buffer
+	if err := m.Archive(src, &buf, ArchiveOptions{Include: []string{"*.go", "src/c"}}); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if _, err := m.Extract(dest, &buf, ExtractOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.go", "src/b.go", "src/c/d.txt"}
+	if got := listTree(t, dest); !slices.Equal(got, want) {
+		t.Errorf("extracted %v, want %v", got, want)
+	}
+}
+
+func TestExtract_Unsafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tar.Header
+	}{
+		{"parent", []tar.Header{{Name: "../evil.txt", Typeflag: tar.TypeReg}}},
+		{"absolute", []tar.Header{{Name: "/tmp/evil.txt", Typeflag: tar.TypeReg}}},
+		{"symlink target", []tar.Header{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../../etc"}}},
+		{"through symlink", []tar.Header{
+			{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "sub"},
+			{Name: "link/evil.txt", Typeflag: tar.TypeReg},
+		}},
+		{"symlink target through symlink", []tar.Header{
+			{Name: "x", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "s", Typeflag: tar.TypeSymlink, Linkname: "x/.."},
+		}},
+		{"symlink target through later symlink", []tar.Header{
+			{Name: "s", Typeflag: tar.TypeSymlink, Linkname: "a/b/../.."},
+			{Name: "a/b", Typeflag: tar.TypeDir},
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+		}},
+		{"symlink loop", []tar.Header{
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "b/x"},
+			{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "a/x"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, hdr := range tt.entries {
+				hdr.Mode = 0644
+				tw.WriteHeader(&hdr)
+			}
+			tw.Close()
+
+			dest := filepath.Join(t.TempDir(), "dest")
+			_, err := NewManager().Extract(dest, &buf, ExtractOptions{})
+			if !errors.Is(err, ErrUnsafeArchive) {
+				t.Fatalf("expected ErrUnsafeArchive, got %v", err)
+			}
+			if _, err := os.Stat(dest); !os.IsNotExist(err) {
+				t.Error("expected nothing to be written for an unsafe archive")
+			}
+		})
+	}
+}
+
+func TestExtract_Limits(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte("content"))
+	}
+	zw.Close()
+	archive := buf.Bytes()
+
+	dest := filepath.Join(t.TempDir(), "dest")
+	_, err := NewManager(WithExtractLimits(0, 2)).Extract(dest, bytes.NewReader(archive), ExtractOptions{})
+	if !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("expected ErrArchiveLimit for too many entries, got %v", err)
+	}
+	_, err = NewManager(WithExtractLimits(int64(len(archive)), 0)).Extract(dest, bytes.NewReader(archive), ExtractOptions{Format: ArchiveZip})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	_, err = NewManager(WithExtractLimits(10, 0)).Extract(dest, bytes.NewReader(archive), ExtractOptions{})
+	if !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("expected ErrArchiveLimit for a large archive, got %v", err)
+	}
+	_, err = NewManager().Extract(dest, bytes.NewReader([]byte("not an archive")), ExtractOptions{})
+	if !errors.Is(err, ErrArchiveFormat) {
+		t.Fatalf("expected ErrArchiveFormat, got %v", err)
+	}
+}
+
+func TestExtract_StripComponentsAndUndo(t *testing.T) {
+	m, workspace := newHistoryManager(t, HistoryOptions{})
+	writeTree(t, workspace, map[string]string{"README.md": "old\n", "src/keep.go": "keep\n"})
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"project-1.0/README.md":  "new\n",
+		"project-1.0/src/new.go": "new\n",
+		"project-1.0/lib/x.go":   "x\n",
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+
+	result, err := m.Extract(workspace, &buf, ExtractOptions{StripComponents: 1})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if result.Files != 3 {
+		t.Errorf("expected 3 files, got %+v", result)
+	}
+	want := []string{"README.md", "lib/x.go", "src/keep.go", "src/new.go"}
+	if got := listTree(t, workspace); !slices.Equal(got, want) {
+		t.Errorf("extracted %v, want %v", got, want)
+	}
+	if got := readString(t, filepath.Join(workspace, "README.md")); got != "new\n" {
+		t.Errorf("expected README.md to be replaced, got %q", got)
+	}
+
+	if _, err := m.Undo(UndoOptions{}); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	want = []string{"README.md", "src/keep.go"}
+	if got := listTree(t, workspace); !slices.Equal(got, want) {
+		t.Errorf("after undo %v, want %v", got, want)
+	}
+	if got := readString(t, filepath.Join(workspace, "README.md")); got != "old\n" {
+		t.Errorf("expected README.md to be restored, got %q", got)
+	}
+}
+
+func TestPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "dir/a.log", false, true},
+		{"/a.log", "dir/a.log", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"src/*.go", "src/a.go", false, true},
+		{"src/*.go", "src/sub/a.go", false, false},
+		{"src/**/*.go", "src/sub/a.go", false, true},
+		{"src/**/*.go", "src/a.go", false, true},
+		{"**/node_modules", "a/b/node_modules", true, true},
+		{"docs/**", "docs/a/b.md", false, true},
+		{"file[0-9].txt", "file7.txt", false, true},
+		{"file[!0-9].txt", "file7.txt", false, false},
+		{"?.txt", "ab.txt", false, false},
+	}
+	for _, tt := range tests {
+		p, err := compilePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("compilePattern(%q) error = %v", tt.pattern, err)
+		}
+		if got := p.match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q.match(%q, %v) = %v, want %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package filesystem
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+type diffKind byte
+
+const (
+	diffEqual  diffKind = ' '
+	diffDelete diffKind = '-'
+	diffInsert diffKind = '+'
+)
+
+type diffLine struct {
+	kind diffKind
+	text string
+}
+
+// UnifiedDiff returns the changes from oldContent to newContent in unified
+// diff format, with path in the file headers. It is empty when the contents
+// are equal.
+func UnifiedDiff(path, oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+	lines := diffLines(splitLines(oldContent), splitLines(newContent))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", path, path)
+	for _, h := range hunks(lines) {
+		writeHunk(&b, lines, h)
+	}
+	return b.String()
+}
+
+// splitLines splits s after each newline, so a missing final newline shows
+// as a difference.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns an edit script turning a into b, which is a shortest one
+// unless a changed region exceeds diffMaxCost. Common leading and trailing
+// lines are matched first, so the Myers search only covers the changed region.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		lines = append(lines, diffLine{diffEqual, line})
+	}
+	lines = append(lines, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{diffEqual, line})
+	}
+	return lines
+}
+
+// diffMaxCost bounds the Myers search of one region, counted in edits from
+// either end. Regions that differ more are shown as deleted and inserted as a
+// whole, which keeps large rewrites from taking quadratic time.
+const diffMaxCost = 1024
+
+// differ finds a shortest edit script with the linear space variant of the
+// Myers algorithm: it looks for the middle snake of an optimal path from both
+// ends and recurses on the regions before and after it.
+type differ struct {
+	a, b []string
+	// vf and vb hold the furthest x reached on each diagonal by the forward
+	// and backward searches.
+	vf, vb []int
+	lines  []diffLine
+}
+
+func myers(a, b []string) []diffLine {
+	size := 2*(len(a)+len(b)) + 4
+	d := &differ{a: a, b: b, vf: make([]int, size), vb: make([]int, size)}
+	d.compare(0, len(a), 0, len(b))
+	return d.lines
+}
+
+func (d *differ) compare(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.lines = append(d.lines, diffLine{diffEqual, d.a[a0]})
+		a0++
+		b0++
+	}
+	suffix := a1
+	for a1 > a0 && b1 > b0 && d.a[a1-1] == d.b[b1-1] {
+		a1--
+		b1--
+	}
+
+	switch x, y, u, v, ok := d.middleSnake(a0, a1, b0, b1); {
+	case a0 == a1 || b0 == b1 || !ok:
+		for _, line := range d.a[a0:a1] {
+			d.lines = append(d.lines, diffLine{diffDelete, line})
+		}
+		for _, line := range d.b[b0:b1] {
+			d.lines = append(d.lines, diffLine{diffInsert, line})
+		}
+	default:
+		d.compare(a0, x, b0, y)
+		for _, line := range d.a[x:u] {
+			d.lines = append(d.lines, diffLine{diffEqual, line})
+		}
+		d.compare(u, a1, v, b1)
+	}
+
+	for _, line := range d.a[a1:suffix] {
+		d.lines = append(d.lines, diffLine{diffEqual, line})
+	}
+}
+
+// middleSnake returns the snake from (x, y) to (u, v) in the middle of a
+// shortest edit script for a[a0:a1] and b[b0:b1], which must both be non-empty
+// and differ in their first and last lines. It reports false when the script
+// is longer than diffMaxCost from either end.
+func (d *differ) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int, ok bool) {
+	n, m := a1-a0, b1-b0
+	if n == 0 || m == 0 {
+		return 0, 0, 0, 0, false
+	}
+	delta := n - m
+	odd := delta%2 != 0
+	// Diagonal k is at index offset+k; coordinates are relative to (a0, b0).
+	offset := m + (n+m+1)/2 + 1
+	vf, vb := d.vf, d.vb
+	vf[offset+1] = 0
+	vb[offset+delta+1] = n + 1
+
+	for cost := 0; cost <= (n+m+1)/2 && cost <= diffMaxCost; cost++ {
+		for k := -cost; k <= cost; k += 2 {
+			var x int
+			if k == -cost || (k != cost && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			start := x
+			for x < n && x-k < m && d.a[a0+x] == d.b[b0+x-k] {
+				x++
+			}
+			vf[offset+k] = x
+			if odd && k >= delta-cost+1 && k <= delta+cost-1 && x >= vb[offset+k] {
+				return a0 + start, b0 + start - k, a0 + x, b0 + x - k, true
+			}
+		}
+		// Going down the diagonals prefers a split that puts deletions
+		// before insertions.
+		for k := delta + cost; k >= delta-cost; k -= 2 {
+			var x int
+			if k == delta-cost || (k != delta+cost && vb[offset+k+1]-1 < vb[offset+k-1]) {
+				x = vb[offset+k+1] - 1
+			} else {
+				x = vb[offset+k-1]
+			}
+			end := x
+			for x > 0 && x-k > 0 && d.a[a0+x-1] == d.b[b0+x-k-1] {
+				x--
+			}
+			vb[offset+k] = x
+			if !odd && k >= -cost && k <= cost && x <= vf[offset+k] {
+				return a0 + x, b0 + x - k, a0 + end, b0 + end - k, true
+			}
+		}
+	}
+	return 0, 0, 0, 0, false
+}
+
+// hunk is a range of the edit script shown together.
+type hunk struct {
+	start, end int
+}
+
+// hunks groups the changes with their context, merging changes whose
+// context would overlap.
+func hunks(lines []diffLine) []hunk {
+	var result []hunk
+	for i := 0; i < len(lines); i++ {
+		if lines[i].kind == diffEqual {
+			continue
+		}
+		start := max(i-diffContext, 0)
+		end := i + 1
+		for end < len(lines) {
+			if lines[end].kind != diffEqual {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].kind == diffEqual {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				break
+			}
+			end = next
+		}
+		i = end
+		end = min(end+diffContext, len(lines))
+
+		if n := len(result); n > 0 && result[n-1].end >= start {
+			result[n-1].end = end
+		} else {
+			result = append(result, hunk{start, end})
+		}
+	}
+	return result
+}
+
+func writeHunk(b *strings.Builder, lines []diffLine, h hunk) {
+	// Line numbers of the hunk start in the old and new content.
+	oldLine, newLine := 1, 1
+	for _, line := range lines[:h.start] {
+		if line.kind != diffInsert {
+			oldLine++
+		}
+		if line.kind != diffDelete {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, line := range lines[h.start:h.end] {
+		if line.kind != diffInsert {
+			oldCount++
+		}
+		if line.kind != diffDelete {
+			newCount++
+		}
+	}
+
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+	for _, line := range lines[h.start:h.end] {
+		b.WriteByte(byte(line.kind))
+		b.WriteString(line.text)
+		if !strings.HasSuffix(line.text, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats a hunk range like diff -u: an empty range names the line
+// before it, and a count of one is omitted.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
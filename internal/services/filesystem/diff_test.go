@@ -0,0 +1,148 @@
+package filesystem
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "equal",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "separate hunks",
+			old:  "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n",
+			new:  "A\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n",
+			want: "--- f\n+++ f\n@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n d\n@@ -7,4 +7,4 @@\n g\n h\n i\n-j\n+J\n",
+		},
+		{
+			name: "merged hunk",
+			old:  "a\nb\nc\nd\ne\n",
+			new:  "A\nb\nc\nd\nE\n",
+			want: "--- f\n+++ f\n@@ -1,5 +1,5 @@\n-a\n+A\n b\n c\n d\n-e\n+E\n",
+		},
+		{
+			name: "new file",
+			old:  "",
+			new:  "x\n",
+			want: "--- f\n+++ f\n@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			name: "missing final newline",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "--- f\n+++ f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "moved line",
+			old:  "a\nb\nc\n",
+			new:  "a\nc\nb\n",
+			want: "--- f\n+++ f\n@@ -1,3 +1,3 @@\n a\n-b\n c\n+b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff("f", tt.old, tt.new); got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// applyScript returns the old and new lines described by an edit script.
+func applyScript(lines []diffLine) (a, b []string) {
+	for _, line := range lines {
+		if line.kind != diffInsert {
+			a = append(a, line.text)
+		}
+		if line.kind != diffDelete {
+			b = append(b, line.text)
+		}
+	}
+	return a, b
+}
+
+func editCost(lines []diffLine) int {
+	cost := 0
+	for _, line := range lines {
+		if line.kind != diffEqual {
+			cost++
+		}
+	}
+	return cost
+}
+
+// lcsCost returns the length of a shortest edit script by dynamic programming.
+func lcsCost(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func TestDiffLines_Shortest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(3)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		lines := diffLines(a, b)
+		gotA, gotB := applyScript(lines)
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("diffLines(%q, %q) = %v does not turn one into the other", a, b, lines)
+		}
+		if got, want := editCost(lines), lcsCost(a, b); got != want {
+			t.Fatalf("diffLines(%q, %q) has %d edits, want %d", a, b, got, want)
+		}
+	}
+}
+
+func TestDiffLines_Large(t *testing.T) {
+	// Every other line changes, so the edit script is long but the common
+	// lines still have to be found.
+	var a, b []string
+	for i := 0; i < 20000; i++ {
+		a = append(a, fmt.Sprintf("line %d\n", i))
+		if i%2 == 0 {
+			b = append(b, fmt.Sprintf("changed %d\n", i))
+		} else {
+			b = append(b, a[i])
+		}
+	}
+
+	lines := diffLines(a, b)
+	gotA, gotB := applyScript(lines)
+	if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+		t.Fatal("diffLines() does not turn one into the other")
+	}
+	if got := editCost(lines); got < 20000 || got > 2*len(a) {
+		t.Errorf("diffLines() has %d edits", got)
+	}
+}
@@ -0,0 +1,557 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Default limits of the archives Extract unpacks, see WithExtractLimits.
+const (
+	DefaultExtractMaxSize    = 1 << 30
+	DefaultExtractMaxEntries = 100_000
+)
+
+// maxSymlinkTarget bounds the symlink targets read from zip archives.
+const maxSymlinkTarget = 4096
+
+type ExtractOptions struct {
+	// Format is ArchiveTarGz, ArchiveTar or ArchiveZip; empty detects it
+	// from the content.
+	Format string
+	// StripComponents drops that many leading directories from the entry
+	// names, like tar --strip-components. Shorter entries are skipped.
+	StripComponents int
+}
+
+type ExtractResult struct {
+	Path  string
+	Files int
+	Dirs  int
+	Size  int64
+}
+
+const (
+	entryFile = iota
+	entryDir
+	entrySymlink
+	entryHardlink
+)
+
+type archiveEntry struct {
+	// name is cleaned and relative to the destination; link is the
+	// target of a symlink, or the name of a hard link's file.
+	name    string
+	kind    int
+	mode    fs.FileMode
+	size    int64
+	link    string
+	modTime time.Time
+}
+
+// extraction is an archive being unpacked into dest. The archive is kept in
+// a temporary file so its entries can be checked before anything is written.
+type extraction struct {
+	file     *os.File
+	size     int64
+	format   string
+	dest     string
+	strip    int
+	maxSize  int64
+	maxFiles int
+
+	entries []archiveEntry
+	// paths are the paths the extraction changes, as journaled.
+	paths []string
+}
+
+// Extract unpacks the archive read from r into the directory path, which is
+// created if needed. The whole archive is checked first: entries leading out
+// of path, archives over the limits and unknown formats are refused without
+// writing anything. Existing files are replaced, and entries are never
+// written through symlinks.
+func (m *Manager) Extract(path string, r io.Reader, opts ExtractOptions) (*ExtractResult, error) {
+	var x *extraction
+	err := m.run(func() error {
+		var err error
+		x, err = m.prepareExtract(path, r, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer m.run(x.close)
+
+	var result *ExtractResult
+	err = m.journalFiles(OpExtract, x.paths, func() error {
+		var err error
+		result, err = x.extract()
+		return err
+	})
+	return result, err
+}
+
+func (m *Manager) prepareExtract(path string, r io.Reader, opts ExtractOptions) (*extraction, error) {
+	dest, err := m.validatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if dest, err = filepath.Abs(dest); err != nil {
+		return nil, err
+	}
+	if opts.StripComponents < 0 {
+		return nil, fmt.Errorf("invalid strip_components: %d", opts.StripComponents)
+	}
+
+	x := &extraction{
+		dest:     dest,
+		strip:    opts.StripComponents,
+		maxSize:  m.extractMaxSize,
+		maxFiles: m.extractMaxEntries,
+	}
+	if x.maxSize <= 0 {
+		x.maxSize = DefaultExtractMaxSize
+	}
+	if x.maxFiles <= 0 {
+		x.maxFiles = DefaultExtractMaxEntries
+	}
+
+	if x.file, err = os.CreateTemp("", "sandbox-extract-*"); err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if x.size, err = io.Copy(x.file, io.LimitReader(r, x.maxSize+1)); err != nil {
+		x.close()
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if x.size > x.maxSize {
+		x.close()
+		return nil, fmt.Errorf("%w: archive is larger than %d bytes", ErrArchiveLimit, x.maxSize)
+	}
+	if x.format, err = x.detectFormat(opts.Format); err != nil {
+		x.close()
+		return nil, err
+	}
+	if err := x.list(); err != nil {
+		x.close()
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *extraction) close() error {
+	x.file.Close()
+	return os.Remove(x.file.Name())
+}
+
+func (x *extraction) detectFormat(format string) (string, error) {
+	switch format {
+	case ArchiveTarGz, ArchiveTar, ArchiveZip:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("%w: %s", ErrArchiveFormat, format)
+	}
+
+	head := make([]byte, 512)
+	n, _ := x.file.ReadAt(head, 0)
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return ArchiveTarGz, nil
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ArchiveZip, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return ArchiveTar, nil
+	}
+	return "", fmt.Errorf("%w: expected a tar.gz, tar or zip archive", ErrArchiveFormat)
+}
+
+// list reads the entries, checking their names and the limits, and works out
+// the paths the extraction changes.
+func (x *extraction) list() error {
+	var size int64
+	files := map[string]bool{}
+	symlinks := map[string]bool{}
+	err := x.each(func(e archiveEntry, _ io.Reader) error {
+		if len(x.entries) >= x.maxFiles {
+			return fmt.Errorf("%w: more than %d entries", ErrArchiveLimit, x.maxFiles)
+		}
+		if size += e.size; size > x.maxSize {
+			return fmt.Errorf("%w: more than %d bytes", ErrArchiveLimit, x.maxSize)
+		}
+		for dir := path.Dir(e.name); dir != "."; dir = path.Dir(dir) {
+			if symlinks[dir] {
+				return fmt.Errorf("%w: %s is below the symlink %s", ErrUnsafeArchive, e.name, dir)
+			}
+		}
+		switch e.kind {
+		case entryFile:
+			files[e.name] = true
+		case entrySymlink:
+			symlinks[e.name] = true
+		case entryHardlink:
+			if !files[e.link] {
+				return fmt.Errorf("invalid archive: hard link %s to %s, which is not a file before it", e.name, e.link)
+			}
+		}
+		x.entries = append(x.entries, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := x.checkSymlinks(); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, e := range x.entries {
+		p, err := x.changedPath(e)
+		if err != nil {
+			return err
+		}
+		if p != "" && !seen[p] {
+			seen[p] = true
+			x.paths = append(x.paths, p)
+		}
+	}
+	return nil
+}
+
+// maxSymlinkFollows bounds the symlinks followed resolving one target, which
+// also stops loops.
+const maxSymlinkFollows = 40
+
+// checkSymlinks refuses symlinks whose targets lead out of the destination
+// once resolved through the other symlinks of the archive. Checking a target
+// as text would miss s -> x/.. next to x -> ., which points to the parent of
+// the destination. Entries replace earlier ones of the same name, so the
+// symlinks are resolved as they are after the extraction.
+func (x *extraction) checkSymlinks() error {
+	links := map[string]string{}
+	for _, e := range x.entries {
+		if e.kind == entrySymlink {
+			links[e.name] = e.link
+		} else {
+			delete(links, e.name)
+		}
+	}
+	for name, link := range links {
+		if !resolvesInside(links, name) {
+			return fmt.Errorf("%w: symlink %s points to %s", ErrUnsafeArchive, name, link)
+		}
+	}
+	return nil
+}
+
+// resolvesInside reports whether the symlink name, resolved through links,
+// stays inside the destination.
+func resolvesInside(links map[string]string, name string) bool {
+	var resolved []string
+	pending := append(strings.Split(path.Dir(name), "/"), strings.Split(links[name], "/")...)
+	follows := 0
+	for len(pending) > 0 {
+		c := pending[0]
+		pending = pending[1:]
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return false
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		resolved = append(resolved, c)
+		link, ok := links[strings.Join(resolved, "/")]
+		if !ok {
+			continue
+		}
+		if path.IsAbs(link) || follows >= maxSymlinkFollows {
+			return false
+		}
+		follows++
+		resolved = resolved[:len(resolved)-1]
+		pending = append(strings.Split(link, "/"), pending...)
+	}
+	return true
+}
+
+// changedPath returns the path undoing the entry restores: the topmost
+// directory the extraction creates, or the entry itself. Directories that
+// already exist are left out. Entries below a symlink already in the
+// destination are refused.
+func (x *extraction) changedPath(e archiveEntry) (string, error) {
+	p := x.dest
+	components := strings.Split(e.name, "/")
+	for i := -1; i < len(components); i++ {
+		if i >= 0 {
+			p = filepath.Join(p, components[i])
+		}
+		info, err := os.Lstat(p)
+		if err != nil {
+			return p, nil
+		}
+		last := i == len(components)-1
+		if last && e.kind == entryDir && info.IsDir() {
+			return "", nil
+		}
+		if !last && i >= 0 && info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("%w: %s is below the symlink %s", ErrUnsafeArchive, e.name, p)
+		}
+	}
+	return p, nil
+}
+
+// each calls fn for every entry of the archive that is extracted, in order,
+// with a reader for the content of files.
+func (x *extraction) each(fn func(e archiveEntry, r io.Reader) error) error {
+	if x.format == ArchiveZip {
+		return x.eachZip(fn)
+	}
+
+	var r io.Reader = io.NewSectionReader(x.file, 0, x.size)
+	if x.format == ArchiveTarGz {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return archiveError(err)
+		}
+
+		e := archiveEntry{
+			mode:    fs.FileMode(hdr.Mode).Perm(),
+			modTime: hdr.ModTime,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			e.kind, e.size = entryFile, hdr.Size
+		case tar.TypeDir:
+			e.kind = entryDir
+		case tar.TypeSymlink:
+			e.kind, e.link = entrySymlink, hdr.Linkname
+		case tar.TypeLink:
+			e.kind, e.link = entryHardlink, hdr.Linkname
+		default:
+			// Devices, FIFOs and the like are not extracted.
+			continue
+		}
+		ok, err := x.resolve(&e, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := fn(e, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (x *extraction) eachZip(fn func(e archiveEntry, r io.Reader) error) error {
+	zr, err := zip.NewReader(x.file, x.size)
+	if err != nil {
+		return archiveError(err)
+	}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		e := archiveEntry{mode: mode.Perm(), modTime: f.Modified}
+		switch {
+		case mode.IsDir():
+			e.kind = entryDir
+		case mode&fs.ModeSymlink != 0:
+			e.kind = entrySymlink
+		case mode.IsRegular():
+			e.kind, e.size = entryFile, int64(f.UncompressedSize64)
+		default:
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return archiveError(err)
+		}
+		if e.kind == entrySymlink {
+			link, err := io.ReadAll(io.LimitReader(rc, maxSymlinkTarget))
+			if err != nil {
+				rc.Close()
+				return archiveError(err)
+			}
+			e.link = string(link)
+		}
+		ok, err := x.resolve(&e, f.Name)
+		if err == nil && ok {
+			err = fn(e, rc)
+		}
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func archiveError(err error) error {
+	if errors.Is(err, tar.ErrInsecurePath) || errors.Is(err, zip.ErrInsecurePath) {
+		return fmt.Errorf("%w: %v", ErrUnsafeArchive, err)
+	}
+	return fmt.Errorf("invalid archive: %w", err)
+}
+
+// resolve sets the name of the entry from its name in the archive, and
+// reports false for entries stripped away. Names and links leading out of
+// the destination are refused; links through other symlinks are checked by
+// checkSymlinks once all entries are known.
+func (x *extraction) resolve(e *archiveEntry, name string) (bool, error) {
+	var ok bool
+	var err error
+	if e.name, ok, err = x.entryName(name); err != nil || !ok {
+		return false, err
+	}
+
+	switch e.kind {
+	case entrySymlink:
+		target := path.Join(path.Dir(e.name), e.link)
+		if path.IsAbs(e.link) || escapes(target) {
+			return false, fmt.Errorf("%w: symlink %s points to %s", ErrUnsafeArchive, e.name, e.link)
+		}
+	case entryHardlink:
+		if e.link, ok, err = x.entryName(e.link); err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, fmt.Errorf("invalid archive: hard link %s points to a stripped entry", e.name)
+		}
+	}
+	return true, nil
+}
+
+func (x *extraction) entryName(name string) (string, bool, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(name) || escapes(clean) {
+		return "", false, fmt.Errorf("%w: %s", ErrUnsafeArchive, name)
+	}
+	if x.strip > 0 {
+		components := strings.Split(clean, "/")
+		if clean == "." || len(components) <= x.strip {
+			return "", false, nil
+		}
+		clean = strings.Join(components[x.strip:], "/")
+	}
+	return clean, clean != ".", nil
+}
+
+func escapes(name string) bool {
+	return name == ".." || strings.HasPrefix(name, "../")
+}
+
+func (x *extraction) extract() (*ExtractResult, error) {
+	if err := os.MkdirAll(x.dest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	result := &ExtractResult{Path: x.dest}
+	err := x.each(func(e archiveEntry, r io.Reader) error {
+		target := filepath.Join(x.dest, filepath.FromSlash(e.name))
+		if err := x.checkParents(e.name); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		if e.kind == entryDir {
+			result.Dirs++
+			if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+				if err := os.Remove(target); err != nil {
+					return err
+				}
+			}
+			// Directories stay writable so their entries can follow.
+			if err := os.MkdirAll(target, e.mode|0700); err != nil {
+				return err
+			}
+			return os.Chmod(target, e.mode|0700)
+		}
+
+		// Existing files are replaced rather than written through, which
+		// would follow symlinks and change other hard links.
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		result.Files++
+		switch e.kind {
+		case entrySymlink:
+			return os.Symlink(e.link, target)
+		case entryHardlink:
+			if err := x.checkParents(e.link); err != nil {
+				return err
+			}
+			return os.Link(filepath.Join(x.dest, filepath.FromSlash(e.link)), target)
+		}
+
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, e.mode)
+		if err != nil {
+			return err
+		}
+		// The tar and zip readers stop at the size the entry declared.
+		n, err := io.Copy(f, r)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		result.Size += n
+		if !e.modTime.IsZero() {
+			os.Chtimes(target, e.modTime, e.modTime)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract archive: %w", err)
+	}
+	return result, nil
+}
+
+// checkParents refuses entries below a symlink. The archive was checked for
+// them already; this catches symlinks created since.
+func (x *extraction) checkParents(name string) error {
+	p := x.dest
+	components := strings.Split(name, "/")
+	for _, c := range components[:len(components)-1] {
+		p = filepath.Join(p, c)
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s is below the symlink %s", ErrUnsafeArchive, name, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FileTypeText     = "text"
+	FileTypeImage    = "image"
+	FileTypePDF      = "pdf"
+	FileTypeNotebook = "notebook"
+	FileTypeBinary   = "binary"
+)
+
+// ErrBinaryFile is returned when reading a binary file that is not an image
+// or a PDF as text.
+var ErrBinaryFile = errors.New("cannot read binary file as text")
+
+// sniffLen is how much of a file is inspected to detect its type, as in
+// http.DetectContentType.
+const sniffLen = 512
+
+// DetectFileType returns the type of the file at path, one of the FileType
+// constants, and its MIME type.
+func (m *Manager) DetectFileType(path string) (string, string, error) {
+	type detected struct{ fileType, mimeType string }
+	d, err := runAs(m, func() (detected, error) {
+		absPath, err := m.validateReadPath(path)
+		if err != nil {
+			return detected{}, err
+		}
+		head, err := readHead(absPath)
+		if err != nil {
+			return detected{}, err
+		}
+		fileType, mimeType := detectFileType(absPath, head)
+		return detected{fileType, mimeType}, nil
+	})
+	return d.fileType, d.mimeType, err
+}
+
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return head[:n], nil
+}
+
+func detectFileType(path string, head []byte) (string, string) {
+	if strings.EqualFold(filepath.Ext(path), ".ipynb") {
+		return FileTypeNotebook, "application/x-ipynb+json"
+	}
+
+	mimeType := http.DetectContentType(head)
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return FileTypeImage, mimeType
+	case mimeType == "application/pdf":
+		return FileTypePDF, mimeType
+	case strings.HasPrefix(mimeType, "text/"):
+		return FileTypeText, mimeType
+	case mimeType == "application/octet-stream" && bytes.IndexByte(head, 0) < 0:
+		// Control characters such as terminal escapes make
+		// DetectContentType give up on text, but text in any
+		// ASCII-compatible encoding has no NUL bytes.
+		return FileTypeText, "text/plain"
+	}
+	return FileTypeBinary, mimeType
+}
+
+func binaryFileError(path, mimeType string) error {
+	return fmt.Errorf("%w: %s is %s", ErrBinaryFile, path, mimeType)
+}
@@ -0,0 +1,49 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFileType(t *testing.T) {
+	m := NewManager()
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "a.png"), 1, 1)
+	files := map[string][]byte{
+		"a.txt":      []byte("hello\n"),
+		"color.log":  []byte("\x1b[31merror\x1b[0m\n"),
+		"doc.pdf":    []byte("%PDF-1.4\n"),
+		"nb.ipynb":   []byte(`{"cells": []}`),
+		"a.zip":      []byte("PK\x03\x04\x00\x00"),
+		"blob.bin":   {0x01, 0x00, 0x02},
+		"empty.file": {},
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(dir, name), content, 0644)
+	}
+
+	want := map[string]string{
+		"a.png":      FileTypeImage,
+		"a.txt":      FileTypeText,
+		"color.log":  FileTypeText,
+		"doc.pdf":    FileTypePDF,
+		"nb.ipynb":   FileTypeNotebook,
+		"a.zip":      FileTypeBinary,
+		"blob.bin":   FileTypeBinary,
+		"empty.file": FileTypeText,
+	}
+	for name, fileType := range want {
+		got, _, err := m.DetectFileType(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("DetectFileType(%s) error = %v", name, err)
+		}
+		if got != fileType {
+			t.Errorf("DetectFileType(%s) = %s, want %s", name, got, fileType)
+		}
+	}
+
+	if _, err := m.ReadFileWithOptions(filepath.Join(dir, "a.zip"), ReadOptions{}); err == nil {
+		t.Error("expected reading a binary file as text to fail")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
 )
 
 type GlobOptions struct {
@@ -34,10 +35,18 @@ func (m *Manager) Glob(opts GlobOptions) (*GlobResult, error) {
 }
 
 func (m *Manager) GlobWithContext(ctx context.Context, opts GlobOptions) (*GlobResult, error) {
+	return runAs(m, func() (*GlobResult, error) { return m.globWithContext(ctx, opts) })
+}
+
+func (m *Manager) globWithContext(ctx context.Context, opts GlobOptions) (*GlobResult, error) {
 	searchPath := opts.Path
 	if searchPath == "" {
 		searchPath = "."
 	}
+	searchPath, err := m.validateReadPath(searchPath)
+	if err != nil {
+		return nil, err
+	}
 
 	result, err := m.globWithRipgrep(ctx, searchPath, opts.Pattern, opts.Limit)
 	if err != nil {
@@ -62,16 +71,26 @@ func (m *Manager) globWithRipgrep(ctx context.Context, searchPath, pattern strin
 		}
 	}
 
-	args := []string{
-		"--files",
-		"--hidden",
-		"--follow",
+	// A pattern prefix like "../" must not lead out of a confined workspace,
+	// and links are not followed there so listings stay inside it too.
+	if _, err := m.validateReadPath(effectiveSearchPath); err != nil {
+		return nil, err
+	}
+
+	args := []string{"--files", "--hidden"}
+	if m.root == "" {
+		args = append(args, "--follow")
+	}
+	args = append(args,
 		"--no-messages",
 		"--glob", effectivePattern,
 		effectiveSearchPath,
-	}
+	)
 
 	cmd := exec.CommandContext(ctx, "rg", args...)
+	if m.user != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: m.user.Credential()}
+	}
 	output, err := cmd.Output()
 
 	if err != nil {
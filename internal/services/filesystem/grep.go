@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 type GrepOptions struct {
@@ -38,10 +39,18 @@ type GrepResult struct {
 }
 
 func (m *Manager) Grep(ctx context.Context, opts GrepOptions) (*GrepResult, error) {
+	return runAs(m, func() (*GrepResult, error) { return m.grep(ctx, opts) })
+}
+
+func (m *Manager) grep(ctx context.Context, opts GrepOptions) (*GrepResult, error) {
 	searchPath := opts.Path
 	if searchPath == "" {
 		searchPath = "."
 	}
+	searchPath, err := m.validateReadPath(searchPath)
+	if err != nil {
+		return nil, err
+	}
 
 	var args []string
 	args = append(args, "--color=never", "--no-messages")
@@ -69,6 +78,9 @@ func (m *Manager) Grep(ctx context.Context, opts GrepOptions) (*GrepResult, erro
 	args = append(args, "--regexp", opts.Pattern, searchPath)
 
 	cmd := exec.CommandContext(ctx, "rg", args...)
+	if m.user != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: m.user.Credential()}
+	}
 	output, err := cmd.Output()
 
 	exitCode := 0
@@ -0,0 +1,486 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	OpWrite   = "write"
+	OpEdit    = "edit"
+	OpDelete  = "delete"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpPatch   = "patch"
+	OpExtract = "extract"
+)
+
+const (
+	stateFile    = "file"
+	stateDir     = "dir"
+	stateSymlink = "symlink"
+
+	defaultSessionKey = "default"
+	journalFile       = "journal.json"
+	blobsDir          = "blobs"
+)
+
+var (
+	ErrHistoryDisabled = errors.New("file history is disabled")
+	ErrChangeNotFound  = errors.New("change not found")
+	ErrUndoConflict    = errors.New("undo conflict")
+)
+
+// FileState is a path as it was before or after a change. Content is kept in
+// the history by hash for the states a change may have to restore.
+type FileState struct {
+	Path   string      `json:"path"`
+	Exists bool        `json:"exists"`
+	Type   string      `json:"type,omitempty"`
+	Mode   fs.FileMode `json:"mode,omitempty"`
+	Size   int64       `json:"size,omitempty"`
+	Hash   string      `json:"hash,omitempty"`
+	Target string      `json:"target,omitempty"`
+}
+
+// Change is one journaled file operation. Before holds what undoing it
+// restores, After what the paths must still look like for the undo to be
+// safe.
+type Change struct {
+	ID   string `json:"id"`
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Dest string `json:"dest,omitempty"`
+	// Paths lists every file changed by a patch or an extraction; Path is
+	// the first of them.
+	Paths  []string    `json:"paths,omitempty"`
+	Time   time.Time   `json:"time"`
+	Before []FileState `json:"before"`
+	After  []FileState `json:"after"`
+	// Incomplete explains why the previous state could not be kept, which
+	// makes the change impossible to undo.
+	Incomplete string `json:"incomplete,omitempty"`
+	Undone     bool   `json:"undone,omitempty"`
+}
+
+// touches reports whether the change affected path. A copy leaves its source
+// as it was.
+func (c *Change) touches(path string) bool {
+	if c.Op == OpCopy {
+		return c.Dest == path
+	}
+	for _, p := range c.Paths {
+		if p == path {
+			return true
+		}
+	}
+	return c.Path == path || c.Dest == path
+}
+
+type journal struct {
+	Seq     int      `json:"seq"`
+	Changes []Change `json:"changes"`
+}
+
+type HistoryOptions struct {
+	// MaxEntries is the number of changes kept per session and MaxSize the
+	// largest total content kept for one change.
+	MaxEntries int
+	MaxSize    int64
+}
+
+// History journals file changes per session so they can be undone. It lives
+// outside the workspaces, readable only by the server; the server and the MCP
+// hub may share one directory, which is locked with flock while it changes.
+type History struct {
+	mu         sync.Mutex
+	dir        string
+	maxEntries int
+	maxSize    int64
+	lock       *os.File
+}
+
+func NewHistory(dir string, opts HistoryOptions) (*History, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create file history directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to restrict file history directory: %w", err)
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file history lock file: %w", err)
+	}
+	return &History{
+		dir:        dir,
+		maxEntries: opts.MaxEntries,
+		maxSize:    opts.MaxSize,
+		lock:       lock,
+	}, nil
+}
+
+func (h *History) Close() error {
+	if h == nil {
+		return nil
+	}
+	return h.lock.Close()
+}
+
+func (h *History) withLock(fn func() error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := syscall.Flock(int(h.lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock file history: %w", err)
+	}
+	defer syscall.Flock(int(h.lock.Fd()), syscall.LOCK_UN)
+	return fn()
+}
+
+func (h *History) sessionDir(sessionID string) string {
+	if sessionID == "" {
+		sessionID = defaultSessionKey
+	}
+	return filepath.Join(h.dir, hashBytes([]byte(sessionID))[:16])
+}
+
+func (h *History) load(sessionID string) (*journal, error) {
+	data, err := os.ReadFile(filepath.Join(h.sessionDir(sessionID), journalFile))
+	if os.IsNotExist(err) {
+		return &journal{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file history: %w", err)
+	}
+	var j journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("invalid file history: %w", err)
+	}
+	return &j, nil
+}
+
+func (h *History) save(sessionID string, j *journal) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("failed to encode file history: %w", err)
+	}
+	dir := h.sessionDir(sessionID)
+	tmp, err := os.CreateTemp(dir, journalFile+".")
+	if err != nil {
+		return fmt.Errorf("failed to save file history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save file history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save file history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, journalFile)); err != nil {
+		return fmt.Errorf("failed to save file history: %w", err)
+	}
+	return nil
+}
+
+// record adds a change with the contents of its before states.
+func (h *History) record(sessionID string, change *Change, blobs map[string][]byte) error {
+	return h.withLock(func() error {
+		dir := h.sessionDir(sessionID)
+		if err := os.MkdirAll(filepath.Join(dir, blobsDir), 0700); err != nil {
+			return fmt.Errorf("failed to create file history: %w", err)
+		}
+		j, err := h.load(sessionID)
+		if err != nil {
+			return err
+		}
+
+		for hash, content := range blobs {
+			blob := filepath.Join(dir, blobsDir, hash)
+			if _, err := os.Stat(blob); err == nil {
+				continue
+			}
+			if err := os.WriteFile(blob, content, 0600); err != nil {
+				return fmt.Errorf("failed to save previous content: %w", err)
+			}
+		}
+
+		j.Seq++
+		change.ID = fmt.Sprintf("change_%d", j.Seq)
+		change.Time = time.Now()
+		j.Changes = append(j.Changes, *change)
+		if h.maxEntries > 0 && len(j.Changes) > h.maxEntries {
+			j.Changes = j.Changes[len(j.Changes)-h.maxEntries:]
+		}
+		if err := h.save(sessionID, j); err != nil {
+			return err
+		}
+		return h.gc(sessionID, j)
+	})
+}
+
+// gc removes the contents no change of the session refers to.
+func (h *History) gc(sessionID string, j *journal) error {
+	referenced := map[string]bool{}
+	for _, c := range j.Changes {
+		for _, s := range c.Before {
+			referenced[s.Hash] = true
+		}
+	}
+	blobs, err := os.ReadDir(filepath.Join(h.sessionDir(sessionID), blobsDir))
+	if err != nil {
+		return nil
+	}
+	for _, blob := range blobs {
+		if !referenced[blob.Name()] {
+			os.Remove(filepath.Join(h.sessionDir(sessionID), blobsDir, blob.Name()))
+		}
+	}
+	return nil
+}
+
+// Changes returns the changes of a session that touched path, or all of them
+// when path is empty, newest first.
+func (h *History) Changes(sessionID, path string, limit int) ([]Change, error) {
+	j, err := h.load(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	changes := []Change{}
+	for i := len(j.Changes) - 1; i >= 0; i-- {
+		c := j.Changes[i]
+		if path != "" && !c.touches(path) {
+			continue
+		}
+		changes = append(changes, c)
+		if limit > 0 && len(changes) == limit {
+			break
+		}
+	}
+	return changes, nil
+}
+
+func (h *History) readBlob(sessionID, hash string) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(h.sessionDir(sessionID), blobsDir, hash))
+	if err != nil {
+		return nil, fmt.Errorf("previous content is missing from the history: %w", err)
+	}
+	return content, nil
+}
+
+// capture collects states and, when wanted, their contents. It runs with the
+// session user's credentials, so contents are held in memory until the change
+// is recorded.
+type capture struct {
+	maxSize    int64
+	size       int64
+	blobs      map[string][]byte
+	incomplete string
+}
+
+func newCapture(maxSize int64) *capture {
+	return &capture{maxSize: maxSize, blobs: map[string][]byte{}}
+}
+
+// states returns the state of path and, for a directory whose content is
+// wanted, of everything below it. A directory too large to keep is not read.
+func (c *capture) states(path string, content bool) ([]FileState, error) {
+	state, err := c.state(path, content)
+	if err != nil || !content || state.Type != stateDir || !c.fits(path) {
+		return []FileState{state}, err
+	}
+
+	var states []FileState
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		s, err := c.state(p, true)
+		if err != nil {
+			return err
+		}
+		states = append(states, s)
+		return nil
+	})
+	return states, err
+}
+
+func (c *capture) state(path string, content bool) (FileState, error) {
+	state := FileState{Path: path}
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	state.Exists = true
+	state.Mode = info.Mode().Perm()
+
+	switch {
+	case info.IsDir():
+		state.Type = stateDir
+	case info.Mode()&fs.ModeSymlink != 0:
+		state.Type = stateSymlink
+		state.Target, err = os.Readlink(path)
+	case info.Mode().IsRegular():
+		state.Type = stateFile
+		state.Size = info.Size()
+		if content {
+			state.Hash, err = c.keep(path, info.Size())
+		} else {
+			state.Hash, err = hashFile(path)
+		}
+	default:
+		c.incomplete = fmt.Sprintf("%s is not a regular file, directory or symlink", path)
+	}
+	return state, err
+}
+
+// fits reports whether the regular files below dir fit in the size limit,
+// walking only the directory entries and stopping once they do not.
+func (c *capture) fits(dir string) bool {
+	if c.maxSize <= 0 {
+		return true
+	}
+	size := c.size
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		// Unreadable entries fail the capture itself.
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		if size > c.maxSize {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if size > c.maxSize {
+		c.tooLarge()
+		return false
+	}
+	return true
+}
+
+// keep hashes a file and holds its content unless the change would exceed
+// the size limit, in which case the file is not read.
+func (c *capture) keep(path string, size int64) (string, error) {
+	if c.maxSize > 0 && c.size+size > c.maxSize {
+		c.tooLarge()
+		return "", nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	hash := hashBytes(content)
+	c.size += int64(len(content))
+	c.blobs[hash] = content
+	return hash, nil
+}
+
+func (c *capture) tooLarge() {
+	if c.incomplete == "" {
+		c.incomplete = fmt.Sprintf("previous content is larger than %d bytes", c.maxSize)
+	}
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// sameState reports whether the path still looks like the recorded state.
+// Directories are compared by type only.
+func sameState(recorded FileState) (bool, error) {
+	current, err := newCapture(0).state(recorded.Path, false)
+	if err != nil {
+		return false, err
+	}
+	return current.Exists == recorded.Exists &&
+		current.Type == recorded.Type &&
+		current.Hash == recorded.Hash &&
+		current.Target == recorded.Target, nil
+}
+
+// restoreState puts a path back into a recorded state; contents maps hashes
+// to file contents.
+func restoreState(s FileState, contents map[string][]byte) error {
+	if !s.Exists {
+		return os.RemoveAll(s.Path)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return err
+	}
+
+	switch s.Type {
+	case stateDir:
+		if info, err := os.Lstat(s.Path); err == nil && !info.IsDir() {
+			if err := os.Remove(s.Path); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(s.Path, s.Mode); err != nil {
+			return err
+		}
+		return os.Chmod(s.Path, s.Mode)
+	case stateSymlink:
+		if err := os.RemoveAll(s.Path); err != nil {
+			return err
+		}
+		return os.Symlink(s.Target, s.Path)
+	default:
+		return replaceFile(s.Path, contents[s.Hash], s.Mode)
+	}
+}
+
+// replaceFile atomically replaces path with content.
+func replaceFile(path string, content []byte, mode fs.FileMode) error {
+	if info, err := os.Lstat(path); err == nil && info.IsDir() {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".undo-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,204 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+)
+
+func newHistoryManager(t *testing.T, opts HistoryOptions) (*Manager, string) {
+	t.Helper()
+	base := t.TempDir()
+	history, err := NewHistory(filepath.Join(base, "history"), opts)
+	if err != nil {
+		t.Fatalf("failed to create history: %v", err)
+	}
+	t.Cleanup(func() { history.Close() })
+
+	workspace := filepath.Join(base, "workspace")
+	os.MkdirAll(workspace, 0755)
+	return NewManager(WithHistory(history)), workspace
+}
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestHistory_UndoWriteAndEdit(t *testing.T) {
+	m, workspace := newHistoryManager(t, HistoryOptions{})
+	file := filepath.Join(workspace, "main.go")
+
+	if err := m.WriteFile(file, "package main\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.EditFile(file, "main", "app", EditOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := m.History(file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Op != OpEdit || changes[1].Op != OpWrite {
+		t.Fatalf("expected edit then write, got %+v", changes)
+	}
+
+	undone, err := m.Undo(UndoOptions{})
+	if err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if len(undone) != 1 || undone[0].ID != changes[0].ID {
+		t.Errorf("expected the edit to be undone, got %+v", undone)
+	}
+	if got := readString(t, file); got != "package main\n" {
+		t.Errorf("expected content before the edit, got %q", got)
+	}
+
+	if _, err := m.Undo(UndoOptions{Path: file}); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("expected undoing the write to remove the created file")
+	}
+
+	if _, err := m.Undo(UndoOptions{}); !errors.Is(err, ErrChangeNotFound) {
+		t.Errorf("expected ErrChangeNotFound with nothing left to undo, got %v", err)
+	}
+}
+
+func TestHistory_UndoDeleteAndMove(t *testing.T) {
+	m, workspace := newHistoryManager(t, HistoryOptions{})
+	dir := filepath.Join(workspace, "pkg")
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.go"), []byte("b"), 0600)
+
+	if err := m.DeleteFile(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Undo(UndoOptions{}); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if got := readString(t, filepath.Join(dir, "sub", "b.go")); got != "b" {
+		t.Errorf("expected deleted file to be restored, got %q", got)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "sub", "b.go")); info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600 to be restored, got %v", info.Mode().Perm())
+	}
+
+	src := filepath.Join(dir, "a.go")
+	dst := filepath.Join(workspace, "b.go")
+	os.WriteFile(dst, []byte("replaced"), 0644)
+	if err := m.MoveFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Undo(UndoOptions{}); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if got := readString(t, src); got != "a" {
+		t.Errorf("expected file to be moved back, got %q", got)
+	}
+	if got := readString(t, dst); got != "replaced" {
+		t.Errorf("expected replaced destination to be restored, got %q", got)
+	}
+}
+
+func TestHistory_UndoConflict(t *testing.T) {
+	m, workspace := newHistoryManager(t, HistoryOptions{})
+	file := filepath.Join(workspace, "notes.txt")
+	os.WriteFile(file, []byte("v1"), 0644)
+
+	if err := m.WriteFile(file, "v2"); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(file, []byte("changed outside"), 0644)
+
+	changes, _ := m.History(file, 1)
+	if _, err := m.Undo(UndoOptions{ID: changes[0].ID}); !errors.Is(err, ErrUndoConflict) {
+		t.Fatalf("expected ErrUndoConflict, got %v", err)
+	}
+	if got := readString(t, file); got != "changed outside" {
+		t.Errorf("expected conflicting file to be left alone, got %q", got)
+	}
+
+	if _, err := m.Undo(UndoOptions{ID: changes[0].ID, Force: true}); err != nil {
+		t.Fatalf("forced undo failed: %v", err)
+	}
+	if got := readString(t, file); got != "v1" {
+		t.Errorf("expected forced undo to restore v1, got %q", got)
+	}
+	if _, err := m.Undo(UndoOptions{ID: changes[0].ID}); err == nil {
+		t.Error("expected error undoing a change twice")
+	}
+}
+
+func TestHistory_Limits(t *testing.T) {
+	m, workspace := newHistoryManager(t, HistoryOptions{MaxEntries: 2, MaxSize: 4})
+	file := filepath.Join(workspace, "big.txt")
+	os.WriteFile(file, []byte("too large"), 0644)
+
+	if err := m.WriteFile(file, "small"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Undo(UndoOptions{}); err == nil {
+		t.Error("expected undo to fail when the previous content exceeded the size limit")
+	}
+
+	dir := filepath.Join(workspace, "pkg")
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("abc"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.go"), []byte("de"), 0644)
+	if err := m.DeleteFile(dir); err != nil {
+		t.Fatal(err)
+	}
+	changes, _ := m.History(dir, 1)
+	if len(changes) != 1 || changes[0].Incomplete == "" || len(changes[0].Before) != 1 {
+		t.Errorf("expected a directory over the size limit to be recorded without its files, got %+v", changes)
+	}
+
+	for _, content := range []string{"a", "b", "c"} {
+		if err := m.WriteFile(file, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes, _ = m.History("", 0)
+	if len(changes) != 2 {
+		t.Fatalf("expected history to keep 2 changes, got %d", len(changes))
+	}
+	if _, err := m.Undo(UndoOptions{Count: 2}); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if got := readString(t, file); got != "a" {
+		t.Errorf("expected content after the oldest kept change, got %q", got)
+	}
+}
+
+func TestHistory_PerSession(t *testing.T) {
+	m, workspace := newHistoryManager(t, HistoryOptions{})
+	file := filepath.Join(workspace, "shared.txt")
+
+	alice, _ := m.ForContext(ctxutil.WithSessionID(context.Background(), "alice"))
+	bob, _ := m.ForContext(ctxutil.WithSessionID(context.Background(), "bob"))
+	if err := alice.WriteFile(file, "from alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if changes, _ := bob.History("", 0); len(changes) != 0 {
+		t.Errorf("expected other sessions to have no history, got %+v", changes)
+	}
+	if _, err := bob.Undo(UndoOptions{}); !errors.Is(err, ErrChangeNotFound) {
+		t.Errorf("expected ErrChangeNotFound in another session, got %v", err)
+	}
+	if changes, _ := alice.History("", 0); len(changes) != 1 {
+		t.Errorf("expected 1 change in the session, got %d", len(changes))
+	}
+}
@@ -0,0 +1,200 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// pathPattern is a pattern in .gitignore syntax: "*" and "?" stay within a
+// path component, "**" spans components, a pattern without a slash matches
+// a name at any depth and a trailing slash only matches directories.
+type pathPattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+func compilePattern(pattern string) (pathPattern, error) {
+	var p pathPattern
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	var expr strings.Builder
+	if !strings.Contains(pattern, "/") {
+		expr.WriteString("^(?:.*/)?")
+	} else {
+		pattern = strings.TrimPrefix(pattern, "/")
+		expr.WriteString("^")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if !strings.HasPrefix(pattern[i:], "**") {
+				expr.WriteString("[^/]*")
+				continue
+			}
+			i++
+			switch {
+			case strings.HasPrefix(pattern[i+1:], "/"):
+				// "**/" matches zero or more directories.
+				expr.WriteString("(?:.*/)?")
+				i++
+			default:
+				expr.WriteString(".*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return p, fmt.Errorf("%w %q: unterminated [", ErrInvalidPattern, pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return p, fmt.Errorf("%w %q: %v", ErrInvalidPattern, pattern, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// match reports whether the slash-separated relative path matches.
+func (p pathPattern) match(rel string, isDir bool) bool {
+	return (isDir || !p.dirOnly) && p.re.MatchString(rel)
+}
+
+func compilePatterns(patterns []string) ([]pathPattern, error) {
+	compiled := make([]pathPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		p, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, p)
+	}
+	return compiled, nil
+}
+
+func matchAny(patterns []pathPattern, rel string, isDir bool) bool {
+	for _, p := range patterns {
+		if p.match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyParent is matchAny for a file, which also matches through any
+// directory above it.
+func matchAnyParent(patterns []pathPattern, rel string) bool {
+	if matchAny(patterns, rel, false) {
+		return true
+	}
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if matchAny(patterns, dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreFile holds the rules of one .gitignore, which apply to paths below
+// the directory dir it was found in.
+type ignoreFile struct {
+	dir   string
+	rules []pathPattern
+}
+
+func parseIgnoreFile(dir string, content []byte) ignoreFile {
+	f := ignoreFile{dir: dir}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Invalid lines are ignored, as git does.
+		if p, err := compilePattern(line); err == nil {
+			f.rules = append(f.rules, p)
+		}
+	}
+	return f
+}
+
+// ignored applies the .gitignore files from the root down to the directory
+// of rel; the last rule matching rel decides.
+func ignored(files []ignoreFile, rel string, isDir bool) bool {
+	result := false
+	for _, f := range files {
+		sub := rel
+		if f.dir != "." {
+			var ok bool
+			if sub, ok = strings.CutPrefix(rel, f.dir+"/"); !ok {
+				continue
+			}
+		}
+		for _, rule := range f.rules {
+			if rule.match(sub, isDir) {
+				result = !rule.negate
+			}
+		}
+	}
+	return result
+}
+
+// gitignores tracks the .gitignore files that apply in each directory of a
+// walk from the top down, keyed by slash-separated path relative to its root.
+type gitignores map[string][]ignoreFile
+
+// enter reads the .gitignore of the directory at p, rel from the root, for
+// the entries below it.
+func (g gitignores) enter(p, rel string) {
+	files := slices.Clip(g[path.Dir(rel)])
+	if content, err := os.ReadFile(filepath.Join(p, ".gitignore")); err == nil {
+		files = append(files, parseIgnoreFile(rel, content))
+	}
+	g[rel] = files
+}
+
+// ignored reports whether rel is ignored by the .gitignore files above it or
+// is a .git directory.
+func (g gitignores) ignored(rel string, isDir bool) bool {
+	return path.Base(rel) == ".git" || ignored(g[path.Dir(rel)], rel, isDir)
+}
@@ -0,0 +1,151 @@
+package filesystem
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// MaxImageFileSize bounds the images ReadImage loads, and maxImagePixels the
+// images it decodes to downscale them.
+const (
+	MaxImageFileSize = 32 << 20
+	maxImagePixels   = 50_000_000
+)
+
+type Image struct {
+	Data     []byte
+	MimeType string
+	// Width and Height are those of Data; the original dimensions differ
+	// when the image was downscaled.
+	Width          int
+	Height         int
+	OriginalWidth  int
+	OriginalHeight int
+}
+
+// ReadImage reads the image at path. PNG, JPEG and GIF images larger than
+// maxDimension on either side are downscaled to fit; zero keeps the original
+// size. Other formats are returned unchanged.
+func (m *Manager) ReadImage(path string, maxDimension int) (*Image, error) {
+	return runAs(m, func() (*Image, error) { return m.readImage(path, maxDimension) })
+}
+
+func (m *Manager) readImage(path string, maxDimension int) (*Image, error) {
+	absPath, err := m.validateReadPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if info.Size() > MaxImageFileSize {
+		return nil, fmt.Errorf("image is too large: %d bytes, at most %d", info.Size(), MaxImageFileSize)
+	}
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	m.markRead(absPath)
+
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("%s is not an image but %s", absPath, mimeType)
+	}
+	result := &Image{Data: data, MimeType: mimeType}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		// Formats the standard library cannot decode, such as WebP, are
+		// passed through as they are.
+		return result, nil
+	}
+	result.Width, result.Height = config.Width, config.Height
+	result.OriginalWidth, result.OriginalHeight = config.Width, config.Height
+
+	width, height := fitDimensions(config.Width, config.Height, maxDimension)
+	if width == config.Width && height == config.Height {
+		return result, nil
+	}
+
+	if config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("image is too large to downscale: %dx%d", config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	var buf bytes.Buffer
+	scaled := scaleImage(src, width, height)
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85})
+	} else {
+		result.MimeType = "image/png"
+		err = png.Encode(&buf, scaled)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	result.Data = buf.Bytes()
+	result.Width, result.Height = width, height
+	return result, nil
+}
+
+// fitDimensions scales width and height down, keeping the aspect ratio, so
+// neither exceeds limit.
+func fitDimensions(width, height, limit int) (int, int) {
+	if limit <= 0 || (width <= limit && height <= limit) {
+		return width, height
+	}
+	if width >= height {
+		return limit, max(1, height*limit/width)
+	}
+	return max(1, width*limit/height), limit
+}
+
+// scaleImage downscales src to width x height, averaging the source pixels
+// each destination pixel covers.
+func scaleImage(src image.Image, width, height int) *image.NRGBA {
+	bounds := src.Bounds()
+	in := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(in, in.Bounds(), src, bounds.Min, draw.Src)
+
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	srcW, srcH := in.Rect.Dx(), in.Rect.Dy()
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcH/height, max((y+1)*srcH/height, y*srcH/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcW/width, max((x+1)*srcW/width, x*srcW/width+1)
+			// Colors are weighted by alpha so transparent pixels do not
+			// bleed into their neighbours.
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := in.Pix[sy*in.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					alpha := int(p[3])
+					r += int(p[0]) * alpha
+					g += int(p[1]) * alpha
+					b += int(p[2]) * alpha
+					a += alpha
+					n++
+				}
+			}
+			o := out.Pix[y*out.Stride+x*4:]
+			if a > 0 {
+				o[0], o[1], o[2] = uint8(r/a), uint8(g/a), uint8(b/a)
+			}
+			o[3] = uint8(a / n)
+		}
+	}
+	return out
+}
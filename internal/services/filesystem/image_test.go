@@ -0,0 +1,79 @@
+package filesystem
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePNG(t *testing.T, path string, width, height int) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadImage(t *testing.T) {
+	m := NewManager()
+	file := filepath.Join(t.TempDir(), "wide.png")
+	writePNG(t, file, 400, 100)
+
+	img, err := m.ReadImage(file, 0)
+	if err != nil {
+		t.Fatalf("ReadImage() error = %v", err)
+	}
+	original, _ := os.ReadFile(file)
+	if !bytes.Equal(img.Data, original) || img.MimeType != "image/png" || img.Width != 400 {
+		t.Errorf("expected the original image without a limit, got %s %dx%d", img.MimeType, img.Width, img.Height)
+	}
+
+	img, err = m.ReadImage(file, 100)
+	if err != nil {
+		t.Fatalf("ReadImage() error = %v", err)
+	}
+	if img.Width != 100 || img.Height != 25 || img.OriginalWidth != 400 || img.OriginalHeight != 100 {
+		t.Errorf("expected 100x25 downscaled from 400x100, got %+v", img)
+	}
+	decoded, err := png.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatalf("failed to decode downscaled image: %v", err)
+	}
+	if r, g, b, a := decoded.At(50, 10).RGBA(); r>>8 != 255 || g != 0 || b != 0 || a>>8 != 255 {
+		t.Errorf("expected downscaling to keep the color, got %v %v %v %v", r, g, b, a)
+	}
+}
+
+func TestReadImage_JPEG(t *testing.T) {
+	m := NewManager()
+	file := filepath.Join(t.TempDir(), "photo.jpg")
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 50, 200)), nil)
+	os.WriteFile(file, buf.Bytes(), 0644)
+
+	img, err := m.ReadImage(file, 100)
+	if err != nil {
+		t.Fatalf("ReadImage() error = %v", err)
+	}
+	if img.MimeType != "image/jpeg" || img.Width != 25 || img.Height != 100 {
+		t.Errorf("expected a 25x100 JPEG, got %s %dx%d", img.MimeType, img.Width, img.Height)
+	}
+
+	text := filepath.Join(t.TempDir(), "fake.png")
+	os.WriteFile(text, []byte("not an image"), 0644)
+	if _, err := m.ReadImage(text, 0); err == nil {
+		t.Error("expected error for a file that is not an image")
+	}
+}
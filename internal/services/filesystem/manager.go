@@ -1,22 +1,255 @@
 package filesystem
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/deep-agent/sandbox/internal/services/identity"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
 )
 
-type Manager struct{}
+// ErrOutsideWorkspace is returned for paths that resolve outside the
+// workspace of a confined Manager.
+var ErrOutsideWorkspace = errors.New("path outside workspace")
+
+// maxSymlinks bounds symlink resolution like the kernel's ELOOP limit.
+const maxSymlinks = 40
+
+type Manager struct {
+	users *identity.Manager
+	user  *identity.User
+
+	confined      bool
+	workspace     string
+	readOnlyRoots []string
+	// root is the workspace paths are confined to; empty means unconfined.
+	root string
+
+	history *History
+	reads   *ReadTracker
+	session string
+
+	extractMaxSize    int64
+	extractMaxEntries int
+}
+
+type Option func(*Manager)
+
+// WithUsers makes ForContext run file operations as the session's dedicated
+// user.
+func WithUsers(users *identity.Manager) Option {
+	return func(m *Manager) {
+		m.users = users
+	}
+}
+
+// WithConfinement makes ForContext confine paths to the request's workspace,
+// or to workspace when the request has none. Paths under readOnlyRoots may
+// also be read, but not modified.
+func WithConfinement(workspace string, readOnlyRoots []string) Option {
+	return func(m *Manager) {
+		m.confined = true
+		m.workspace = workspace
+		m.readOnlyRoots = readOnlyRoots
+	}
+}
+
+// WithHistory journals changes made by Write, Edit, Delete, Move and Copy in
+// history, per session, so they can be undone.
+func WithHistory(history *History) Option {
+	return func(m *Manager) {
+		m.history = history
+	}
+}
+
+// WithReadTracker makes Write, Edit and MultiEdit refuse to change an
+// existing file unless the session read it, through Read or an earlier
+// write, and it was not modified since.
+func WithReadTracker(reads *ReadTracker) Option {
+	return func(m *Manager) {
+		m.reads = reads
+	}
+}
+
+// WithExtractLimits bounds the archives Extract unpacks to maxEntries entries
+// and maxSize bytes, compressed and uncompressed. Zero keeps the defaults.
+func WithExtractLimits(maxSize int64, maxEntries int) Option {
+	return func(m *Manager) {
+		m.extractMaxSize = maxSize
+		m.extractMaxEntries = maxEntries
+	}
+}
+
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// ForContext returns a Manager acting for the request in ctx: operations run
+// with the permissions of the session's user and are journaled in the
+// session's history and, with confinement, paths are resolved against and
+// restricted to the session workspace, which must lie inside the configured
+// one.
+func (m *Manager) ForContext(ctx context.Context) (*Manager, error) {
+	// Requests without a session act for the default one, as bash does.
+	sessionID := ctxutil.GetSessionIDFromCtx(ctx)
+	if sessionID == "" {
+		sessionID = defaultSessionKey
+	}
+	user, err := m.users.Lookup(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil && !m.confined && m.history == nil && m.reads == nil {
+		return m, nil
+	}
+
+	scoped := *m
+	scoped.user = user
+	scoped.session = sessionID
+	if m.confined {
+		if scoped.root, err = m.sessionWorkspace(ctxutil.GetCwd(ctx)); err != nil {
+			return nil, err
+		}
+	}
+	return &scoped, nil
+}
 
-func NewManager() *Manager {
-	return &Manager{}
+// sessionWorkspace cleans the workspace a request asked for, defaulting to the
+// configured one, and checks that it lies inside it.
+func (m *Manager) sessionWorkspace(workspace string) (string, error) {
+	if workspace == "" {
+		return m.workspace, nil
+	}
+	if !filepath.IsAbs(workspace) {
+		return "", fmt.Errorf("%w: workspace must be an absolute path: %s", ErrOutsideWorkspace, workspace)
+	}
+	workspace = filepath.Clean(workspace)
+	realPath, err := resolveSymlinks(workspace)
+	if err != nil || !isWithin(m.workspace, realPath) {
+		return "", fmt.Errorf("%w: workspace %s is outside %s", ErrOutsideWorkspace, workspace, m.workspace)
+	}
+	return workspace, nil
 }
 
+// run calls fn with the file system credentials of the manager's user.
+func (m *Manager) run(fn func() error) error {
+	return identity.RunAs(m.user, fn)
+}
+
+func runAs[T any](m *Manager, fn func() (T, error)) (T, error) {
+	var result T
+	err := m.run(func() error {
+		var err error
+		result, err = fn()
+		return err
+	})
+	return result, err
+}
+
+// validatePath returns the cleaned path for an operation that may modify it.
 func (m *Manager) validatePath(path string) (string, error) {
+	return m.resolvePath(path, false)
+}
+
+// validateReadPath returns the cleaned path for an operation that only reads
+// it, which is also allowed under the read-only roots.
+func (m *Manager) validateReadPath(path string) (string, error) {
+	return m.resolvePath(path, true)
+}
+
+func (m *Manager) resolvePath(path string, readOnly bool) (string, error) {
 	if path == "" {
 		return "", fmt.Errorf("invalid path: path cannot be empty")
 	}
+	if m.root == "" {
+		return filepath.Clean(path), nil
+	}
 
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.root, path)
+	}
 	cleanPath := filepath.Clean(path)
 
-	return cleanPath, nil
+	realPath, err := resolveSymlinks(cleanPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	if isWithin(m.root, realPath) {
+		return cleanPath, nil
+	}
+	for _, root := range m.readOnlyRoots {
+		if isWithin(root, realPath) {
+			if readOnly {
+				return cleanPath, nil
+			}
+			return "", fmt.Errorf("%w: %s is read-only", ErrOutsideWorkspace, cleanPath)
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrOutsideWorkspace, cleanPath)
+}
+
+// isWithin reports whether the symlink-free path lies inside root.
+func isWithin(root, realPath string) bool {
+	realRoot, err := resolveSymlinks(filepath.Clean(root))
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(realRoot, realPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveSymlinks resolves the symlinks in every existing component of an
+// absolute path, including dangling ones, so a link cannot lead out of a
+// root. Components that do not exist yet are kept as they are.
+func resolveSymlinks(path string) (string, error) {
+	resolved := "/"
+	pending := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	links := 0
+
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if name == "" || name == "." {
+			continue
+		}
+		if name == ".." {
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, name)
+		info, err := os.Lstat(next)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.Join(append([]string{next}, pending...)...), nil
+			}
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links: %s", path)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return resolved, nil
 }
@@ -1,17 +1,25 @@
 package filesystem
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/deep-agent/sandbox/internal/services/identity"
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
 )
 
 func TestValidatePath(t *testing.T) {
 	m := NewManager()
 
 	tests := []struct {
-		name      string
-		path      string
-		wantErr   bool
-		checkAbs  bool
+		name     string
+		path     string
+		wantErr  bool
+		checkAbs bool
 	}{
 		{
 			name:     "relative path .task",
@@ -53,3 +61,198 @@ func TestValidatePath(t *testing.T) {
 		})
 	}
 }
+
+func TestManager_ForContext(t *testing.T) {
+	m := NewManager()
+	same, err := m.ForContext(context.Background())
+	if err != nil || same != m {
+		t.Errorf("expected the same manager without users or confinement, got %v, %v", same, err)
+	}
+
+	workspace := t.TempDir()
+	confined := NewManager(WithConfinement(workspace, nil))
+	scoped, err := confined.ForContext(ctxutil.WithCwd(context.Background(), filepath.Join(workspace, "s1")))
+	if err != nil {
+		t.Fatalf("ForContext failed: %v", err)
+	}
+	if scoped.root != filepath.Join(workspace, "s1") {
+		t.Errorf("expected root from request cwd, got %q", scoped.root)
+	}
+	if scoped.session != defaultSessionKey {
+		t.Errorf("expected a request without a session to act for %q, got %q", defaultSessionKey, scoped.session)
+	}
+
+	for _, cwd := range []string{"/", filepath.Dir(workspace), filepath.Join(workspace, "..", "other"), "relative"} {
+		if _, err := confined.ForContext(ctxutil.WithCwd(context.Background(), cwd)); !errors.Is(err, ErrOutsideWorkspace) {
+			t.Errorf("ForContext with cwd %q: expected ErrOutsideWorkspace, got %v", cwd, err)
+		}
+	}
+
+	if err := os.Symlink(filepath.Dir(workspace), filepath.Join(workspace, "up")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := confined.ForContext(ctxutil.WithCwd(context.Background(), filepath.Join(workspace, "up"))); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Errorf("expected a symlink out of the workspace to be rejected, got %v", err)
+	}
+}
+
+func TestManager_Confinement(t *testing.T) {
+	base := t.TempDir()
+	workspace := filepath.Join(base, "workspace")
+	shared := filepath.Join(base, "shared")
+	other := filepath.Join(base, "other")
+	for _, dir := range []string{workspace, shared, other} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"escape":   other,
+		"dangling": filepath.Join(other, "missing"),
+		"inner":    "sub",
+		"loop":     "loop",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(workspace, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &Manager{root: workspace, readOnlyRoots: []string{shared}}
+
+	tests := []struct {
+		name     string
+		path     string
+		readOnly bool
+		want     string
+		wantErr  string
+	}{
+		{name: "relative path", path: "a/b.txt", want: filepath.Join(workspace, "a/b.txt")},
+		{name: "workspace itself", path: ".", want: workspace},
+		{name: "absolute inside", path: filepath.Join(workspace, "x"), want: filepath.Join(workspace, "x")},
+		{name: "dot dot escape", path: "../other/x", wantErr: "path outside workspace"},
+		{name: "absolute outside", path: "/etc/passwd", wantErr: "path outside workspace"},
+		{name: "symlink escape", path: "escape/x", wantErr: "path outside workspace"},
+		{name: "dangling symlink escape", path: "dangling", wantErr: "path outside workspace"},
+		{name: "symlink inside", path: "inner/x", want: filepath.Join(workspace, "inner/x")},
+		{name: "symlink loop", path: "loop", wantErr: "too many levels of symbolic links"},
+		{name: "read-only root read", path: filepath.Join(shared, "f"), readOnly: true, want: filepath.Join(shared, "f")},
+		{name: "read-only root write", path: filepath.Join(shared, "f"), wantErr: "is read-only"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.resolvePath(tt.path, tt.readOnly)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %q, %v", tt.wantErr, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolvePath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_ConfinedOperations(t *testing.T) {
+	base := t.TempDir()
+	workspace := filepath.Join(base, "workspace")
+	if err := os.Mkdir(workspace, 0755); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(base, "outside.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Manager{root: workspace}
+	if err := m.WriteFile("notes.txt", "secret here"); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "notes.txt")); err != nil {
+		t.Errorf("expected relative write inside workspace: %v", err)
+	}
+
+	checks := map[string]error{
+		"ReadFile":   func() error { _, err := m.ReadFile(outside); return err }(),
+		"ReadRange":  func() error { _, err := m.ReadFileWithOptions(outside, ReadOptions{}); return err }(),
+		"EditFile":   func() error { _, err := m.EditFile(outside, "secret", "x", EditOptions{}); return err }(),
+		"ListDir":    func() error { _, err := m.ListDir(base); return err }(),
+		"Tree":       func() error { _, err := m.Tree(base, TreeOptions{}); return err }(),
+		"MoveFile":   m.MoveFile("notes.txt", outside),
+		"CopyFile":   m.CopyFile(outside, "copy.txt"),
+		"DeleteFile": m.DeleteFile(outside),
+		"Grep": func() error {
+			_, err := m.Grep(context.Background(), GrepOptions{Pattern: "secret", Path: base})
+			return err
+		}(),
+		"Glob": func() error { _, err := m.Glob(GlobOptions{Pattern: "*.txt", Path: base}); return err }(),
+	}
+	for op, err := range checks {
+		if !errors.Is(err, ErrOutsideWorkspace) {
+			t.Errorf("%s: expected ErrOutsideWorkspace, got %v", op, err)
+		}
+	}
+	if m.Exists(outside) {
+		t.Error("Exists: expected false outside the workspace")
+	}
+
+	result, err := m.Glob(GlobOptions{Pattern: "../*.txt"})
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	for _, f := range result.Files {
+		if f == outside {
+			t.Errorf("expected glob to stay inside the workspace, got %v", result.Files)
+		}
+	}
+}
+
+func TestManager_RunsAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+
+	root := t.TempDir()
+	for _, dir := range []string{filepath.Dir(root), root} {
+		if err := os.Chmod(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	private := filepath.Join(root, "private")
+	own := filepath.Join(root, "own")
+	for _, dir := range []string{private, own} {
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	user := &identity.User{Name: "nobody", UID: 65534, GID: 65534, Home: own}
+	if err := user.Chown(own); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(private, "secret"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Manager{user: user}
+	if _, err := m.ReadFile(filepath.Join(private, "secret")); err == nil {
+		t.Error("expected read of another user's file to fail")
+	}
+	if err := m.WriteFile(filepath.Join(private, "new"), "x"); err == nil {
+		t.Error("expected write into another user's directory to fail")
+	}
+	if m.Exists(filepath.Join(private, "secret")) {
+		t.Error("expected file behind a private directory to be invisible")
+	}
+	if err := m.WriteFile(filepath.Join(own, "file"), "hello"); err != nil {
+		t.Fatalf("write in own directory failed: %v", err)
+	}
+	if content, err := m.ReadFile(filepath.Join(own, "file")); err != nil || content != "hello" {
+		t.Errorf("expected own file to be readable, got %q, %v", content, err)
+	}
+}
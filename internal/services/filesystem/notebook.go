@@ -0,0 +1,192 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type NotebookOutput struct {
+	// Type is the output_type: stream, execute_result, display_data or
+	// error.
+	Type string
+	Text string
+	// MimeType and Data hold a base64 image for image outputs.
+	MimeType string
+	Data     string
+}
+
+type NotebookCell struct {
+	Index          int
+	ID             string
+	Type           string
+	Source         string
+	ExecutionCount int
+	Outputs        []NotebookOutput
+}
+
+type Notebook struct {
+	Language string
+	Cells    []NotebookCell
+}
+
+// nbText is a notebook string, stored either as one string or as a list of
+// lines.
+type nbText string
+
+func (t *nbText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = nbText(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*t = nbText(strings.Join(lines, ""))
+	return nil
+}
+
+type nbFile struct {
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+	} `json:"metadata"`
+	Cells []struct {
+		ID             string `json:"id"`
+		CellType       string `json:"cell_type"`
+		Source         nbText `json:"source"`
+		ExecutionCount *int   `json:"execution_count"`
+		Outputs        []struct {
+			OutputType string            `json:"output_type"`
+			Text       nbText            `json:"text"`
+			Data       map[string]nbText `json:"data"`
+			Ename      string            `json:"ename"`
+			Evalue     string            `json:"evalue"`
+			Traceback  []string          `json:"traceback"`
+		} `json:"outputs"`
+	} `json:"cells"`
+}
+
+// ansiEscape matches the terminal color codes in tracebacks.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// ReadNotebook parses the Jupyter notebook at path into its cells and their
+// outputs.
+func (m *Manager) ReadNotebook(path string) (*Notebook, error) {
+	return runAs(m, func() (*Notebook, error) { return m.readNotebook(path) })
+}
+
+func (m *Manager) readNotebook(path string) (*Notebook, error) {
+	absPath, err := m.validateReadPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	var file nbFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %w", err)
+	}
+	m.markRead(absPath)
+
+	notebook := &Notebook{
+		Language: file.Metadata.LanguageInfo.Name,
+		Cells:    make([]NotebookCell, 0, len(file.Cells)),
+	}
+	if notebook.Language == "" {
+		notebook.Language = file.Metadata.Kernelspec.Language
+	}
+	for i, c := range file.Cells {
+		cell := NotebookCell{
+			Index:  i + 1,
+			ID:     c.ID,
+			Type:   c.CellType,
+			Source: string(c.Source),
+		}
+		if c.ExecutionCount != nil {
+			cell.ExecutionCount = *c.ExecutionCount
+		}
+		for _, o := range c.Outputs {
+			output := NotebookOutput{Type: o.OutputType}
+			switch o.OutputType {
+			case "stream":
+				output.Text = string(o.Text)
+			case "error":
+				output.Text = o.Ename + ": " + o.Evalue
+				if len(o.Traceback) > 0 {
+					output.Text = strings.Join(o.Traceback, "\n")
+				}
+			default:
+				output.Text, output.MimeType, output.Data = notebookData(o.Data)
+			}
+			output.Text = ansiEscape.ReplaceAllString(output.Text, "")
+			cell.Outputs = append(cell.Outputs, output)
+		}
+		notebook.Cells = append(notebook.Cells, cell)
+	}
+	return notebook, nil
+}
+
+// notebookData picks the representation of a rich output to return: an
+// image if there is one, otherwise the most readable text.
+func notebookData(data map[string]nbText) (string, string, string) {
+	for _, mimeType := range []string{"image/png", "image/jpeg", "image/gif"} {
+		if d, ok := data[mimeType]; ok {
+			return string(data["text/plain"]), mimeType, strings.ReplaceAll(string(d), "\n", "")
+		}
+	}
+	for _, mimeType := range []string{"text/plain", "text/markdown", "text/html", "application/json"} {
+		if d, ok := data[mimeType]; ok {
+			return string(d), "", ""
+		}
+	}
+	return "", "", ""
+}
+
+// Render formats the notebook as text, with each cell followed by its
+// outputs. Image outputs are only named.
+func (n *Notebook) Render() string {
+	var b strings.Builder
+	for _, cell := range n.Cells {
+		fmt.Fprintf(&b, "<cell %d type=%q", cell.Index, cell.Type)
+		if cell.ID != "" {
+			fmt.Fprintf(&b, " id=%q", cell.ID)
+		}
+		if cell.Type == "code" && n.Language != "" {
+			fmt.Fprintf(&b, " language=%q", n.Language)
+		}
+		b.WriteString(">\n")
+		writeBlock(&b, cell.Source)
+		for _, output := range cell.Outputs {
+			fmt.Fprintf(&b, "<output type=%q>\n", output.Type)
+			if output.MimeType != "" {
+				fmt.Fprintf(&b, "[%s image]\n", output.MimeType)
+			}
+			writeBlock(&b, output.Text)
+			b.WriteString("</output>\n")
+		}
+		b.WriteString("</cell>\n")
+	}
+	return b.String()
+}
+
+func writeBlock(b *strings.Builder, text string) {
+	if text == "" {
+		return
+	}
+	b.WriteString(text)
+	if !strings.HasSuffix(text, "\n") {
+		b.WriteString("\n")
+	}
+}
@@ -0,0 +1,69 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+ "metadata": {"language_info": {"name": "python"}},
+ "nbformat": 4,
+ "cells": [
+  {"cell_type": "markdown", "id": "intro", "source": ["# Title\n", "Some text"]},
+  {"cell_type": "code", "id": "calc", "execution_count": 2, "source": "print(1)\n1 + 1",
+   "outputs": [
+    {"output_type": "stream", "name": "stdout", "text": ["1\n"]},
+    {"output_type": "execute_result", "execution_count": 2, "data": {"text/plain": ["2"]}},
+    {"output_type": "display_data", "data": {"image/png": "iVBORw0K\nGgo=\n", "text/plain": ["<Figure>"]}}
+   ]},
+  {"cell_type": "code", "source": "1/0", "outputs": [
+   {"output_type": "error", "ename": "ZeroDivisionError", "evalue": "division by zero",
+    "traceback": ["\u001b[0;31mZeroDivisionError\u001b[0m: division by zero"]}
+  ]}
+ ]
+}`
+
+func TestReadNotebook(t *testing.T) {
+	m := NewManager()
+	file := filepath.Join(t.TempDir(), "analysis.ipynb")
+	os.WriteFile(file, []byte(testNotebook), 0644)
+
+	notebook, err := m.ReadNotebook(file)
+	if err != nil {
+		t.Fatalf("ReadNotebook() error = %v", err)
+	}
+	if notebook.Language != "python" || len(notebook.Cells) != 3 {
+		t.Fatalf("unexpected notebook %+v", notebook)
+	}
+	if cell := notebook.Cells[0]; cell.Type != "markdown" || cell.Source != "# Title\nSome text" {
+		t.Errorf("expected source lines to be joined, got %+v", cell)
+	}
+
+	outputs := notebook.Cells[1].Outputs
+	if len(outputs) != 3 || outputs[0].Text != "1\n" || outputs[1].Text != "2" {
+		t.Fatalf("unexpected outputs %+v", outputs)
+	}
+	if outputs[2].MimeType != "image/png" || outputs[2].Data != "iVBORw0KGgo=" || outputs[2].Text != "<Figure>" {
+		t.Errorf("unexpected image output %+v", outputs[2])
+	}
+	if got := notebook.Cells[2].Outputs[0].Text; got != "ZeroDivisionError: division by zero" {
+		t.Errorf("expected traceback without color codes, got %q", got)
+	}
+
+	rendered := notebook.Render()
+	for _, want := range []string{
+		"<cell 2 type=\"code\" id=\"calc\" language=\"python\">\nprint(1)\n1 + 1\n<output type=\"stream\">\n1\n</output>",
+		"[image/png image]\n<Figure>\n",
+	} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("expected rendered notebook to contain %q, got:\n%s", want, rendered)
+		}
+	}
+
+	os.WriteFile(file, []byte("not json"), 0644)
+	if _, err := m.ReadNotebook(file); err == nil {
+		t.Error("expected error for an invalid notebook")
+	}
+}
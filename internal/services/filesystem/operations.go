@@ -10,7 +10,11 @@ import (
 )
 
 func (m *Manager) ListDir(path string) ([]model.FileInfo, error) {
-	absPath, err := m.validatePath(path)
+	return runAs(m, func() ([]model.FileInfo, error) { return m.listDir(path) })
+}
+
+func (m *Manager) listDir(path string) ([]model.FileInfo, error) {
+	absPath, err := m.validateReadPath(path)
 	if err != nil {
 		return nil, err
 	}
@@ -41,6 +45,10 @@ func (m *Manager) ListDir(path string) ([]model.FileInfo, error) {
 }
 
 func (m *Manager) DeleteFile(path string) error {
+	return m.journal(OpDelete, path, "", func() error { return m.deleteFile(path) })
+}
+
+func (m *Manager) deleteFile(path string) error {
 	absPath, err := m.validatePath(path)
 	if err != nil {
 		return err
@@ -54,6 +62,10 @@ func (m *Manager) DeleteFile(path string) error {
 }
 
 func (m *Manager) MoveFile(src, dst string) error {
+	return m.journal(OpMove, src, dst, func() error { return m.moveFile(src, dst) })
+}
+
+func (m *Manager) moveFile(src, dst string) error {
 	absSrc, err := m.validatePath(src)
 	if err != nil {
 		return err
@@ -72,7 +84,11 @@ func (m *Manager) MoveFile(src, dst string) error {
 }
 
 func (m *Manager) CopyFile(src, dst string) error {
-	absSrc, err := m.validatePath(src)
+	return m.journal(OpCopy, src, dst, func() error { return m.copyFile(src, dst) })
+}
+
+func (m *Manager) copyFile(src, dst string) error {
+	absSrc, err := m.validateReadPath(src)
 	if err != nil {
 		return err
 	}
@@ -107,16 +123,22 @@ func (m *Manager) CopyFile(src, dst string) error {
 }
 
 func (m *Manager) Exists(path string) bool {
-	absPath, err := m.validatePath(path)
+	absPath, err := m.validateReadPath(path)
 	if err != nil {
 		return false
 	}
 
-	_, err = os.Stat(absPath)
-	return err == nil
+	return m.run(func() error {
+		_, err := os.Stat(absPath)
+		return err
+	}) == nil
 }
 
 func (m *Manager) MkDir(path string) error {
+	return m.run(func() error { return m.mkDir(path) })
+}
+
+func (m *Manager) mkDir(path string) error {
 	absPath, err := m.validatePath(path)
 	if err != nil {
 		return err
@@ -0,0 +1,583 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const devNull = "/dev/null"
+
+const (
+	PatchCreate = "create"
+	PatchModify = "modify"
+	PatchDelete = "delete"
+	PatchRename = "rename"
+)
+
+var (
+	// ErrInvalidPatch is returned for text that is not a patch this package
+	// can apply, and ErrPatchFailed when a patch does not apply to the files.
+	// Nothing is written in either case.
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrPatchFailed  = errors.New("patch does not apply")
+)
+
+// filePatch is the part of a patch changing one file. OldPath is empty for
+// created files and NewPath for deleted ones.
+type filePatch struct {
+	OldPath string
+	NewPath string
+	Mode    fs.FileMode
+	Hunks   []patchHunk
+}
+
+type patchHunk struct {
+	OldStart, OldCount int
+	NewStart, NewCount int
+	// Lines are the hunk lines with their ' ', '-' or '+' prefix; each ends
+	// with a newline unless it is the last line of its file.
+	Lines []string
+}
+
+// parsePatch parses a unified diff or git patch. Text outside the file
+// sections, such as a commit message, is ignored.
+func parsePatch(patch string) ([]filePatch, error) {
+	lines := splitLines(patch)
+	var files []filePatch
+	var current *filePatch
+	git := false
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\n")
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			git = true
+			files = append(files, filePatch{})
+			current = &files[len(files)-1]
+			if oldPath, newPath, ok := parseGitHeader(line); ok {
+				current.OldPath, current.NewPath = oldPath, newPath
+			}
+
+		case current != nil && git && len(current.Hunks) == 0 && strings.HasPrefix(line, "new file mode "):
+			current.OldPath = ""
+			current.Mode = parseGitMode(strings.TrimPrefix(line, "new file mode "))
+		case current != nil && git && len(current.Hunks) == 0 && strings.HasPrefix(line, "deleted file mode "):
+			current.NewPath = ""
+		case current != nil && git && len(current.Hunks) == 0 && strings.HasPrefix(line, "rename from "):
+			current.OldPath = strings.TrimPrefix(line, "rename from ")
+		case current != nil && git && len(current.Hunks) == 0 && strings.HasPrefix(line, "rename to "):
+			current.NewPath = strings.TrimPrefix(line, "rename to ")
+		case current != nil && git && len(current.Hunks) == 0 && strings.HasPrefix(line, "copy from "):
+			return nil, fmt.Errorf("%w: copies are not supported", ErrInvalidPatch)
+		case strings.HasPrefix(line, "GIT binary patch"), strings.HasPrefix(line, "Binary files "):
+			return nil, fmt.Errorf("%w: binary patches are not supported", ErrInvalidPatch)
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldPath := parsePatchPath(strings.TrimPrefix(line, "--- "), git)
+			newPath := parsePatchPath(strings.TrimPrefix(strings.TrimSuffix(lines[i+1], "\n"), "+++ "), git)
+			i++
+			if current == nil || !git || len(current.Hunks) > 0 {
+				files = append(files, filePatch{})
+				current = &files[len(files)-1]
+				git = false
+			}
+			current.OldPath, current.NewPath = oldPath, newPath
+
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, fmt.Errorf("%w: hunk before file header at line %d", ErrInvalidPatch, i+1)
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			current.Hunks = append(current.Hunks, hunk)
+			i = next - 1
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: no file changes found", ErrInvalidPatch)
+	}
+	if !git {
+		stripPrefixes(files)
+	}
+	for _, f := range files {
+		if f.OldPath == "" && f.NewPath == "" {
+			return nil, fmt.Errorf("%w: file section without a path", ErrInvalidPatch)
+		}
+	}
+	return files, nil
+}
+
+// parseGitHeader splits "diff --git a/old b/new" for paths without spaces;
+// the ---/+++ and rename lines override it otherwise.
+func parseGitHeader(line string) (string, string, bool) {
+	fields := strings.Fields(strings.TrimPrefix(line, "diff --git "))
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return strings.TrimPrefix(fields[0], "a/"), strings.TrimPrefix(fields[1], "b/"), true
+}
+
+func parseGitMode(mode string) fs.FileMode {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0
+	}
+	return fs.FileMode(m).Perm()
+}
+
+// parsePatchPath returns the path of a ---/+++ line without its timestamp,
+// and empty for /dev/null. Git paths lose their a/ or b/ prefix.
+func parsePatchPath(s string, git bool) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == devNull {
+		return ""
+	}
+	if git && (strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/")) {
+		s = s[2:]
+	}
+	return s
+}
+
+// stripPrefixes removes the a/ and b/ prefixes of a plain unified diff when
+// every file has them, like git diff output without the git headers.
+func stripPrefixes(files []filePatch) {
+	for _, f := range files {
+		if (f.OldPath != "" && !strings.HasPrefix(f.OldPath, "a/")) ||
+			(f.NewPath != "" && !strings.HasPrefix(f.NewPath, "b/")) {
+			return
+		}
+	}
+	for i := range files {
+		files[i].OldPath = strings.TrimPrefix(files[i].OldPath, "a/")
+		files[i].NewPath = strings.TrimPrefix(files[i].NewPath, "b/")
+	}
+}
+
+// parseHunk parses the hunk starting at lines[start] and returns the index
+// of the line after it.
+func parseHunk(lines []string, start int) (patchHunk, int, error) {
+	var h patchHunk
+	header := strings.TrimSuffix(lines[start], "\n")
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[3] != "@@" {
+		return h, 0, fmt.Errorf("%w: malformed hunk header at line %d: %s", ErrInvalidPatch, start+1, header)
+	}
+	var err error
+	if h.OldStart, h.OldCount, err = parseHunkRange(fields[1], "-"); err == nil {
+		h.NewStart, h.NewCount, err = parseHunkRange(fields[2], "+")
+	}
+	if err != nil {
+		return h, 0, fmt.Errorf("%w: malformed hunk header at line %d: %s", ErrInvalidPatch, start+1, header)
+	}
+
+	oldLeft, newLeft := h.OldCount, h.NewCount
+	i := start + 1
+	for ; i < len(lines) && (oldLeft > 0 || newLeft > 0); i++ {
+		line := lines[i]
+		if line == "\n" {
+			// Editors and mail clients drop the space of empty context lines.
+			line = " \n"
+		}
+		switch line[0] {
+		case ' ':
+			oldLeft--
+			newLeft--
+		case '-':
+			oldLeft--
+		case '+':
+			newLeft--
+		case '\\':
+			h.Lines = trimLastNewline(h.Lines)
+			continue
+		default:
+			return h, 0, fmt.Errorf("%w: unexpected line %d in hunk: %s", ErrInvalidPatch, i+1, strings.TrimSuffix(line, "\n"))
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		h.Lines = append(h.Lines, line)
+	}
+	if oldLeft != 0 || newLeft != 0 {
+		return h, 0, fmt.Errorf("%w: hunk at line %d is truncated", ErrInvalidPatch, start+1)
+	}
+	if i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+		h.Lines = trimLastNewline(h.Lines)
+		i++
+	}
+	return h, i, nil
+}
+
+func parseHunkRange(s, prefix string) (int, int, error) {
+	if !strings.HasPrefix(s, prefix) {
+		return 0, 0, fmt.Errorf("missing %s", prefix)
+	}
+	start, count, found := strings.Cut(s[1:], ",")
+	n, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	c := 1
+	if found {
+		if c, err = strconv.Atoi(count); err != nil {
+			return 0, 0, err
+		}
+	}
+	return n, c, nil
+}
+
+// trimLastNewline handles "\ No newline at end of file" for the last line.
+func trimLastNewline(lines []string) []string {
+	if n := len(lines); n > 0 {
+		lines[n-1] = strings.TrimSuffix(lines[n-1], "\n")
+	}
+	return lines
+}
+
+// applyHunks applies the hunks to content in order. A hunk whose lines are
+// not at the stated position is looked for at the nearest offset.
+func applyHunks(path, content string, hunks []patchHunk) (string, error) {
+	lines := splitLines(content)
+	var result []string
+	pos := 0
+	offset := 0
+
+	for n, h := range hunks {
+		var old, added []string
+		for _, line := range h.Lines {
+			if line[0] != '+' {
+				old = append(old, line[1:])
+			}
+			if line[0] != '-' {
+				added = append(added, line[1:])
+			}
+		}
+
+		want := h.OldStart - 1 + offset
+		if h.OldCount == 0 {
+			want = h.OldStart + offset
+		}
+		at := findLines(lines, old, pos, want)
+		if at < 0 {
+			return "", fmt.Errorf("%w: hunk %d of %s does not match at line %d", ErrPatchFailed, n+1, path, h.OldStart)
+		}
+		offset = at - (want - offset)
+		result = append(result, lines[pos:at]...)
+		result = append(result, added...)
+		pos = at + len(old)
+	}
+	result = append(result, lines[pos:]...)
+	return strings.Join(result, ""), nil
+}
+
+// findLines returns the index at or after from where old occurs in lines,
+// closest to want, or -1.
+func findLines(lines, old []string, from, want int) int {
+	matches := func(at int) bool {
+		if at < from || at+len(old) > len(lines) {
+			return false
+		}
+		for i, line := range old {
+			if lines[at+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+	for delta := 0; want-delta >= from || want+delta <= len(lines); delta++ {
+		if matches(want - delta) {
+			return want - delta
+		}
+		if matches(want + delta) {
+			return want + delta
+		}
+	}
+	return -1
+}
+
+type PatchOptions struct {
+	// Dir resolves relative paths in the patch; without it they are
+	// resolved like any other path of the manager.
+	Dir    string
+	DryRun bool
+}
+
+type PatchedFile struct {
+	Path    string
+	OldPath string
+	Op      string
+	Added   int
+	Removed int
+}
+
+type PatchResult struct {
+	Files []PatchedFile
+}
+
+// fileUpdate is the planned outcome of a patch for one path. exists and old
+// describe the file on disk, present and content the file once patched.
+type fileUpdate struct {
+	path    string
+	exists  bool
+	old     string
+	present bool
+	content string
+	mode    fs.FileMode
+}
+
+// ApplyPatch applies a unified diff or git patch. Every hunk of every file is
+// checked before anything is written; the files are then replaced one by one
+// and restored if any replacement fails.
+func (m *Manager) ApplyPatch(patch string, opts PatchOptions) (*PatchResult, error) {
+	files, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := runAs(m, func() ([]string, error) { return m.patchPaths(files, opts.Dir) })
+	if err != nil {
+		return nil, err
+	}
+
+	var result *PatchResult
+	apply := func() error {
+		planned, updates, err := m.planPatch(files, opts.Dir)
+		if err != nil {
+			return err
+		}
+		result = planned
+		if opts.DryRun {
+			return nil
+		}
+		if err := commitUpdates(updates); err != nil {
+			return err
+		}
+		for _, u := range updates {
+			if u.present {
+				m.markRead(u.path)
+			}
+		}
+		return nil
+	}
+	if opts.DryRun {
+		err = m.run(apply)
+	} else {
+		err = m.journalFiles(OpPatch, paths, apply)
+	}
+	return result, err
+}
+
+func (m *Manager) patchPath(path, dir string) (string, error) {
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	return m.absPath(path)
+}
+
+// patchPaths returns every path the patch changes, once, in patch order.
+func (m *Manager) patchPaths(files []filePatch, dir string) ([]string, error) {
+	var paths []string
+	seen := map[string]bool{}
+	for _, f := range files {
+		for _, p := range []string{f.OldPath, f.NewPath} {
+			if p == "" {
+				continue
+			}
+			abs, err := m.patchPath(p, dir)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[abs] {
+				seen[abs] = true
+				paths = append(paths, abs)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// planPatch applies the patch in memory. Files changed by several sections
+// see the result of the earlier ones.
+func (m *Manager) planPatch(files []filePatch, dir string) (*PatchResult, []*fileUpdate, error) {
+	byPath := map[string]*fileUpdate{}
+	var updates []*fileUpdate
+	load := func(path string) (*fileUpdate, error) {
+		if u, ok := byPath[path]; ok {
+			return u, nil
+		}
+		u := &fileUpdate{path: path, mode: 0644}
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, err
+		case info.IsDir():
+			return nil, fmt.Errorf("%w: %s is a directory", ErrPatchFailed, path)
+		default:
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file: %w", err)
+			}
+			u.exists, u.present = true, true
+			u.mode = info.Mode().Perm()
+			u.old = string(content)
+			u.content = u.old
+		}
+		byPath[path] = u
+		updates = append(updates, u)
+		return u, nil
+	}
+
+	result := &PatchResult{}
+	for _, f := range files {
+		var src, dst *fileUpdate
+		var err error
+		if f.OldPath != "" {
+			var p string
+			if p, err = m.patchPath(f.OldPath, dir); err != nil {
+				return nil, nil, err
+			}
+			if src, err = load(p); err != nil {
+				return nil, nil, err
+			}
+			if !src.present {
+				return nil, nil, fmt.Errorf("%w: %s does not exist", ErrPatchFailed, p)
+			}
+		}
+		if f.NewPath != "" {
+			var p string
+			if p, err = m.patchPath(f.NewPath, dir); err != nil {
+				return nil, nil, err
+			}
+			if dst, err = load(p); err != nil {
+				return nil, nil, err
+			}
+			if dst != src && dst.present {
+				return nil, nil, fmt.Errorf("%w: %s already exists", ErrPatchFailed, p)
+			}
+		}
+
+		content := ""
+		name := f.NewPath
+		if src != nil {
+			content = src.content
+			name = f.OldPath
+		}
+		patched, err := applyHunks(name, content, f.Hunks)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		file := PatchedFile{Op: PatchModify}
+		for _, h := range f.Hunks {
+			for _, line := range h.Lines {
+				switch line[0] {
+				case '+':
+					file.Added++
+				case '-':
+					file.Removed++
+				}
+			}
+		}
+		switch {
+		case dst == nil:
+			if patched != "" {
+				return nil, nil, fmt.Errorf("%w: %s is not empty after deleting it", ErrPatchFailed, src.path)
+			}
+			src.present = false
+			file.Op, file.Path = PatchDelete, src.path
+		case src == nil:
+			dst.content, dst.present = patched, true
+			if f.Mode != 0 {
+				dst.mode = f.Mode
+			}
+			file.Op, file.Path = PatchCreate, dst.path
+		case src != dst:
+			src.present = false
+			dst.content, dst.present, dst.mode = patched, true, src.mode
+			file.Op, file.Path, file.OldPath = PatchRename, dst.path, src.path
+		default:
+			dst.content = patched
+			file.Path = dst.path
+		}
+		result.Files = append(result.Files, file)
+	}
+	return result, updates, nil
+}
+
+// commitUpdates writes the planned files. Contents are first written to
+// temporary files next to their targets, which are then renamed over them;
+// when a step fails the files already replaced or removed are restored.
+func commitUpdates(updates []*fileUpdate) (err error) {
+	temps := map[*fileUpdate]string{}
+	var done []*fileUpdate
+	defer func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+		if err == nil {
+			return
+		}
+		for i := len(done) - 1; i >= 0; i-- {
+			u := done[i]
+			if u.exists {
+				replaceFile(u.path, []byte(u.old), u.mode)
+			} else {
+				os.Remove(u.path)
+			}
+		}
+	}()
+
+	for _, u := range updates {
+		if !u.present || (u.exists && u.content == u.old) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(u.path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(u.path), "."+filepath.Base(u.path)+".patch-")
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", u.path, err)
+		}
+		temps[u] = tmp.Name()
+		_, err = tmp.WriteString(u.content)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), u.mode)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", u.path, err)
+		}
+	}
+
+	for _, u := range updates {
+		tmp, ok := temps[u]
+		switch {
+		case ok:
+			if err := os.Rename(tmp, u.path); err != nil {
+				return fmt.Errorf("failed to replace %s: %w", u.path, err)
+			}
+			delete(temps, u)
+		case u.exists && !u.present:
+			if err := os.Remove(u.path); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", u.path, err)
+			}
+		default:
+			continue
+		}
+		done = append(done, u)
+	}
+	return nil
+}
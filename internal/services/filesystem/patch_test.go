@@ -0,0 +1,198 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultiEdit(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager()
+	testFile := filepath.Join(tmpDir, "main.go")
+	initialContent := "func foo() {\n\treturn 1\n}\n"
+	os.WriteFile(testFile, []byte(initialContent), 0644)
+
+	_, err := manager.MultiEdit(testFile, []Edit{
+		{OldString: "foo", NewString: "bar"},
+		{OldString: "missing", NewString: "x"},
+	}, false)
+	if err == nil || !strings.Contains(err.Error(), "edit 2") {
+		t.Fatalf("expected the second edit to fail, got %v", err)
+	}
+	if got := readString(t, testFile); got != initialContent {
+		t.Errorf("expected file to be unchanged after a failed edit, got %q", got)
+	}
+
+	result, err := manager.MultiEdit(testFile, []Edit{
+		{OldString: "foo", NewString: "bar"},
+		{OldString: "bar() {", NewString: "bar() int {"},
+		{OldString: "1", NewString: "2"},
+	}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readString(t, testFile); got != "func bar() int {\n\treturn 2\n}\n" {
+		t.Errorf("unexpected content %q", got)
+	}
+	if len(result.Strategies) != 3 || result.Replacements != 3 {
+		t.Errorf("expected 3 strategies and replacements, got %+v", result)
+	}
+	if !strings.Contains(result.Diff, "-func foo() {\n-\treturn 1\n+func bar() int {\n+\treturn 2\n") {
+		t.Errorf("expected combined diff, got %q", result.Diff)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewManager()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\nthree\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "old.txt"), []byte("moved\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "gone.txt"), []byte("bye\n"), 0644)
+
+	patch := `Commit message text is ignored.
+
+diff --git a/a.txt b/a.txt
+index 1111111..2222222 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+diff --git a/new/b.txt b/new/b.txt
+new file mode 100755
+--- /dev/null
++++ b/new/b.txt
+@@ -0,0 +1,2 @@
++hello
++world
+\ No newline at end of file
+diff --git a/old.txt b/renamed.txt
+similarity index 100%
+rename from old.txt
+rename to renamed.txt
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`
+	result, err := manager.ApplyPatch(patch, PatchOptions{Dir: dir, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(result.Files) != 4 {
+		t.Fatalf("expected 4 files, got %+v", result.Files)
+	}
+	if got := readString(t, filepath.Join(dir, "a.txt")); got != "one\ntwo\nthree\n" {
+		t.Errorf("expected dry run to leave files unchanged, got %q", got)
+	}
+
+	if _, err := manager.ApplyPatch(patch, PatchOptions{Dir: dir}); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if got := readString(t, filepath.Join(dir, "a.txt")); got != "one\nTWO\nthree\n" {
+		t.Errorf("unexpected a.txt %q", got)
+	}
+	if got := readString(t, filepath.Join(dir, "new", "b.txt")); got != "hello\nworld" {
+		t.Errorf("unexpected new/b.txt %q", got)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "new", "b.txt")); info.Mode().Perm() != 0755 {
+		t.Errorf("expected new file mode 0755, got %v", info.Mode().Perm())
+	}
+	if info, err := os.Stat(filepath.Join(dir, "renamed.txt")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected renamed file to keep its mode: %v", err)
+	}
+	for _, name := range []string{"old.txt", "gone.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", name)
+		}
+	}
+}
+
+func TestApplyPatch_Offset(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewManager()
+	file := filepath.Join(dir, "f.txt")
+	os.WriteFile(file, []byte("added\nadded\na\nb\nc\n"), 0644)
+
+	patch := "--- f.txt\n+++ f.txt\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"
+	if _, err := manager.ApplyPatch(patch, PatchOptions{Dir: dir}); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if got := readString(t, file); got != "added\nadded\na\nB\nc\n" {
+		t.Errorf("unexpected content %q", got)
+	}
+}
+
+func TestApplyPatch_AllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewManager()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b\n"), 0644)
+
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+A\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-x\n+X\n"
+	_, err := manager.ApplyPatch(patch, PatchOptions{Dir: dir})
+	if !errors.Is(err, ErrPatchFailed) {
+		t.Fatalf("expected ErrPatchFailed, got %v", err)
+	}
+	if got := readString(t, filepath.Join(dir, "a.txt")); got != "a\n" {
+		t.Errorf("expected a.txt to be unchanged, got %q", got)
+	}
+
+	if _, err := manager.ApplyPatch("not a patch", PatchOptions{Dir: dir}); err == nil {
+		t.Error("expected error for text without file changes")
+	}
+	if _, err := manager.ApplyPatch("--- /dev/null\n+++ b/a.txt\n@@ -0,0 +1 @@\n+x\n", PatchOptions{Dir: dir}); !errors.Is(err, ErrPatchFailed) {
+		t.Errorf("expected ErrPatchFailed creating an existing file, got %v", err)
+	}
+}
+
+func TestCommitUpdatesRollback(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	os.WriteFile(file, []byte("old\n"), 0644)
+
+	err := commitUpdates([]*fileUpdate{
+		{path: file, exists: true, old: "old\n", present: true, content: "new\n", mode: 0644},
+		// A directory cannot be replaced by a file, so the second rename fails.
+		{path: dir, exists: false, present: true, content: "x", mode: 0644},
+	})
+	if err == nil {
+		t.Fatal("expected commit to fail")
+	}
+	if got := readString(t, file); got != "old\n" {
+		t.Errorf("expected a.txt to be rolled back, got %q", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected temporary files to be removed, got %d entries", len(entries))
+	}
+}
+
+func TestApplyPatch_Undo(t *testing.T) {
+	m, workspace := newHistoryManager(t, HistoryOptions{})
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("a\n"), 0644)
+
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+A\n--- /dev/null\n+++ b/b.txt\n@@ -0,0 +1 @@\n+b\n"
+	if _, err := m.ApplyPatch(patch, PatchOptions{Dir: workspace}); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if changes, _ := m.History(filepath.Join(workspace, "b.txt"), 0); len(changes) != 1 || changes[0].Op != OpPatch {
+		t.Fatalf("expected the patch in the history of b.txt, got %+v", changes)
+	}
+	if _, err := m.Undo(UndoOptions{}); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if got := readString(t, filepath.Join(workspace, "a.txt")); got != "a\n" {
+		t.Errorf("expected a.txt to be restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "b.txt")); !os.IsNotExist(err) {
+		t.Error("expected b.txt to be removed")
+	}
+}
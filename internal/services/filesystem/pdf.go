@@ -0,0 +1,129 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// MaxPDFPages is the most pages ReadPDF extracts at once.
+const MaxPDFPages = 20
+
+type PDFPage struct {
+	Number int
+	Text   string
+}
+
+type PDF struct {
+	Pages      []PDFPage
+	TotalPages int
+}
+
+// ReadPDF extracts the text of a PDF page by page with pdftotext. pages is a
+// page number or a range such as "3-7"; empty means the first MaxPDFPages
+// pages.
+func (m *Manager) ReadPDF(ctx context.Context, path, pages string) (*PDF, error) {
+	return runAs(m, func() (*PDF, error) { return m.readPDF(ctx, path, pages) })
+}
+
+func (m *Manager) readPDF(ctx context.Context, path, pages string) (*PDF, error) {
+	absPath, err := m.validateReadPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := m.pdfCommand(ctx, "pdfinfo", absPath).Output()
+	if err != nil {
+		return nil, pdfError("pdfinfo", err)
+	}
+	total := parsePDFPageCount(info)
+	if total == 0 {
+		return &PDF{Pages: []PDFPage{}}, nil
+	}
+
+	first, last, err := parsePageRange(pages, total)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := m.pdfCommand(ctx, "pdftotext", "-layout", "-enc", "UTF-8",
+		"-f", strconv.Itoa(first), "-l", strconv.Itoa(last), absPath, "-").Output()
+	if err != nil {
+		return nil, pdfError("pdftotext", err)
+	}
+	m.markRead(absPath)
+
+	// pdftotext ends every page with a form feed.
+	texts := strings.Split(string(out), "\f")
+	result := &PDF{TotalPages: total}
+	for i := 0; i <= last-first; i++ {
+		page := PDFPage{Number: first + i}
+		if i < len(texts) {
+			page.Text = strings.TrimRight(texts[i], " \n")
+		}
+		result.Pages = append(result.Pages, page)
+	}
+	return result, nil
+}
+
+func (m *Manager) pdfCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if m.user != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: m.user.Credential()}
+	}
+	return cmd
+}
+
+func pdfError(name string, err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return fmt.Errorf("failed to read PDF: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return fmt.Errorf("failed to execute %s: %w", name, err)
+}
+
+func parsePDFPageCount(info []byte) int {
+	scanner := bufio.NewScanner(bytes.NewReader(info))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "Pages:"); ok {
+			n, _ := strconv.Atoi(strings.TrimSpace(value))
+			return n
+		}
+	}
+	return 0
+}
+
+// parsePageRange returns the first and last page of pages, a page number or
+// a range such as "3-7", within a document of total pages.
+func parsePageRange(pages string, total int) (int, int, error) {
+	pages = strings.TrimSpace(pages)
+	if pages == "" {
+		return 1, min(total, MaxPDFPages), nil
+	}
+
+	from, to, isRange := strings.Cut(pages, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid pages %q: expected a page number or a range such as 1-5", pages)
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return 0, 0, fmt.Errorf("invalid pages %q: expected a page number or a range such as 1-5", pages)
+		}
+	}
+
+	switch {
+	case first < 1 || last < first:
+		return 0, 0, fmt.Errorf("invalid pages %q", pages)
+	case first > total:
+		return 0, 0, fmt.Errorf("invalid pages %q: the document has %d pages", pages, total)
+	case last-first+1 > MaxPDFPages:
+		return 0, 0, fmt.Errorf("invalid pages %q: at most %d pages can be read at once", pages, MaxPDFPages)
+	}
+	return first, min(last, total), nil
+}
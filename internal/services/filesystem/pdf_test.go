@@ -0,0 +1,42 @@
+package filesystem
+
+import (
+	"testing"
+)
+
+func TestParsePageRange(t *testing.T) {
+	tests := []struct {
+		pages       string
+		total       int
+		first, last int
+		wantErr     bool
+	}{
+		{"", 5, 1, 5, false},
+		{"", 50, 1, MaxPDFPages, false},
+		{"3", 5, 3, 3, false},
+		{" 2 - 4 ", 5, 2, 4, false},
+		{"4-9", 5, 4, 5, false},
+		{"6", 5, 0, 0, true},
+		{"0", 5, 0, 0, true},
+		{"4-2", 5, 0, 0, true},
+		{"1-21", 50, 0, 0, true},
+		{"a-b", 5, 0, 0, true},
+	}
+	for _, tt := range tests {
+		first, last, err := parsePageRange(tt.pages, tt.total)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePageRange(%q, %d) error = %v, wantErr %v", tt.pages, tt.total, err, tt.wantErr)
+			continue
+		}
+		if first != tt.first || last != tt.last {
+			t.Errorf("parsePageRange(%q, %d) = %d-%d, want %d-%d", tt.pages, tt.total, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestParsePDFPageCount(t *testing.T) {
+	info := []byte("Title:          report\nProducer:       test\nPages:          12\nEncrypted:      no\n")
+	if got := parsePDFPageCount(info); got != 12 {
+		t.Errorf("parsePDFPageCount() = %d, want 12", got)
+	}
+}
@@ -9,7 +9,11 @@ import (
 )
 
 func (m *Manager) ReadFile(path string) (string, error) {
-	absPath, err := m.validatePath(path)
+	return runAs(m, func() (string, error) { return m.readFile(path) })
+}
+
+func (m *Manager) readFile(path string) (string, error) {
+	absPath, err := m.validateReadPath(path)
 	if err != nil {
 		return "", err
 	}
@@ -18,12 +22,17 @@ func (m *Manager) ReadFile(path string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
+	m.markRead(absPath)
 
 	return string(content), nil
 }
 
 func (m *Manager) ReadFileBase64(path string) (string, error) {
-	absPath, err := m.validatePath(path)
+	return runAs(m, func() (string, error) { return m.readFileBase64(path) })
+}
+
+func (m *Manager) readFileBase64(path string) (string, error) {
+	absPath, err := m.validateReadPath(path)
 	if err != nil {
 		return "", err
 	}
@@ -32,6 +41,7 @@ func (m *Manager) ReadFileBase64(path string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
+	m.markRead(absPath)
 
 	return base64.StdEncoding.EncodeToString(content), nil
 }
@@ -50,6 +60,10 @@ type ReadResult struct {
 }
 
 func (m *Manager) ReadFileWithOptions(path string, opts ReadOptions) (*ReadResult, error) {
+	return runAs(m, func() (*ReadResult, error) { return m.readFileWithOptions(path, opts) })
+}
+
+func (m *Manager) readFileWithOptions(path string, opts ReadOptions) (*ReadResult, error) {
 	if opts.Offset < 1 {
 		opts.Offset = 1
 	}
@@ -60,7 +74,20 @@ func (m *Manager) ReadFileWithOptions(path string, opts ReadOptions) (*ReadResul
 		opts.MaxLineLength = 2000
 	}
 
-	file, err := os.Open(path)
+	absPath, err := m.validateReadPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := readHead(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if fileType, mimeType := detectFileType(absPath, head); fileType != FileTypeText && fileType != FileTypeNotebook {
+		return nil, binaryFileError(absPath, mimeType)
+	}
+
+	file, err := os.Open(absPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
@@ -100,6 +127,7 @@ func (m *Manager) ReadFileWithOptions(path string, opts ReadOptions) (*ReadResul
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("error reading file: %w", err)
 	}
+	m.markRead(absPath)
 
 	return &ReadResult{
 		Content:   result.String(),
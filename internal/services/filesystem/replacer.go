@@ -466,21 +466,35 @@ func levenshtein(a, b string) int {
 	return matrix[aLen][bLen]
 }
 
-var AllReplacers = []Replacer{
-	SimpleReplacer,
-	LineTrimmedReplacer,
-	BlockAnchorReplacer,
-	WhitespaceNormalizedReplacer,
-	IndentationFlexibleReplacer,
-	EscapeNormalizedReplacer,
-	TrimmedBoundaryReplacer,
-	ContextAwareReplacer,
-	MultiOccurrenceReplacer,
+// Strategy is a Replacer with the name an edit reports when it matched.
+type Strategy struct {
+	Name     string
+	Replacer Replacer
+}
+
+// AllReplacers are tried in order, from exact to increasingly fuzzy matches.
+var AllReplacers = []Strategy{
+	{"simple", SimpleReplacer},
+	{"line_trimmed", LineTrimmedReplacer},
+	{"block_anchor", BlockAnchorReplacer},
+	{"whitespace_normalized", WhitespaceNormalizedReplacer},
+	{"indentation_flexible", IndentationFlexibleReplacer},
+	{"escape_normalized", EscapeNormalizedReplacer},
+	{"trimmed_boundary", TrimmedBoundaryReplacer},
+	{"context_aware", ContextAwareReplacer},
+	{"multi_occurrence", MultiOccurrenceReplacer},
 }
 
 func FindReplacement(content, oldString string, replaceAll bool) (string, error) {
-	for _, replacer := range AllReplacers {
-		matches := replacer(content, oldString)
+	search, _, err := FindReplacementStrategy(content, oldString, replaceAll)
+	return search, err
+}
+
+// FindReplacementStrategy is FindReplacement that also returns the name of
+// the strategy that matched.
+func FindReplacementStrategy(content, oldString string, replaceAll bool) (string, string, error) {
+	for _, strategy := range AllReplacers {
+		matches := strategy.Replacer(content, oldString)
 		if len(matches) == 0 {
 			continue
 		}
@@ -492,7 +506,7 @@ func FindReplacement(content, oldString string, replaceAll bool) (string, error)
 		}
 
 		if replaceAll {
-			return search, nil
+			return search, strategy.Name, nil
 		}
 
 		lastIndex := strings.LastIndex(content, search)
@@ -500,8 +514,8 @@ func FindReplacement(content, oldString string, replaceAll bool) (string, error)
 			continue
 		}
 
-		return search, nil
+		return search, strategy.Name, nil
 	}
 
-	return "", nil
+	return "", "", nil
 }
@@ -274,8 +274,8 @@ func TestAllReplacersExist(t *testing.T) {
 	find := "hello"
 
 	foundMatch := false
-	for _, replacer := range AllReplacers {
-		result := replacer(content, find)
+	for _, strategy := range AllReplacers {
+		result := strategy.Replacer(content, find)
 		if len(result) > 0 {
 			foundMatch = true
 			break
@@ -0,0 +1,145 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	ErrFileNotRead  = errors.New("file has not been read")
+	ErrFileModified = errors.New("file was modified since it was last read")
+)
+
+// Each session tracks at most trackedFiles files, forgetting the least
+// recently read ones, and sessions that read or write nothing for
+// trackedSessionIdle are forgotten. A forgotten file must be read again.
+const (
+	trackedFiles       = 1000
+	trackedSessionIdle = 24 * time.Hour
+)
+
+// fileStamp identifies a version of a file by its size and modification
+// time, so files are never read just to be tracked.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// ReadTracker remembers, per session, the version of each file the session
+// last read or wrote.
+type ReadTracker struct {
+	mu       sync.Mutex
+	sessions map[string]*trackedSession
+}
+
+type trackedSession struct {
+	used  time.Time
+	files map[string]trackedFile
+}
+
+type trackedFile struct {
+	stamp fileStamp
+	used  time.Time
+}
+
+func NewReadTracker() *ReadTracker {
+	return &ReadTracker{sessions: make(map[string]*trackedSession)}
+}
+
+func (t *ReadTracker) get(session, path string) (fileStamp, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.sessions[session]
+	if s == nil {
+		return fileStamp{}, false
+	}
+	file, ok := s.files[path]
+	return file.stamp, ok
+}
+
+func (t *ReadTracker) set(session, path string, stamp fileStamp) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for id, s := range t.sessions {
+		if now.Sub(s.used) > trackedSessionIdle {
+			delete(t.sessions, id)
+		}
+	}
+
+	s := t.sessions[session]
+	if s == nil {
+		s = &trackedSession{files: make(map[string]trackedFile)}
+		t.sessions[session] = s
+	}
+	s.used = now
+	if _, ok := s.files[path]; !ok && len(s.files) >= trackedFiles {
+		s.forgetOldest()
+	}
+	s.files[path] = trackedFile{stamp: stamp, used: now}
+}
+
+func (s *trackedSession) forgetOldest() {
+	var oldest string
+	var used time.Time
+	for path, file := range s.files {
+		if oldest == "" || file.used.Before(used) {
+			oldest, used = path, file.used
+		}
+	}
+	delete(s.files, oldest)
+}
+
+func stampFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// markRead records the current version of path as known to the session.
+func (m *Manager) markRead(path string) {
+	if m.reads == nil {
+		return
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	if stamp, err := stampFile(path); err == nil {
+		m.reads.set(m.session, path, stamp)
+	}
+}
+
+// checkRead fails unless the session read or wrote the current version of
+// path. Files that do not exist yet may always be written.
+func (m *Manager) checkRead(path string) error {
+	if m.reads == nil {
+		return nil
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	known, ok := m.reads.get(m.session, path)
+	if !ok {
+		return fmt.Errorf("%w: read %s before writing it", ErrFileNotRead, path)
+	}
+	if !info.ModTime().Equal(known.modTime) || info.Size() != known.size {
+		return fmt.Errorf("%w: read %s again before writing it", ErrFileModified, path)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deep-agent/sandbox/pkg/ctxutil"
+)
+
+func TestReadTracker(t *testing.T) {
+	m := NewManager(WithReadTracker(NewReadTracker()))
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	os.WriteFile(file, []byte("package main\n"), 0644)
+
+	if err := m.WriteFile(filepath.Join(dir, "new.go"), "package main\n"); err != nil {
+		t.Errorf("expected new files to be writable without reading: %v", err)
+	}
+	if _, err := m.EditFile(file, "main", "app", EditOptions{}); !errors.Is(err, ErrFileNotRead) {
+		t.Fatalf("expected ErrFileNotRead, got %v", err)
+	}
+
+	if _, err := m.ReadFileWithOptions(file, ReadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.EditFile(file, "main", "app", EditOptions{}); err != nil {
+		t.Fatalf("edit after read failed: %v", err)
+	}
+	if _, err := m.EditFile(file, "app", "lib", EditOptions{}); err != nil {
+		t.Fatalf("expected the session's own edit to keep the file known: %v", err)
+	}
+
+	// Files are compared by size and modification time only.
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(file, later, later)
+	if err := m.WriteFile(file, "package lib\n"); !errors.Is(err, ErrFileModified) {
+		t.Fatalf("expected a touched file to be stale, got %v", err)
+	}
+
+	os.WriteFile(file, []byte("changed outside\n"), 0644)
+	if err := m.WriteFile(file, "package lib\n"); !errors.Is(err, ErrFileModified) {
+		t.Fatalf("expected ErrFileModified, got %v", err)
+	}
+	if got := readString(t, file); got != "changed outside\n" {
+		t.Errorf("expected stale file to be left alone, got %q", got)
+	}
+
+	other, _ := m.ForContext(ctxutil.WithSessionID(context.Background(), "other"))
+	if _, err := other.ReadFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile(file, "package lib\n"); !errors.Is(err, ErrFileModified) {
+		t.Errorf("expected reads of another session not to count, got %v", err)
+	}
+	if err := other.WriteFile(file, "package lib\n"); err != nil {
+		t.Errorf("write after read failed: %v", err)
+	}
+}
+
+func TestReadTracker_Bounds(t *testing.T) {
+	tracker := NewReadTracker()
+	stamp := fileStamp{size: 1}
+	for i := 0; i <= trackedFiles; i++ {
+		tracker.set("s1", fmt.Sprintf("/f%d", i), stamp)
+	}
+	if _, ok := tracker.get("s1", "/f0"); ok {
+		t.Error("expected the least recently read file to be forgotten")
+	}
+	if _, ok := tracker.get("s1", fmt.Sprintf("/f%d", trackedFiles)); !ok {
+		t.Error("expected the newest file to be tracked")
+	}
+
+	tracker.sessions["s1"].used = time.Now().Add(-trackedSessionIdle - time.Minute)
+	tracker.set("s2", "/f0", stamp)
+	if _, ok := tracker.get("s1", "/f1"); ok {
+		t.Error("expected an idle session to be forgotten")
+	}
+}
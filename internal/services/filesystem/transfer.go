@@ -0,0 +1,185 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+var (
+	ErrUploadOffset     = errors.New("upload offset does not match the uploaded size")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// OpenFile opens the regular file at path for reading with the credentials
+// of the manager's user.
+func (m *Manager) OpenFile(path string) (*os.File, error) {
+	return runAs(m, func() (*os.File, error) {
+		absPath, err := m.validateReadPath(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+			f.Close()
+			return nil, fmt.Errorf("failed to open file: %s is not a regular file", absPath)
+		}
+		m.markRead(absPath)
+		return f, nil
+	})
+}
+
+type UploadOptions struct {
+	// Offset is where the data starts in the file. Zero starts a new upload;
+	// a resumed upload continues at the size UploadStatus reports.
+	Offset int64
+	// Partial keeps the upload open for more chunks. Otherwise the upload is
+	// completed and replaces the file.
+	Partial bool
+	// SHA256 is the expected hex checksum of the whole file, verified when
+	// the upload is completed.
+	SHA256 string
+}
+
+type UploadResult struct {
+	Path     string
+	Size     int64
+	SHA256   string
+	Complete bool
+}
+
+// Upload streams r into path. The data is collected in a hidden file next to
+// path, so a failed or partial upload leaves path untouched, and is renamed
+// over path when the upload is completed.
+func (m *Manager) Upload(path string, r io.Reader, opts UploadOptions) (*UploadResult, error) {
+	if opts.Partial {
+		return runAs(m, func() (*UploadResult, error) { return m.upload(path, r, opts) })
+	}
+	var result *UploadResult
+	err := m.journal(OpWrite, path, "", func() error {
+		var err error
+		result, err = m.upload(path, r, opts)
+		return err
+	})
+	return result, err
+}
+
+func (m *Manager) upload(path string, r io.Reader, opts UploadOptions) (*UploadResult, error) {
+	absPath, err := m.validatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Offset < 0 {
+		return nil, fmt.Errorf("invalid offset: %d", opts.Offset)
+	}
+	part := uploadPartPath(absPath)
+
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	// The part file is confined by its name alone, so it must not be a
+	// symlink or anything else but a regular file.
+	if info, err := os.Lstat(part); err == nil && !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("failed to write file: %s is not a regular file", part)
+	}
+	flags := os.O_WRONLY | syscall.O_NOFOLLOW
+	if opts.Offset == 0 {
+		flags |= os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(part, flags, 0644)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no upload in progress for %s", ErrUploadOffset, absPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("failed to write file: %s is not a regular file", part)
+	}
+	if info.Size() != opts.Offset {
+		return nil, fmt.Errorf("%w: %d bytes uploaded, got offset %d", ErrUploadOffset, info.Size(), opts.Offset)
+	}
+
+	// A new upload is hashed as it is written; a resumed one is hashed
+	// from disk once complete.
+	var h hash.Hash
+	w := io.Writer(f)
+	if opts.Offset == 0 && !opts.Partial {
+		h = sha256.New()
+		w = io.MultiWriter(f, h)
+	}
+	if _, err := f.Seek(opts.Offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	result := &UploadResult{Path: absPath, Size: opts.Offset + n}
+	if opts.Partial {
+		return result, nil
+	}
+
+	if h != nil {
+		result.SHA256 = hex.EncodeToString(h.Sum(nil))
+	} else if result.SHA256, err = hashFile(part); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if opts.SHA256 != "" && !strings.EqualFold(opts.SHA256, result.SHA256) {
+		os.Remove(part)
+		return nil, fmt.Errorf("%w: expected sha256 %s, got %s", ErrChecksumMismatch, opts.SHA256, result.SHA256)
+	}
+	if err := os.Rename(part, absPath); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	m.markRead(absPath)
+	result.Complete = true
+	return result, nil
+}
+
+// UploadStatus returns how many bytes of an upload to path were received, or
+// zero when none is in progress.
+func (m *Manager) UploadStatus(path string) (int64, error) {
+	return runAs(m, func() (int64, error) {
+		absPath, err := m.validatePath(path)
+		if err != nil {
+			return 0, err
+		}
+		part := uploadPartPath(absPath)
+		info, err := os.Lstat(part)
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !info.Mode().IsRegular() {
+			return 0, fmt.Errorf("%s is not a regular file", part)
+		}
+		return info.Size(), nil
+	})
+}
+
+func uploadPartPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".upload")
+}
@@ -0,0 +1,119 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpload(t *testing.T) {
+	m := NewManager()
+	file := filepath.Join(t.TempDir(), "out", "data.bin")
+	sum := sha256.Sum256([]byte("hello world"))
+
+	result, err := m.Upload(file, strings.NewReader("hello world"), UploadOptions{SHA256: hex.EncodeToString(sum[:])})
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if !result.Complete || result.Size != 11 || result.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if got := readString(t, file); got != "hello world" {
+		t.Errorf("unexpected content %q", got)
+	}
+
+	_, err = m.Upload(file, strings.NewReader("corrupted"), UploadOptions{SHA256: hex.EncodeToString(sum[:])})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if got := readString(t, file); got != "hello world" {
+		t.Errorf("expected a failed upload to leave the file alone, got %q", got)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(file)); len(entries) != 1 {
+		t.Errorf("expected the partial upload to be removed, got %d entries", len(entries))
+	}
+}
+
+func TestUpload_Resumable(t *testing.T) {
+	m := NewManager()
+	file := filepath.Join(t.TempDir(), "big.bin")
+	sum := sha256.Sum256([]byte("chunk1chunk2"))
+
+	if _, err := m.Upload(file, strings.NewReader("chunk1"), UploadOptions{Partial: true}); err != nil {
+		t.Fatalf("first chunk failed: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("expected the file to appear only when the upload completes")
+	}
+	if offset, _ := m.UploadStatus(file); offset != 6 {
+		t.Errorf("expected upload status 6, got %d", offset)
+	}
+
+	_, err := m.Upload(file, strings.NewReader("chunk2"), UploadOptions{Offset: 3})
+	if !errors.Is(err, ErrUploadOffset) {
+		t.Fatalf("expected ErrUploadOffset, got %v", err)
+	}
+
+	result, err := m.Upload(file, strings.NewReader("chunk2"), UploadOptions{Offset: 6, SHA256: hex.EncodeToString(sum[:])})
+	if err != nil {
+		t.Fatalf("last chunk failed: %v", err)
+	}
+	if !result.Complete || result.Size != 12 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if got := readString(t, file); got != "chunk1chunk2" {
+		t.Errorf("unexpected content %q", got)
+	}
+	if offset, _ := m.UploadStatus(file); offset != 0 {
+		t.Errorf("expected no upload in progress, got %d", offset)
+	}
+}
+
+func TestUpload_SymlinkedPart(t *testing.T) {
+	m := NewManager()
+	base := t.TempDir()
+	outside := filepath.Join(base, "outside.txt")
+	os.WriteFile(outside, []byte("secret"), 0644)
+	file := filepath.Join(base, "workspace", "data.bin")
+	os.MkdirAll(filepath.Dir(file), 0755)
+	if err := os.Symlink(outside, uploadPartPath(file)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []UploadOptions{{}, {Partial: true}, {Offset: 6}} {
+		if _, err := m.Upload(file, strings.NewReader("hello"), opts); err == nil {
+			t.Errorf("expected upload %+v through a symlinked part file to fail", opts)
+		}
+	}
+	if got := readString(t, outside); got != "secret" {
+		t.Errorf("expected the symlink target to be left alone, got %q", got)
+	}
+	if _, err := m.UploadStatus(file); err == nil {
+		t.Error("expected UploadStatus to reject a symlinked part file")
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	m := NewManager()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	os.WriteFile(file, []byte("content"), 0644)
+
+	f, err := m.OpenFile(file)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer f.Close()
+	if content, _ := io.ReadAll(f); string(content) != "content" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if _, err := m.OpenFile(dir); err == nil {
+		t.Error("expected error opening a directory")
+	}
+}
@@ -0,0 +1,242 @@
+package filesystem
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+const (
+	TreeSortName  = "name"
+	TreeSortSize  = "size"
+	TreeSortMtime = "mtime"
+)
+
+const (
+	DefaultTreeLimit = 1000
+	// maxTreeEntries bounds how much of a tree is walked for one listing;
+	// Total stops counting there.
+	maxTreeEntries = 100_000
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+type TreeOptions struct {
+	// Depth is how many levels below the directory are listed, all of them
+	// when zero.
+	Depth int
+	// Ignore skips the entries matching a pattern, in .gitignore syntax
+	// relative to the directory. Gitignore also skips what the .gitignore
+	// files in the tree ignore, and .git directories.
+	Ignore    []string
+	Gitignore bool
+	// Sort orders siblings by TreeSortName, the default, TreeSortSize or
+	// TreeSortMtime, descending with Reverse.
+	Sort    string
+	Reverse bool
+	// Offset and Limit select a page of the listing; Limit is
+	// DefaultTreeLimit when zero.
+	Offset int
+	Limit  int
+}
+
+// Tree lists the directory at path recursively, depth first with every
+// directory followed by its entries. Symlinks are reported with their target
+// rather than followed, and entries that cannot be read are reported with
+// the error.
+func (m *Manager) Tree(path string, opts TreeOptions) (*model.FileTreeResult, error) {
+	return runAs(m, func() (*model.FileTreeResult, error) { return m.tree(path, opts) })
+}
+
+func (m *Manager) tree(dir string, opts TreeOptions) (*model.FileTreeResult, error) {
+	absPath, err := m.validateReadPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := compilePatterns(opts.Ignore)
+	if err != nil {
+		return nil, err
+	}
+	compare, err := treeCompare(opts.Sort, opts.Reverse)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("failed to list %s: %w", absPath, syscall.ENOTDIR)
+	}
+
+	t := &treeWalk{
+		ignore:  ignore,
+		depth:   opts.Depth,
+		compare: compare,
+	}
+	if opts.Gitignore {
+		t.ignores = gitignores{}
+	}
+	if err := t.walk(absPath, ".", 1); err != nil {
+		return nil, fmt.Errorf("failed to list directory: %w", err)
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultTreeLimit
+	}
+	start := min(max(opts.Offset, 0), len(t.items))
+	end := min(start+limit, len(t.items))
+	result := &model.FileTreeResult{
+		Path:      absPath,
+		Entries:   make([]model.FileTreeEntry, 0, end-start),
+		Total:     len(t.items),
+		Truncated: t.capped || end < len(t.items),
+	}
+	for _, item := range t.items[start:end] {
+		// Only the files returned are sniffed, which reads them.
+		if item.mode.IsRegular() {
+			if head, err := readHead(item.Path); err == nil {
+				_, item.MimeType = detectFileType(item.Path, head)
+			}
+		}
+		result.Entries = append(result.Entries, item.FileTreeEntry)
+	}
+	return result, nil
+}
+
+type treeItem struct {
+	model.FileTreeEntry
+	mode fs.FileMode
+}
+
+type treeWalk struct {
+	ignore  []pathPattern
+	ignores gitignores
+	depth   int
+	compare func(a, b *treeItem) int
+	items   []treeItem
+	capped  bool
+}
+
+// walk adds the entries of the directory at p, rel from the root, which are
+// depth levels below it.
+func (t *treeWalk) walk(p, rel string, depth int) error {
+	dirEntries, err := os.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	if t.ignores != nil {
+		t.ignores.enter(p, rel)
+	}
+
+	var children []*treeItem
+	for _, d := range dirEntries {
+		childRel := path.Join(rel, d.Name())
+		if matchAny(t.ignore, childRel, d.IsDir()) || (t.ignores != nil && t.ignores.ignored(childRel, d.IsDir())) {
+			continue
+		}
+		item := &treeItem{FileTreeEntry: model.FileTreeEntry{
+			Name:  d.Name(),
+			Path:  filepath.Join(p, d.Name()),
+			Depth: depth,
+			IsDir: d.IsDir(),
+		}}
+		if info, err := d.Info(); err != nil {
+			item.Error = treeError(err)
+		} else {
+			item.mode = info.Mode()
+			item.Size = info.Size()
+			item.Mode = info.Mode().String()
+			item.ModTimeUnix = info.ModTime().Unix()
+			if info.Mode()&fs.ModeSymlink != 0 {
+				if target, err := os.Readlink(item.Path); err == nil {
+					item.LinkTarget = target
+				}
+			}
+		}
+		children = append(children, item)
+	}
+	slices.SortStableFunc(children, t.compare)
+
+	for _, item := range children {
+		if len(t.items) >= maxTreeEntries {
+			t.capped = true
+			return nil
+		}
+		t.items = append(t.items, *item)
+		if !item.IsDir || (t.depth > 0 && depth >= t.depth) {
+			continue
+		}
+		i := len(t.items) - 1
+		if err := t.walk(item.Path, path.Join(rel, item.Name), depth+1); err != nil {
+			t.items[i].Error = treeError(err)
+		}
+	}
+	return nil
+}
+
+func treeCompare(sort string, reverse bool) (func(a, b *treeItem) int, error) {
+	var compare func(a, b *treeItem) int
+	switch sort {
+	case "", TreeSortName:
+		compare = func(a, b *treeItem) int { return strings.Compare(a.Name, b.Name) }
+	case TreeSortSize:
+		compare = func(a, b *treeItem) int {
+			return cmp.Or(cmp.Compare(a.Size, b.Size), strings.Compare(a.Name, b.Name))
+		}
+	case TreeSortMtime:
+		compare = func(a, b *treeItem) int {
+			return cmp.Or(cmp.Compare(a.ModTimeUnix, b.ModTimeUnix), strings.Compare(a.Name, b.Name))
+		}
+	default:
+		return nil, fmt.Errorf("%w %q: must be name, size or mtime", ErrInvalidSort, sort)
+	}
+	if reverse {
+		return func(a, b *treeItem) int { return compare(b, a) }, nil
+	}
+	return compare, nil
+}
+
+// treeError describes why an entry could not be read without repeating its
+// path.
+func treeError(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return err.Error()
+}
+
+// RenderTree renders a tree listing compactly as an indented list with one
+// entry per line, directories ending in a slash.
+func RenderTree(result *model.FileTreeResult) string {
+	var b strings.Builder
+	b.WriteString("- " + strings.TrimSuffix(result.Path, "/") + "/\n")
+	for _, e := range result.Entries {
+		b.WriteString(strings.Repeat("  ", e.Depth) + "- " + e.Name)
+		switch {
+		case e.IsDir:
+			b.WriteString("/")
+		case e.LinkTarget != "":
+			b.WriteString(" -> " + e.LinkTarget)
+		}
+		if e.Error != "" {
+			b.WriteString(" (" + e.Error + ")")
+		}
+		b.WriteString("\n")
+	}
+	if result.Truncated {
+		fmt.Fprintf(&b, "\n(%d of %d entries shown; list the rest with an offset, a narrower path or a smaller depth)\n", len(result.Entries), result.Total)
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func treePaths(t *testing.T, root string, opts TreeOptions) []string {
+	t.Helper()
+	result, err := NewManager().Tree(root, opts)
+	if err != nil {
+		t.Fatalf("Tree() error = %v", err)
+	}
+	var paths []string
+	for _, e := range result.Entries {
+		rel, _ := filepath.Rel(root, e.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return paths
+}
+
+func TestTree(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"README.md":         "# readme\n",
+		"src/main.go":       "package main\n",
+		"src/lib/util.go":   "package lib\n",
+		"build/out.bin":     "\x00\x01",
+		"node_modules/x.js": "x",
+		".gitignore":        "build/\n",
+		".git/HEAD":         "ref\n",
+	})
+	os.Symlink("README.md", filepath.Join(root, "link.md"))
+
+	m := NewManager()
+	result, err := m.Tree(root, TreeOptions{Ignore: []string{"node_modules"}, Gitignore: true})
+	if err != nil {
+		t.Fatalf("Tree() error = %v", err)
+	}
+	want := []string{".gitignore", "README.md", "link.md", "src", "src/lib", "src/lib/util.go", "src/main.go"}
+	var got []string
+	entries := map[string]int{}
+	for i, e := range result.Entries {
+		rel, _ := filepath.Rel(root, e.Path)
+		got = append(got, filepath.ToSlash(rel))
+		entries[filepath.ToSlash(rel)] = i
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("listed %v, want %v", got, want)
+	}
+	if result.Total != len(want) || result.Truncated {
+		t.Errorf("unexpected total %d, truncated %v", result.Total, result.Truncated)
+	}
+
+	readme := result.Entries[entries["README.md"]]
+	if readme.Size != 9 || readme.Depth != 1 || readme.Mode != "-rw-r--r--" || readme.ModTimeUnix == 0 ||
+		!strings.HasPrefix(readme.MimeType, "text/plain") {
+		t.Errorf("unexpected README.md entry %+v", readme)
+	}
+	if link := result.Entries[entries["link.md"]]; link.LinkTarget != "README.md" || link.MimeType != "" {
+		t.Errorf("unexpected link.md entry %+v", link)
+	}
+	if lib := result.Entries[entries["src/lib"]]; !lib.IsDir || lib.Depth != 2 {
+		t.Errorf("unexpected src/lib entry %+v", lib)
+	}
+
+	text := RenderTree(result)
+	for _, line := range []string{"- " + root + "/\n", "  - link.md -> README.md\n", "  - src/\n", "    - lib/\n", "      - util.go\n"} {
+		if !strings.Contains(text, line) {
+			t.Errorf("expected rendered tree to contain %q, got:\n%s", line, text)
+		}
+	}
+}
+
+func TestTree_DepthSortAndPages(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.txt":       "aaa",
+		"b.txt":       "b",
+		"c/d.txt":     "dd",
+		"c/e/f.txt":   "f",
+		"c/e/g/h.txt": "h",
+	})
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(root, "b.txt"), old, old)
+
+	if got, want := treePaths(t, root, TreeOptions{Depth: 2}), []string{"a.txt", "b.txt", "c", "c/d.txt", "c/e"}; !slices.Equal(got, want) {
+		t.Errorf("depth 2: got %v, want %v", got, want)
+	}
+	if got, want := treePaths(t, root, TreeOptions{Sort: TreeSortSize, Reverse: true, Ignore: []string{"e/"}}), []string{"c", "c/d.txt", "a.txt", "b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("by size: got %v, want %v", got, want)
+	}
+	if got := treePaths(t, root, TreeOptions{Depth: 1, Sort: TreeSortMtime}); got[0] != "b.txt" {
+		t.Errorf("by mtime: expected b.txt first, got %v", got)
+	}
+
+	result, err := NewManager().Tree(root, TreeOptions{Offset: 2, Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 8 || !result.Truncated || len(result.Entries) != 3 || result.Entries[0].Name != "c" {
+		t.Errorf("unexpected page %+v", result)
+	}
+	if !strings.Contains(RenderTree(result), "(3 of 8 entries shown") {
+		t.Errorf("expected the rendered tree to say it is cut off, got:\n%s", RenderTree(result))
+	}
+}
+
+func TestTree_Errors(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.txt": "a"})
+	m := NewManager()
+
+	if _, err := m.Tree(filepath.Join(root, "missing"), TreeOptions{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if _, err := m.Tree(filepath.Join(root, "a.txt"), TreeOptions{}); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("expected ENOTDIR, got %v", err)
+	}
+	if _, err := m.Tree(root, TreeOptions{Sort: "color"}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("expected ErrInvalidSort, got %v", err)
+	}
+
+	if os.Geteuid() == 0 {
+		return
+	}
+	locked := filepath.Join(root, "locked")
+	os.Mkdir(locked, 0)
+	defer os.Chmod(locked, 0755)
+	result, err := m.Tree(root, TreeOptions{})
+	if err != nil {
+		t.Fatalf("Tree() error = %v", err)
+	}
+	if e := result.Entries[1]; e.Name != "locked" || e.Error == "" {
+		t.Errorf("expected an error for the unreadable directory, got %+v", e)
+	}
+}
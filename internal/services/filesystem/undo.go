@@ -0,0 +1,315 @@
+package filesystem
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// absPath validates path for modification and makes it absolute, so history
+// entries name a file the same way however it was addressed.
+func (m *Manager) absPath(path string) (string, error) {
+	p, err := m.validatePath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(p)
+}
+
+// journal runs fn with the credentials of the manager's user and records the
+// change it makes to path, and to dest for moves and copies, in the history.
+func (m *Manager) journal(op, path, dest string, fn func() error) error {
+	if m.history == nil {
+		return m.run(fn)
+	}
+
+	var change *Change
+	var blobs map[string][]byte
+	err := m.run(func() error {
+		change, blobs = m.captureBefore(op, path, dest)
+		if err := fn(); err != nil {
+			return err
+		}
+		if change != nil {
+			change.After = captureAfter(change)
+		}
+		return nil
+	})
+	if err != nil || change == nil {
+		return err
+	}
+
+	m.record(change, blobs)
+	return nil
+}
+
+// journalFiles is journal for an operation changing several files, given as
+// absolute paths.
+func (m *Manager) journalFiles(op string, paths []string, fn func() error) error {
+	if m.history == nil || len(paths) == 0 {
+		return m.run(fn)
+	}
+
+	change := &Change{Op: op, Path: paths[0], Paths: paths}
+	c := newCapture(m.history.maxSize)
+	err := m.run(func() error {
+		for _, p := range paths {
+			states, err := c.states(p, true)
+			if err != nil {
+				c.incomplete = "failed to read previous state: " + err.Error()
+			}
+			change.Before = append(change.Before, states...)
+		}
+		change.Incomplete = c.incomplete
+
+		if err := fn(); err != nil {
+			return err
+		}
+		change.After = captureAfter(change)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.record(change, c.blobs)
+	return nil
+}
+
+// record adds a change to the history. A change that cannot be recorded is
+// logged but does not fail the operation that made it.
+func (m *Manager) record(change *Change, blobs map[string][]byte) {
+	if err := m.history.record(m.session, change, blobs); err != nil {
+		log.Printf("failed to record %s of %s in file history: %v", change.Op, change.Path, err)
+	}
+}
+
+// captureBefore returns the change with the states undoing it restores. It
+// returns nil for paths the operation will reject anyway.
+func (m *Manager) captureBefore(op, path, dest string) (*Change, map[string][]byte) {
+	src, err := m.absPath(path)
+	if op == OpCopy {
+		// Copies may read from the read-only roots.
+		if src, err = m.validateReadPath(path); err == nil {
+			src, err = filepath.Abs(src)
+		}
+	}
+	if err != nil {
+		return nil, nil
+	}
+	change := &Change{Op: op, Path: src}
+	if op == OpMove || op == OpCopy {
+		if change.Dest, err = m.absPath(dest); err != nil {
+			return nil, nil
+		}
+	}
+
+	c := newCapture(m.history.maxSize)
+	var states []FileState
+	switch op {
+	case OpMove:
+		// Moving back restores the source; only a replaced destination
+		// needs its content.
+		var s FileState
+		if s, err = c.state(change.Path, false); err == nil {
+			states = append(states, s)
+			if s, err = c.state(change.Dest, true); err == nil {
+				states = append(states, s)
+			}
+		}
+	case OpCopy:
+		states, err = c.states(change.Dest, true)
+	default:
+		states, err = c.states(change.Path, true)
+	}
+	if err != nil {
+		c.incomplete = "failed to read previous state: " + err.Error()
+	}
+
+	change.Before = states
+	change.Incomplete = c.incomplete
+	return change, c.blobs
+}
+
+func captureAfter(change *Change) []FileState {
+	paths := []string{change.Path}
+	switch change.Op {
+	case OpMove:
+		paths = append(paths, change.Dest)
+	case OpCopy:
+		paths = []string{change.Dest}
+	case OpPatch, OpExtract:
+		paths = change.Paths
+	}
+
+	c := newCapture(0)
+	states := make([]FileState, 0, len(paths))
+	for _, p := range paths {
+		s, err := c.state(p, false)
+		if err != nil {
+			s = FileState{Path: p, Exists: true, Type: "unknown"}
+		}
+		states = append(states, s)
+	}
+	return states
+}
+
+// History returns the journaled changes of the manager's session to path, or
+// all of them when path is empty, newest first.
+func (m *Manager) History(path string, limit int) ([]Change, error) {
+	if m.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	if path != "" {
+		var err error
+		if path, err = m.absPath(path); err != nil {
+			return nil, err
+		}
+	}
+	return m.history.Changes(m.session, path, limit)
+}
+
+type UndoOptions struct {
+	// ID undoes one change. Otherwise the last Count changes of the session
+	// are undone, counting only changes to Path when it is set.
+	ID    string
+	Path  string
+	Count int
+	// Force undoes a change even though its paths were modified since.
+	Force bool
+}
+
+// Undo reverts journaled changes, newest first, and returns the changes it
+// undid. It stops at the first change that cannot be undone; a change whose
+// paths were modified since fails with ErrUndoConflict unless forced.
+func (m *Manager) Undo(opts UndoOptions) ([]Change, error) {
+	if m.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	path := opts.Path
+	if path != "" {
+		var err error
+		if path, err = m.absPath(path); err != nil {
+			return nil, err
+		}
+	}
+
+	undone := []Change{}
+	err := m.history.withLock(func() error {
+		j, err := m.history.load(m.session)
+		if err != nil {
+			return err
+		}
+		targets, err := selectChanges(j, opts.ID, path, opts.Count)
+		if err != nil {
+			return err
+		}
+
+		for _, i := range targets {
+			c := &j.Changes[i]
+			if err = m.undoChange(c, opts.Force); err != nil {
+				err = fmt.Errorf("failed to undo %s: %w", c.ID, err)
+				break
+			}
+			c.Undone = true
+			undone = append(undone, *c)
+		}
+		if len(undone) > 0 {
+			if serr := m.history.save(m.session, j); err == nil {
+				err = serr
+			}
+		}
+		return err
+	})
+	return undone, err
+}
+
+// selectChanges returns the indexes of the changes to undo, newest first.
+func selectChanges(j *journal, id, path string, count int) ([]int, error) {
+	if id != "" {
+		for i := range j.Changes {
+			if j.Changes[i].ID != id {
+				continue
+			}
+			if j.Changes[i].Undone {
+				return nil, fmt.Errorf("change %s was already undone", id)
+			}
+			return []int{i}, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrChangeNotFound, id)
+	}
+
+	if count <= 0 {
+		count = 1
+	}
+	var targets []int
+	for i := len(j.Changes) - 1; i >= 0 && len(targets) < count; i-- {
+		c := &j.Changes[i]
+		if c.Undone || (path != "" && !c.touches(path)) {
+			continue
+		}
+		targets = append(targets, i)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: nothing to undo", ErrChangeNotFound)
+	}
+	return targets, nil
+}
+
+func (m *Manager) undoChange(c *Change, force bool) error {
+	if c.Incomplete != "" {
+		return fmt.Errorf("change cannot be undone: %s", c.Incomplete)
+	}
+	for _, s := range append(append([]FileState{}, c.Before...), c.After...) {
+		if _, err := m.validatePath(s.Path); err != nil {
+			return err
+		}
+	}
+
+	// A move is undone by moving back, so only the replaced destination is
+	// restored from the history.
+	restore := c.Before
+	if c.Op == OpMove && len(c.Before) == 2 {
+		restore = c.Before[1:]
+	}
+	contents := map[string][]byte{}
+	for _, s := range restore {
+		if s.Exists && s.Type == stateFile {
+			content, err := m.history.readBlob(m.session, s.Hash)
+			if err != nil {
+				return err
+			}
+			contents[s.Hash] = content
+		}
+	}
+
+	return m.run(func() error {
+		if !force {
+			for _, s := range c.After {
+				same, err := sameState(s)
+				if err != nil {
+					return err
+				}
+				if !same {
+					return fmt.Errorf("%w: %s was modified after %s", ErrUndoConflict, s.Path, c.ID)
+				}
+			}
+		}
+
+		if c.Op == OpMove {
+			if err := os.Rename(c.Dest, c.Path); err != nil {
+				return err
+			}
+		}
+		for _, s := range restore {
+			if c.Op == OpMove && !s.Exists {
+				continue
+			}
+			if err := restoreState(s, contents); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
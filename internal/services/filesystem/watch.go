@@ -0,0 +1,315 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	WatchCreate = "create"
+	WatchModify = "modify"
+	WatchDelete = "delete"
+	// WatchRename reports the old path of a renamed file; the new path,
+	// when it is inside the watched directory, is reported as created.
+	WatchRename = "rename"
+)
+
+const (
+	DefaultWatchDebounce = 100 * time.Millisecond
+	// maxWatchDirs bounds the inotify watches one Watcher holds.
+	maxWatchDirs = 10000
+)
+
+var ErrWatchLimit = errors.New("too many directories to watch")
+
+type WatchOptions struct {
+	// Include reports only the paths matching a pattern and Exclude
+	// ignores the paths matching one. Patterns use .gitignore syntax
+	// relative to the watched directory; excluded directories are not
+	// watched at all.
+	Include []string
+	Exclude []string
+	// Debounce is how long changes are collected before they are reported
+	// together, DefaultWatchDebounce when zero.
+	Debounce time.Duration
+}
+
+type WatchEvent struct {
+	Type  string
+	Path  string
+	IsDir bool
+}
+
+// Watcher reports the changes below a directory tree.
+type Watcher struct {
+	m        *Manager
+	root     string
+	include  []pathPattern
+	exclude  []pathPattern
+	debounce time.Duration
+	fsw      *fsnotify.Watcher
+	// dirs are the watched directories. They are only used by Watch and
+	// then by Run.
+	dirs map[string]bool
+}
+
+// Watch starts watching the directory at path and everything below it.
+// Changes are reported by Run; Close stops the watch.
+func (m *Manager) Watch(path string, opts WatchOptions) (*Watcher, error) {
+	include, err := compilePatterns(opts.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compilePatterns(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		m:        m,
+		include:  include,
+		exclude:  exclude,
+		debounce: opts.Debounce,
+		dirs:     map[string]bool{},
+	}
+	if w.debounce <= 0 {
+		w.debounce = DefaultWatchDebounce
+	}
+
+	err = m.run(func() error {
+		absPath, err := m.validateReadPath(path)
+		if err != nil {
+			return err
+		}
+		if w.root, err = filepath.EvalSymlinks(absPath); err != nil {
+			return fmt.Errorf("failed to watch: %w", err)
+		}
+		info, err := os.Stat(w.root)
+		if err != nil {
+			return fmt.Errorf("failed to watch: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("failed to watch %s: %w", absPath, syscall.ENOTDIR)
+		}
+
+		if w.fsw, err = fsnotify.NewWatcher(); err != nil {
+			return fmt.Errorf("failed to watch: %w", err)
+		}
+		if err := w.addTree(w.root, nil); err != nil {
+			w.fsw.Close()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Root returns the watched directory with symlinks resolved, which the
+// reported paths start with.
+func (w *Watcher) Root() string {
+	return w.root
+}
+
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+// Run reports batches of changes to onEvents until ctx is done or the
+// watcher is closed. Errors that do not end the watch, such as events lost
+// to a full queue, go to onError.
+func (w *Watcher) Run(ctx context.Context, onEvents func([]WatchEvent), onError func(error)) {
+	b := &watchBatch{index: map[string]int{}}
+	var flush <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if err := w.handle(ev, b); err != nil {
+				onError(err)
+			}
+			if flush == nil && len(b.events) > 0 {
+				flush = time.After(w.debounce)
+			}
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			onError(err)
+		case <-flush:
+			flush = nil
+			if events := b.take(); len(events) > 0 {
+				onEvents(events)
+			}
+		}
+	}
+}
+
+func (w *Watcher) handle(ev fsnotify.Event, b *watchBatch) error {
+	switch {
+	case ev.Op&fsnotify.Create != 0:
+		info, err := os.Lstat(ev.Name)
+		if err != nil {
+			// Already gone again. fsnotify drops most of these itself, so
+			// files that only existed briefly may be reported as deleted.
+			return nil
+		}
+		if !w.report(b, WatchCreate, ev.Name, info.IsDir()) || !info.IsDir() {
+			return nil
+		}
+		// Entries created before the directory was watched are reported
+		// as created too.
+		err = w.m.run(func() error {
+			return w.addTree(ev.Name, func(p string, isDir bool) {
+				w.report(b, WatchCreate, p, isDir)
+			})
+		})
+		if errors.Is(err, os.ErrNotExist) {
+			// Removed again; its events follow.
+			return nil
+		}
+		return err
+	case ev.Op&fsnotify.Remove != 0:
+		w.report(b, WatchDelete, ev.Name, w.forget(ev.Name))
+	case ev.Op&fsnotify.Rename != 0:
+		w.report(b, WatchRename, ev.Name, w.forget(ev.Name))
+	case ev.Op&fsnotify.Write != 0:
+		w.report(b, WatchModify, ev.Name, false)
+	}
+	return nil
+}
+
+// report adds the change to the batch unless the filters leave the path
+// out, and says whether they did not.
+func (w *Watcher) report(b *watchBatch, typ, path string, isDir bool) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if matchAny(w.exclude, rel, isDir) {
+		return false
+	}
+	if len(w.include) > 0 {
+		included := matchAnyParent(w.include, rel) || (isDir && matchAny(w.include, rel, true))
+		// Directories are still watched for the files they may get.
+		if !included {
+			return isDir
+		}
+	}
+	b.add(WatchEvent{Type: typ, Path: path, IsDir: isDir})
+	return true
+}
+
+// forget stops watching a directory that was removed or renamed, and the
+// directories below it, and reports whether path was one.
+func (w *Watcher) forget(path string) bool {
+	if !w.dirs[path] {
+		return false
+	}
+	prefix := path + string(filepath.Separator)
+	for dir := range w.dirs {
+		if dir == path || strings.HasPrefix(dir, prefix) {
+			// A renamed directory keeps its watch under the old name.
+			w.fsw.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+	return true
+}
+
+// addTree watches dir and the directories below it that are not excluded,
+// calling found for every entry below dir.
+func (w *Watcher) addTree(dir string, found func(path string, isDir bool)) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p != dir {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(w.root, p)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); rel != "." && matchAny(w.exclude, rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if p != dir && found != nil {
+			found(p, d.IsDir())
+		}
+		if !d.IsDir() || w.dirs[p] {
+			return nil
+		}
+
+		if len(w.dirs) >= maxWatchDirs {
+			return fmt.Errorf("%w: more than %d, exclude some of them", ErrWatchLimit, maxWatchDirs)
+		}
+		if err := w.fsw.Add(p); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", p, err)
+		}
+		w.dirs[p] = true
+		return nil
+	})
+}
+
+// watchBatch collects the changes of one debounce interval, one per path.
+type watchBatch struct {
+	events []WatchEvent
+	index  map[string]int
+}
+
+func (b *watchBatch) add(e WatchEvent) {
+	i, ok := b.index[e.Path]
+	if !ok {
+		b.index[e.Path] = len(b.events)
+		b.events = append(b.events, e)
+		return
+	}
+
+	prev := &b.events[i]
+	switch {
+	case prev.Type == WatchCreate && e.Type == WatchModify:
+		// Still just created.
+	case prev.Type == WatchCreate && (e.Type == WatchDelete || e.Type == WatchRename):
+		// Gone before it was reported.
+		prev.Type = ""
+	case (prev.Type == WatchDelete || prev.Type == WatchRename) && e.Type == WatchCreate:
+		// Replaced, as editors saving through a temporary file do.
+		prev.Type = WatchModify
+		prev.IsDir = e.IsDir
+	default:
+		prev.Type = e.Type
+		prev.IsDir = e.IsDir || (prev.IsDir && e.Type != WatchCreate)
+	}
+}
+
+func (b *watchBatch) take() []WatchEvent {
+	events := make([]WatchEvent, 0, len(b.events))
+	for _, e := range b.events {
+		if e.Type != "" {
+			events = append(events, e)
+		}
+	}
+	b.events = b.events[:0]
+	clear(b.index)
+	return events
+}
@@ -0,0 +1,176 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startWatch watches dir and returns a function that waits for the next
+// batch of changes, keyed by path relative to dir.
+func startWatch(t *testing.T, dir string, opts WatchOptions) func() map[string]WatchEvent {
+	t.Helper()
+	if opts.Debounce == 0 {
+		opts.Debounce = 50 * time.Millisecond
+	}
+	w, err := NewManager().Watch(dir, opts)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		w.Close()
+	})
+
+	batches := make(chan []WatchEvent, 16)
+	go w.Run(ctx, func(events []WatchEvent) { batches <- events }, func(error) {})
+	return func() map[string]WatchEvent {
+		t.Helper()
+		select {
+		case events := <-batches:
+			changes := map[string]WatchEvent{}
+			for _, e := range events {
+				rel, _ := filepath.Rel(w.Root(), e.Path)
+				changes[filepath.ToSlash(rel)] = e
+			}
+			return changes
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for changes")
+			return nil
+		}
+	}
+}
+
+func TestWatch_Events(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a", "b.txt": "b", "old.txt": "old"})
+	next := startWatch(t, dir, WatchOptions{})
+
+	writeTree(t, dir, map[string]string{"a.txt": "changed", "new.txt": "new"})
+	os.Remove(filepath.Join(dir, "b.txt"))
+	os.Rename(filepath.Join(dir, "old.txt"), filepath.Join(dir, "renamed.txt"))
+
+	changes := next()
+	want := map[string]string{
+		"a.txt":       WatchModify,
+		"new.txt":     WatchCreate,
+		"b.txt":       WatchDelete,
+		"old.txt":     WatchRename,
+		"renamed.txt": WatchCreate,
+	}
+	if len(changes) != len(want) {
+		t.Errorf("got %+v, want %v", changes, want)
+	}
+	for rel, typ := range want {
+		if changes[rel].Type != typ {
+			t.Errorf("%s: got %q, want %q", rel, changes[rel].Type, typ)
+		}
+	}
+}
+
+func TestWatch_NewDirectories(t *testing.T) {
+	dir := t.TempDir()
+	next := startWatch(t, dir, WatchOptions{})
+
+	writeTree(t, dir, map[string]string{"sub/deep/a.txt": "a"})
+	changes := next()
+	for _, rel := range []string{"sub", "sub/deep", "sub/deep/a.txt"} {
+		if changes[rel].Type != WatchCreate {
+			t.Errorf("%s: expected create, got %+v", rel, changes)
+		}
+	}
+	if !changes["sub"].IsDir {
+		t.Error("expected sub to be reported as a directory")
+	}
+
+	// The new directories are watched too.
+	writeTree(t, dir, map[string]string{"sub/deep/a.txt": "changed"})
+	if changes := next(); changes["sub/deep/a.txt"].Type != WatchModify || len(changes) != 1 {
+		t.Errorf("expected a.txt to be modified, got %+v", changes)
+	}
+}
+
+func TestWatch_Filters(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"node_modules/x.js": "x", "src/a.go": "a"})
+	next := startWatch(t, dir, WatchOptions{
+		Include: []string{"*.go", "docs"},
+		Exclude: []string{"node_modules/"},
+	})
+
+	writeTree(t, dir, map[string]string{
+		"node_modules/x.js": "changed",
+		"src/a.go":          "changed",
+		"src/a.txt":         "a",
+		"pkg/b.go":          "b",
+		"docs/c.md":         "c",
+	})
+	changes := next()
+	want := map[string]string{
+		"src/a.go":  WatchModify,
+		"pkg/b.go":  WatchCreate,
+		"docs":      WatchCreate,
+		"docs/c.md": WatchCreate,
+	}
+	if len(changes) != len(want) {
+		t.Errorf("got %+v, want %v", changes, want)
+	}
+	for rel, typ := range want {
+		if changes[rel].Type != typ {
+			t.Errorf("%s: got %q, want %q", rel, changes[rel].Type, typ)
+		}
+	}
+}
+
+func TestWatch_Coalesce(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"saved.txt": "old"})
+	next := startWatch(t, dir, WatchOptions{Debounce: 200 * time.Millisecond})
+
+	// A file saved the way editors do, by moving the old one aside first,
+	// is reported as modified, and one written twice as created.
+	os.Rename(filepath.Join(dir, "saved.txt"), filepath.Join(dir, "saved.txt~"))
+	writeTree(t, dir, map[string]string{"saved.txt": "new", "log.txt": "1"})
+	f, err := os.OpenFile(filepath.Join(dir, "log.txt"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("2")
+	f.Close()
+
+	changes := next()
+	want := map[string]string{
+		"saved.txt":  WatchModify,
+		"saved.txt~": WatchCreate,
+		"log.txt":    WatchCreate,
+	}
+	if len(changes) != len(want) {
+		t.Errorf("got %+v, want %v", changes, want)
+	}
+	for rel, typ := range want {
+		if changes[rel].Type != typ {
+			t.Errorf("%s: got %q, want %q", rel, changes[rel].Type, typ)
+		}
+	}
+}
+
+func TestWatch_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a"})
+	m := NewManager()
+
+	if _, err := m.Watch(filepath.Join(dir, "missing"), WatchOptions{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if _, err := m.Watch(filepath.Join(dir, "a.txt"), WatchOptions{}); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("expected ENOTDIR, got %v", err)
+	}
+	if _, err := m.Watch(dir, WatchOptions{Include: []string{"[a-"}}); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("expected ErrInvalidPattern, got %v", err)
+	}
+}
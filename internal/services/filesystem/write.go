@@ -9,10 +9,17 @@ import (
 )
 
 func (m *Manager) WriteFile(path string, content string) error {
+	return m.journal(OpWrite, path, "", func() error { return m.writeFile(path, content) })
+}
+
+func (m *Manager) writeFile(path string, content string) error {
 	absPath, err := m.validatePath(path)
 	if err != nil {
 		return err
 	}
+	if err := m.checkRead(absPath); err != nil {
+		return err
+	}
 
 	dir := filepath.Dir(absPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -22,15 +29,23 @@ func (m *Manager) WriteFile(path string, content string) error {
 	if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
+	m.markRead(absPath)
 
 	return nil
 }
 
 func (m *Manager) WriteFileBase64(path string, contentBase64 string) error {
+	return m.journal(OpWrite, path, "", func() error { return m.writeFileBase64(path, contentBase64) })
+}
+
+func (m *Manager) writeFileBase64(path string, contentBase64 string) error {
 	absPath, err := m.validatePath(path)
 	if err != nil {
 		return err
 	}
+	if err := m.checkRead(absPath); err != nil {
+		return err
+	}
 
 	content, err := base64.StdEncoding.DecodeString(contentBase64)
 	if err != nil {
@@ -45,49 +60,172 @@ func (m *Manager) WriteFileBase64(path string, contentBase64 string) error {
 	if err := os.WriteFile(absPath, content, 0644); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
+	m.markRead(absPath)
 
 	return nil
 }
 
 type EditOptions struct {
 	ReplaceAll bool
+	// DryRun computes the edit without writing the file.
+	DryRun bool
 }
 
-func (m *Manager) EditFile(path string, oldString, newString string, opts EditOptions) error {
-	if oldString == newString {
-		return fmt.Errorf("old_string and new_string must be different")
+type EditResult struct {
+	// Diff is the change in unified diff format.
+	Diff string
+	// Strategy names the replacer that matched old_string; anything but
+	// "simple" means it did not match exactly.
+	Strategy     string
+	Replacements int
+}
+
+func (m *Manager) EditFile(path string, oldString, newString string, opts EditOptions) (*EditResult, error) {
+	if opts.DryRun {
+		return runAs(m, func() (*EditResult, error) { return m.editFile(path, oldString, newString, opts) })
 	}
+	var result *EditResult
+	err := m.journal(OpEdit, path, "", func() error {
+		var err error
+		result, err = m.editFile(path, oldString, newString, opts)
+		return err
+	})
+	return result, err
+}
 
-	content, err := os.ReadFile(path)
+func (m *Manager) editFile(path string, oldString, newString string, opts EditOptions) (*EditResult, error) {
+	absPath, err := m.validatePath(path)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, err
+	}
+	if err := m.checkRead(absPath); err != nil {
+		return nil, err
 	}
 
-	fileContent := string(content)
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
 
-	search, err := FindReplacement(fileContent, oldString, opts.ReplaceAll)
+	fileContent, strategy, replacements, err := applyEdit(string(content), oldString, newString, opts.ReplaceAll)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	result := &EditResult{
+		Diff:         UnifiedDiff(absPath, string(content), fileContent),
+		Strategy:     strategy,
+		Replacements: replacements,
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if err := os.WriteFile(absPath, []byte(fileContent), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	m.markRead(absPath)
+
+	return result, nil
+}
+
+// applyEdit replaces oldString in content and returns the new content, the
+// strategy that matched and the number of replacements.
+func applyEdit(content, oldString, newString string, replaceAll bool) (string, string, int, error) {
+	if oldString == newString {
+		return "", "", 0, fmt.Errorf("old_string and new_string must be different")
+	}
+
+	search, strategy, err := FindReplacementStrategy(content, oldString, replaceAll)
+	if err != nil {
+		return "", "", 0, err
 	}
 
 	if search == "" {
-		return fmt.Errorf("old_string not found in file")
+		return "", "", 0, fmt.Errorf("old_string not found in file")
+	}
+
+	if replaceAll {
+		return strings.ReplaceAll(content, search, newString), strategy, strings.Count(content, search), nil
+	}
+
+	index := strings.Index(content, search)
+	lastIndex := strings.LastIndex(content, search)
+	if index != lastIndex {
+		return "", "", 0, fmt.Errorf("found multiple matches for old_string. Provide more surrounding lines in old_string to identify the correct match")
+	}
+	return content[:index] + newString + content[index+len(search):], strategy, 1, nil
+}
+
+type Edit struct {
+	OldString  string
+	NewString  string
+	ReplaceAll bool
+}
+
+type MultiEditResult struct {
+	// Diff is the combined change in unified diff format.
+	Diff string
+	// Strategies names the replacer that matched each edit, in order.
+	Strategies   []string
+	Replacements int
+}
+
+// MultiEdit applies edits to one file in order, each to the result of the
+// previous one. The file is only written when every edit applies.
+func (m *Manager) MultiEdit(path string, edits []Edit, dryRun bool) (*MultiEditResult, error) {
+	if dryRun {
+		return runAs(m, func() (*MultiEditResult, error) { return m.multiEdit(path, edits, dryRun) })
+	}
+	var result *MultiEditResult
+	err := m.journal(OpEdit, path, "", func() error {
+		var err error
+		result, err = m.multiEdit(path, edits, dryRun)
+		return err
+	})
+	return result, err
+}
+
+func (m *Manager) multiEdit(path string, edits []Edit, dryRun bool) (*MultiEditResult, error) {
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("edits cannot be empty")
+	}
+
+	absPath, err := m.validatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkRead(absPath); err != nil {
+		return nil, err
 	}
 
-	if opts.ReplaceAll {
-		fileContent = strings.ReplaceAll(fileContent, search, newString)
-	} else {
-		index := strings.Index(fileContent, search)
-		lastIndex := strings.LastIndex(fileContent, search)
-		if index != lastIndex {
-			return fmt.Errorf("found multiple matches for old_string. Provide more surrounding lines in old_string to identify the correct match")
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	fileContent := string(content)
+	result := &MultiEditResult{Strategies: make([]string, 0, len(edits))}
+	for i, edit := range edits {
+		var strategy string
+		var replacements int
+		fileContent, strategy, replacements, err = applyEdit(fileContent, edit.OldString, edit.NewString, edit.ReplaceAll)
+		if err != nil {
+			return nil, fmt.Errorf("edit %d: %w", i+1, err)
 		}
-		fileContent = fileContent[:index] + newString + fileContent[index+len(search):]
+		result.Strategies = append(result.Strategies, strategy)
+		result.Replacements += replacements
 	}
 
-	if err := os.WriteFile(path, []byte(fileContent), 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	result.Diff = UnifiedDiff(absPath, string(content), fileContent)
+	if dryRun {
+		return result, nil
 	}
 
-	return nil
+	if err := os.WriteFile(absPath, []byte(fileContent), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	m.markRead(absPath)
+
+	return result, nil
 }
@@ -119,7 +119,7 @@ func TestEditFile(t *testing.T) {
 				t.Fatalf("failed to create test file: %v", err)
 			}
 
-			err := manager.EditFile(testFile, tt.oldString, tt.newString, tt.opts)
+			_, err := manager.EditFile(testFile, tt.oldString, tt.newString, tt.opts)
 
 			if tt.wantErr {
 				if err == nil {
@@ -153,7 +153,7 @@ func TestEditFile_FileNotFound(t *testing.T) {
 	tmpDir := t.TempDir()
 	manager := NewManager()
 
-	err := manager.EditFile(filepath.Join(tmpDir, "nonexistent.txt"), "old", "new", EditOptions{})
+	_, err := manager.EditFile(filepath.Join(tmpDir, "nonexistent.txt"), "old", "new", EditOptions{})
 	if err == nil {
 		t.Error("expected error for non-existent file, got nil")
 	}
@@ -175,7 +175,7 @@ func TestEditFile_LineTrimmedMatching(t *testing.T) {
 	oldString := "func foo() {\n    return 1\n}"
 	newString := "func bar() {\n    return 2\n}"
 
-	err := manager.EditFile(testFile, oldString, newString, EditOptions{})
+	_, err := manager.EditFile(testFile, oldString, newString, EditOptions{})
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 		return
@@ -201,7 +201,7 @@ func TestEditFile_PreservesOtherContent(t *testing.T) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 
-	err := manager.EditFile(testFile, "func old() {}", "func new() {}", EditOptions{})
+	_, err := manager.EditFile(testFile, "func old() {}", "func new() {}", EditOptions{})
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 		return
@@ -218,6 +218,43 @@ func TestEditFile_PreservesOtherContent(t *testing.T) {
 	}
 }
 
+func TestEditFile_DiffAndDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager()
+
+	initialContent := "    func foo() {\n        return 1\n    }\n"
+	testFile := filepath.Join(tmpDir, "dryrun.txt")
+	if err := os.WriteFile(testFile, []byte(initialContent), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	result, err := manager.EditFile(testFile, "return 1", "return 2", EditOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantDiff := "--- " + testFile + "\n+++ " + testFile + "\n@@ -1,3 +1,3 @@\n     func foo() {\n-        return 1\n+        return 2\n     }\n"
+	if result.Diff != wantDiff {
+		t.Errorf("diff mismatch:\ngot:  %q\nwant: %q", result.Diff, wantDiff)
+	}
+	if result.Strategy != "simple" || result.Replacements != 1 {
+		t.Errorf("expected 1 simple replacement, got %d %s", result.Replacements, result.Strategy)
+	}
+	if content, _ := os.ReadFile(testFile); string(content) != initialContent {
+		t.Errorf("expected dry run to leave the file unchanged, got %q", content)
+	}
+
+	result, err = manager.EditFile(testFile, "func foo() {\n    return 1\n}", "func foo() {\n        return 3\n    }", EditOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Strategy != "line_trimmed" {
+		t.Errorf("expected line_trimmed strategy, got %s", result.Strategy)
+	}
+	if !contains(result.Diff, "+        return 3\n") {
+		t.Errorf("expected diff to show the change, got %q", result.Diff)
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
 		(len(s) > 0 && len(substr) > 0 && findSubstring(s, substr)))
@@ -0,0 +1,55 @@
+package identity
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/deep-agent/sandbox/pkg/safe"
+	"golang.org/x/sys/unix"
+)
+
+// RunAs calls fn with the file system UID and GID of the current thread set
+// to u, so every path fn touches is checked against u's permissions. fn runs
+// on its own locked OS thread, which is only handed back to the scheduler once
+// the server's credentials are restored. A nil u calls fn directly.
+func RunAs(u *User, fn func() error) error {
+	if u == nil {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	safe.Go(func() {
+		err := fmt.Errorf("file operation as %s did not complete", u.Name)
+		defer func() { done <- err }()
+
+		runtime.LockOSThread()
+		prevGID, _ := unix.SetfsgidRetGid(int(u.GID))
+		prevUID, _ := unix.SetfsuidRetUid(int(u.UID))
+		defer func() {
+			unix.SetfsuidRetUid(prevUID)
+			unix.SetfsgidRetGid(prevGID)
+			if fsuid() == prevUID && fsgid() == prevGID {
+				runtime.UnlockOSThread()
+			}
+		}()
+
+		if fsuid() != int(u.UID) || fsgid() != int(u.GID) {
+			err = fmt.Errorf("failed to switch file system credentials to %s", u.Name)
+			return
+		}
+		err = fn()
+	})
+	return <-done
+}
+
+// fsuid and fsgid read the current value: an invalid ID leaves it unchanged
+// and returns it.
+func fsuid() int {
+	uid, _ := unix.SetfsuidRetUid(-1)
+	return uid
+}
+
+func fsgid() int {
+	gid, _ := unix.SetfsgidRetGid(-1)
+	return gid
+}
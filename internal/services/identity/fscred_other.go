@@ -0,0 +1,13 @@
+//go:build !linux
+
+package identity
+
+import "errors"
+
+// RunAs calls fn directly for a nil u; per-session users need Linux.
+func RunAs(u *User, fn func() error) error {
+	if u == nil {
+		return fn()
+	}
+	return errors.New("per-session users are only supported on Linux")
+}
@@ -0,0 +1,187 @@
+package identity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+const (
+	DefaultMinID = 20000
+	DefaultMaxID = 29999
+
+	userPrefix = "sbx-"
+)
+
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+// User is the unprivileged Linux account a session runs as. Home is the
+// session's workspace, which only this user can access.
+type User struct {
+	SessionID string
+	Name      string
+	UID       uint32
+	GID       uint32
+	Home      string
+}
+
+// Credential returns the credential for SysProcAttr.Credential. Supplementary
+// groups are cleared so the process keeps none of the server's groups.
+func (u *User) Credential() *syscall.Credential {
+	return &syscall.Credential{Uid: u.UID, Gid: u.GID, Groups: []uint32{}}
+}
+
+// Env returns the login variables of the user, to be appended to a process
+// environment.
+func (u *User) Env() []string {
+	return []string{
+		"HOME=" + u.Home,
+		"USER=" + u.Name,
+		"LOGNAME=" + u.Name,
+	}
+}
+
+// Chown hands path over to the user.
+func (u *User) Chown(path string) error {
+	return os.Lchown(path, int(u.UID), int(u.GID))
+}
+
+// Manager maps session IDs to dedicated users, creating the account and the
+// session workspace on first use. A nil Manager maps every session to the
+// server's own user.
+type Manager struct {
+	mu        sync.Mutex
+	workspace string
+	minID     uint32
+	maxID     uint32
+	users     map[string]*User
+	addUser   func(name string, uid, gid uint32, home string) error
+}
+
+// NewManager returns a Manager that allocates UIDs and GIDs from
+// [minID, maxID] and places session workspaces under workspace.
+func NewManager(workspace string, minID, maxID uint32) *Manager {
+	if minID == 0 || maxID < minID {
+		minID, maxID = DefaultMinID, DefaultMaxID
+	}
+	return &Manager{
+		workspace: workspace,
+		minID:     minID,
+		maxID:     maxID,
+		users:     make(map[string]*User),
+		addUser:   addSystemUser,
+	}
+}
+
+// Lookup returns the user of a session. An empty session ID returns nil,
+// meaning the server's own user.
+func (m *Manager) Lookup(sessionID string) (*User, error) {
+	if m == nil || sessionID == "" {
+		return nil, nil
+	}
+	if !sessionIDPattern.MatchString(sessionID) || sessionID == "." || sessionID == ".." {
+		return nil, fmt.Errorf("invalid session id: %q", sessionID)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[sessionID]
+	if !ok {
+		var err error
+		if u, err = m.create(sessionID); err != nil {
+			return nil, err
+		}
+		m.users[sessionID] = u
+	}
+
+	if err := m.prepareHome(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (m *Manager) create(sessionID string) (*User, error) {
+	sum := sha256.Sum256([]byte(sessionID))
+	name := userPrefix + hex.EncodeToString(sum[:6])
+	home := filepath.Join(m.workspace, sessionID)
+
+	if existing, err := user.Lookup(name); err == nil {
+		uid, _ := strconv.ParseUint(existing.Uid, 10, 32)
+		gid, _ := strconv.ParseUint(existing.Gid, 10, 32)
+		return &User{SessionID: sessionID, Name: name, UID: uint32(uid), GID: uint32(gid), Home: home}, nil
+	}
+
+	id, err := m.allocateID()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.addUser(name, id, id, home); err != nil {
+		return nil, fmt.Errorf("failed to create user for session %s: %w", sessionID, err)
+	}
+	return &User{SessionID: sessionID, Name: name, UID: id, GID: id, Home: home}, nil
+}
+
+func (m *Manager) allocateID() (uint32, error) {
+	taken := make(map[uint32]bool, len(m.users))
+	for _, u := range m.users {
+		taken[u.UID] = true
+	}
+	for id := m.minID; id <= m.maxID; id++ {
+		if taken[id] {
+			continue
+		}
+		s := strconv.FormatUint(uint64(id), 10)
+		if _, err := user.LookupId(s); err == nil {
+			continue
+		}
+		if _, err := user.LookupGroupId(s); err == nil {
+			continue
+		}
+		return id, nil
+	}
+	return 0, fmt.Errorf("no free user id in range %d-%d", m.minID, m.maxID)
+}
+
+// prepareHome creates the session workspace, owned by the user and closed to
+// everyone else.
+func (m *Manager) prepareHome(u *User) error {
+	if err := os.MkdirAll(u.Home, 0700); err != nil {
+		return fmt.Errorf("failed to create session workspace: %w", err)
+	}
+	if err := u.Chown(u.Home); err != nil {
+		return fmt.Errorf("failed to assign session workspace: %w", err)
+	}
+	if err := os.Chmod(u.Home, 0700); err != nil {
+		return fmt.Errorf("failed to restrict session workspace: %w", err)
+	}
+	return nil
+}
+
+// addSystemUser registers the account so tools like whoami and ls -l show its
+// name. Without groupadd and useradd the numeric IDs are used as they are.
+func addSystemUser(name string, uid, gid uint32, home string) error {
+	commands := [][]string{
+		{"groupadd", "-g", strconv.FormatUint(uint64(gid), 10), name},
+		{"useradd", "-M", "-N", "-u", strconv.FormatUint(uint64(uid), 10), "-g", strconv.FormatUint(uint64(gid), 10), "-d", home, "-s", "/bin/bash", name},
+	}
+	for _, args := range commands {
+		out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", args[0], err, out)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package identity
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+	// Session users must be able to traverse into the workspace root.
+	workspace := t.TempDir()
+	for _, dir := range []string{filepath.Dir(workspace), workspace} {
+		if err := os.Chmod(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewManager(workspace, 61000, 61010)
+	m.addUser = func(name string, uid, gid uint32, home string) error { return nil }
+	return m
+}
+
+func TestManager_Lookup(t *testing.T) {
+	m := newTestManager(t)
+
+	u1, err := m.Lookup("s1")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	again, err := m.Lookup("s1")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if again != u1 {
+		t.Error("expected the same user for the same session")
+	}
+
+	u2, err := m.Lookup("s2")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if u1.UID == u2.UID || u1.Name == u2.Name {
+		t.Errorf("expected distinct users, got %+v and %+v", u1, u2)
+	}
+
+	info, err := os.Stat(u1.Home)
+	if err != nil {
+		t.Fatalf("expected session workspace: %v", err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	if st.Uid != u1.UID || st.Gid != u1.GID || info.Mode().Perm() != 0700 {
+		t.Errorf("unexpected workspace owner %d:%d mode %v", st.Uid, st.Gid, info.Mode().Perm())
+	}
+	if u1.Home != filepath.Join(m.workspace, "s1") {
+		t.Errorf("unexpected home %s", u1.Home)
+	}
+}
+
+func TestManager_Lookup_NoSession(t *testing.T) {
+	var nilManager *Manager
+	if u, err := nilManager.Lookup("s1"); u != nil || err != nil {
+		t.Errorf("expected nil user from nil manager, got %v, %v", u, err)
+	}
+
+	m := NewManager(t.TempDir(), 0, 0)
+	if u, err := m.Lookup(""); u != nil || err != nil {
+		t.Errorf("expected nil user for empty session, got %v, %v", u, err)
+	}
+	for _, id := range []string{"..", "a/b", "x y"} {
+		if _, err := m.Lookup(id); err == nil {
+			t.Errorf("expected error for session id %q", id)
+		}
+	}
+}
+
+func TestManager_Lookup_RangeExhausted(t *testing.T) {
+	m := newTestManager(t)
+	m.minID, m.maxID = 61020, 61020
+
+	if _, err := m.Lookup("s1"); err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if _, err := m.Lookup("s2"); err == nil {
+		t.Error("expected error when no user id is free")
+	}
+}
+
+func TestRunAs(t *testing.T) {
+	m := newTestManager(t)
+	u, err := m.Lookup("s1")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+
+	private := filepath.Join(t.TempDir(), "private")
+	if err := os.Mkdir(private, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	err = RunAs(u, func() error {
+		return os.WriteFile(filepath.Join(private, "f"), []byte("x"), 0644)
+	})
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected permission error outside the session workspace, got %v", err)
+	}
+
+	own := filepath.Join(u.Home, "f")
+	if err := RunAs(u, func() error { return os.WriteFile(own, []byte("x"), 0644) }); err != nil {
+		t.Fatalf("write in session workspace failed: %v", err)
+	}
+	info, err := os.Stat(own)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := info.Sys().(*syscall.Stat_t); st.Uid != u.UID {
+		t.Errorf("expected file owned by %d, got %d", u.UID, st.Uid)
+	}
+
+	if err := os.WriteFile(filepath.Join(private, "g"), []byte("x"), 0644); err != nil {
+		t.Errorf("expected server credentials after RunAs, got %v", err)
+	}
+}
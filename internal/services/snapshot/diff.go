@@ -0,0 +1,93 @@
+package snapshot
+
+import "sort"
+
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// Change is a path that differs between two states of a workspace.
+type Change struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	Type    string `json:"type"`
+	OldSize int64  `json:"old_size,omitempty"`
+	NewSize int64  `json:"new_size,omitempty"`
+}
+
+// Diff lists the changes from snapshot from to snapshot to, or to the current
+// workspace when to is empty. Paths excluded from the first snapshot are not
+// compared.
+func (s *Store) Diff(workspace, from, to string) ([]Change, error) {
+	workspace, err := s.resolveWorkspace(workspace)
+	if err != nil {
+		return nil, err
+	}
+	old, err := s.load(workspace, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var current []Entry
+	if to != "" {
+		snap, err := s.load(workspace, to)
+		if err != nil {
+			return nil, err
+		}
+		current = snap.Entries
+	} else {
+		match, err := newMatcher(old.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		previous := make(map[string]Entry, len(old.Entries))
+		for _, e := range old.Entries {
+			previous[e.Path] = e
+		}
+		sc := &scanner{store: s, match: match, previous: previous}
+		if current, err = sc.scan(workspace); err != nil {
+			return nil, err
+		}
+	}
+	return diffEntries(old.Entries, current), nil
+}
+
+func diffEntries(old, current []Entry) []Change {
+	before := make(map[string]Entry, len(old))
+	for _, e := range old {
+		before[e.Path] = e
+	}
+
+	changes := []Change{}
+	for _, e := range current {
+		prev, ok := before[e.Path]
+		delete(before, e.Path)
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: e.Path, Status: ChangeAdded, Type: e.Type, NewSize: e.Size})
+		case modified(prev, e):
+			changes = append(changes, Change{Path: e.Path, Status: ChangeModified, Type: e.Type, OldSize: prev.Size, NewSize: e.Size})
+		}
+	}
+	for _, e := range before {
+		changes = append(changes, Change{Path: e.Path, Status: ChangeRemoved, Type: e.Type, OldSize: e.Size})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// modified compares content, link target, mode and owner; times alone do not
+// count as a change.
+func modified(a, b Entry) bool {
+	return a.Type != b.Type ||
+		a.Hash != b.Hash ||
+		a.Target != b.Target ||
+		a.Mode != b.Mode ||
+		a.UID != b.UID ||
+		a.GID != b.GID
+}
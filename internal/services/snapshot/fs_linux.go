@@ -0,0 +1,39 @@
+package snapshot
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+type fileStat struct {
+	uid, gid int
+	dev, ino uint64
+	ctime    int64
+}
+
+func statOf(info fs.FileInfo) fileStat {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileStat{uid: -1, gid: -1}
+	}
+	return fileStat{
+		uid:   int(st.Uid),
+		gid:   int(st.Gid),
+		dev:   st.Dev,
+		ino:   st.Ino,
+		ctime: st.Ctim.Nano(),
+	}
+}
+
+// exchange atomically swaps two paths on the same file system.
+func exchange(a, b string) error {
+	return unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+}
+
+// cloneFile makes dst share the blocks of src on file systems with reflinks.
+func cloneFile(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
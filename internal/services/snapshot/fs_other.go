@@ -0,0 +1,39 @@
+//go:build !linux
+
+package snapshot
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+type fileStat struct {
+	uid, gid int
+	dev, ino uint64
+	ctime    int64
+}
+
+// statOf leaves the change time unset, so unchanged files are hashed again
+// by every snapshot.
+func statOf(info fs.FileInfo) fileStat {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileStat{uid: -1, gid: -1}
+	}
+	return fileStat{
+		uid: int(st.Uid),
+		gid: int(st.Gid),
+		dev: uint64(st.Dev),
+		ino: uint64(st.Ino),
+	}
+}
+
+func exchange(a, b string) error {
+	return errors.New("atomic exchange is only supported on Linux")
+}
+
+func cloneFile(dst, src *os.File) error {
+	return errors.New("reflinks are only supported on Linux")
+}
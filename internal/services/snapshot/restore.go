@@ -0,0 +1,316 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Restore replaces the contents of workspace with a snapshot. The snapshot is
+// rebuilt in a staging directory next to the workspace, which is then swapped
+// in with a single rename, so the workspace path always shows either the old
+// or the restored tree. Paths matching the snapshot's exclude patterns are
+// carried over as they are. A workspace that is a mount point cannot be
+// swapped; its entries are replaced one by one instead. Options.Restored is
+// called afterwards.
+func (s *Store) Restore(workspace, id string) (*Snapshot, error) {
+	workspace, err := s.resolveWorkspace(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	var snap *Snapshot
+	err = s.withLock(func() error {
+		var err error
+		if snap, err = s.load(workspace, id); err != nil {
+			return err
+		}
+		match, err := newMatcher(snap.Exclude)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(workspace, 0700); err != nil {
+			return fmt.Errorf("failed to create workspace: %w", err)
+		}
+		// A symlink may have been put in place since the workspace was
+		// resolved.
+		if real, err := realPath(workspace); err != nil || real != workspace {
+			return fmt.Errorf("workspace %s changed while restoring", workspace)
+		}
+
+		swap, err := canSwap(workspace)
+		if err != nil {
+			return err
+		}
+		parent, prefix := filepath.Dir(workspace), "."+filepath.Base(workspace)+".restore-"
+		if !swap {
+			parent, prefix = workspace, ".snapshot-restore-"
+		}
+		staging, err := os.MkdirTemp(parent, prefix)
+		if err != nil {
+			return fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		// After the swap the staging directory holds the old tree.
+		defer os.RemoveAll(staging)
+
+		dirs, err := s.materialize(snap.Entries, staging)
+		if err != nil {
+			return err
+		}
+		if len(snap.Exclude) > 0 {
+			if err := carryOver(workspace, staging, match); err != nil {
+				return err
+			}
+		}
+		if err := finishDirs(dirs, staging); err != nil {
+			return err
+		}
+
+		if swap {
+			return swapIn(staging, workspace)
+		}
+		return replaceEntries(workspace, staging)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.restored != nil {
+		s.restored(workspace)
+	}
+	return summary(snap), nil
+}
+
+// canSwap reports whether workspace can be renamed, which is not the case for
+// a mount point.
+func canSwap(workspace string) (bool, error) {
+	info, err := os.Lstat(workspace)
+	if err != nil {
+		return false, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	if !info.IsDir() {
+		return false, fmt.Errorf("workspace is not a directory: %s", workspace)
+	}
+	parent, err := os.Stat(filepath.Dir(workspace))
+	if err != nil {
+		return false, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	return workspace != filepath.Dir(workspace) && statOf(info).dev == statOf(parent).dev, nil
+}
+
+// materialize creates the entries below dst. It returns the directories,
+// whose modes and times are set by finishDirs once nothing more is added.
+func (s *Store) materialize(entries []Entry, dst string) ([]Entry, error) {
+	chown := os.Geteuid() == 0
+	var dirs []Entry
+
+	for _, e := range entries {
+		if e.Path != "." && !filepath.IsLocal(filepath.FromSlash(e.Path)) {
+			return nil, fmt.Errorf("invalid path in snapshot: %s", e.Path)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(e.Path))
+
+		var err error
+		switch e.Type {
+		case TypeDir:
+			dirs = append(dirs, e)
+			if e.Path != "." {
+				err = os.Mkdir(target, 0700)
+			}
+		case TypeFile:
+			err = s.restoreFile(target, e)
+		case TypeSymlink:
+			err = os.Symlink(e.Target, target)
+		default:
+			continue
+		}
+		if err == nil && chown && e.UID >= 0 {
+			err = os.Lchown(target, e.UID, e.GID)
+		}
+		if err == nil && e.Type == TypeFile {
+			err = setModeAndTime(target, e)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", e.Path, err)
+		}
+	}
+	return dirs, nil
+}
+
+func (s *Store) restoreFile(target string, e Entry) error {
+	if _, err := hex.DecodeString(e.Hash); err != nil || len(e.Hash) != 2*sha256.Size {
+		return fmt.Errorf("invalid content hash %q", e.Hash)
+	}
+	src, err := os.Open(s.objectPath(e.Hash))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if cloneFile(dst, src) != nil {
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			return err
+		}
+	}
+	return dst.Close()
+}
+
+// finishDirs applies directory modes and times deepest first, since adding
+// children changes the time and a read-only mode would prevent it.
+func finishDirs(dirs []Entry, dst string) error {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		e := dirs[i]
+		if err := setModeAndTime(filepath.Join(dst, filepath.FromSlash(e.Path)), e); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", e.Path, err)
+		}
+	}
+	return nil
+}
+
+func setModeAndTime(target string, e Entry) error {
+	if err := os.Chmod(target, e.Mode); err != nil {
+		return err
+	}
+	mtime := time.Unix(0, e.ModTime)
+	return os.Chtimes(target, mtime, mtime)
+}
+
+// carryOver moves the excluded paths of the workspace into the staging tree,
+// creating missing parent directories like the ones in the workspace.
+func carryOver(workspace, staging string, match matcher) error {
+	err := filepath.WalkDir(workspace, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == staging {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(workspace, p)
+		if err != nil || rel == "." || !match(filepath.ToSlash(rel)) {
+			return err
+		}
+
+		if err := mkdirLike(workspace, staging, filepath.Dir(rel)); err != nil {
+			return err
+		}
+		if err := os.Rename(p, filepath.Join(staging, rel)); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to keep excluded paths: %w", err)
+	}
+	return nil
+}
+
+// mkdirLike creates the directory rel below staging where it is missing,
+// copying mode and owner of the same directory in the workspace.
+func mkdirLike(workspace, staging, rel string) error {
+	if rel == "." {
+		return nil
+	}
+	if err := mkdirLike(workspace, staging, filepath.Dir(rel)); err != nil {
+		return err
+	}
+
+	target := filepath.Join(staging, rel)
+	if info, err := os.Lstat(target); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory in the snapshot", rel)
+		}
+		return nil
+	}
+	info, err := os.Lstat(filepath.Join(workspace, rel))
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(target, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if os.Geteuid() == 0 {
+		st := statOf(info)
+		return os.Lchown(target, st.uid, st.gid)
+	}
+	return nil
+}
+
+// swapIn exchanges the staging directory with the workspace. Without
+// RENAME_EXCHANGE it falls back to two renames, between which the workspace
+// is briefly missing.
+func swapIn(staging, workspace string) error {
+	if exchange(staging, workspace) == nil {
+		return nil
+	}
+
+	old := staging + ".old"
+	if err := os.Rename(workspace, old); err != nil {
+		return fmt.Errorf("failed to swap in restored workspace: %w", err)
+	}
+	if err := os.Rename(staging, workspace); err != nil {
+		os.Rename(old, workspace)
+		return fmt.Errorf("failed to swap in restored workspace: %w", err)
+	}
+	return os.Rename(old, staging)
+}
+
+// replaceEntries moves the current entries of the workspace aside and the
+// staged ones in. On failure the previous contents are kept instead of being
+// deleted.
+func replaceEntries(workspace, staging string) error {
+	old, err := os.MkdirTemp(workspace, ".snapshot-old-")
+	if err != nil {
+		return fmt.Errorf("failed to replace workspace: %w", err)
+	}
+
+	current, err := os.ReadDir(workspace)
+	if err != nil {
+		return fmt.Errorf("failed to replace workspace: %w", err)
+	}
+	for _, d := range current {
+		p := filepath.Join(workspace, d.Name())
+		if p == staging || p == old {
+			continue
+		}
+		if err := os.Rename(p, filepath.Join(old, d.Name())); err != nil {
+			return fmt.Errorf("failed to replace workspace, previous contents are in %s: %w", old, err)
+		}
+	}
+
+	staged, err := os.ReadDir(staging)
+	if err != nil {
+		return fmt.Errorf("failed to replace workspace, previous contents are in %s: %w", old, err)
+	}
+	for _, d := range staged {
+		if err := os.Rename(filepath.Join(staging, d.Name()), filepath.Join(workspace, d.Name())); err != nil {
+			return fmt.Errorf("failed to replace workspace, previous contents are in %s: %w", old, err)
+		}
+	}
+
+	info, err := os.Lstat(staging)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(workspace, info.Mode()&(fs.ModePerm|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return err
+	}
+	if os.Geteuid() == 0 {
+		st := statOf(info)
+		if err := os.Lchown(workspace, st.uid, st.gid); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(old)
+}
@@ -0,0 +1,222 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// matcher reports whether a slash-separated workspace path is excluded.
+type matcher func(rel string) bool
+
+func newMatcher(patterns []string) (matcher, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return func(rel string) bool {
+		for _, pattern := range patterns {
+			pattern = strings.Trim(pattern, "/")
+			name := rel
+			if !strings.Contains(pattern, "/") {
+				name = path.Base(rel)
+			}
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// scanner walks a workspace and records its entries. With save set, file
+// contents are added to the store; otherwise they are only hashed.
+type scanner struct {
+	store    *Store
+	match    matcher
+	previous map[string]Entry
+	save     bool
+
+	files  int
+	size   int64
+	stored int64
+}
+
+func (sc *scanner) scan(root string) ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files removed while the walk runs are skipped.
+			if os.IsNotExist(err) && p != root {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && sc.match(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e, ok, err := sc.entry(p, rel, info)
+		if err != nil || !ok {
+			return err
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan workspace: %w", err)
+	}
+	return entries, nil
+}
+
+// entry describes one path. Sockets, pipes and devices are not recorded.
+func (sc *scanner) entry(p, rel string, info fs.FileInfo) (Entry, bool, error) {
+	st := statOf(info)
+	e := Entry{
+		Path:       rel,
+		Mode:       info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky),
+		UID:        st.uid,
+		GID:        st.gid,
+		ModTime:    info.ModTime().UnixNano(),
+		Inode:      st.ino,
+		ChangeTime: st.ctime,
+	}
+
+	switch {
+	case info.IsDir():
+		e.Type = TypeDir
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(p)
+		if err != nil {
+			return e, false, err
+		}
+		e.Type = TypeSymlink
+		e.Target = target
+	case info.Mode().IsRegular():
+		e.Type = TypeFile
+		e.Size = info.Size()
+		sc.files++
+		sc.size += e.Size
+		if sc.save && sc.store.maxSize > 0 && sc.size > sc.store.maxSize {
+			return e, false, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, sc.store.maxSize)
+		}
+		hash, err := sc.hash(p, e)
+		if err != nil {
+			return e, false, err
+		}
+		e.Hash = hash
+	default:
+		return e, false, nil
+	}
+	return e, true, nil
+}
+
+func (sc *scanner) hash(p string, e Entry) (string, error) {
+	if prev, ok := sc.previous[e.Path]; ok && unchanged(prev, e) {
+		if !sc.save {
+			return prev.Hash, nil
+		}
+		if _, err := os.Stat(sc.store.objectPath(prev.Hash)); err == nil {
+			return prev.Hash, nil
+		}
+	}
+	if !sc.save {
+		return hashFile(p)
+	}
+	hash, stored, err := sc.store.addObject(p)
+	sc.stored += stored
+	return hash, err
+}
+
+// unchanged reports whether a file still is the one recorded in prev. The
+// change time cannot be set by users, so a rewrite always alters it.
+func unchanged(prev, cur Entry) bool {
+	return prev.Type == TypeFile && prev.Hash != "" && cur.ChangeTime != 0 &&
+		prev.Size == cur.Size &&
+		prev.ModTime == cur.ModTime &&
+		prev.Inode == cur.Inode &&
+		prev.ChangeTime == cur.ChangeTime
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.OpenFile(p, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// addObject copies a file into the store and returns its hash and the number
+// of bytes added, which is zero when the content was already stored.
+func (s *Store) addObject(p string) (string, int64, error) {
+	src, err := os.OpenFile(p, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return "", 0, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, tmpDir), "object-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to store %s: %w", p, err)
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	dst := s.objectPath(hash)
+	if _, err := os.Stat(dst); err == nil {
+		return hash, 0, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return "", 0, err
+	}
+	if err := os.Chmod(tmp.Name(), 0400); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", 0, err
+	}
+	return hash, n, nil
+}
@@ -0,0 +1,475 @@
+package snapshot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	TypeFile    = "file"
+	TypeDir     = "dir"
+	TypeSymlink = "symlink"
+)
+
+const (
+	objectsDir   = "objects"
+	snapshotsDir = "snapshots"
+	tmpDir       = "tmp"
+)
+
+var (
+	ErrNotFound = errors.New("snapshot not found")
+	ErrTooLarge = errors.New("workspace too large to snapshot")
+)
+
+var idPattern = regexp.MustCompile(`^snap_[0-9A-Za-z_]{1,64}$`)
+
+// Entry is one file, directory or symlink of a snapshot. Path is relative to
+// the workspace and slash-separated; the workspace itself is ".".
+type Entry struct {
+	Path    string      `json:"path"`
+	Type    string      `json:"type"`
+	Mode    fs.FileMode `json:"mode"`
+	UID     int         `json:"uid"`
+	GID     int         `json:"gid"`
+	Size    int64       `json:"size,omitempty"`
+	ModTime int64       `json:"mtime_ns"`
+	Hash    string      `json:"hash,omitempty"`
+	Target  string      `json:"target,omitempty"`
+
+	// Inode and ChangeTime let the next snapshot reuse Hash for files that
+	// have not been touched since.
+	Inode      uint64 `json:"ino,omitempty"`
+	ChangeTime int64  `json:"ctime_ns,omitempty"`
+}
+
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Workspace string    `json:"workspace"`
+	SessionID string    `json:"session_id,omitempty"`
+	Label     string    `json:"label,omitempty"`
+	Exclude   []string  `json:"exclude,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     int       `json:"files"`
+	// Size is the total size of the files; StoredSize is the part that was
+	// not already in the store when the snapshot was taken.
+	Size       int64   `json:"size"`
+	StoredSize int64   `json:"stored_size"`
+	Entries    []Entry `json:"entries,omitempty"`
+}
+
+type CreateOptions struct {
+	SessionID string
+	Label     string
+	// Exclude lists paths left out of the snapshot and kept as they are on
+	// restore. Patterns without a slash match a name at any depth, others
+	// match the path relative to the workspace.
+	Exclude []string
+}
+
+type Options struct {
+	// Workspace is the directory all snapshotted workspaces must be in. It is
+	// also used for requests that do not name a workspace.
+	Workspace string
+	// MaxSize limits the total file size of one snapshot and MaxCount the
+	// snapshots kept per workspace, pruning the oldest; zero means unlimited.
+	MaxSize  int64
+	MaxCount int
+	// Restored is called with the workspace after Restore replaced it, which
+	// leaves processes inside it in deleted directories.
+	Restored func(workspace string)
+}
+
+// Store keeps content-addressed snapshots of workspaces. File contents are
+// stored once per SHA-256 no matter how many snapshots refer to them, and
+// restores clone them with reflinks where the file system supports it. The
+// server and the MCP hub may share a store: changes are serialised with an
+// flock on a lock file in the store directory.
+type Store struct {
+	mu        sync.Mutex
+	dir       string
+	workspace string
+	maxSize   int64
+	maxCount  int
+	restored  func(workspace string)
+	lock      *os.File
+}
+
+func NewStore(dir string, opts Options) (*Store, error) {
+	for _, sub := range []string{objectsDir, snapshotsDir, tmpDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create snapshot store: %w", err)
+		}
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to restrict snapshot store: %w", err)
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot lock file: %w", err)
+	}
+	return &Store{
+		dir:       filepath.Clean(dir),
+		workspace: filepath.Clean(opts.Workspace),
+		maxSize:   opts.MaxSize,
+		maxCount:  opts.MaxCount,
+		restored:  opts.Restored,
+		lock:      lock,
+	}, nil
+}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.lock.Close()
+}
+
+func (s *Store) withLock(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := syscall.Flock(int(s.lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock snapshot store: %w", err)
+	}
+	defer syscall.Flock(int(s.lock.Fd()), syscall.LOCK_UN)
+	return fn()
+}
+
+// resolveWorkspace resolves the symlinks in workspace, defaulting to the
+// store's workspace, and checks that it lies inside it. Restores run as root,
+// so the returned path is the real one.
+func (s *Store) resolveWorkspace(workspace string) (string, error) {
+	if workspace == "" {
+		workspace = s.workspace
+	}
+	if !filepath.IsAbs(workspace) {
+		return "", fmt.Errorf("workspace must be an absolute path: %s", workspace)
+	}
+	root, err := realPath(s.workspace)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+	resolved, err := realPath(filepath.Clean(workspace))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+	if !within(root, resolved) {
+		return "", fmt.Errorf("workspace %s is outside %s", workspace, s.workspace)
+	}
+	dir, err := realPath(s.dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve snapshot store: %w", err)
+	}
+	if within(resolved, dir) {
+		return "", fmt.Errorf("workspace %s contains the snapshot store", workspace)
+	}
+	return resolved, nil
+}
+
+// realPath resolves the symlinks in path. Components that do not exist yet
+// are kept as they are.
+func realPath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return resolved, err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	if resolved, err = realPath(parent); err != nil {
+		return "", err
+	}
+	return filepath.Join(resolved, filepath.Base(path)), nil
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Create snapshots workspace.
+func (s *Store) Create(workspace string, opts CreateOptions) (*Snapshot, error) {
+	workspace, err := s.resolveWorkspace(workspace)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(workspace); err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("workspace is not a directory: %s", workspace)
+	}
+	match, err := newMatcher(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	var snap *Snapshot
+	err = s.withLock(func() error {
+		// Unchanged files keep the hash they had in the latest snapshot.
+		previous := map[string]Entry{}
+		if latest, err := s.latest(workspace); err == nil && latest != nil {
+			for _, e := range latest.Entries {
+				previous[e.Path] = e
+			}
+		}
+
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		snap = &Snapshot{
+			ID:        id,
+			Workspace: workspace,
+			SessionID: opts.SessionID,
+			Label:     opts.Label,
+			Exclude:   opts.Exclude,
+			CreatedAt: time.Now(),
+		}
+		sc := &scanner{store: s, match: match, previous: previous, save: true}
+		if snap.Entries, err = sc.scan(workspace); err != nil {
+			s.gc()
+			return err
+		}
+		snap.Files, snap.Size, snap.StoredSize = sc.files, sc.size, sc.stored
+
+		if err := s.save(snap); err != nil {
+			s.gc()
+			return err
+		}
+		return s.prune(workspace)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary(snap), nil
+}
+
+// List returns the snapshots of workspace, oldest first, without entries.
+func (s *Store) List(workspace string) ([]Snapshot, error) {
+	workspace, err := s.resolveWorkspace(workspace)
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := s.list(workspace)
+	if err != nil {
+		return nil, err
+	}
+	for i := range snaps {
+		snaps[i] = *summary(&snaps[i])
+	}
+	return snaps, nil
+}
+
+// Get returns a snapshot of workspace including its entries.
+func (s *Store) Get(workspace, id string) (*Snapshot, error) {
+	workspace, err := s.resolveWorkspace(workspace)
+	if err != nil {
+		return nil, err
+	}
+	return s.load(workspace, id)
+}
+
+// Delete removes a snapshot and the contents no other snapshot refers to.
+func (s *Store) Delete(workspace, id string) error {
+	workspace, err := s.resolveWorkspace(workspace)
+	if err != nil {
+		return err
+	}
+	return s.withLock(func() error {
+		if _, err := s.load(workspace, id); err != nil {
+			return err
+		}
+		if err := os.Remove(s.manifestPath(workspace, id)); err != nil {
+			return fmt.Errorf("failed to delete snapshot: %w", err)
+		}
+		return s.gc()
+	})
+}
+
+func summary(snap *Snapshot) *Snapshot {
+	out := *snap
+	out.Entries = nil
+	return &out
+}
+
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate snapshot id: %w", err)
+	}
+	return fmt.Sprintf("snap_%s_%s", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(b)), nil
+}
+
+// workspaceDir holds the manifests of one workspace.
+func (s *Store) workspaceDir(workspace string) string {
+	return filepath.Join(s.dir, snapshotsDir, hashString(workspace)[:16])
+}
+
+func (s *Store) manifestPath(workspace, id string) string {
+	return filepath.Join(s.workspaceDir(workspace), id+".json")
+}
+
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.dir, objectsDir, hash[:2], hash)
+}
+
+func (s *Store) save(snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	dir := s.workspaceDir(snap.Workspace)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, tmpDir), "manifest-")
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.manifestPath(snap.Workspace, snap.ID)); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) load(workspace, id string) (*Snapshot, error) {
+	if !idPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	snap, err := readManifest(s.manifestPath(workspace, id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if snap.Workspace != workspace {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return snap, nil
+}
+
+func readManifest(name string) (*Snapshot, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("invalid snapshot manifest %s: %w", filepath.Base(name), err)
+	}
+	return &snap, nil
+}
+
+// list loads the snapshots of workspace with their entries, oldest first.
+func (s *Store) list(workspace string) ([]Snapshot, error) {
+	names, err := filepath.Glob(filepath.Join(s.workspaceDir(workspace), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	snaps := make([]Snapshot, 0, len(names))
+	for _, name := range names {
+		snap, err := readManifest(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if snap.Workspace == workspace {
+			snaps = append(snaps, *snap)
+		}
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].CreatedAt.Before(snaps[j].CreatedAt)
+	})
+	return snaps, nil
+}
+
+func (s *Store) latest(workspace string) (*Snapshot, error) {
+	snaps, err := s.list(workspace)
+	if err != nil || len(snaps) == 0 {
+		return nil, err
+	}
+	return &snaps[len(snaps)-1], nil
+}
+
+// prune drops the oldest snapshots of workspace beyond the count limit.
+func (s *Store) prune(workspace string) error {
+	if s.maxCount <= 0 {
+		return nil
+	}
+	snaps, err := s.list(workspace)
+	if err != nil {
+		return err
+	}
+	if len(snaps) <= s.maxCount {
+		return nil
+	}
+	for _, snap := range snaps[:len(snaps)-s.maxCount] {
+		if err := os.Remove(s.manifestPath(workspace, snap.ID)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune snapshot: %w", err)
+		}
+	}
+	return s.gc()
+}
+
+// gc removes contents no snapshot refers to and leftover temporary files.
+// It must be called with the store locked.
+func (s *Store) gc() error {
+	names, err := filepath.Glob(filepath.Join(s.dir, snapshotsDir, "*", "*.json"))
+	if err != nil {
+		return err
+	}
+	referenced := map[string]bool{}
+	for _, name := range names {
+		snap, err := readManifest(name)
+		if err != nil {
+			// Keep every object rather than lose contents of a snapshot that
+			// could not be read.
+			return err
+		}
+		for _, e := range snap.Entries {
+			if e.Hash != "" {
+				referenced[e.Hash] = true
+			}
+		}
+	}
+
+	objects, err := filepath.Glob(filepath.Join(s.dir, objectsDir, "*", "*"))
+	if err != nil {
+		return err
+	}
+	for _, name := range objects {
+		if !referenced[filepath.Base(name)] {
+			os.Remove(name)
+		}
+	}
+	temps, _ := filepath.Glob(filepath.Join(s.dir, tmpDir, "*"))
+	for _, name := range temps {
+		os.Remove(name)
+	}
+	return nil
+}
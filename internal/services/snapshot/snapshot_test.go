@@ -0,0 +1,274 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestStore(t *testing.T, opts Options) (*Store, string) {
+	t.Helper()
+	base := t.TempDir()
+	workspace := filepath.Join(base, "workspace", "s1")
+	if err := os.MkdirAll(workspace, 0755); err != nil {
+		t.Fatal(err)
+	}
+	opts.Workspace = filepath.Join(base, "workspace")
+	s, err := NewStore(filepath.Join(base, "store"), opts)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, workspace
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTree returns the files and symlinks below root, with symlinks shown as
+// "-> target".
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := map[string]string{}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, _ := os.Readlink(p)
+			tree[rel] = "-> " + target
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			tree[rel] = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestStore_CreateRestore(t *testing.T) {
+	s, ws := newTestStore(t, Options{})
+	writeFiles(t, ws, map[string]string{
+		"main.go":         "package main\n",
+		"pkg/util.go":     "package pkg\n",
+		"pkg/copy.go":     "package pkg\n",
+		"node_modules/x":  "dependency",
+		"docs/notes.txt":  "notes",
+		"docs/build/keep": "generated",
+	})
+	if err := os.Symlink("main.go", filepath.Join(ws, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(ws, "main.go"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	want := readTree(t, ws)
+
+	snap, err := s.Create(ws, CreateOptions{SessionID: "s1", Label: "before", Exclude: []string{"node_modules", "docs/build"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if snap.Files != 4 || snap.Entries != nil {
+		t.Errorf("snapshot = %+v, want 4 files and no entries", snap)
+	}
+	// Identical contents are stored once.
+	if snap.StoredSize != snap.Size-int64(len("package pkg\n")) {
+		t.Errorf("StoredSize = %d, Size = %d, want the duplicate stored once", snap.StoredSize, snap.Size)
+	}
+
+	// Wreck the workspace.
+	os.RemoveAll(filepath.Join(ws, "pkg"))
+	os.Remove(filepath.Join(ws, "link"))
+	writeFiles(t, ws, map[string]string{
+		"main.go":         "broken",
+		"new.txt":         "new",
+		"node_modules/y":  "installed later",
+		"docs/build/keep": "regenerated",
+	})
+	os.Chmod(filepath.Join(ws, "main.go"), 0600)
+
+	if _, err := s.Restore(ws, snap.ID); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	got := readTree(t, ws)
+	// Excluded paths keep their current contents.
+	want["node_modules/y"] = "installed later"
+	want["docs/build/keep"] = "regenerated"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("restored tree = %v, want %v", got, want)
+	}
+	info, err := os.Stat(filepath.Join(ws, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("main.go mode = %v, want 0755", info.Mode().Perm())
+	}
+
+	// Nothing of the staging directory is left next to the workspace.
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(ws), ".s1.restore-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("staging directories left behind: %v", leftovers)
+	}
+}
+
+func TestStore_Diff(t *testing.T) {
+	s, ws := newTestStore(t, Options{})
+	writeFiles(t, ws, map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"})
+	first, err := s.Create(ws, CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	writeFiles(t, ws, map[string]string{"a.txt": "changed", "d.txt": "d"})
+	os.Remove(filepath.Join(ws, "b.txt"))
+	os.Chmod(filepath.Join(ws, "c.txt"), 0600)
+
+	want := []Change{
+		{Path: "a.txt", Status: ChangeModified, Type: TypeFile, OldSize: 1, NewSize: 7},
+		{Path: "b.txt", Status: ChangeRemoved, Type: TypeFile, OldSize: 1},
+		{Path: "c.txt", Status: ChangeModified, Type: TypeFile, OldSize: 1, NewSize: 1},
+		{Path: "d.txt", Status: ChangeAdded, Type: TypeFile, NewSize: 1},
+	}
+
+	changes, err := s.Diff(ws, first.ID, "")
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Diff() against workspace = %+v, want %+v", changes, want)
+	}
+
+	second, err := s.Create(ws, CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	changes, err = s.Diff(ws, first.ID, second.ID)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Diff() between snapshots = %+v, want %+v", changes, want)
+	}
+
+	changes, err = s.Diff(ws, second.ID, "")
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Diff() of unchanged workspace = %+v, want none", changes)
+	}
+}
+
+func TestStore_IncrementalAndPrune(t *testing.T) {
+	s, ws := newTestStore(t, Options{MaxCount: 2})
+	writeFiles(t, ws, map[string]string{"big.bin": string(make([]byte, 4096))})
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		snap, err := s.Create(ws, CreateOptions{})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if i > 0 && snap.StoredSize != 0 {
+			t.Errorf("snapshot %d stored %d bytes, want 0 for an unchanged workspace", i, snap.StoredSize)
+		}
+		ids = append(ids, snap.ID)
+	}
+
+	snaps, err := s.List(ws)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(snaps) != 2 || snaps[0].ID != ids[1] || snaps[1].ID != ids[2] {
+		t.Errorf("List() = %+v, want the two newest snapshots", snaps)
+	}
+	if _, err := s.Get(ws, ids[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of pruned snapshot error = %v, want ErrNotFound", err)
+	}
+
+	for _, id := range ids[1:] {
+		if err := s.Delete(ws, id); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	}
+	objects, _ := filepath.Glob(filepath.Join(s.dir, objectsDir, "*", "*"))
+	if len(objects) != 0 {
+		t.Errorf("objects left after deleting every snapshot: %v", objects)
+	}
+}
+
+func TestStore_Errors(t *testing.T) {
+	s, ws := newTestStore(t, Options{MaxSize: 10})
+	writeFiles(t, ws, map[string]string{"large.txt": "more than ten bytes"})
+
+	if _, err := s.Create(ws, CreateOptions{}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Create() of large workspace error = %v, want ErrTooLarge", err)
+	}
+	if _, err := s.Create(t.TempDir(), CreateOptions{}); err == nil {
+		t.Error("Create() outside the workspace root succeeded")
+	}
+	link := filepath.Join(filepath.Dir(ws), "link")
+	if err := os.Symlink(t.TempDir(), link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(link, CreateOptions{}); err == nil {
+		t.Error("Create() through a symlink out of the workspace root succeeded")
+	}
+	if _, err := s.Create(ws, CreateOptions{Exclude: []string{"["}}); err == nil {
+		t.Error("Create() with invalid exclude pattern succeeded")
+	}
+	if _, err := s.Restore(ws, "../../etc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore() of invalid id error = %v, want ErrNotFound", err)
+	}
+
+	// Snapshots are only visible from their own workspace.
+	os.Remove(filepath.Join(ws, "large.txt"))
+	snap, err := s.Create(ws, CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	other := filepath.Join(filepath.Dir(ws), "s2")
+	os.MkdirAll(other, 0755)
+	if _, err := s.Restore(other, snap.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore() from another workspace error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_Restored(t *testing.T) {
+	var restored []string
+	s, ws := newTestStore(t, Options{Restored: func(workspace string) {
+		restored = append(restored, workspace)
+	}})
+	snap, err := s.Create(ws, CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := s.Restore(ws, snap.ID); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if !reflect.DeepEqual(restored, []string{ws}) {
+		t.Errorf("Restored called with %v, want [%s]", restored, ws)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"unsafe"
 
 	"github.com/creack/pty"
+	"github.com/deep-agent/sandbox/internal/services/identity"
 )
 
 type Terminal struct {
@@ -23,7 +24,21 @@ type Size struct {
 	Cols uint16 `json:"cols"`
 }
 
-func New(shell string, workDir string, env []string) (*Terminal, error) {
+type Option func(*exec.Cmd)
+
+// WithUser starts the shell with the credentials and login variables of a
+// session user.
+func WithUser(user *identity.User) Option {
+	return func(cmd *exec.Cmd) {
+		if user == nil {
+			return
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: user.Credential()}
+		cmd.Env = append(cmd.Env, user.Env()...)
+	}
+}
+
+func New(shell string, workDir string, env []string, opts ...Option) (*Terminal, error) {
 	if shell == "" {
 		shell = "/bin/bash"
 	}
@@ -31,6 +46,9 @@ func New(shell string, workDir string, env []string) (*Terminal, error) {
 	cmd := exec.Command(shell)
 	cmd.Dir = workDir
 	cmd.Env = append(os.Environ(), env...)
+	for _, opt := range opts {
+		opt(cmd)
+	}
 
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+// FileArchive streams an archive of the directory req selects to w and
+// returns the number of bytes written.
+func (c *Client) FileArchive(req *model.FileArchiveRequest, w io.Writer) (int64, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	httpReq, err := c.newRequest("POST", "/v1/file/archive", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if _, err := decodeResponse(resp); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("download failed: %w", err)
+	}
+	return n, nil
+}
+
+// FileExtract streams the archive read from r into the sandbox and unpacks
+// it into req.Path.
+func (c *Client) FileExtract(req *model.FileExtractRequest, r io.Reader) (*model.FileExtractResult, error) {
+	query := url.Values{}
+	query.Set("path", req.Path)
+	if req.Format != "" {
+		query.Set("format", req.Format)
+	}
+	if req.StripComponents > 0 {
+		query.Set("strip_components", strconv.Itoa(req.StripComponents))
+	}
+	httpReq, err := c.newRequest("POST", "/v1/file/extract?"+query.Encode(), r)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result, err := decodeResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	var extract model.FileExtractResult
+	if err := json.Unmarshal(result.Data, &extract); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+	return &extract, nil
+}
+
+// UploadDir copies the local directory localDir into remoteDir in the
+// sandbox, packed on the fly into a single tar.gz stream. Files already in
+// remoteDir are replaced but not removed. opts selects the files to copy as
+// for FileArchive; its Path and Format are ignored.
+func (c *Client) UploadDir(localDir, remoteDir string, opts *model.FileArchiveRequest) (*model.FileExtractResult, error) {
+	archiveOpts := filesystem.ArchiveOptions{Format: filesystem.ArchiveTarGz}
+	if opts != nil {
+		archiveOpts.Include = opts.Include
+		archiveOpts.Exclude = opts.Exclude
+		archiveOpts.Gitignore = opts.Gitignore
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(filesystem.NewManager().Archive(localDir, pw, archiveOpts))
+	}()
+	result, err := c.FileExtract(&model.FileExtractRequest{Path: remoteDir, Format: filesystem.ArchiveTarGz}, pr)
+	// Stops the packing when the request failed before reading it all.
+	pr.Close()
+	return result, err
+}
+
+// DownloadDir copies remoteDir from the sandbox into the local directory
+// localDir, which is created if needed. opts selects the files to copy as
+// for FileArchive; its Path and Format are ignored.
+func (c *Client) DownloadDir(remoteDir, localDir string, opts *model.FileArchiveRequest) (*model.FileExtractResult, error) {
+	req := model.FileArchiveRequest{}
+	if opts != nil {
+		req = *opts
+	}
+	req.Path = remoteDir
+	req.Format = filesystem.ArchiveTarGz
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := c.FileArchive(&req, pw)
+		pw.CloseWithError(err)
+	}()
+	result, err := filesystem.NewManager().Extract(localDir, pr, filesystem.ExtractOptions{Format: filesystem.ArchiveTarGz})
+	pr.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &model.FileExtractResult{
+		Path:  result.Path,
+		Files: result.Files,
+		Dirs:  result.Dirs,
+		Size:  result.Size,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+func (c *Client) AuditQuery(req *model.AuditQueryRequest) (*model.AuditQueryResult, error) {
+	query := url.Values{}
+	if req.SessionID != "" {
+		query.Set("session", req.SessionID)
+	}
+	if req.Tool != "" {
+		query.Set("tool", req.Tool)
+	}
+	if req.Since != "" {
+		query.Set("since", req.Since)
+	}
+	if req.Until != "" {
+		query.Set("until", req.Until)
+	}
+	if req.Limit > 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+
+	path := "/v1/audit"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.AuditQueryResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
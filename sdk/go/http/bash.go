@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/deep-agent/sandbox/types/model"
 )
@@ -24,3 +25,148 @@ func (c *Client) BashExec(req *model.BashExecRequest) (*model.BashExecResult, er
 
 	return &result, nil
 }
+
+func (c *Client) BashSetEnv(req *model.BashSessionEnvRequest) (*model.BashSessionEnvResult, error) {
+	resp, err := c.doRequest("PUT", "/v1/bash/sessions/"+url.PathEscape(c.bashSessionID(req.SessionID))+"/env", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BashSessionEnvResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BashGetEnv(sessionID string) (*model.BashSessionEnvResult, error) {
+	resp, err := c.doRequest("GET", "/v1/bash/sessions/"+url.PathEscape(c.bashSessionID(sessionID))+"/env", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BashSessionEnvResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) bashSessionID(sessionID string) string {
+	if sessionID != "" {
+		return sessionID
+	}
+	if c.sessionID != "" {
+		return c.sessionID
+	}
+	return "default"
+}
+
+func (c *Client) BashJobList() (*model.BashJobListResult, error) {
+	resp, err := c.doRequest("GET", "/v1/bash/jobs", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BashJobListResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BashJobGet(id string) (*model.BashJobInfo, error) {
+	resp, err := c.doRequest("GET", "/v1/bash/jobs/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BashJobInfo
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BashJobOutput(req *model.BashJobOutputRequest) (*model.BashJobOutputResult, error) {
+	path := fmt.Sprintf("/v1/bash/jobs/%s/output?offset=%d&limit=%d", url.PathEscape(req.ID), req.Offset, req.Limit)
+	resp, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BashJobOutputResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BashJobKill(req *model.BashJobKillRequest) (*model.BashJobInfo, error) {
+	resp, err := c.doRequest("POST", "/v1/bash/jobs/"+url.PathEscape(req.ID)+"/kill", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BashJobInfo
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BashCheckPolicy(req *model.BashPolicyCheckRequest) (*model.BashPolicyDecision, error) {
+	resp, err := c.doRequest("POST", "/v1/bash/policy/check", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BashPolicyDecision
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BashApprovalList() (*model.BashApprovalListResult, error) {
+	resp, err := c.doRequest("GET", "/v1/bash/approvals", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BashApprovalListResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BashApprove(id string) (*model.BashApproval, error) {
+	return c.resolveBashApproval(&model.BashApprovalResolveRequest{ID: id}, "approve")
+}
+
+func (c *Client) BashReject(req *model.BashApprovalResolveRequest) (*model.BashApproval, error) {
+	return c.resolveBashApproval(req, "reject")
+}
+
+func (c *Client) resolveBashApproval(req *model.BashApprovalResolveRequest, action string) (*model.BashApproval, error) {
+	resp, err := c.doRequest("POST", "/v1/bash/approvals/"+url.PathEscape(req.ID)+"/"+action, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BashApproval
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
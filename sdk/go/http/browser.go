@@ -3,6 +3,8 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 
 	"github.com/deep-agent/sandbox/types/model"
 )
@@ -50,6 +52,39 @@ func (c *Client) BrowserType(req *model.BrowserTypeRequest) error {
 	return err
 }
 
+func (c *Client) BrowserHover(req *model.BrowserHoverRequest) error {
+	_, err := c.doRequest("POST", "/v1/browser/hover", req)
+	return err
+}
+
+func (c *Client) BrowserSelect(req *model.BrowserSelectRequest) (*model.BrowserSelectResult, error) {
+	resp, err := c.doRequest("POST", "/v1/browser/select", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BrowserSelectResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BrowserSnapshot() (*model.BrowserSnapshotResult, error) {
+	resp, err := c.doRequest("POST", "/v1/browser/snapshot", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BrowserSnapshotResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
 func (c *Client) BrowserEvaluate(req *model.BrowserEvaluateRequest) (*model.BrowserEvaluateResult, error) {
 	resp, err := c.doRequest("POST", "/v1/browser/evaluate", req)
 	if err != nil {
@@ -69,6 +104,36 @@ func (c *Client) BrowserScroll(req *model.BrowserScrollRequest) error {
 	return err
 }
 
+func (c *Client) BrowserMouseMove(req *model.BrowserMouseMoveRequest) error {
+	_, err := c.doRequest("POST", "/v1/browser/mouse/move", req)
+	return err
+}
+
+func (c *Client) BrowserMouseClick(req *model.BrowserMouseClickRequest) error {
+	_, err := c.doRequest("POST", "/v1/browser/mouse/click", req)
+	return err
+}
+
+func (c *Client) BrowserMouseDrag(req *model.BrowserMouseDragRequest) error {
+	_, err := c.doRequest("POST", "/v1/browser/mouse/drag", req)
+	return err
+}
+
+func (c *Client) BrowserMouseWheel(req *model.BrowserMouseWheelRequest) error {
+	_, err := c.doRequest("POST", "/v1/browser/mouse/wheel", req)
+	return err
+}
+
+func (c *Client) BrowserPressKey(req *model.BrowserKeyPressRequest) error {
+	_, err := c.doRequest("POST", "/v1/browser/keyboard/press", req)
+	return err
+}
+
+func (c *Client) BrowserInsertText(req *model.BrowserInsertTextRequest) error {
+	_, err := c.doRequest("POST", "/v1/browser/keyboard/insert", req)
+	return err
+}
+
 func (c *Client) BrowserGetHTML(req *model.BrowserGetHTMLRequest) (*model.BrowserGetHTMLResult, error) {
 	resp, err := c.doRequest("POST", "/v1/browser/html", req)
 	if err != nil {
@@ -143,3 +208,129 @@ func (c *Client) BrowserPDF() (*model.BrowserPDFResult, error) {
 
 	return &result, nil
 }
+
+func (c *Client) BrowserTabs() (*model.BrowserTabListResult, error) {
+	resp, err := c.doRequest("GET", "/v1/browser/tabs", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BrowserTabListResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BrowserOpenTab(req *model.BrowserOpenTabRequest) (*model.BrowserTab, error) {
+	resp, err := c.doRequest("POST", "/v1/browser/tabs", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BrowserTab
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BrowserCloseTab(id string) error {
+	_, err := c.doRequest("DELETE", "/v1/browser/tabs/"+url.PathEscape(id), nil)
+	return err
+}
+
+func (c *Client) BrowserActivateTab(id string) error {
+	_, err := c.doRequest("POST", "/v1/browser/tabs/"+url.PathEscape(id)+"/activate", nil)
+	return err
+}
+
+func (c *Client) BrowserContexts() (*model.BrowserContextListResult, error) {
+	resp, err := c.doRequest("GET", "/v1/browser/contexts", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BrowserContextListResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BrowserCloseContext(sessionID string) error {
+	_, err := c.doRequest("DELETE", "/v1/browser/contexts/"+url.PathEscape(sessionID), nil)
+	return err
+}
+
+func (c *Client) BrowserConsoleMessages(req *model.BrowserConsoleRequest) (*model.BrowserConsoleResult, error) {
+	query := url.Values{}
+	if req.Level != "" {
+		query.Set("level", req.Level)
+	}
+	if req.Limit > 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+
+	path := "/v1/browser/console"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BrowserConsoleResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BrowserNetworkRequests(req *model.BrowserNetworkRequest) (*model.BrowserNetworkResult, error) {
+	query := url.Values{}
+	if req.URL != "" {
+		query.Set("url", req.URL)
+	}
+	if req.Status != "" {
+		query.Set("status", req.Status)
+	}
+	if req.Limit > 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+
+	path := "/v1/browser/network"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.BrowserNetworkResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) BrowserNetworkHAR() (*model.HAR, error) {
+	resp, err := c.doRequest("GET", "/v1/browser/network/har", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.HAR
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
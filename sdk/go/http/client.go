@@ -25,6 +25,7 @@ type Client struct {
 	tokenProvider tokenProvider
 	sessionID     string
 	cwd           string
+	adminToken    string
 }
 
 type Option func(*Client)
@@ -71,6 +72,14 @@ func WithCwd(cwd string) Option {
 	}
 }
 
+// WithAdminToken sends the server's SANDBOX_ADMIN_TOKEN, which changing
+// session command policies and resolving approvals require.
+func WithAdminToken(token string) Option {
+	return func(c *Client) {
+		c.adminToken = token
+	}
+}
+
 func NewClient(baseURL, sessionID string, opts ...Option) *Client {
 	c := &Client{
 		baseURL:   baseURL,
@@ -103,12 +112,29 @@ func (c *Client) doRequest(method, path string, body interface{}) (*response, er
 		reqBody = bytes.NewReader(jsonBody)
 	}
 
-	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	req, err := c.newRequest(method, path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return decodeResponse(resp)
+}
+
+// newRequest creates a request carrying the session, workspace and token of
+// the client.
+func (c *Client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
 	if c.cwd != "" {
 		req.Header.Set(consts.HeaderWorkspace, c.cwd)
 	}
@@ -117,6 +143,10 @@ func (c *Client) doRequest(method, path string, body interface{}) (*response, er
 		req.Header.Set(consts.HeaderSessionID, c.sessionID)
 	}
 
+	if c.adminToken != "" {
+		req.Header.Set(consts.HeaderAdminToken, c.adminToken)
+	}
+
 	if c.tokenProvider != nil {
 		token, err := c.tokenProvider()
 		if err != nil {
@@ -126,13 +156,10 @@ func (c *Client) doRequest(method, path string, body interface{}) (*response, er
 			req.Header.Set("Authorization", "Bearer "+token)
 		}
 	}
+	return req, nil
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
+func decodeResponse(resp *http.Response) (*response, error) {
 	var result response
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
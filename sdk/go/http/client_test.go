@@ -1,13 +1,19 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
+	"github.com/deep-agent/sandbox/types/consts"
 	"github.com/deep-agent/sandbox/types/model"
 )
 
@@ -33,6 +39,7 @@ func TestNewClientWithOptions(t *testing.T) {
 	client := NewClient(testBaseURL, "test-session",
 		WithTimeout(60*time.Second),
 		WithSecret("test-secret"),
+		WithAdminToken("admin"),
 	)
 
 	if client.httpClient.Timeout != 60*time.Second {
@@ -42,6 +49,14 @@ func TestNewClientWithOptions(t *testing.T) {
 	if client.tokenProvider == nil {
 		t.Error("expected tokenProvider to be set")
 	}
+
+	req, err := client.newRequest("GET", "/v1/bash/approvals", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get(consts.HeaderAdminToken); got != "admin" {
+		t.Errorf("expected admin token header, got %q", got)
+	}
 }
 
 func TestWithSecretFromEnv(t *testing.T) {
@@ -159,6 +174,125 @@ func TestBashExec(t *testing.T) {
 	}
 }
 
+func TestBashJobOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/bash/jobs/job_1/output" {
+			t.Errorf("expected path /v1/bash/jobs/job_1/output, got %s", r.URL.Path)
+		}
+		if r.Method != "GET" {
+			t.Errorf("expected method GET, got %s", r.Method)
+		}
+		if r.URL.Query().Get("offset") != "5" {
+			t.Errorf("expected offset 5, got %s", r.URL.Query().Get("offset"))
+		}
+
+		resp := map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"output":      "world\n",
+				"offset":      5,
+				"next_offset": 11,
+				"size":        11,
+				"state":       "exited",
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.BashJobOutput(&model.BashJobOutputRequest{ID: "job_1", Offset: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Output != "world\n" {
+		t.Errorf("expected output 'world\\n', got %s", result.Output)
+	}
+	if result.NextOffset != 11 {
+		t.Errorf("expected next offset 11, got %d", result.NextOffset)
+	}
+	if result.State != "exited" {
+		t.Errorf("expected state exited, got %s", result.State)
+	}
+}
+
+func TestBashJobKill(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/bash/jobs/job_2/kill" {
+			t.Errorf("expected path /v1/bash/jobs/job_2/kill, got %s", r.URL.Path)
+		}
+		if r.Method != "POST" {
+			t.Errorf("expected method POST, got %s", r.Method)
+		}
+
+		var req model.BashJobKillRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		if req.Signal != "SIGKILL" {
+			t.Errorf("expected signal SIGKILL, got %s", req.Signal)
+		}
+
+		resp := map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"id":    "job_2",
+				"state": "killed",
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.BashJobKill(&model.BashJobKillRequest{ID: "job_2", Signal: "SIGKILL"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.State != "killed" {
+		t.Errorf("expected state killed, got %s", result.State)
+	}
+}
+
+func TestBashSetEnv(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/bash/sessions/test-session/env" {
+			t.Errorf("expected path /v1/bash/sessions/test-session/env, got %s", r.URL.Path)
+		}
+		if r.Method != "PUT" {
+			t.Errorf("expected method PUT, got %s", r.Method)
+		}
+
+		var req model.BashSessionEnvRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		if req.Env["APP_MODE"] != "dev" {
+			t.Errorf("expected APP_MODE=dev, got %v", req.Env)
+		}
+
+		resp := map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"session_id": "test-session",
+				"env":        req.Env,
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.BashSetEnv(&model.BashSessionEnvRequest{Env: map[string]string{"APP_MODE": "dev"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.SessionID != "test-session" || result.Env["APP_MODE"] != "dev" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
 func TestFileRead(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/v1/file/read" {
@@ -228,6 +362,239 @@ func TestFileWrite(t *testing.T) {
 	}
 }
 
+func TestFileEdit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/file/edit" {
+			t.Errorf("expected path /v1/file/edit, got %s", r.URL.Path)
+		}
+
+		var req model.FileEditRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		if req.OldString != "old" || req.NewString != "new" || !req.DryRun {
+			t.Errorf("unexpected request %+v", req)
+		}
+
+		resp := map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"diff":         "--- /tmp/test.txt\n+++ /tmp/test.txt\n@@ -1 +1 @@\n-old\n+new\n",
+				"strategy":     "simple",
+				"replacements": 1,
+				"dry_run":      true,
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.FileEdit(&model.FileEditRequest{
+		File:      "/tmp/test.txt",
+		OldString: "old",
+		NewString: "new",
+		DryRun:    true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Strategy != "simple" || !result.DryRun || result.Diff == "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestFilePatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/file/patch" {
+			t.Errorf("expected path /v1/file/patch, got %s", r.URL.Path)
+		}
+
+		var req model.FilePatchRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		if req.Patch == "" {
+			t.Error("expected patch in request")
+		}
+
+		resp := map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"files": []map[string]interface{}{
+					{"path": "/tmp/a.txt", "op": "modify", "added": 1, "removed": 1},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.FilePatch(&model.FilePatchRequest{
+		Patch: "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+A\n",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Op != "modify" || result.Files[0].Added != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestFileDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/file/download" {
+			t.Errorf("expected path /v1/file/download, got %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("path") != "/tmp/test.bin" {
+			t.Errorf("expected path /tmp/test.bin, got %s", r.URL.Query().Get("path"))
+		}
+		if r.Header.Get("Range") != "bytes=2-5" {
+			t.Errorf("expected range bytes=2-5, got %s", r.Header.Get("Range"))
+		}
+
+		w.Header().Set("Content-Range", "bytes 2-5/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("2345"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	var buf bytes.Buffer
+	n, err := client.FileDownload(&model.FileDownloadRequest{
+		Path:   "/tmp/test.bin",
+		Offset: 2,
+		Length: 4,
+	}, &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 4 || buf.String() != "2345" {
+		t.Errorf("unexpected download %d %q", n, buf.String())
+	}
+}
+
+func TestFileUpload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/v1/file/upload" {
+			t.Errorf("expected PUT /v1/file/upload, got %s %s", r.Method, r.URL.Path)
+		}
+		query := r.URL.Query()
+		if query.Get("path") != "/tmp/test.bin" || query.Get("offset") != "4" ||
+			query.Get("partial") != "" || query.Get("sha256") != "abc" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "4567" {
+			t.Errorf("unexpected body %q", body)
+		}
+
+		resp := map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"path":     "/tmp/test.bin",
+				"size":     8,
+				"sha256":   "abc",
+				"complete": true,
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.FileUpload(&model.FileUploadRequest{
+		Path:   "/tmp/test.bin",
+		Offset: 4,
+		SHA256: "abc",
+	}, strings.NewReader("4567"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Size != 8 || !result.Complete {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestFileExtract(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/file/extract" {
+			t.Errorf("expected POST /v1/file/extract, got %s %s", r.Method, r.URL.Path)
+		}
+		query := r.URL.Query()
+		if query.Get("path") != "/tmp/project" || query.Get("format") != "zip" || query.Get("strip_components") != "1" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "archive" {
+			t.Errorf("unexpected body %q", body)
+		}
+
+		resp := map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"path":  "/tmp/project",
+				"files": 2,
+				"dirs":  1,
+				"size":  12,
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.FileExtract(&model.FileExtractRequest{
+		Path:            "/tmp/project",
+		Format:          "zip",
+		StripComponents: 1,
+	}, strings.NewReader("archive"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Files != 2 || result.Dirs != 1 || result.Size != 12 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestUploadDir(t *testing.T) {
+	local := t.TempDir()
+	os.MkdirAll(filepath.Join(local, "src"), 0755)
+	os.WriteFile(filepath.Join(local, "src", "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(local, "debug.log"), []byte("log\n"), 0644)
+	remote := t.TempDir()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "tar.gz" {
+			t.Errorf("expected a tar.gz upload, got %s", r.URL.RawQuery)
+		}
+		result, err := filesystem.NewManager().Extract(remote, r.Body, filesystem.ExtractOptions{})
+		if err != nil {
+			t.Errorf("failed to extract upload: %v", err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{"path": result.Path, "files": result.Files, "dirs": result.Dirs},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.UploadDir(local, "/workspace", &model.FileArchiveRequest{Exclude: []string{"*.log"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Files != 1 || result.Dirs != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if content, err := os.ReadFile(filepath.Join(remote, "src", "main.go")); err != nil || string(content) != "package main\n" {
+		t.Errorf("unexpected uploaded file %q, %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(remote, "debug.log")); !os.IsNotExist(err) {
+		t.Error("expected excluded files to be left out")
+	}
+}
+
 func TestFileList(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/v1/file/list" {
@@ -260,6 +627,44 @@ func TestFileList(t *testing.T) {
 	}
 }
 
+func TestFileTree(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/file/tree" {
+			t.Errorf("expected path /v1/file/tree, got %s", r.URL.Path)
+		}
+		var req model.FileTreeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Depth != 2 || !req.Gitignore || req.Format != "text" {
+			t.Errorf("unexpected request %+v", req)
+		}
+
+		resp := map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"path": "/tmp",
+				"entries": []map[string]interface{}{
+					{"name": "src", "path": "/tmp/src", "depth": 1, "is_dir": true},
+					{"name": "main.go", "path": "/tmp/src/main.go", "depth": 2, "size": 13, "mime_type": "text/plain; charset=utf-8"},
+				},
+				"total": 2,
+				"text":  "- /tmp/\n  - src/\n    - main.go\n",
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.FileTree(&model.FileTreeRequest{Path: "/tmp", Depth: 2, Gitignore: true, Format: "text"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Total != 2 || len(result.Entries) != 2 || result.Entries[1].Depth != 2 || result.Text == "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
 func TestFileDelete(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/v1/file/delete" {
@@ -526,6 +931,73 @@ func TestBrowserType(t *testing.T) {
 	}
 }
 
+func TestBrowserMouseClick(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/browser/mouse/click" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var req model.BrowserMouseClickRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.X != 12.5 || req.Y != 40 || req.ClickCount != 2 || req.Button != "left" {
+			t.Errorf("unexpected body %+v", req)
+		}
+		resp := map[string]interface{}{"code": 0}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	err := client.BrowserMouseClick(&model.BrowserMouseClickRequest{X: 12.5, Y: 40, Button: "left", ClickCount: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBrowserPressKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/browser/keyboard/press" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		resp := map[string]interface{}{"code": 0}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	if err := client.BrowserPressKey(&model.BrowserKeyPressRequest{Key: "Control+a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBrowserNetworkRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1/browser/network" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if q := r.URL.Query(); q.Get("url") != "api/" || q.Get("status") != "4xx" || q.Get("limit") != "5" {
+			t.Errorf("unexpected query %v", q)
+		}
+		resp := map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"requests": []map[string]interface{}{{"id": "1", "method": "GET", "url": "http://app/api/", "status": 404}},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-session")
+	result, err := client.BrowserNetworkRequests(&model.BrowserNetworkRequest{URL: "api/", Status: "4xx", Limit: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result.Requests) != 1 || result.Requests[0].Status != 404 {
+		t.Errorf("unexpected requests: %+v", result.Requests)
+	}
+}
+
 func TestBrowserEvaluate(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := map[string]interface{}{
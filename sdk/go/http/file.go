@@ -3,6 +3,8 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 
 	"github.com/deep-agent/sandbox/types/model"
 )
@@ -40,6 +42,62 @@ func (c *Client) FileList(req *model.FileListRequest) (*model.FileListResult, er
 	return &result, nil
 }
 
+func (c *Client) FileTree(req *model.FileTreeRequest) (*model.FileTreeResult, error) {
+	resp, err := c.doRequest("POST", "/v1/file/tree", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.FileTreeResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) FileEdit(req *model.FileEditRequest) (*model.FileEditResult, error) {
+	resp, err := c.doRequest("POST", "/v1/file/edit", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.FileEditResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) FileMultiEdit(req *model.FileMultiEditRequest) (*model.FileMultiEditResult, error) {
+	resp, err := c.doRequest("POST", "/v1/file/multi_edit", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.FileMultiEditResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) FilePatch(req *model.FilePatchRequest) (*model.FilePatchResult, error) {
+	resp, err := c.doRequest("POST", "/v1/file/patch", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.FilePatchResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
 func (c *Client) FileDelete(req *model.FileDeleteRequest) error {
 	_, err := c.doRequest("POST", "/v1/file/delete", req)
 	return err
@@ -73,3 +131,40 @@ func (c *Client) FileExists(path string) (*model.FileExistsResult, error) {
 
 	return &result, nil
 }
+
+// FileHistory lists the undoable changes of the session to path, or all of
+// them when path is empty, newest first. A limit of zero returns every change.
+func (c *Client) FileHistory(path string, limit int) (*model.FileHistoryResult, error) {
+	query := url.Values{}
+	if path != "" {
+		query.Set("path", path)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	resp, err := c.doRequest("GET", "/v1/file/history?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.FileHistoryResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) FileUndo(req *model.FileUndoRequest) (*model.FileUndoResult, error) {
+	resp, err := c.doRequest("POST", "/v1/file/undo", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.FileUndoResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+// FileDownload streams a file, or the range of it req selects, to w and
+// returns the number of bytes written. The client timeout bounds the whole
+// transfer.
+func (c *Client) FileDownload(req *model.FileDownloadRequest, w io.Writer) (int64, error) {
+	query := url.Values{}
+	query.Set("path", req.Path)
+	httpReq, err := c.newRequest("GET", "/v1/file/download?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if req.Offset > 0 || req.Length > 0 {
+		end := ""
+		if req.Length > 0 {
+			end = strconv.FormatInt(req.Offset+req.Length-1, 10)
+		}
+		httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-%s", req.Offset, end))
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		if _, err := decodeResponse(resp); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("download failed: %w", err)
+	}
+	return n, nil
+}
+
+// FileUpload streams r into a file. For a resumable upload, send the file in
+// chunks with Partial set on all but the last, and resume an interrupted one
+// at the offset FileUploadStatus reports.
+func (c *Client) FileUpload(req *model.FileUploadRequest, r io.Reader) (*model.FileUploadResult, error) {
+	query := url.Values{}
+	query.Set("path", req.Path)
+	if req.Offset > 0 {
+		query.Set("offset", strconv.FormatInt(req.Offset, 10))
+	}
+	if req.Partial {
+		query.Set("partial", "true")
+	}
+	if req.SHA256 != "" {
+		query.Set("sha256", req.SHA256)
+	}
+	httpReq, err := c.newRequest("PUT", "/v1/file/upload?"+query.Encode(), r)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result, err := decodeResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	var upload model.FileUploadResult
+	if err := json.Unmarshal(result.Data, &upload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+	return &upload, nil
+}
+
+func (c *Client) FileUploadStatus(path string) (*model.FileUploadStatus, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	resp, err := c.doRequest("GET", "/v1/file/upload?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.FileUploadStatus
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/deep-agent/sandbox/types/model"
+)
+
+func (c *Client) WorkspaceSnapshot(req *model.WorkspaceSnapshotRequest) (*model.WorkspaceSnapshot, error) {
+	resp, err := c.doRequest("POST", "/v1/workspace/snapshot", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.WorkspaceSnapshot
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) WorkspaceSnapshotList() (*model.WorkspaceSnapshotListResult, error) {
+	resp, err := c.doRequest("GET", "/v1/workspace/snapshots", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.WorkspaceSnapshotListResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) WorkspaceSnapshotDiff(req *model.WorkspaceSnapshotDiffRequest) (*model.WorkspaceSnapshotDiffResult, error) {
+	resp, err := c.doRequest("POST", "/v1/workspace/snapshots/diff", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.WorkspaceSnapshotDiffResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) WorkspaceSnapshotRestore(id string) (*model.WorkspaceSnapshot, error) {
+	resp, err := c.doRequest("POST", "/v1/workspace/snapshots/"+url.PathEscape(id)+"/restore", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.WorkspaceSnapshot
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) WorkspaceSnapshotDelete(id string) error {
+	_, err := c.doRequest("DELETE", "/v1/workspace/snapshots/"+url.PathEscape(id), nil)
+	return err
+}
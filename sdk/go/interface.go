@@ -1,6 +1,10 @@
 package sandbox
 
-import "github.com/deep-agent/sandbox/types/model"
+import (
+	"io"
+
+	"github.com/deep-agent/sandbox/types/model"
+)
 
 type Sandbox interface {
 	ContextProvider
@@ -16,17 +20,29 @@ type ContextProvider interface {
 
 type BashExecutor interface {
 	BashExec(req *model.BashExecRequest) (*model.BashExecResult, error)
+	BashSetEnv(req *model.BashSessionEnvRequest) (*model.BashSessionEnvResult, error)
+	BashGetEnv(sessionID string) (*model.BashSessionEnvResult, error)
+	BashJobList() (*model.BashJobListResult, error)
+	BashJobGet(id string) (*model.BashJobInfo, error)
+	BashJobOutput(req *model.BashJobOutputRequest) (*model.BashJobOutputResult, error)
+	BashJobKill(req *model.BashJobKillRequest) (*model.BashJobInfo, error)
 }
 
 type FileManager interface {
 	FileRead(req *model.FileReadRequest) (*model.FileReadResult, error)
 	FileWrite(req *model.FileWriteRequest) error
 	FileList(req *model.FileListRequest) (*model.FileListResult, error)
+	FileTree(req *model.FileTreeRequest) (*model.FileTreeResult, error)
 	FileDelete(req *model.FileDeleteRequest) error
 	FileMove(req *model.FileMoveRequest) error
 	FileCopy(req *model.FileCopyRequest) error
 	MkDir(req *model.MkDirRequest) error
 	FileExists(path string) (*model.FileExistsResult, error)
+	FileDownload(req *model.FileDownloadRequest, w io.Writer) (int64, error)
+	FileUpload(req *model.FileUploadRequest, r io.Reader) (*model.FileUploadResult, error)
+	FileUploadStatus(path string) (*model.FileUploadStatus, error)
+	FileArchive(req *model.FileArchiveRequest, w io.Writer) (int64, error)
+	FileExtract(req *model.FileExtractRequest, r io.Reader) (*model.FileExtractResult, error)
 }
 
 type GrepSearcher interface {
@@ -39,12 +55,30 @@ type BrowserController interface {
 	BrowserScreenshot(req *model.BrowserScreenshotRequest) (*model.BrowserScreenshotResult, error)
 	BrowserClick(req *model.BrowserClickRequest) error
 	BrowserType(req *model.BrowserTypeRequest) error
+	BrowserHover(req *model.BrowserHoverRequest) error
+	BrowserSelect(req *model.BrowserSelectRequest) (*model.BrowserSelectResult, error)
+	BrowserSnapshot() (*model.BrowserSnapshotResult, error)
+	BrowserConsoleMessages(req *model.BrowserConsoleRequest) (*model.BrowserConsoleResult, error)
+	BrowserNetworkRequests(req *model.BrowserNetworkRequest) (*model.BrowserNetworkResult, error)
+	BrowserNetworkHAR() (*model.HAR, error)
 	BrowserEvaluate(req *model.BrowserEvaluateRequest) (*model.BrowserEvaluateResult, error)
 	BrowserScroll(req *model.BrowserScrollRequest) error
+	BrowserMouseMove(req *model.BrowserMouseMoveRequest) error
+	BrowserMouseClick(req *model.BrowserMouseClickRequest) error
+	BrowserMouseDrag(req *model.BrowserMouseDragRequest) error
+	BrowserMouseWheel(req *model.BrowserMouseWheelRequest) error
+	BrowserPressKey(req *model.BrowserKeyPressRequest) error
+	BrowserInsertText(req *model.BrowserInsertTextRequest) error
 	BrowserGetHTML(req *model.BrowserGetHTMLRequest) (*model.BrowserGetHTMLResult, error)
 	BrowserWaitVisible(req *model.BrowserWaitVisibleRequest) error
 	BrowserGetCurrentURL() (*model.BrowserURLResult, error)
 	BrowserGetTitle() (*model.BrowserTitleResult, error)
 	BrowserGetPageInfo() (*model.BrowserPageInfo, error)
 	BrowserPDF() (*model.BrowserPDFResult, error)
+	BrowserTabs() (*model.BrowserTabListResult, error)
+	BrowserOpenTab(req *model.BrowserOpenTabRequest) (*model.BrowserTab, error)
+	BrowserCloseTab(id string) error
+	BrowserActivateTab(id string) error
+	BrowserContexts() (*model.BrowserContextListResult, error)
+	BrowserCloseContext(sessionID string) error
 }
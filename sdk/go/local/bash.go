@@ -1,7 +1,10 @@
 package local
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/deep-agent/sandbox/internal/services/bash"
@@ -11,41 +14,148 @@ import (
 func (c *Client) BashExec(req *model.BashExecRequest) (*model.BashExecResult, error) {
 	ctx := context.Background()
 
-	timeout := 30 * time.Second
-	if req.TimeoutMS > 0 {
-		timeout = time.Duration(req.TimeoutMS) * time.Millisecond
-	}
-	c.bashExecutor.SetTimeout(timeout)
+	timeout := time.Duration(req.TimeoutMS) * time.Millisecond
 
-	cwd := req.Cwd
-	if cwd == "" {
-		cwd = c.sandboxCtx.Workspace
+	var stdin io.Reader
+	if req.Stdin != "" {
+		data, err := bash.DecodeStdin(req.Stdin, req.StdinEncoding)
+		if err != nil {
+			return nil, err
+		}
+		stdin = bytes.NewReader(data)
 	}
 
 	if req.RunInBackground {
-		result, err := c.bashExecutor.ExecuteBackground(ctx, req.Command, cwd, timeout)
+		cwd := req.Cwd
+		if cwd == "" {
+			cwd = c.bashSessions.Cwd("")
+		}
+		if cwd == "" {
+			cwd = c.sandboxCtx.Workspace
+		}
+		job, err := c.bashJobs.Start(ctx, bash.JobStartRequest{
+			Command: req.Command,
+			WorkDir: cwd,
+			Env:     bash.MergeEnv(c.bashSessions.Env(""), req.Env),
+			Stdin:   stdin,
+			Timeout: timeout,
+			Limits:  bash.LimitsFromModel(req.Limits),
+		})
 		if err != nil {
 			return nil, err
 		}
 		return &model.BashExecResult{
-			Output:     result.Output,
-			ExitCode:   result.ExitCode,
-			OutputFile: result.OutputFile,
+			Output:     fmt.Sprintf("Command started in background (Job ID: %s, PID: %d)", job.ID, job.PID),
+			OutputFile: job.OutputFile,
+			JobID:      job.ID,
 		}, nil
 	}
 
-	truncateOpts := &bash.TruncateOptions{
-		MaxLines: 2000,
-		MaxBytes: 100000,
+	result, err := c.bashSessions.Execute(ctx, bash.SessionExecRequest{
+		Command:   req.Command,
+		Workspace: c.sandboxCtx.Workspace,
+		Cwd:       req.Cwd,
+		Env:       req.Env,
+		Stdin:     stdin,
+		Timeout:   timeout,
+		Limits:    bash.LimitsFromModel(req.Limits),
+		Truncate: &bash.TruncateOptions{
+			MaxLines: 2000,
+			MaxBytes: 100000,
+		},
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	result, err := c.bashExecutor.Execute(ctx, req.Command, cwd, truncateOpts)
+	return &model.BashExecResult{
+		Output:        result.Output,
+		ExitCode:      result.ExitCode,
+		LimitExceeded: result.LimitExceeded,
+	}, nil
+}
+
+func (c *Client) BashSetEnv(req *model.BashSessionEnvRequest) (*model.BashSessionEnvResult, error) {
+	if err := c.bashSessions.SetEnv(req.SessionID, req.Env); err != nil {
+		return nil, err
+	}
+	return c.BashGetEnv(req.SessionID)
+}
+
+func (c *Client) BashGetEnv(sessionID string) (*model.BashSessionEnvResult, error) {
+	if sessionID == "" {
+		sessionID = bash.DefaultSessionID
+	}
+	return &model.BashSessionEnvResult{
+		SessionID: sessionID,
+		Env:       c.bashSessions.Env(sessionID),
+	}, nil
+}
+
+func (c *Client) BashJobList() (*model.BashJobListResult, error) {
+	jobs := c.bashJobs.List("")
+
+	result := &model.BashJobListResult{Jobs: make([]model.BashJobInfo, 0, len(jobs))}
+	for _, j := range jobs {
+		result.Jobs = append(result.Jobs, toBashJobInfo(j))
+	}
+	return result, nil
+}
+
+func (c *Client) BashJobGet(id string) (*model.BashJobInfo, error) {
+	job, err := c.bashJobs.Get("", id)
 	if err != nil {
 		return nil, err
 	}
 
-	return &model.BashExecResult{
-		Output:   result.Output,
-		ExitCode: result.ExitCode,
+	info := toBashJobInfo(*job)
+	return &info, nil
+}
+
+func (c *Client) BashJobOutput(req *model.BashJobOutputRequest) (*model.BashJobOutputResult, error) {
+	output, err := c.bashJobs.ReadOutput("", req.ID, req.Offset, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.BashJobOutputResult{
+		Output:     output.Output,
+		Offset:     output.Offset,
+		NextOffset: output.NextOffset,
+		Size:       output.Size,
+		State:      string(output.State),
 	}, nil
 }
+
+func (c *Client) BashJobKill(req *model.BashJobKillRequest) (*model.BashJobInfo, error) {
+	sig, err := bash.ParseSignal(req.Signal)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := c.bashJobs.Kill("", req.ID, sig)
+	if err != nil {
+		return nil, err
+	}
+
+	info := toBashJobInfo(*job)
+	return &info, nil
+}
+
+func toBashJobInfo(j bash.JobInfo) model.BashJobInfo {
+	info := model.BashJobInfo{
+		ID:            j.ID,
+		SessionID:     j.SessionID,
+		Command:       j.Command,
+		Cwd:           j.WorkDir,
+		PID:           j.PID,
+		State:         string(j.State),
+		ExitCode:      j.ExitCode,
+		OutputFile:    j.OutputFile,
+		StartedAtUnix: j.StartedAt.Unix(),
+	}
+	if !j.EndedAt.IsZero() {
+		info.EndedAtUnix = j.EndedAt.Unix()
+	}
+	return info
+}
@@ -53,14 +53,41 @@ func (c *Client) BrowserClick(req *model.BrowserClickRequest) error {
 	if err := c.ensureBrowser(); err != nil {
 		return err
 	}
-	return c.browserCtrl.Click(req.Selector)
+	return c.browserCtrl.Click(browser.Element{Selector: req.Selector, Ref: req.Ref})
 }
 
 func (c *Client) BrowserType(req *model.BrowserTypeRequest) error {
 	if err := c.ensureBrowser(); err != nil {
 		return err
 	}
-	return c.browserCtrl.Type(req.Selector, req.Text)
+	return c.browserCtrl.Type(browser.Element{Selector: req.Selector, Ref: req.Ref}, req.Text)
+}
+
+func (c *Client) BrowserHover(req *model.BrowserHoverRequest) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.Hover(browser.Element{Selector: req.Selector, Ref: req.Ref})
+}
+
+func (c *Client) BrowserSelect(req *model.BrowserSelectRequest) (*model.BrowserSelectResult, error) {
+	if err := c.ensureBrowser(); err != nil {
+		return nil, err
+	}
+
+	selected, err := c.browserCtrl.Select(browser.Element{Selector: req.Selector, Ref: req.Ref}, req.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.BrowserSelectResult{Selected: selected}, nil
+}
+
+func (c *Client) BrowserSnapshot() (*model.BrowserSnapshotResult, error) {
+	if err := c.ensureBrowser(); err != nil {
+		return nil, err
+	}
+	return c.browserCtrl.Snapshot()
 }
 
 func (c *Client) BrowserEvaluate(req *model.BrowserEvaluateRequest) (*model.BrowserEvaluateResult, error) {
@@ -85,6 +112,52 @@ func (c *Client) BrowserScroll(req *model.BrowserScrollRequest) error {
 	return c.browserCtrl.Scroll(req.X, req.Y)
 }
 
+func (c *Client) BrowserMouseMove(req *model.BrowserMouseMoveRequest) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.MouseMove(req.X, req.Y)
+}
+
+func (c *Client) BrowserMouseClick(req *model.BrowserMouseClickRequest) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.MouseClick(req.X, req.Y, browser.MouseOptions{
+		Button:    req.Button,
+		Count:     req.ClickCount,
+		Modifiers: req.Modifiers,
+	})
+}
+
+func (c *Client) BrowserMouseDrag(req *model.BrowserMouseDragRequest) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.MouseDrag(req.FromX, req.FromY, req.ToX, req.ToY, req.Steps)
+}
+
+func (c *Client) BrowserMouseWheel(req *model.BrowserMouseWheelRequest) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.MouseWheel(req.X, req.Y, req.DeltaX, req.DeltaY)
+}
+
+func (c *Client) BrowserPressKey(req *model.BrowserKeyPressRequest) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.PressKey(req.Key)
+}
+
+func (c *Client) BrowserInsertText(req *model.BrowserInsertTextRequest) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.InsertText(req.Text)
+}
+
 func (c *Client) BrowserGetHTML(req *model.BrowserGetHTMLRequest) (*model.BrowserGetHTMLResult, error) {
 	if err := c.ensureBrowser(); err != nil {
 		return nil, err
@@ -169,3 +242,72 @@ func (c *Client) BrowserPDF() (*model.BrowserPDFResult, error) {
 		PDF: pdf,
 	}, nil
 }
+
+func (c *Client) BrowserTabs() (*model.BrowserTabListResult, error) {
+	if err := c.ensureBrowser(); err != nil {
+		return nil, err
+	}
+
+	tabs, err := c.browserCtrl.Tabs()
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.BrowserTabListResult{Tabs: tabs}, nil
+}
+
+func (c *Client) BrowserOpenTab(req *model.BrowserOpenTabRequest) (*model.BrowserTab, error) {
+	if err := c.ensureBrowser(); err != nil {
+		return nil, err
+	}
+	return c.browserCtrl.OpenTab(req.URL)
+}
+
+func (c *Client) BrowserCloseTab(id string) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.CloseTab(id)
+}
+
+func (c *Client) BrowserActivateTab(id string) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.ActivateTab(id)
+}
+
+func (c *Client) BrowserContexts() (*model.BrowserContextListResult, error) {
+	if err := c.ensureBrowser(); err != nil {
+		return nil, err
+	}
+	return &model.BrowserContextListResult{Contexts: c.browserCtrl.Contexts()}, nil
+}
+
+func (c *Client) BrowserCloseContext(sessionID string) error {
+	if err := c.ensureBrowser(); err != nil {
+		return err
+	}
+	return c.browserCtrl.CloseContext(sessionID)
+}
+
+func (c *Client) BrowserConsoleMessages(req *model.BrowserConsoleRequest) (*model.BrowserConsoleResult, error) {
+	if err := c.ensureBrowser(); err != nil {
+		return nil, err
+	}
+	return c.browserCtrl.ConsoleMessages(browser.ConsoleFilter{Level: req.Level, Limit: req.Limit})
+}
+
+func (c *Client) BrowserNetworkRequests(req *model.BrowserNetworkRequest) (*model.BrowserNetworkResult, error) {
+	if err := c.ensureBrowser(); err != nil {
+		return nil, err
+	}
+	return c.browserCtrl.NetworkRequests(browser.NetworkFilter{URL: req.URL, Status: req.Status, Limit: req.Limit})
+}
+
+func (c *Client) BrowserNetworkHAR() (*model.HAR, error) {
+	if err := c.ensureBrowser(); err != nil {
+		return nil, err
+	}
+	return c.browserCtrl.NetworkHAR()
+}
@@ -13,7 +13,8 @@ import (
 var _ sandbox.Sandbox = (*Client)(nil)
 
 type Client struct {
-	bashExecutor *bash.Executor
+	bashSessions *bash.SessionManager
+	bashJobs     *bash.JobManager
 	fileManager  *filesystem.Manager
 	browserCtrl  *browser.Controller
 	sandboxCtx   *model.SandboxContext
@@ -35,7 +36,8 @@ func WithSandboxContext(ctx *model.SandboxContext) Option {
 
 func NewClient(workDir string, opts ...Option) *Client {
 	c := &Client{
-		bashExecutor: bash.NewExecutor(),
+		bashSessions: bash.NewSessionManager(),
+		bashJobs:     bash.NewJobManager(),
 		fileManager:  filesystem.NewManager(),
 		sandboxCtx: &model.SandboxContext{
 			Workspace: workDir,
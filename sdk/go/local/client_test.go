@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/deep-agent/sandbox/types/model"
 )
@@ -68,8 +69,8 @@ func TestNewClient(t *testing.T) {
 	workDir := t.TempDir()
 	client := NewClient(workDir)
 
-	if client.bashExecutor == nil {
-		t.Error("expected bashExecutor to be initialized")
+	if client.bashSessions == nil {
+		t.Error("expected bashSessions to be initialized")
 	}
 	if client.fileManager == nil {
 		t.Error("expected fileManager to be initialized")
@@ -172,6 +173,105 @@ func TestBashExecWithExitCode(t *testing.T) {
 	}
 }
 
+func TestBashExecBackground(t *testing.T) {
+	workDir := t.TempDir()
+	client := NewClient(workDir)
+
+	result, err := client.BashExec(&model.BashExecRequest{
+		Command:         "echo background",
+		RunInBackground: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.JobID == "" {
+		t.Fatal("expected job ID for background command")
+	}
+
+	var job *model.BashJobInfo
+	for i := 0; i < 100; i++ {
+		job, err = client.BashJobGet(result.JobID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if job.State != "running" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if job.State != "exited" {
+		t.Fatalf("expected state exited, got %s", job.State)
+	}
+
+	output, err := client.BashJobOutput(&model.BashJobOutputRequest{ID: result.JobID})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output.Output != "background\n" {
+		t.Errorf("expected output 'background\\n', got %q", output.Output)
+	}
+
+	list, err := client.BashJobList()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Jobs) != 1 {
+		t.Errorf("expected 1 job, got %d", len(list.Jobs))
+	}
+}
+
+func TestBashExecEnv(t *testing.T) {
+	workDir := t.TempDir()
+	client := NewClient(workDir)
+
+	if _, err := client.BashSetEnv(&model.BashSessionEnvRequest{Env: map[string]string{"APP_MODE": "test"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, err := client.BashExec(&model.BashExecRequest{
+		Command: "echo $APP_MODE $EXTRA",
+		Env:     map[string]string{"EXTRA": "extra"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result.Output, "test extra") {
+		t.Errorf("expected output to contain 'test extra', got %s", result.Output)
+	}
+
+	env, err := client.BashGetEnv("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env.Env["APP_MODE"] != "test" {
+		t.Errorf("expected APP_MODE=test, got %v", env.Env)
+	}
+
+	if _, err := client.BashExec(&model.BashExecRequest{
+		Command: "true",
+		Env:     map[string]string{"LD_PRELOAD": "/tmp/x.so"},
+	}); err == nil {
+		t.Error("expected denied variable to be rejected")
+	}
+}
+
+func TestBashExecStdin(t *testing.T) {
+	workDir := t.TempDir()
+	client := NewClient(workDir)
+
+	result, err := client.BashExec(&model.BashExecRequest{
+		Command:       "cat",
+		Stdin:         "aGVsbG8gc3RkaW4=",
+		StdinEncoding: "base64",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result.Output, "hello stdin") {
+		t.Errorf("expected output to contain 'hello stdin', got %s", result.Output)
+	}
+}
+
 func TestFileWrite(t *testing.T) {
 	workDir := t.TempDir()
 	client := NewClient(workDir)
@@ -675,6 +775,214 @@ func TestBrowserPDF(t *testing.T) {
 	}
 }
 
+func TestBrowserTabs(t *testing.T) {
+	client := newBrowserClient(t)
+
+	if err := client.BrowserNavigate(&model.BrowserNavigateRequest{URL: "data:text/html,<title>First</title><input id=q>"}); err != nil {
+		t.Fatalf("navigate failed: %v", err)
+	}
+	if err := client.BrowserType(&model.BrowserTypeRequest{Selector: "#q", Text: "kept"}); err != nil {
+		t.Fatalf("type failed: %v", err)
+	}
+	value, err := client.BrowserEvaluate(&model.BrowserEvaluateRequest{Expression: "document.querySelector('#q').value"})
+	if err != nil {
+		t.Fatalf("evaluate failed: %v", err)
+	}
+	if value.Result != "kept" {
+		t.Errorf("expected the page state to carry over between calls, got %v", value.Result)
+	}
+
+	first, err := client.BrowserGetTitle()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tab, err := client.BrowserOpenTab(&model.BrowserOpenTabRequest{URL: "data:text/html,<title>Second</title>"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tab.Title != "Second" || !tab.Active {
+		t.Errorf("unexpected tab %+v", tab)
+	}
+	if title, _ := client.BrowserGetTitle(); title == nil || title.Title != "Second" {
+		t.Errorf("expected the new tab to be active, got %+v", title)
+	}
+
+	tabs, err := client.BrowserTabs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var firstID string
+	for _, tb := range tabs.Tabs {
+		if tb.Title == first.Title {
+			firstID = tb.ID
+		}
+		if tb.Active != (tb.ID == tab.ID) {
+			t.Errorf("expected only the new tab to be active, got %+v", tb)
+		}
+	}
+	if firstID == "" {
+		t.Fatalf("expected the first tab to be listed, got %+v", tabs.Tabs)
+	}
+
+	if err := client.BrowserActivateTab(firstID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if title, _ := client.BrowserGetTitle(); title == nil || title.Title != first.Title {
+		t.Errorf("expected the first tab to be active again, got %+v", title)
+	}
+	if err := client.BrowserCloseTab(tab.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.BrowserCloseTab(tab.ID); err == nil {
+		t.Error("expected an error closing a closed tab")
+	}
+}
+
+func TestBrowserSnapshot(t *testing.T) {
+	client := newBrowserClient(t)
+
+	page := `data:text/html,<title>Form</title><button onclick="this.textContent='Done'">Go</button>` +
+		`<input aria-label=Name><select aria-label=Size><option value=s>Small</option><option value=l>Large</option></select>`
+	if err := client.BrowserNavigate(&model.BrowserNavigateRequest{URL: page}); err != nil {
+		t.Fatalf("navigate failed: %v", err)
+	}
+	snapshot, err := client.BrowserSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	refs := map[string]string{}
+	for _, n := range snapshot.Nodes {
+		refs[n.Role+" "+n.Name] = n.Ref
+	}
+	button, input, combo := refs["button Go"], refs["textbox Name"], refs["combobox Size"]
+	if button == "" || input == "" || combo == "" {
+		t.Fatalf("expected the button, input and select in the snapshot, got\n%s", snapshot.Snapshot)
+	}
+	if !strings.Contains(snapshot.Snapshot, `button "Go" [ref=`+button+`]`) {
+		t.Errorf("unexpected rendering:\n%s", snapshot.Snapshot)
+	}
+
+	if err := client.BrowserClick(&model.BrowserClickRequest{Ref: button}); err != nil {
+		t.Fatalf("click failed: %v", err)
+	}
+	if err := client.BrowserType(&model.BrowserTypeRequest{Ref: input, Text: "Ann"}); err != nil {
+		t.Fatalf("type failed: %v", err)
+	}
+	selected, err := client.BrowserSelect(&model.BrowserSelectRequest{Ref: combo, Values: []string{"Large"}})
+	if err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if len(selected.Selected) != 1 || selected.Selected[0] != "l" {
+		t.Errorf("expected option l to be selected, got %v", selected.Selected)
+	}
+	state, err := client.BrowserEvaluate(&model.BrowserEvaluateRequest{
+		Expression: "[document.querySelector('button').textContent, document.querySelector('input').value].join()",
+	})
+	if err != nil {
+		t.Fatalf("evaluate failed: %v", err)
+	}
+	if state.Result != "Done,Ann" {
+		t.Errorf("expected the click and typing to take effect, got %v", state.Result)
+	}
+
+	if err := client.BrowserHover(&model.BrowserHoverRequest{Ref: "e9999"}); err == nil {
+		t.Error("expected an error for an unknown ref")
+	}
+	if err := client.BrowserClick(&model.BrowserClickRequest{}); err == nil {
+		t.Error("expected an error without a selector or ref")
+	}
+}
+
+func TestBrowserConsoleAndNetwork(t *testing.T) {
+	client := newBrowserClient(t)
+
+	page := `data:text/html,<title>Logs</title><script>console.warn("careful"); console.log("fine")</script>`
+	tab, err := client.BrowserOpenTab(&model.BrowserOpenTabRequest{URL: page})
+	if err != nil {
+		t.Fatalf("open tab failed: %v", err)
+	}
+	defer client.BrowserCloseTab(tab.ID)
+
+	console, err := client.BrowserConsoleMessages(&model.BrowserConsoleRequest{Level: "warning"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(console.Messages) != 1 || console.Messages[0].Text != "careful" || console.Messages[0].Level != "warning" {
+		t.Errorf("expected only the warning, got %+v", console.Messages)
+	}
+
+	network, err := client.BrowserNetworkRequests(&model.BrowserNetworkRequest{URL: "^data:"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(network.Requests) == 0 || network.Requests[0].ResourceType != "Document" {
+		t.Errorf("expected the page load to be recorded, got %+v", network.Requests)
+	}
+	har, err := client.BrowserNetworkHAR()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) == 0 {
+		t.Errorf("unexpected HAR %+v", har.Log)
+	}
+
+	if _, err := client.BrowserNetworkRequests(&model.BrowserNetworkRequest{Status: "teapot"}); err == nil {
+		t.Error("expected an error for an invalid status filter")
+	}
+}
+
+func TestBrowserMouseAndKeyboard(t *testing.T) {
+	client := newBrowserClient(t)
+
+	page := `data:text/html,<body style="margin:0"><canvas width=200 height=100></canvas><input>` +
+		`<script>window.events=[];const c=document.querySelector('canvas');` +
+		`for(const e of ['click','dblclick','mouseup'])c.addEventListener(e,ev=>events.push(e+':'+ev.offsetX+','+ev.offsetY));` +
+		`document.querySelector('input').addEventListener('keydown',e=>events.push('key:'+e.key))</script>`
+	tab, err := client.BrowserOpenTab(&model.BrowserOpenTabRequest{URL: page})
+	if err != nil {
+		t.Fatalf("open tab failed: %v", err)
+	}
+	defer client.BrowserCloseTab(tab.ID)
+
+	if err := client.BrowserMouseClick(&model.BrowserMouseClickRequest{X: 20, Y: 30, ClickCount: 2}); err != nil {
+		t.Fatalf("click failed: %v", err)
+	}
+	if err := client.BrowserMouseDrag(&model.BrowserMouseDragRequest{FromX: 10, FromY: 10, ToX: 50, ToY: 60}); err != nil {
+		t.Fatalf("drag failed: %v", err)
+	}
+	if err := client.BrowserMouseClick(&model.BrowserMouseClickRequest{X: 210, Y: 10, Button: "side"}); err == nil {
+		t.Error("expected an error for an unknown button")
+	}
+
+	// Focus the input, then replace what is inserted into it.
+	if _, err := client.BrowserEvaluate(&model.BrowserEvaluateRequest{Expression: "document.querySelector('input').focus()"}); err != nil {
+		t.Fatalf("focus failed: %v", err)
+	}
+	if err := client.BrowserInsertText(&model.BrowserInsertTextRequest{Text: "draft"}); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	for _, key := range []string{"Control+a", "Shift+k", "Enter"} {
+		if err := client.BrowserPressKey(&model.BrowserKeyPressRequest{Key: key}); err != nil {
+			t.Fatalf("press %s failed: %v", key, err)
+		}
+	}
+	if err := client.BrowserPressKey(&model.BrowserKeyPressRequest{Key: "Hyper+k"}); err == nil {
+		t.Error("expected an error for an unknown modifier")
+	}
+
+	state, err := client.BrowserEvaluate(&model.BrowserEvaluateRequest{
+		Expression: "[document.querySelector('input').value].concat(events).join(' ')",
+	})
+	if err != nil {
+		t.Fatalf("evaluate failed: %v", err)
+	}
+	want := "K mouseup:20,30 click:20,30 mouseup:20,30 click:20,30 dblclick:20,30 mouseup:50,60 click:50,60 " +
+		"key:Control key:a key:Shift key:K key:Enter"
+	if state.Result != want {
+		t.Errorf("unexpected input\ngot  %v\nwant %s", state.Result, want)
+	}
+}
+
 func TestBrowserNotInitialized(t *testing.T) {
 	workDir := t.TempDir()
 	client := NewClient(workDir)
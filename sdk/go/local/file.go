@@ -1,6 +1,9 @@
 package local
 
 import (
+	"io"
+
+	"github.com/deep-agent/sandbox/internal/services/filesystem"
 	"github.com/deep-agent/sandbox/types/model"
 )
 
@@ -41,6 +44,25 @@ func (c *Client) FileList(req *model.FileListRequest) (*model.FileListResult, er
 	}, nil
 }
 
+func (c *Client) FileTree(req *model.FileTreeRequest) (*model.FileTreeResult, error) {
+	result, err := c.fileManager.Tree(req.Path, filesystem.TreeOptions{
+		Depth:     req.Depth,
+		Ignore:    req.Ignore,
+		Gitignore: req.Gitignore,
+		Sort:      req.Sort,
+		Reverse:   req.Reverse,
+		Offset:    req.Offset,
+		Limit:     req.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if req.Format == "text" {
+		result.Text = filesystem.RenderTree(result)
+	}
+	return result, nil
+}
+
 func (c *Client) FileDelete(req *model.FileDeleteRequest) error {
 	return c.fileManager.DeleteFile(req.Path)
 }
@@ -63,3 +85,86 @@ func (c *Client) FileExists(path string) (*model.FileExistsResult, error) {
 		Exists: exists,
 	}, nil
 }
+
+func (c *Client) FileDownload(req *model.FileDownloadRequest, w io.Writer) (int64, error) {
+	f, err := c.fileManager.OpenFile(req.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(req.Offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var r io.Reader = f
+	if req.Length > 0 {
+		r = io.LimitReader(f, req.Length)
+	}
+	return io.Copy(w, r)
+}
+
+func (c *Client) FileUpload(req *model.FileUploadRequest, r io.Reader) (*model.FileUploadResult, error) {
+	result, err := c.fileManager.Upload(req.Path, r, filesystem.UploadOptions{
+		Offset:  req.Offset,
+		Partial: req.Partial,
+		SHA256:  req.SHA256,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.FileUploadResult{
+		Path:     result.Path,
+		Size:     result.Size,
+		SHA256:   result.SHA256,
+		Complete: result.Complete,
+	}, nil
+}
+
+func (c *Client) FileUploadStatus(path string) (*model.FileUploadStatus, error) {
+	offset, err := c.fileManager.UploadStatus(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.FileUploadStatus{Path: path, Offset: offset}, nil
+}
+
+func (c *Client) FileArchive(req *model.FileArchiveRequest, w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := c.fileManager.Archive(req.Path, cw, filesystem.ArchiveOptions{
+		Format:    req.Format,
+		Include:   req.Include,
+		Exclude:   req.Exclude,
+		Gitignore: req.Gitignore,
+	})
+	return cw.n, err
+}
+
+func (c *Client) FileExtract(req *model.FileExtractRequest, r io.Reader) (*model.FileExtractResult, error) {
+	result, err := c.fileManager.Extract(req.Path, r, filesystem.ExtractOptions{
+		Format:          req.Format,
+		StripComponents: req.StripComponents,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.FileExtractResult{
+		Path:  result.Path,
+		Files: result.Files,
+		Dirs:  result.Dirs,
+		Size:  result.Size,
+	}, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -2,3 +2,4 @@ package consts
 
 const HeaderSessionID = "X-Session-ID"
 const HeaderWorkspace = "X-Workspace"
+const HeaderAdminToken = "X-Admin-Token"
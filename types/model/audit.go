@@ -0,0 +1,30 @@
+package model
+
+type AuditEntry struct {
+	TimeUnixMs   int64                  `json:"time_unix_ms"`
+	Source       string                 `json:"source"`
+	SessionID    string                 `json:"session_id,omitempty"`
+	Subject      string                 `json:"subject,omitempty"`
+	Tool         string                 `json:"tool"`
+	Args         map[string]interface{} `json:"args,omitempty"`
+	Status       string                 `json:"status"`
+	Result       string                 `json:"result,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	ExitCode     *int                   `json:"exit_code,omitempty"`
+	DurationMs   int64                  `json:"duration_ms"`
+	BytesChanged int64                  `json:"bytes_changed,omitempty"`
+}
+
+// AuditQueryRequest filters the audit log. Since and Until accept RFC 3339
+// timestamps or Unix seconds.
+type AuditQueryRequest struct {
+	SessionID string `json:"session,omitempty"`
+	Tool      string `json:"tool,omitempty"`
+	Since     string `json:"since,omitempty"`
+	Until     string `json:"until,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+}
+
+type AuditQueryResult struct {
+	Entries []AuditEntry `json:"entries"`
+}
@@ -5,12 +5,136 @@ type BashExecRequest struct {
 	Cwd             string            `json:"cwd,omitempty"`
 	TimeoutMS       int               `json:"timeout_ms,omitempty"`
 	Env             map[string]string `json:"env,omitempty"`
+	Stdin           string            `json:"stdin,omitempty"`
+	StdinEncoding   string            `json:"stdin_encoding,omitempty"`
+	Limits          *BashLimits       `json:"limits,omitempty"`
 	RunInBackground bool              `json:"run_in_background,omitempty"`
 }
 
+// BashLimits tightens the server's resource limits for a single command.
+// Zero fields keep the server default; limits can never be raised.
+type BashLimits struct {
+	CPUSeconds    int   `json:"cpu_seconds,omitempty"`
+	MemoryBytes   int64 `json:"memory_bytes,omitempty"`
+	Processes     int   `json:"processes,omitempty"`
+	FileSizeBytes int64 `json:"file_size_bytes,omitempty"`
+	OutputBytes   int64 `json:"output_bytes,omitempty"`
+}
+
 type BashExecResult struct {
+	Output        string `json:"output"`
+	ExitCode      int    `json:"exit_code"`
+	Error         string `json:"error,omitempty"`
+	LimitExceeded string `json:"limit_exceeded,omitempty"`
+	OutputFile    string `json:"output_file,omitempty"`
+	JobID         string `json:"job_id,omitempty"`
+}
+
+type BashSessionInfo struct {
+	ID             string `json:"id"`
+	Cwd            string `json:"cwd"`
+	PID            int    `json:"pid"`
+	Busy           bool   `json:"busy"`
+	CreatedAtUnix  int64  `json:"created_at_unix"`
+	LastUsedAtUnix int64  `json:"last_used_at_unix"`
+}
+
+type BashSessionListResult struct {
+	Sessions []BashSessionInfo `json:"sessions"`
+}
+
+type BashJobInfo struct {
+	ID            string `json:"id"`
+	SessionID     string `json:"session_id"`
+	Command       string `json:"command"`
+	Cwd           string `json:"cwd"`
+	PID           int    `json:"pid"`
+	State         string `json:"state"`
+	ExitCode      int    `json:"exit_code"`
+	OutputFile    string `json:"output_file"`
+	StartedAtUnix int64  `json:"started_at_unix"`
+	EndedAtUnix   int64  `json:"ended_at_unix,omitempty"`
+}
+
+type BashJobListResult struct {
+	Jobs []BashJobInfo `json:"jobs"`
+}
+
+type BashJobOutputRequest struct {
+	ID     string `json:"id" vd:"len($)>0"`
+	Offset int64  `json:"offset,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+type BashJobOutputResult struct {
 	Output     string `json:"output"`
-	ExitCode   int    `json:"exit_code"`
-	Error      string `json:"error,omitempty"`
-	OutputFile string `json:"output_file,omitempty"`
+	Offset     int64  `json:"offset"`
+	NextOffset int64  `json:"next_offset"`
+	Size       int64  `json:"size"`
+	State      string `json:"state"`
+}
+
+type BashJobKillRequest struct {
+	ID     string `json:"id,omitempty"`
+	Signal string `json:"signal,omitempty"`
+}
+
+type BashSessionEnvRequest struct {
+	SessionID string            `json:"session_id,omitempty"`
+	Env       map[string]string `json:"env"`
+}
+
+type BashSessionEnvResult struct {
+	SessionID string            `json:"session_id"`
+	Env       map[string]string `json:"env"`
+}
+
+// BashPolicy lists the rules commands are checked against before they run.
+// Default is "allow" or "deny"; with "deny" only commands matched by an allow
+// rule may run.
+type BashPolicy struct {
+	Default string           `json:"default,omitempty"`
+	Rules   []BashPolicyRule `json:"rules"`
+}
+
+// BashPolicyRule matches simple commands with the regular expression Match.
+// With PipedTo the rule only matches when the command pipes into a command
+// matching PipedTo. Action is "allow", "deny" or "ask".
+type BashPolicyRule struct {
+	ID      string `json:"id,omitempty"`
+	Action  string `json:"action"`
+	Match   string `json:"match"`
+	PipedTo string `json:"piped_to,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type BashPolicyDecision struct {
+	Action  string `json:"action"`
+	Rule    string `json:"rule,omitempty"`
+	Command string `json:"command,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type BashPolicyCheckRequest struct {
+	SessionID string `json:"session_id,omitempty"`
+	Command   string `json:"command" vd:"len($)>0"`
+}
+
+type BashApproval struct {
+	ID            string             `json:"id"`
+	SessionID     string             `json:"session_id"`
+	Command       string             `json:"command"`
+	State         string             `json:"state"`
+	Decision      BashPolicyDecision `json:"decision"`
+	CreatedAtUnix int64              `json:"created_at_unix"`
+	ExpiresAtUnix int64              `json:"expires_at_unix"`
+}
+
+type BashApprovalListResult struct {
+	Approvals []BashApproval `json:"approvals"`
+}
+
+type BashApprovalResolveRequest struct {
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason,omitempty"`
 }
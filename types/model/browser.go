@@ -20,15 +20,76 @@ type BrowserScreenshotResult struct {
 	Screenshot string `json:"screenshot"`
 }
 
+// BrowserClickRequest picks the element by selector or by a ref from the
+// last snapshot, exactly one of which is required.
 type BrowserClickRequest struct {
-	Selector string `json:"selector" vd:"len($)>0"`
+	Selector string `json:"selector,omitempty"`
+	Ref      string `json:"ref,omitempty"`
 }
 
 type BrowserTypeRequest struct {
-	Selector string `json:"selector" vd:"len($)>0"`
+	Selector string `json:"selector,omitempty"`
+	Ref      string `json:"ref,omitempty"`
 	Text     string `json:"text" vd:"len($)>0"`
 }
 
+type BrowserHoverRequest struct {
+	Selector string `json:"selector,omitempty"`
+	Ref      string `json:"ref,omitempty"`
+}
+
+type BrowserSelectRequest struct {
+	Selector string   `json:"selector,omitempty"`
+	Ref      string   `json:"ref,omitempty"`
+	Values   []string `json:"values" vd:"len($)>0"`
+}
+
+type BrowserSelectResult struct {
+	Selected []string `json:"selected"`
+}
+
+// Coordinates of mouse input are CSS pixels from the top left of the
+// viewport, as in screenshots.
+type BrowserMouseMoveRequest struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// BrowserMouseClickRequest clicks the left button once unless told
+// otherwise; a ClickCount of 2 double-clicks.
+type BrowserMouseClickRequest struct {
+	X          float64  `json:"x"`
+	Y          float64  `json:"y"`
+	Button     string   `json:"button,omitempty"`
+	ClickCount int      `json:"click_count,omitempty"`
+	Modifiers  []string `json:"modifiers,omitempty"`
+}
+
+type BrowserMouseDragRequest struct {
+	FromX float64 `json:"from_x"`
+	FromY float64 `json:"from_y"`
+	ToX   float64 `json:"to_x"`
+	ToY   float64 `json:"to_y"`
+	Steps int     `json:"steps,omitempty"`
+}
+
+type BrowserMouseWheelRequest struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	DeltaX float64 `json:"delta_x"`
+	DeltaY float64 `json:"delta_y"`
+}
+
+// BrowserKeyPressRequest presses a key such as Enter or a, with optional
+// modifiers as in Control+Shift+k.
+type BrowserKeyPressRequest struct {
+	Key string `json:"key" vd:"len($)>0"`
+}
+
+type BrowserInsertTextRequest struct {
+	Text string `json:"text" vd:"len($)>0"`
+}
+
 type BrowserEvaluateRequest struct {
 	Expression string `json:"expression" vd:"len($)>0"`
 }
@@ -72,3 +133,102 @@ type BrowserPageInfo struct {
 	Width  int    `json:"width"`
 	Height int    `json:"height"`
 }
+
+type BrowserTab struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+	Active bool   `json:"active"`
+}
+
+type BrowserTabListResult struct {
+	Tabs []BrowserTab `json:"tabs"`
+}
+
+type BrowserOpenTabRequest struct {
+	URL string `json:"url,omitempty"`
+}
+
+type BrowserContextInfo struct {
+	SessionID      string `json:"session_id"`
+	ContextID      string `json:"context_id"`
+	CreatedAtUnix  int64  `json:"created_at_unix"`
+	LastUsedAtUnix int64  `json:"last_used_at_unix"`
+}
+
+type BrowserContextListResult struct {
+	Contexts []BrowserContextInfo `json:"contexts"`
+}
+
+type BrowserSnapshotNode struct {
+	Ref    string   `json:"ref,omitempty"`
+	Role   string   `json:"role"`
+	Name   string   `json:"name,omitempty"`
+	Value  string   `json:"value,omitempty"`
+	States []string `json:"states,omitempty"`
+	Depth  int      `json:"depth"`
+}
+
+type BrowserSnapshotResult struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Snapshot is Nodes rendered as an indented list.
+	Snapshot  string                `json:"snapshot"`
+	Nodes     []BrowserSnapshotNode `json:"nodes"`
+	Truncated bool                  `json:"truncated,omitempty"`
+}
+
+type BrowserConsoleMessage struct {
+	// Level is debug, info, warning or error.
+	Level string `json:"level"`
+	// Source is console, exception, or the browser component that logged
+	// the message, such as network or security.
+	Source     string `json:"source"`
+	Text       string `json:"text"`
+	URL        string `json:"url,omitempty"`
+	Line       int64  `json:"line,omitempty"`
+	TimeUnixMs int64  `json:"time_unix_ms"`
+}
+
+// BrowserConsoleRequest filters the console messages of the active tab.
+// Level is the lowest level returned.
+type BrowserConsoleRequest struct {
+	Level string `json:"level,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type BrowserConsoleResult struct {
+	Messages []BrowserConsoleMessage `json:"messages"`
+	// Dropped counts the older messages no longer kept.
+	Dropped int `json:"dropped,omitempty"`
+}
+
+type BrowserNetworkEntry struct {
+	ID              string `json:"id"`
+	Method          string `json:"method"`
+	URL             string `json:"url"`
+	ResourceType    string `json:"resource_type,omitempty"`
+	Status          int64  `json:"status,omitempty"`
+	StatusText      string `json:"status_text,omitempty"`
+	MimeType        string `json:"mime_type,omitempty"`
+	Size            int64  `json:"size,omitempty"`
+	Error           string `json:"error,omitempty"`
+	Pending         bool   `json:"pending,omitempty"`
+	StartedAtUnixMs int64  `json:"started_at_unix_ms"`
+	DurationMs      int64  `json:"duration_ms,omitempty"`
+}
+
+// BrowserNetworkRequest filters the network requests of the active tab. URL
+// is a regular expression; Status is a code such as 404, a class such as
+// 4xx, or failed for requests that got no response.
+type BrowserNetworkRequest struct {
+	URL    string `json:"url,omitempty"`
+	Status string `json:"status,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+type BrowserNetworkResult struct {
+	Requests []BrowserNetworkEntry `json:"requests"`
+	// Dropped counts the older requests no longer kept.
+	Dropped int `json:"dropped,omitempty"`
+}
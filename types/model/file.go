@@ -3,10 +3,130 @@ package model
 type FileReadRequest struct {
 	File   string `json:"file" vd:"len($)>0"`
 	Base64 bool   `json:"base64,omitempty"`
+	// Mode "auto" detects images, PDFs and notebooks and refuses other
+	// binary files; the default "raw" returns the file as it is.
+	Mode string `json:"mode,omitempty"`
+	// Pages is a PDF page number or range such as "1-5".
+	Pages string `json:"pages,omitempty"`
+	// MaxDimension downscales larger images to fit; zero keeps their size.
+	MaxDimension int `json:"max_dimension,omitempty" vd:"$>=0"`
 }
 
+// FileReadResult holds the file content. In auto mode Type tells what it
+// is: the text, base64 image data, the text of the PDF pages separated by
+// form feeds, or the rendered notebook.
 type FileReadResult struct {
-	Content string `json:"content"`
+	Content  string `json:"content"`
+	Type     string `json:"type,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+
+	Width          int `json:"width,omitempty"`
+	Height         int `json:"height,omitempty"`
+	OriginalWidth  int `json:"original_width,omitempty"`
+	OriginalHeight int `json:"original_height,omitempty"`
+
+	Pages      []PDFPage `json:"pages,omitempty"`
+	TotalPages int       `json:"total_pages,omitempty"`
+
+	Cells []NotebookCell `json:"cells,omitempty"`
+}
+
+type PDFPage struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+type NotebookCell struct {
+	Index          int              `json:"index"`
+	ID             string           `json:"id,omitempty"`
+	Type           string           `json:"type"`
+	Source         string           `json:"source"`
+	ExecutionCount int              `json:"execution_count,omitempty"`
+	Outputs        []NotebookOutput `json:"outputs,omitempty"`
+}
+
+type NotebookOutput struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	// MimeType and Data hold a base64 image for image outputs.
+	MimeType string `json:"mime_type,omitempty"`
+	Data     string `json:"data,omitempty"`
+}
+
+// FileDownloadRequest selects the bytes to download; Length zero means up to
+// the end of the file.
+type FileDownloadRequest struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset,omitempty"`
+	Length int64  `json:"length,omitempty"`
+}
+
+// FileUploadRequest describes an upload or one chunk of it. Every chunk but
+// the last sets Partial, and Offset is the number of bytes uploaded before
+// it. SHA256 is checked against the whole file when the upload completes.
+type FileUploadRequest struct {
+	Path    string `json:"path"`
+	Offset  int64  `json:"offset,omitempty"`
+	Partial bool   `json:"partial,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+type FileUploadResult struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256,omitempty"`
+	Complete bool   `json:"complete"`
+}
+
+// FileUploadStatus reports how many bytes of an unfinished upload were
+// received, which is the offset to resume it at.
+type FileUploadStatus struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+}
+
+// FileArchiveRequest selects what to pack from Path. Include and Exclude use
+// .gitignore syntax; Gitignore also skips what the directory's .gitignore
+// files ignore.
+type FileArchiveRequest struct {
+	Path      string   `json:"path" vd:"len($)>0"`
+	Format    string   `json:"format,omitempty"`
+	Include   []string `json:"include,omitempty"`
+	Exclude   []string `json:"exclude,omitempty"`
+	Gitignore bool     `json:"gitignore,omitempty"`
+}
+
+// FileExtractRequest describes where to unpack an archive. An empty Format
+// is detected from the content.
+type FileExtractRequest struct {
+	Path            string `json:"path"`
+	Format          string `json:"format,omitempty"`
+	StripComponents int    `json:"strip_components,omitempty"`
+}
+
+type FileExtractResult struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Dirs  int    `json:"dirs"`
+	Size  int64  `json:"size"`
+}
+
+// FileWatchRequest subscribes to the changes below the directory Path.
+// Include and Exclude use .gitignore syntax; changes are reported in
+// batches every DebounceMs.
+type FileWatchRequest struct {
+	Path       string   `json:"path"`
+	Include    []string `json:"include,omitempty"`
+	Exclude    []string `json:"exclude,omitempty"`
+	DebounceMs int      `json:"debounce_ms,omitempty"`
+}
+
+// FileWatchEvent is one change: Type is create, modify, delete or rename,
+// the latter reported for the old path.
+type FileWatchEvent struct {
+	Type  string `json:"type"`
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir,omitempty"`
 }
 
 type FileWriteRequest struct {
@@ -15,6 +135,61 @@ type FileWriteRequest struct {
 	Base64  bool   `json:"base64,omitempty"`
 }
 
+type FileEditRequest struct {
+	File       string `json:"file" vd:"len($)>0"`
+	OldString  string `json:"old_string" vd:"len($)>0"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+	DryRun     bool   `json:"dry_run,omitempty"`
+}
+
+type FileEditResult struct {
+	Diff string `json:"diff"`
+	// Strategy names the replacer that matched old_string; anything but
+	// "simple" means it did not match exactly.
+	Strategy     string `json:"strategy"`
+	Replacements int    `json:"replacements"`
+	DryRun       bool   `json:"dry_run"`
+}
+
+type FileEditOperation struct {
+	OldString  string `json:"old_string" vd:"len($)>0"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+}
+
+type FileMultiEditRequest struct {
+	File   string              `json:"file" vd:"len($)>0"`
+	Edits  []FileEditOperation `json:"edits" vd:"len($)>0"`
+	DryRun bool                `json:"dry_run,omitempty"`
+}
+
+type FileMultiEditResult struct {
+	Diff string `json:"diff"`
+	// Strategies names the replacer that matched each edit, in order.
+	Strategies   []string `json:"strategies"`
+	Replacements int      `json:"replacements"`
+	DryRun       bool     `json:"dry_run"`
+}
+
+type FilePatchRequest struct {
+	Patch  string `json:"patch" vd:"len($)>0"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+type PatchedFile struct {
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	Op      string `json:"op"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+type FilePatchResult struct {
+	Files  []PatchedFile `json:"files"`
+	DryRun bool          `json:"dry_run"`
+}
+
 type FileListRequest struct {
 	Path string `json:"path" vd:"len($)>0"`
 }
@@ -32,6 +207,48 @@ type FileListResult struct {
 	Files []FileInfo `json:"files"`
 }
 
+// FileTreeRequest lists Path recursively down to Depth levels, all of them
+// when zero. Ignore uses .gitignore syntax; Gitignore also skips what the
+// .gitignore files in the tree ignore. Siblings are ordered by Sort, one of
+// name, size or mtime, and the listing is paginated with Offset and Limit.
+// Format "text" also renders the page as an indented tree.
+type FileTreeRequest struct {
+	Path      string   `json:"path" vd:"len($)>0"`
+	Depth     int      `json:"depth,omitempty"`
+	Ignore    []string `json:"ignore,omitempty"`
+	Gitignore bool     `json:"gitignore,omitempty"`
+	Sort      string   `json:"sort,omitempty"`
+	Reverse   bool     `json:"reverse,omitempty"`
+	Offset    int      `json:"offset,omitempty"`
+	Limit     int      `json:"limit,omitempty"`
+	Format    string   `json:"format,omitempty"`
+}
+
+// FileTreeEntry is one entry of a tree listing, Depth levels below its root.
+// Error is set instead of the metadata when the entry could not be read.
+type FileTreeEntry struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Depth       int    `json:"depth"`
+	IsDir       bool   `json:"is_dir"`
+	Size        int64  `json:"size"`
+	Mode        string `json:"mode,omitempty"`
+	ModTimeUnix int64  `json:"mod_time_unix,omitempty"`
+	LinkTarget  string `json:"link_target,omitempty"`
+	MimeType    string `json:"mime_type,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// FileTreeResult holds one page of entries, depth first. Total counts every
+// entry in the tree and Truncated is set when there are more than the page.
+type FileTreeResult struct {
+	Path      string          `json:"path"`
+	Entries   []FileTreeEntry `json:"entries"`
+	Total     int             `json:"total"`
+	Truncated bool            `json:"truncated"`
+	Text      string          `json:"text,omitempty"`
+}
+
 type FileDeleteRequest struct {
 	Path string `json:"path" vd:"len($)>0"`
 }
@@ -53,3 +270,30 @@ type MkDirRequest struct {
 type FileExistsResult struct {
 	Exists bool `json:"exists"`
 }
+
+type FileChange struct {
+	ID       string `json:"id"`
+	Op       string `json:"op"`
+	Path     string `json:"path"`
+	Dest     string `json:"dest,omitempty"`
+	TimeUnix int64  `json:"time_unix"`
+	Undone   bool   `json:"undone"`
+	Undoable bool   `json:"undoable"`
+	// Reason explains why a change that was not undone cannot be.
+	Reason string `json:"reason,omitempty"`
+}
+
+type FileHistoryResult struct {
+	Changes []FileChange `json:"changes"`
+}
+
+type FileUndoRequest struct {
+	ID    string `json:"id,omitempty"`
+	Path  string `json:"path,omitempty"`
+	Count int    `json:"count,omitempty" vd:"$>=0"`
+	Force bool   `json:"force,omitempty"`
+}
+
+type FileUndoResult struct {
+	Undone []FileChange `json:"undone"`
+}
@@ -0,0 +1,43 @@
+package model
+
+type WorkspaceSnapshotRequest struct {
+	Label string `json:"label,omitempty"`
+	// Exclude lists paths left out of the snapshot and kept on restore.
+	// Patterns without a slash match a name at any depth.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+type WorkspaceSnapshot struct {
+	ID            string   `json:"id"`
+	Workspace     string   `json:"workspace"`
+	SessionID     string   `json:"session_id,omitempty"`
+	Label         string   `json:"label,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	CreatedAtUnix int64    `json:"created_at_unix"`
+	Files         int      `json:"files"`
+	Size          int64    `json:"size"`
+	StoredSize    int64    `json:"stored_size"`
+}
+
+type WorkspaceSnapshotListResult struct {
+	Snapshots []WorkspaceSnapshot `json:"snapshots"`
+}
+
+// WorkspaceSnapshotDiffRequest compares snapshot From with snapshot To, or
+// with the current workspace when To is empty.
+type WorkspaceSnapshotDiffRequest struct {
+	From string `json:"from" vd:"len($)>0"`
+	To   string `json:"to,omitempty"`
+}
+
+type WorkspaceChange struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	Type    string `json:"type"`
+	OldSize int64  `json:"old_size,omitempty"`
+	NewSize int64  `json:"new_size,omitempty"`
+}
+
+type WorkspaceSnapshotDiffResult struct {
+	Changes []WorkspaceChange `json:"changes"`
+}